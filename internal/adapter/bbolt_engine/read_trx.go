@@ -18,6 +18,15 @@ func NewReadTransaction(tx *bbolt.Tx) *ReadTransaction {
 	}
 }
 
+func (tx *ReadTransaction) Buckets() [][]byte {
+	var names [][]byte
+	_ = tx.tx.ForEach(func(name []byte, _ *bbolt.Bucket) error {
+		names = append(names, append([]byte(nil), name...))
+		return nil
+	})
+	return names
+}
+
 func (tx *ReadTransaction) BucketStats(bucket []byte) *model.IndexStats {
 	b := tx.tx.Bucket(bucket)
 	if b == nil {
@@ -169,6 +169,62 @@ func (i *MangoIndex) LookupEq(ctx context.Context, tx port.EngineReadTransaction
 	return ids, nil
 }
 
+// SampleCardinality estimates the number of distinct field-value tuples in
+// the index by inspecting at most sampleSize keys from the start of the
+// main bucket. It returns the total number of keys and the estimated number
+// of distinct tuples. The estimate is exact if the index holds no more than
+// sampleSize keys.
+func (i *MangoIndex) SampleCardinality(_ context.Context, tx port.EngineReadTransaction, sampleSize int) (keys, distinct uint64) {
+	i.mu.RLock()
+	n := len(i.fields)
+	i.mu.RUnlock()
+
+	keys = tx.BucketStats(i.bucketName).Keys
+	if keys == 0 || n == 0 {
+		return keys, 0
+	}
+
+	seen := make(map[string]struct{})
+	var sampled uint64
+	c := tx.Cursor(i.bucketName)
+	for k, _ := c.First(); k != nil && sampled < uint64(sampleSize); k, _ = c.Next() {
+		sampled++
+		if p := mangoKeyPrefixLen(k, n); p > 0 {
+			seen[string(k[:p])] = struct{}{}
+		}
+	}
+	if sampled == 0 {
+		return keys, 0
+	}
+
+	distinct = uint64(len(seen))
+	if sampled < keys {
+		// extrapolate linearly, the sample is taken in key order which
+		// clusters equal tuples, so this tends to underestimate selectivity
+		distinct = distinct * keys / sampled
+	}
+	if distinct == 0 {
+		distinct = 1
+	}
+	return keys, distinct
+}
+
+// mangoKeyPrefixLen returns the length of the field-value portion of a main
+// bucket key with n fields, or 0 if the key is malformed.
+func mangoKeyPrefixLen(key []byte, n int) int {
+	p := 0
+	for f := 0; f < n; f++ {
+		if len(key) < p+2 {
+			return 0
+		}
+		p += 2 + int(binary.BigEndian.Uint16(key[p:p+2]))
+		if p > len(key) {
+			return 0
+		}
+	}
+	return p
+}
+
 // buildMangoKey encodes all index fields from doc into the main bucket key format.
 func buildMangoKey(fields []string, doc *model.Document) ([]byte, error) {
 	values := make([]interface{}, len(fields))
@@ -16,9 +16,14 @@ import (
 type RegularIndexFunc func(ctx context.Context, doc *model.Document) ([][]byte, [][]byte)
 
 var _ port.DocumentIndex = (*RegularIndex)(nil)
+var _ port.KeyChangeTracker = (*RegularIndex)(nil)
 
 var indexInvalidationBucketSuffix = []byte(":invalidation")
 
+// indexChangedKeysBucketSuffix is the bucket of the changed keys
+// if the index tracks key changes
+var indexChangedKeysBucketSuffix = []byte(":changed")
+
 // RegularIndex is able to store the same value multiple times,
 // does so by adding the document id (which is unique) to the end
 // of the document key.
@@ -27,8 +32,10 @@ type RegularIndex struct {
 	idxFn    RegularIndexFunc
 	mu       sync.RWMutex
 	cleanKey func([]byte) interface{}
+	// trackChanges records the changed keys in the changed keys bucket
+	trackChanges bool
 
-	bucketName, indexInvalidationBucket []byte
+	bucketName, indexInvalidationBucket, changedKeysBucket []byte
 }
 
 func NewRegularIndex(ddfn *model.DesignDocFn, idxFn RegularIndexFunc) *RegularIndex {
@@ -37,6 +44,7 @@ func NewRegularIndex(ddfn *model.DesignDocFn, idxFn RegularIndexFunc) *RegularIn
 		idxFn:                   idxFn,
 		bucketName:              ddfn.Bucket(),
 		indexInvalidationBucket: append(ddfn.Bucket(), indexInvalidationBucketSuffix...),
+		changedKeysBucket:       append(ddfn.Bucket(), indexChangedKeysBucketSuffix...),
 	}
 	return ri
 }
@@ -61,6 +69,9 @@ func (i *RegularIndex) Remove(ctx context.Context, tx port.EngineWriteTransactio
 	defer i.mu.Unlock()
 	tx.DeleteBucket(i.bucketName)
 	tx.DeleteBucket(i.indexInvalidationBucket)
+	// the changed keys bucket only exists if changes were tracked
+	tx.EnsureBucket(i.changedKeysBucket)
+	tx.DeleteBucket(i.changedKeysBucket)
 	return nil
 }
 
@@ -114,6 +125,7 @@ func (i *RegularIndex) UpdateStored(ctx context.Context, tx port.EngineWriteTran
 
 			// store information about the key
 			tx.PutWithReusedSequence(i.indexInvalidationBucket, []byte(doc.ID), key, keyWithSeqInv)
+			i.keyChanged(tx, key)
 		}
 	}
 
@@ -155,11 +167,56 @@ func (i *RegularIndex) RemoveOldKeys(tx port.EngineWriteTransaction, doc *model.
 		// and mark invalidation key for later deletion
 		tx.Delete(i.bucketName, v)
 		tx.Delete(i.indexInvalidationBucket, k)
+		i.keyChanged(tx, v[:keyLen(v)])
 	}
 
 	return nil
 }
 
+// TrackKeyChanges enables the recording of the changed keys
+func (i *RegularIndex) TrackKeyChanges(enabled bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.trackChanges = enabled
+}
+
+// keyChanged records the changed key if enabled, every change
+// is a new record so that changes aren't lost while processed
+func (i *RegularIndex) keyChanged(tx port.EngineWriteTransaction, key []byte) {
+	if !i.trackChanges {
+		return
+	}
+	tx.EnsureBucket(i.changedKeysBucket)
+	tx.PutWithSequence(i.changedKeysBucket, key, nil, keyWithSeq)
+}
+
+// ChangedKeys returns the distinct changed keys and their records
+func (i *RegularIndex) ChangedKeys(tx port.EngineReadTransaction) (keys, records [][]byte) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	c := tx.Cursor(i.changedKeysBucket)
+	for k, _ := c.First(); k != nil; k, _ = c.Next() {
+		record := append([]byte{}, k...)
+		records = append(records, record)
+		if key := record[:keyLen(record)]; !seen[string(key)] {
+			keys = append(keys, key)
+			seen[string(key)] = true
+		}
+	}
+	return keys, records
+}
+
+// ClearChangedKeys removes the processed records
+func (i *RegularIndex) ClearChangedKeys(tx port.EngineWriteTransaction, records [][]byte) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for _, record := range records {
+		tx.Delete(i.changedKeysBucket, record)
+	}
+}
+
 func (i *RegularIndex) IteratorOptions(ctx context.Context) (*model.IteratorOptions, error) {
 	i.mu.RLock()
 	defer i.mu.RUnlock()
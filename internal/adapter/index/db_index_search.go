@@ -30,16 +30,17 @@ var _ port.DocumentIndexSourceUpdate = (*ExternalSearchIndex)(nil)
 type ExternalSearchIndex struct {
 	path     string
 	ddfn     *model.DesignDocFn
-	engines  port.ViewEngines
+	engines  map[string]port.DesignDocBuilder[port.ViewServer]
 	server   port.ViewServer
 	mapping  *mapping.IndexMappingImpl
 	idx      bleve.Index
 	mu       sync.RWMutex
 	SearchFn string
+	lib      string
 	logger   port.Logger
 }
 
-func NewExternalSearchIndex(ddfn *model.DesignDocFn, engines port.ViewEngines, path string, logger port.Logger) *ExternalSearchIndex {
+func NewExternalSearchIndex(ddfn *model.DesignDocFn, engines map[string]port.DesignDocBuilder[port.ViewServer], path string, logger port.Logger) *ExternalSearchIndex {
 	return &ExternalSearchIndex{
 		path:    path,
 		ddfn:    ddfn,
@@ -62,6 +63,7 @@ func (i *ExternalSearchIndex) String() string {
 
 func (i *ExternalSearchIndex) UpdateSource(ctx context.Context, doc *model.Document, f *model.Function) error {
 	searchFn := f.SearchFn
+	lib := viewLib(doc)
 	language := doc.Language()
 
 	if searchFn == "" {
@@ -69,7 +71,7 @@ func (i *ExternalSearchIndex) UpdateSource(ctx context.Context, doc *model.Docum
 	}
 
 	// if the mapFn is the same, to nothing
-	if i.SearchFn == searchFn {
+	if i.SearchFn == searchFn && i.lib == lib {
 		return nil
 	}
 
@@ -78,7 +80,7 @@ func (i *ExternalSearchIndex) UpdateSource(ctx context.Context, doc *model.Docum
 	if !ok {
 		return fmt.Errorf("search engine for language %q is not registered", language)
 	}
-	vs, err := builder(searchFn)
+	vs, err := builder(searchFn, doc)
 	if err != nil {
 		return fmt.Errorf("failed to compile search: %w", err)
 	}
@@ -86,6 +88,7 @@ func (i *ExternalSearchIndex) UpdateSource(ctx context.Context, doc *model.Docum
 	// view was successfully created, update mapFn and viewServer
 	i.mu.Lock()
 	i.SearchFn = searchFn
+	i.lib = lib
 	i.server = vs
 	i.mu.Unlock()
 
@@ -139,6 +142,16 @@ func (i *ExternalSearchIndex) Ensure(ctx context.Context, tx port.EngineWriteTra
 	return nil
 }
 
+// Close closes the search index, e.g. if its database is unloaded
+func (i *ExternalSearchIndex) Close() error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.idx == nil {
+		return nil
+	}
+	return i.idx.Close()
+}
+
 func (i *ExternalSearchIndex) Remove(ctx context.Context, tx port.EngineWriteTransaction) error {
 	err := i.idx.Close()
 	if err != nil {
@@ -2,6 +2,7 @@ package index
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"sync"
@@ -18,13 +19,14 @@ var _ port.DocumentIndexSourceUpdate = (*ViewIndex)(nil)
 type ViewIndex struct {
 	*RegularIndex
 	MapFn   string
-	engines port.ViewEngines
+	lib     string
+	engines map[string]port.DesignDocBuilder[port.ViewServer]
 	server  port.ViewServer
 	mu      sync.RWMutex
 	logger  port.Logger
 }
 
-func NewViewIndex(ddfn *model.DesignDocFn, engines port.ViewEngines, logger port.Logger) *ViewIndex {
+func NewViewIndex(ddfn *model.DesignDocFn, engines map[string]port.DesignDocBuilder[port.ViewServer], logger port.Logger) *ViewIndex {
 	vi := &ViewIndex{
 		engines: engines,
 		logger:  logger,
@@ -60,7 +62,8 @@ func (i *ViewIndex) indexSingleDocument(ctx context.Context, doc *model.Document
 	// execute document against view server
 	docs, err := vs.ExecuteView(ctx, []*model.Document{doc})
 	if err != nil {
-		i.logger.Errorf(ctx, "view execution failed", "error", err)
+		// like CouchDB the document is skipped, the index stays usable
+		i.logger.Errorf(ctx, "view execution failed", "view", i.ddfn.String(), "doc", doc.ID, "timeout", errors.Is(err, port.ErrTimeout), "error", err)
 		return nil, nil
 	}
 
@@ -87,14 +90,15 @@ func (i *ViewIndex) indexSingleDocument(ctx context.Context, doc *model.Document
 // rebuilding the whole index
 func (i *ViewIndex) UpdateSource(ctx context.Context, doc *model.Document, vf *model.Function) error {
 	mapFn := vf.MapFn
+	lib := viewLib(doc)
 	language := doc.Language()
 
 	if mapFn == "" {
 		return errors.New("invalid empty view function")
 	}
 
-	// if the mapFn is the same, to nothing
-	if i.MapFn == mapFn {
+	// if the mapFn and its modules are the same, to nothing
+	if i.MapFn == mapFn && i.lib == lib {
 		return nil
 	}
 
@@ -103,7 +107,7 @@ func (i *ViewIndex) UpdateSource(ctx context.Context, doc *model.Document, vf *m
 	if !ok {
 		return fmt.Errorf("view engine for language %q is not registered", language)
 	}
-	vs, err := builder(mapFn)
+	vs, err := builder(mapFn, doc)
 	if err != nil {
 		return fmt.Errorf("failed to compile view: %w", err)
 	}
@@ -111,12 +115,25 @@ func (i *ViewIndex) UpdateSource(ctx context.Context, doc *model.Document, vf *m
 	// view was successfully created, update mapFn and viewServer
 	i.mu.Lock()
 	i.MapFn = mapFn
+	i.lib = lib
 	i.server = vs
 	i.mu.Unlock()
 
 	return nil
 }
 
+// viewLib returns the encoded views.lib of the design document,
+// modules required by view functions are resolved against it.
+func viewLib(doc *model.Document) string {
+	views, _ := doc.Data["views"].(map[string]interface{})
+	lib, ok := views[model.ViewLib]
+	if !ok {
+		return ""
+	}
+	data, _ := json.Marshal(lib)
+	return string(data)
+}
+
 func (i *ViewIndex) SourceType() model.FnType {
 	return model.ViewFn
 }
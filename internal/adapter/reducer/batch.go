@@ -0,0 +1,99 @@
+package reducer
+
+import (
+	"github.com/goydb/goydb/pkg/model"
+	"github.com/goydb/goydb/pkg/port"
+)
+
+// BatchSize is the maximum number of values reduced in one call
+const BatchSize = 1000
+
+var _ port.ReducerError = (*Batch)(nil)
+
+// Key is a key of the first reduce pass with the id of the
+// document that emitted it
+type Key struct {
+	Key interface{}
+	ID  string
+}
+
+// BatchFunc reduces the values, keys are nil if rereduce is set
+type BatchFunc func(keys []Key, values []interface{}, rereduce bool) (interface{}, error)
+
+// Batch reduces the values of consecutive equal keys in batches using
+// a custom reduce function, keys reduced in several batches are
+// rereduced. Used by the engines that don't implement a reducer.
+type Batch struct {
+	fn      BatchFunc
+	keys    []Key
+	group   string // of the collected keys, see model.ViewKeyString
+	values  []interface{}
+	reduced []*model.Document
+	err     error // first error of the reduce function
+}
+
+func NewBatch(fn BatchFunc) *Batch {
+	return &Batch{fn: fn}
+}
+
+func (r *Batch) Reduce(doc *model.Document) {
+	group := model.ViewKeyString(doc.Key)
+	tooManyElements := len(r.keys) >= BatchSize
+	keyChange := len(r.keys) > 0 && r.group != group
+	if tooManyElements || keyChange {
+		r.flush()
+	}
+	r.group = group
+	r.keys = append(r.keys, Key{Key: doc.Key, ID: doc.ID})
+	r.values = append(r.values, doc.Value)
+}
+
+// flush reduces the collected values of a key
+func (r *Batch) flush() {
+	if len(r.keys) == 0 {
+		return
+	}
+	value := r.call(r.keys, r.values, false)
+	r.reduced = append(r.reduced, &model.Document{Key: r.keys[0].Key, Value: value})
+	r.keys, r.values = nil, nil
+}
+
+// call executes the reduce function, once failed further calls are skipped
+func (r *Batch) call(keys []Key, values []interface{}, rereduce bool) interface{} {
+	if r.err != nil {
+		return nil
+	}
+	value, err := r.fn(keys, values, rereduce)
+	r.err = err
+	return value
+}
+
+func (r *Batch) Result() map[interface{}]interface{} {
+	r.flush()
+
+	// the reduced values of a key are consecutive
+	result := make(map[interface{}]interface{})
+	for i := 0; i < len(r.reduced); {
+		j := i + 1
+		canonical := model.ViewKeyString(r.reduced[i].Key)
+		for j < len(r.reduced) && model.ViewKeyString(r.reduced[j].Key) == canonical {
+			j++
+		}
+		value := r.reduced[i].Value
+		if j-i > 1 {
+			values := make([]interface{}, 0, j-i)
+			for _, doc := range r.reduced[i:j] {
+				values = append(values, doc.Value)
+			}
+			value = r.call(nil, values, true)
+		}
+		result[len(result)] = &model.Document{Key: r.reduced[i].Key, Value: value}
+		i = j
+	}
+	return result
+}
+
+// Err returns the first error of the reduce function
+func (r *Batch) Err() error {
+	return r.err
+}
@@ -0,0 +1,31 @@
+package reducer
+
+import (
+	"testing"
+
+	"github.com/goydb/goydb/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatch_Groups(t *testing.T) {
+	rereduced := 0
+	r := NewBatch(func(keys []Key, values []interface{}, rereduce bool) (interface{}, error) {
+		if rereduce {
+			rereduced++
+		}
+		return len(values), nil
+	})
+
+	// keys that are equal in the view collation are one group, even
+	// if the types of their values differ, e.g. after decoding
+	r.Reduce(&model.Document{Key: []interface{}{"a", 1}, Value: 1.0})
+	r.Reduce(&model.Document{Key: []interface{}{"a", 1.0}, Value: 1.0})
+	r.Reduce(&model.Document{Key: "b", Value: 1.0})
+	result := r.Result()
+	require.NoError(t, r.Err())
+	require.Len(t, result, 2)
+	assert.Equal(t, 2, result[0].(*model.Document).Value)
+	assert.Equal(t, 1, result[1].(*model.Document).Value)
+	assert.Equal(t, 0, rereduced)
+}
@@ -0,0 +1,61 @@
+package reducer
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/goydb/goydb/pkg/model"
+)
+
+// defaultDistinctLimit is the number of values kept by _distinct without parameter
+const defaultDistinctLimit = 100
+
+// distinct keeps the distinct values, at most limit values. If there
+// are more distinct values, the lowest values using view collation
+// are kept.
+type distinct struct {
+	limit  int
+	values map[string]interface{}
+}
+
+func (a *distinct) add(value interface{}) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return
+	}
+	if a.values == nil {
+		a.values = make(map[string]interface{})
+	}
+	a.values[string(data)] = value
+	if len(a.values) > a.limit {
+		var maxKey string
+		var maxValue interface{}
+		for k, v := range a.values {
+			if maxKey == "" || model.ViewKeyCmp(v, maxValue) > 0 {
+				maxKey, maxValue = k, v
+			}
+		}
+		delete(a.values, maxKey)
+	}
+}
+
+func (a *distinct) sorted() []interface{} {
+	values := make([]interface{}, 0, len(a.values))
+	for _, v := range a.values {
+		values = append(values, v)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		return model.ViewKeyCmp(values[i], values[j]) < 0
+	})
+	return values
+}
+
+func (a *distinct) merge(other accumulator) {
+	for _, v := range other.(*distinct).values {
+		a.add(v)
+	}
+}
+
+func (a *distinct) result() interface{} {
+	return a.sorted()
+}
@@ -0,0 +1,41 @@
+package reducer
+
+import "github.com/goydb/goydb/pkg/model"
+
+// firstLast keeps the value of the row with the lowest or highest
+// emitted key using view collation, equal keys are ordered by ID
+type firstLast struct {
+	last bool
+	set  bool
+	row  KeyValue
+}
+
+func (a *firstLast) add(value interface{}) {
+	row, ok := value.(KeyValue)
+	if !ok {
+		row = KeyValue{Value: value}
+	}
+	cmp := model.ViewKeyCmp(row.Key, a.row.Key)
+	if cmp == 0 {
+		switch {
+		case row.ID < a.row.ID:
+			cmp = -1
+		case row.ID > a.row.ID:
+			cmp = 1
+		}
+	}
+	if !a.set || (a.last && cmp > 0) || (!a.last && cmp < 0) {
+		a.row = row
+		a.set = true
+	}
+}
+
+func (a *firstLast) merge(other accumulator) {
+	if o := other.(*firstLast); o.set {
+		a.add(o.row)
+	}
+}
+
+func (a *firstLast) result() interface{} {
+	return a.row.Value
+}
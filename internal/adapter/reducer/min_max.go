@@ -0,0 +1,28 @@
+package reducer
+
+import "github.com/goydb/goydb/pkg/model"
+
+// minMax keeps the minimum or maximum value using view collation
+type minMax struct {
+	max   bool
+	set   bool
+	value interface{}
+}
+
+func (a *minMax) add(value interface{}) {
+	cmp := model.ViewKeyCmp(value, a.value)
+	if !a.set || (a.max && cmp > 0) || (!a.max && cmp < 0) {
+		a.value = value
+		a.set = true
+	}
+}
+
+func (a *minMax) merge(other accumulator) {
+	if o := other.(*minMax); o.set {
+		a.add(o.value)
+	}
+}
+
+func (a *minMax) result() interface{} {
+	return a.value
+}
@@ -0,0 +1,143 @@
+package reducer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/goydb/goydb/pkg/model"
+)
+
+// accumulator is the state of a native reducer for one group
+type accumulator interface {
+	add(value interface{})
+	// merge combines the state of another accumulator
+	// of the same function (rereduce)
+	merge(other accumulator)
+	result() interface{}
+}
+
+// KeyValue is the value passed to the reducers that need the
+// emitted key and the document of the row, see OriginalKeys
+type KeyValue struct {
+	Key   interface{}
+	ID    string
+	Value interface{}
+}
+
+// Native implements the goydb extension reducers, e.g. _min or _top_n.
+// Every group has an accumulator, the accumulators of reducers that
+// processed different rows can be combined.
+type Native struct {
+	newAcc       func() accumulator
+	originalKeys bool
+	keys         []interface{}
+	accs         []accumulator
+	// groups is the index of the group of the key, see model.ViewKeyString
+	groups map[string]int
+}
+
+// NewNative returns the native reducer of the reduce function, false
+// if the function isn't a native reducer. Parameters follow the name
+// separated by a colon, e.g. "_top_n:5" or "_percentiles:50,90,99".
+func NewNative(fn string) (*Native, bool, error) {
+	name, param, hasParam := strings.Cut(fn, ":")
+	r := &Native{groups: make(map[string]int)}
+	switch name {
+	case "_min":
+		r.newAcc = func() accumulator { return &minMax{max: false} }
+	case "_max":
+		r.newAcc = func() accumulator { return &minMax{max: true} }
+	case "_first":
+		r.newAcc = func() accumulator { return &firstLast{last: false} }
+		r.originalKeys = true
+	case "_last":
+		r.newAcc = func() accumulator { return &firstLast{last: true} }
+		r.originalKeys = true
+	case "_top_n":
+		n, err := intParam(fn, param, hasParam, defaultTopN)
+		if err != nil {
+			return nil, true, err
+		}
+		r.newAcc = func() accumulator { return &topN{n: n} }
+	case "_distinct":
+		n, err := intParam(fn, param, hasParam, defaultDistinctLimit)
+		if err != nil {
+			return nil, true, err
+		}
+		r.newAcc = func() accumulator { return &distinct{limit: n} }
+	case "_percentiles":
+		percentiles := defaultPercentiles
+		if hasParam {
+			percentiles = nil
+			for _, s := range strings.Split(param, ",") {
+				p, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+				if err != nil || p < 0 || p > 100 {
+					return nil, true, fmt.Errorf("invalid reduce function %q: percentiles have to be in the range 0-100", fn)
+				}
+				percentiles = append(percentiles, p)
+			}
+		}
+		r.newAcc = func() accumulator { return &digest{percentiles: percentiles} }
+	default:
+		return nil, false, nil
+	}
+
+	if hasParam && (name == "_min" || name == "_max" || name == "_first" || name == "_last") {
+		return nil, true, fmt.Errorf("invalid reduce function %q: no parameter expected", fn)
+	}
+
+	return r, true, nil
+}
+
+// intParam parses the positive integer parameter of the reduce function
+func intParam(fn, param string, hasParam bool, def int) (int, error) {
+	if !hasParam {
+		return def, nil
+	}
+	n, err := strconv.Atoi(param)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid reduce function %q: expected a positive number", fn)
+	}
+	return n, nil
+}
+
+// OriginalKeys returns true if the reducer needs the emitted key and
+// document ID of the rows, the values are passed as KeyValue
+func (r *Native) OriginalKeys() bool {
+	return r.originalKeys
+}
+
+// group returns the accumulator of the key, the group is
+// created if the key is seen first
+func (r *Native) group(key interface{}) accumulator {
+	canonical := model.ViewKeyString(key)
+	idx, ok := r.groups[canonical]
+	if !ok {
+		r.keys = append(r.keys, key)
+		r.accs = append(r.accs, r.newAcc())
+		idx = len(r.keys) - 1
+		r.groups[canonical] = idx
+	}
+	return r.accs[idx]
+}
+
+func (r *Native) Reduce(doc *model.Document) {
+	r.group(doc.Key).add(doc.Value)
+}
+
+// Combine merges the groups of the other reducer of the same
+// function into the groups of the reducer (rereduce)
+func (r *Native) Combine(other *Native) {
+	for i, k := range other.keys {
+		r.group(k).merge(other.accs[i])
+	}
+}
+
+func (r *Native) Result() map[interface{}]interface{} {
+	out := make(map[interface{}]interface{}, len(r.keys))
+	for i, k := range r.keys {
+		out[i] = &model.Document{Key: k, Value: r.accs[i].result()}
+	}
+	return out
+}
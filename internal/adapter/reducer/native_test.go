@@ -0,0 +1,229 @@
+package reducer
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/goydb/goydb/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func nativeResult(t *testing.T, r *Native) map[interface{}]interface{} {
+	t.Helper()
+	out := make(map[interface{}]interface{})
+	for _, v := range r.Result() {
+		doc := v.(*model.Document)
+		out[doc.Key] = doc.Value
+	}
+	return out
+}
+
+func newNative(t *testing.T, fn string) *Native {
+	t.Helper()
+	r, ok, err := NewNative(fn)
+	require.NoError(t, err)
+	require.True(t, ok)
+	return r
+}
+
+func TestNewNative(t *testing.T) {
+	_, ok, err := NewNative("_sum")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	_, ok, err = NewNative("function(keys, values) {}")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	for _, fn := range []string{"_top_n:0", "_top_n:x", "_distinct:-1", "_percentiles:101", "_percentiles:a", "_min:1"} {
+		_, ok, err := NewNative(fn)
+		assert.True(t, ok, fn)
+		assert.Error(t, err, fn)
+	}
+}
+
+func TestNative_MinMax(t *testing.T) {
+	min, max := newNative(t, "_min"), newNative(t, "_max")
+	for _, v := range []interface{}{3.0, "b", -1.0, nil, []interface{}{1.0}} {
+		min.Reduce(&model.Document{Key: "k", Value: v})
+		max.Reduce(&model.Document{Key: "k", Value: v})
+	}
+	min.Reduce(&model.Document{Key: "n", Value: 5.0})
+	assert.Equal(t, map[interface{}]interface{}{"k": nil, "n": 5.0}, nativeResult(t, min))
+	assert.Equal(t, []interface{}{1.0}, nativeResult(t, max)["k"])
+}
+
+func TestNative_FirstLast(t *testing.T) {
+	first, last := newNative(t, "_first"), newNative(t, "_last")
+	assert.True(t, first.OriginalKeys())
+	rows := []KeyValue{
+		{Key: []interface{}{"a", 2.0}, ID: "x", Value: "a2"},
+		{Key: []interface{}{"a", 10.0}, ID: "y", Value: "a10"},
+		{Key: []interface{}{"a", 1.0}, ID: "z", Value: "a1"},
+		{Key: []interface{}{"a", 1.0}, ID: "b", Value: "a1b"},
+	}
+	for _, row := range rows {
+		first.Reduce(&model.Document{Key: "a", Value: row})
+		last.Reduce(&model.Document{Key: "a", Value: row})
+	}
+	assert.Equal(t, "a1b", nativeResult(t, first)["a"])
+	assert.Equal(t, "a10", nativeResult(t, last)["a"])
+}
+
+func TestNative_TopN(t *testing.T) {
+	r := newNative(t, "_top_n:3")
+	for _, v := range []float64{5, 1, 9, 3, 9, 7} {
+		r.Reduce(&model.Document{Key: nil, Value: v})
+	}
+	assert.Equal(t, []interface{}{9.0, 9.0, 7.0}, nativeResult(t, r)[nil])
+
+	r = newNative(t, "_top_n")
+	for i := 0; i < 20; i++ {
+		r.Reduce(&model.Document{Key: nil, Value: float64(i)})
+	}
+	assert.Len(t, nativeResult(t, r)[nil], defaultTopN)
+}
+
+func TestNative_Distinct(t *testing.T) {
+	r := newNative(t, "_distinct")
+	for _, v := range []interface{}{"b", "a", "b", 1.0, map[string]interface{}{"x": 1.0}, 1.0} {
+		r.Reduce(&model.Document{Key: nil, Value: v})
+	}
+	assert.Equal(t, []interface{}{1.0, "a", "b", map[string]interface{}{"x": 1.0}}, nativeResult(t, r)[nil])
+
+	// the lowest values are kept
+	r = newNative(t, "_distinct:3")
+	for _, v := range []string{"e", "d", "c", "b", "a", "c"} {
+		r.Reduce(&model.Document{Key: nil, Value: v})
+	}
+	assert.Equal(t, []interface{}{"a", "b", "c"}, nativeResult(t, r)[nil])
+}
+
+func TestNative_Percentiles(t *testing.T) {
+	r := newNative(t, "_percentiles:0,50,100")
+	for _, v := range []interface{}{1.0, 2.0, 3.0, 4.0, 5.0, "ignored"} {
+		r.Reduce(&model.Document{Key: nil, Value: v})
+	}
+	assert.Equal(t, map[string]interface{}{"0": 1.0, "50": 3.0, "100": 5.0}, nativeResult(t, r)[nil])
+
+	// approximation of a large uniform distribution
+	r = newNative(t, "_percentiles")
+	for i := 0; i < 100000; i++ {
+		r.Reduce(&model.Document{Key: nil, Value: float64(i % 1000)})
+	}
+	p := nativeResult(t, r)[nil].(map[string]interface{})
+	assert.InDelta(t, 500, p["50"], 10)
+	assert.InDelta(t, 900, p["90"], 10)
+	assert.InDelta(t, 990, p["99"], 5)
+
+	empty := newNative(t, "_percentiles")
+	empty.Reduce(&model.Document{Key: nil, Value: "x"})
+	assert.Nil(t, nativeResult(t, empty)[nil])
+}
+
+func TestNative_Groups(t *testing.T) {
+	for _, fn := range []string{"_min", "_max", "_top_n:5", "_distinct:5", "_percentiles:10,50,90", "_first", "_last"} {
+		t.Run(fn, func(t *testing.T) {
+			// the rows of the groups are interleaved, e.g. group_level
+			all := newNative(t, fn)
+			groups := map[string]*Native{}
+			keys := []interface{}{"g", []interface{}{"g", 1.0}, nil}
+			for i := 0; i < 2000; i++ {
+				key := keys[i%len(keys)]
+				var value interface{} = float64((i * 7919) % 101)
+				if all.OriginalKeys() {
+					value = KeyValue{Key: float64(i), ID: "doc", Value: value}
+				}
+				all.Reduce(&model.Document{Key: key, Value: value})
+				name := fmt.Sprint(key)
+				if groups[name] == nil {
+					groups[name] = newNative(t, fn)
+				}
+				groups[name].Reduce(&model.Document{Key: name, Value: value})
+			}
+
+			results := all.Result()
+			require.Len(t, results, len(keys))
+			for i, key := range keys {
+				doc := results[i].(*model.Document)
+				assert.Equal(t, key, doc.Key)
+				assert.Equal(t, nativeResult(t, groups[fmt.Sprint(key)])[fmt.Sprint(key)], doc.Value, key)
+			}
+		})
+	}
+}
+
+func TestNative_Combine(t *testing.T) {
+	for _, fn := range []string{"_min", "_max", "_top_n:5", "_distinct:5", "_percentiles:10,50,90", "_first", "_last"} {
+		t.Run(fn, func(t *testing.T) {
+			all, a, b := newNative(t, fn), newNative(t, fn), newNative(t, fn)
+			for i := 0; i < 2000; i++ {
+				key := []interface{}{"g", float64(i % 3)}
+				var value interface{} = float64((i * 7919) % 101)
+				if all.OriginalKeys() {
+					value = KeyValue{Key: float64(i), ID: "doc", Value: value}
+				}
+				doc := &model.Document{Key: key, Value: value}
+				all.Reduce(doc)
+				if i%2 == 0 {
+					a.Reduce(doc)
+				} else {
+					b.Reduce(doc)
+				}
+			}
+			// the partial results combined equal the result of all rows
+			a.Combine(b)
+			combined := make(map[string]interface{})
+			for _, v := range a.Result() {
+				doc := v.(*model.Document)
+				combined[model.ViewKeyString(doc.Key)] = doc.Value
+			}
+			require.Len(t, combined, 3)
+			for _, v := range all.Result() {
+				doc := v.(*model.Document)
+				got := combined[model.ViewKeyString(doc.Key)]
+				if p, ok := doc.Value.(map[string]interface{}); ok {
+					for k, v := range p {
+						assert.InDelta(t, v, got.(map[string]interface{})[k], 2, k)
+					}
+					continue
+				}
+				assert.Equal(t, doc.Value, got, doc.Key)
+			}
+		})
+	}
+}
+
+func TestNative_CombineTopN(t *testing.T) {
+	a, b := newNative(t, "_top_n:3"), newNative(t, "_top_n:3")
+	for _, v := range []float64{5, 1, 9} {
+		a.Reduce(&model.Document{Key: "k", Value: v})
+	}
+	for _, v := range []float64{9, 7, 3} {
+		b.Reduce(&model.Document{Key: "k", Value: v})
+	}
+	b.Reduce(&model.Document{Key: "other", Value: 1.0})
+
+	// the union of both lists, groups only seen by b are added
+	a.Combine(b)
+	result := nativeResult(t, a)
+	assert.Equal(t, []interface{}{9.0, 9.0, 7.0}, result["k"])
+	assert.Equal(t, []interface{}{1.0}, result["other"])
+}
+
+func TestNative_CombinePercentiles(t *testing.T) {
+	a, b := newNative(t, "_percentiles:0,50,100"), newNative(t, "_percentiles:0,50,100")
+	for i := 0; i < 500; i++ {
+		a.Reduce(&model.Document{Key: nil, Value: float64(i)})
+		b.Reduce(&model.Document{Key: nil, Value: float64(500 + i)})
+	}
+
+	// the centroids of both digests are merged, an empty
+	// digest doesn't change the result
+	a.Combine(b)
+	a.Combine(newNative(t, "_percentiles:0,50,100"))
+	result := nativeResult(t, a)[nil].(map[string]interface{})
+	assert.InDelta(t, 0, result["0"], 1)
+	assert.InDelta(t, 499.5, result["50"], 5)
+	assert.InDelta(t, 999, result["100"], 1)
+}
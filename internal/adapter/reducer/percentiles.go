@@ -0,0 +1,124 @@
+package reducer
+
+import (
+	"math"
+	"sort"
+	"strconv"
+)
+
+// defaultPercentiles are computed by _percentiles without parameter
+var defaultPercentiles = []float64{50, 90, 99}
+
+// digestCompression limits the number of centroids of the digest,
+// higher values are more accurate and use more memory
+const digestCompression = 100
+
+// centroid is the mean of count values
+type centroid struct {
+	mean, count float64
+}
+
+// digest approximates the percentiles of the numeric values with a
+// t-digest: close values are merged into centroids, the centroids
+// at the tails stay small to keep the extreme percentiles accurate.
+type digest struct {
+	percentiles []float64
+	centroids   []centroid
+	min, max    float64
+	count       float64
+	unmerged    int
+}
+
+func (a *digest) add(value interface{}) {
+	v := toFloat64(value)
+	if math.IsNaN(v) {
+		return
+	}
+	a.addCentroid(centroid{mean: v, count: 1}, v, v)
+}
+
+func (a *digest) addCentroid(c centroid, min, max float64) {
+	if a.count == 0 || min < a.min {
+		a.min = min
+	}
+	if a.count == 0 || max > a.max {
+		a.max = max
+	}
+	a.centroids = append(a.centroids, c)
+	a.count += c.count
+	a.unmerged++
+	if a.unmerged > 10*digestCompression {
+		a.compress()
+	}
+}
+
+// compress merges adjacent centroids as long as their size
+// stays below the limit of their quantile
+func (a *digest) compress() {
+	a.unmerged = 0
+	if len(a.centroids) < 2 {
+		return
+	}
+	sort.Slice(a.centroids, func(i, j int) bool {
+		return a.centroids[i].mean < a.centroids[j].mean
+	})
+	merged := a.centroids[:1]
+	var seen float64
+	for _, c := range a.centroids[1:] {
+		last := &merged[len(merged)-1]
+		q := (seen + (last.count+c.count)/2) / a.count
+		limit := math.Max(4*a.count*q*(1-q)/digestCompression, 1)
+		if last.count+c.count <= limit {
+			last.mean += (c.mean - last.mean) * c.count / (last.count + c.count)
+			last.count += c.count
+			continue
+		}
+		seen += last.count
+		merged = append(merged, c)
+	}
+	a.centroids = merged
+}
+
+func (a *digest) merge(other accumulator) {
+	o := other.(*digest)
+	for _, c := range o.centroids {
+		a.addCentroid(c, o.min, o.max)
+	}
+}
+
+// quantile interpolates between the centers of the centroids
+func (a *digest) quantile(q float64) float64 {
+	if len(a.centroids) == 1 {
+		return a.centroids[0].mean
+	}
+	target := q * a.count
+	var seen float64
+	prevCenter, prevMean := 0.0, a.min
+	for _, c := range a.centroids {
+		center := seen + c.count/2
+		if target < center {
+			if center == prevCenter {
+				return c.mean
+			}
+			return prevMean + (c.mean-prevMean)*(target-prevCenter)/(center-prevCenter)
+		}
+		seen += c.count
+		prevCenter, prevMean = center, c.mean
+	}
+	if seen == prevCenter {
+		return a.max
+	}
+	return prevMean + (a.max-prevMean)*(target-prevCenter)/(seen-prevCenter)
+}
+
+func (a *digest) result() interface{} {
+	if a.count == 0 {
+		return nil
+	}
+	a.compress()
+	out := make(map[string]interface{}, len(a.percentiles))
+	for _, p := range a.percentiles {
+		out[strconv.FormatFloat(p, 'f', -1, 64)] = a.quantile(p / 100)
+	}
+	return out
+}
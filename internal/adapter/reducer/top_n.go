@@ -0,0 +1,42 @@
+package reducer
+
+import (
+	"sort"
+
+	"github.com/goydb/goydb/pkg/model"
+)
+
+// defaultTopN is the number of values kept by _top_n without parameter
+const defaultTopN = 10
+
+// topN keeps the n highest values using view collation,
+// ordered from the highest to the lowest value
+type topN struct {
+	n      int
+	values []interface{}
+}
+
+func (a *topN) add(value interface{}) {
+	if len(a.values) == a.n && model.ViewKeyCmp(value, a.values[a.n-1]) <= 0 {
+		return
+	}
+	i := sort.Search(len(a.values), func(i int) bool {
+		return model.ViewKeyCmp(a.values[i], value) < 0
+	})
+	a.values = append(a.values, nil)
+	copy(a.values[i+1:], a.values[i:])
+	a.values[i] = value
+	if len(a.values) > a.n {
+		a.values = a.values[:a.n]
+	}
+}
+
+func (a *topN) merge(other accumulator) {
+	for _, v := range other.(*topN).values {
+		a.add(v)
+	}
+}
+
+func (a *topN) result() interface{} {
+	return append([]interface{}{}, a.values...)
+}
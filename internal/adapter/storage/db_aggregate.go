@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/goydb/goydb/internal/adapter/index"
+	"github.com/goydb/goydb/pkg/model"
+	"github.com/goydb/goydb/pkg/port"
+)
+
+// AggregateDocs groups the documents matching the selector of the query
+// and computes the accumulators per group. The match stage uses the same
+// plan as FindDocs, if no mango index is usable or the index is still
+// building all documents are streamed.
+// An empty selector matches all documents.
+func (d *Database) AggregateDocs(ctx context.Context, query model.AggregateQuery, limits model.AggregateLimits) ([]*model.AggregateRow, *model.ExecutionStats, error) {
+	var stats model.ExecutionStats
+	start := time.Now()
+
+	find := query.FindQuery()
+	agg := model.NewAggregation(query, limits)
+	matchAll := len(find.Selector.Members) == 0
+	add := func(doc *model.Document) error {
+		stats.TotalDocsExamined++
+		if matchAll {
+			return agg.Add(doc)
+		}
+		ok, err := find.Match(doc)
+		if err != nil {
+			return fmt.Errorf("aggregate failed: %w", err)
+		}
+		if !ok {
+			return nil
+		}
+		return agg.Add(doc)
+	}
+
+	var plan *model.QueryPlan
+	var mi *index.MangoIndex
+	err := d.db.ReadTransaction(func(tx port.EngineReadTransaction) error {
+		plan, mi = d.planFind(ctx, tx, find)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// a building index would return partial groups, the
+	// documents are scanned instead like in FindDocs
+	if mi != nil && !d.indexStale(ctx, plan.Selected.DesignDocFn().String(), find.Update) {
+		err = d.aggregateViaIndex(ctx, mi, plan.Values, &stats, add)
+	} else {
+		err = d.Iterator(ctx, nil, func(i port.Iterator) error {
+			i.SetSkipDesignDoc(true)
+			i.SetSkipLocalDoc(true)
+			if i.Total() == 0 {
+				return nil
+			}
+			for doc := i.First(); i.Continue(); doc = i.Next() {
+				if err := add(doc); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rows := agg.Rows()
+	stats.ResultsReturned = len(rows)
+	stats.ExecutionTime = float64(time.Since(start)) / float64(time.Millisecond)
+	return rows, &stats, nil
+}
+
+// aggregateViaIndex passes the documents found by an equality lookup
+// of the mango index to add.
+func (d *Database) aggregateViaIndex(
+	ctx context.Context,
+	mi *index.MangoIndex,
+	values []interface{},
+	stats *model.ExecutionStats,
+	add func(doc *model.Document) error,
+) error {
+	var ids []string
+	err := d.rawTx(func(tx *Transaction) error {
+		var err error
+		ids, err = mi.LookupEq(ctx, tx, values)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		stats.TotalKeysExamined++
+		doc, err := d.GetDocument(ctx, id)
+		if err != nil {
+			return err
+		}
+		if doc == nil || doc.Deleted {
+			continue
+		}
+		if err := add(doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -255,8 +255,11 @@ func TestMigrateAttachments_SentinelOnFreshDB(t *testing.T) {
 	require.NoError(t, err)
 	defer s2.Close()
 
-	db2 := s2.dbs["testdb"]
-	require.NotNil(t, db2, "testdb should be reloaded")
+	// databases are loaded on first access
+	assert.Nil(t, s2.dbs["testdb"])
+	pdb2, err := s2.Database(ctx, "testdb")
+	require.NoError(t, err, "testdb should be reloaded")
+	db2 := pdb2.(*Database)
 
 	attR, err := db2.GetAttachment(ctx, "doc1", "file.txt")
 	require.NoError(t, err)
@@ -2,6 +2,7 @@ package storage
 
 import (
 	"context"
+	"time"
 
 	"github.com/goydb/goydb/pkg/model"
 	"github.com/goydb/goydb/pkg/port"
@@ -9,13 +10,58 @@ import (
 
 // rawTx opens a write transaction with a concrete *Transaction for internal use.
 // Use Transaction (port.DatabaseTx callback) for code that should be testable via port.Database.
+// Indices of deleted or replaced design documents are removed by a
+// background task.
 func (d *Database) rawTx(fn func(tx *Transaction) error) error {
-	return d.db.WriteTransaction(d.logger, func(tx port.EngineWriteTransaction) error {
-		return fn(&Transaction{
+	var tasksQueued, documentsChanged bool
+	var t *Transaction
+	err := d.db.WriteTransaction(d.logger, func(tx port.EngineWriteTransaction) error {
+		t = &Transaction{
 			EngineWriteTransaction: tx,
 			Database:               d,
-		})
+		}
+		err := fn(t)
+		if err == nil && t.indicesOrphaned {
+			err = d.AddTasksTx(context.Background(), t, []*model.Task{{
+				Action:          model.ActionViewCleanup,
+				DBName:          d.Name(),
+				ProcessingTotal: 1,
+			}})
+		}
+		tasksQueued = t.tasksQueued
+		documentsChanged = t.documentsChanged
+		return err
 	})
+	if err != nil {
+		if t != nil {
+			d.restoreIndexModes(t.indexModes)
+		}
+		return err
+	}
+	if tasksQueued {
+		if err := d.ScheduleTasks(context.Background()); err != nil {
+			d.logger.Warnf(context.Background(), "failed to schedule tasks", "error", err)
+		}
+	}
+	if documentsChanged && d.hasBackgroundIndices() {
+		d.scheduler.ScheduleChanges(d.Name(), time.Now())
+	}
+	return nil
+}
+
+// hasBackgroundIndices returns true if changed documents are processed
+// in the background, by async indices or materialized views
+func (d *Database) hasBackgroundIndices() bool {
+	found := false
+	check := func(_, _ interface{}) bool {
+		found = true
+		return false
+	}
+	d.asyncIndices.Range(check)
+	if !found {
+		d.dbCopies.Range(check)
+	}
+	return found
 }
 
 // Transaction implements port.Database.Transaction.
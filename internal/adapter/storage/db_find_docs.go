@@ -3,7 +3,7 @@ package storage
 import (
 	"context"
 	"fmt"
-	"strings"
+	"sort"
 	"time"
 
 	"github.com/goydb/goydb/internal/adapter/index"
@@ -21,17 +21,24 @@ func (d *Database) FindDocs(ctx context.Context, query model.FindQuery) ([]*mode
 
 	hasSort := len(query.Sort) > 0
 
-	// Attempt index-based shortcut for equality conditions (only when no sort requested).
-	if !hasSort {
-		if eqFields := query.EqConditions(); len(eqFields) > 0 {
-			if mi, values := d.bestMangoIndex(query); mi != nil {
-				return d.findDocsViaIndex(ctx, query, mi, values, &stats, start)
-			}
-		}
+	// Use the cheapest mango index covering the equality conditions, if any.
+	var plan *model.QueryPlan
+	var mi *index.MangoIndex
+	err := d.db.ReadTransaction(func(tx port.EngineReadTransaction) error {
+		plan, mi = d.planFind(ctx, tx, query)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	stats.Plan = plan
+	if mi != nil && !d.indexStale(ctx, plan.Selected.DesignDocFn().String(), query.Update) {
+		return d.findDocsViaIndex(ctx, query, mi, plan.Values, &stats, start)
 	}
 
-	// Full-table scan fallback.
-	err := d.Iterator(ctx, nil, func(i port.Iterator) error {
+	// Full-table scan fallback, also used instead of a building
+	// index to return current results.
+	err = d.Iterator(ctx, nil, func(i port.Iterator) error {
 		total := i.Total()
 		if total == 0 {
 			return nil
@@ -42,7 +49,9 @@ func (d *Database) FindDocs(ctx context.Context, query model.FindQuery) ([]*mode
 			if query.Limit != 0 {
 				i.SetLimit(int(query.Limit))
 			}
-			if query.Bookmark != "" {
+			if query.After != nil {
+				i.SetStartKey([]byte(query.After.ID))
+			} else if query.Bookmark != "" {
 				i.SetStartKey([]byte(query.Bookmark))
 			}
 		}
@@ -54,6 +63,7 @@ func (d *Database) FindDocs(ctx context.Context, query model.FindQuery) ([]*mode
 			if err != nil {
 				return fmt.Errorf("find failed: %w", err)
 			}
+			ok = ok && query.AfterCursor(doc)
 			if ok {
 				stats.ResultsReturned++
 				docs = append(docs, doc)
@@ -85,59 +95,6 @@ func (d *Database) FindDocs(ctx context.Context, query model.FindQuery) ([]*mode
 	return docs, &stats, nil
 }
 
-// bestMangoIndex finds the first MangoIndex whose fields are a prefix of (or
-// exactly match) the equality conditions. Returns the index and ordered values
-// that cover its fields, or (nil, nil) if no suitable index exists.
-// If query.UseIndex is set it acts as a hint to prefer a specific index.
-func (d *Database) bestMangoIndex(query model.FindQuery) (*index.MangoIndex, []interface{}) {
-	eqFields := query.EqConditions()
-
-	// Parse use_index hint. Index keys have the format "<fnType>:<ddoc>:<name>".
-	var wantPrefix string
-	switch ui := query.UseIndex.(type) {
-	case string:
-		wantPrefix = string(model.MangoFn) + ":" + ui + ":"
-	case []interface{}:
-		if len(ui) >= 1 {
-			wantPrefix = string(model.MangoFn) + ":" + fmt.Sprint(ui[0])
-			if len(ui) >= 2 {
-				wantPrefix += ":" + fmt.Sprint(ui[1])
-			} else {
-				wantPrefix += ":"
-			}
-		}
-	}
-
-	for key, idx := range d.indices {
-		mi, ok := idx.(*index.MangoIndex)
-		if !ok {
-			continue
-		}
-		if wantPrefix != "" && !strings.HasPrefix(key, wantPrefix) {
-			continue
-		}
-		fields := mi.Fields()
-		if len(fields) == 0 {
-			continue
-		}
-		// Check that every index field has an equality condition.
-		values := make([]interface{}, len(fields))
-		covered := true
-		for i, f := range fields {
-			v, ok := eqFields[f]
-			if !ok {
-				covered = false
-				break
-			}
-			values[i] = v
-		}
-		if covered {
-			return mi, values
-		}
-	}
-	return nil, nil
-}
-
 // findDocsViaIndex performs an equality lookup using a MangoIndex and loads
 // matching documents from the database.
 func (d *Database) findDocsViaIndex(
@@ -159,6 +116,13 @@ func (d *Database) findDocsViaIndex(
 		return nil, nil, err
 	}
 
+	// documents are returned in id order like the full
+	// scan, the ids before the cursor aren't loaded
+	sort.Strings(ids)
+	if query.After != nil {
+		ids = ids[sort.SearchStrings(ids, query.After.ID):]
+	}
+
 	skip := query.Skip
 	limit := query.Limit
 	if limit == 0 {
@@ -178,7 +142,7 @@ func (d *Database) findDocsViaIndex(
 		if err != nil {
 			return nil, nil, fmt.Errorf("find failed: %w", err)
 		}
-		if !ok {
+		if !ok || !query.AfterCursor(doc) {
 			continue
 		}
 		if skip > 0 {
@@ -194,3 +158,13 @@ func (d *Database) findDocsViaIndex(
 
 	return docs, stats, nil
 }
+
+// indexStale returns true if the index is still building and the
+// query requires current results (update isn't false or lazy)
+func (d *Database) indexStale(ctx context.Context, name string, update model.FindUpdate) bool {
+	if update == "false" || update == "lazy" {
+		return false
+	}
+	_, building, err := d.IndexSeq(ctx, name)
+	return err != nil || building
+}
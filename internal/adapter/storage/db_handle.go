@@ -0,0 +1,259 @@
+package storage
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/goydb/goydb/internal/adapter/bbolt_engine"
+	"github.com/goydb/goydb/pkg/model"
+	"github.com/goydb/goydb/pkg/port"
+)
+
+var _ port.DatabaseEngine = (*handle)(nil)
+
+var errHandleClosed = errors.New("database is closed")
+
+// handle is the file of a database, it is opened by the first
+// transaction and may be closed by the storage while it is idle.
+// A closed handle is opened again by the next transaction.
+type handle struct {
+	path    string
+	handles *handles
+
+	mu       sync.Mutex
+	db       *bbolt_engine.DB
+	active   int
+	lastUsed time.Time
+	// closed handles aren't opened again, e.g. of deleted databases
+	closed bool
+}
+
+func (h *handle) acquire() (*bbolt_engine.DB, error) {
+	h.mu.Lock()
+	opened := false
+	if h.closed {
+		h.mu.Unlock()
+		return nil, errHandleClosed
+	}
+	if h.db == nil {
+		db, err := bbolt_engine.Open(h.path)
+		if err != nil {
+			h.mu.Unlock()
+			return nil, err
+		}
+		h.db = db
+		opened = true
+	}
+	h.active++
+	h.lastUsed = time.Now()
+	db := h.db
+	h.mu.Unlock()
+
+	if opened {
+		h.handles.opened(h)
+	}
+	return db, nil
+}
+
+func (h *handle) release() {
+	h.mu.Lock()
+	h.active--
+	h.lastUsed = time.Now()
+	h.mu.Unlock()
+}
+
+// idleSince returns the time of the last transaction, false
+// if the file is closed or a transaction is running
+func (h *handle) idleSince() (time.Time, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.lastUsed, h.db != nil && h.active == 0
+}
+
+func (h *handle) isOpen() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.db != nil
+}
+
+// closeUnused closes the closed file for good if it wasn't opened
+// again meanwhile, e.g. before its database is unloaded
+func (h *handle) closeUnused() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.db != nil || h.active > 0 {
+		return false
+	}
+	h.closed = true
+	return true
+}
+
+// closeIdle closes the file if no transaction is running
+func (h *handle) closeIdle() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.db == nil || h.active > 0 {
+		return false
+	}
+	_ = h.db.Close()
+	h.db = nil
+	return true
+}
+
+func (h *handle) Stats() (model.DatabaseStats, error) {
+	db, err := h.acquire()
+	if err != nil {
+		return model.DatabaseStats{}, err
+	}
+	defer h.release()
+	return db.Stats()
+}
+
+func (h *handle) ReadTransaction(fn func(tx port.EngineReadTransaction) error) error {
+	db, err := h.acquire()
+	if err != nil {
+		return err
+	}
+	defer h.release()
+	return db.ReadTransaction(fn)
+}
+
+func (h *handle) WriteTransaction(logger port.Logger, fn func(tx port.EngineWriteTransaction) error) error {
+	db, err := h.acquire()
+	if err != nil {
+		return err
+	}
+	defer h.release()
+	return db.WriteTransaction(logger, fn)
+}
+
+func (h *handle) Compact() error {
+	db, err := h.acquire()
+	if err != nil {
+		return err
+	}
+	defer h.release()
+	return db.Compact()
+}
+
+// Close closes the file for good, running transactions are completed first
+func (h *handle) Close() error {
+	h.mu.Lock()
+	h.closed = true
+	if h.db == nil {
+		h.mu.Unlock()
+		return nil
+	}
+	err := h.db.Close()
+	h.db = nil
+	h.mu.Unlock()
+
+	h.handles.closed(h)
+	return err
+}
+
+// handles limits the number of open database files, the least
+// recently used idle files are closed first (max_dbs_open)
+type handles struct {
+	mu   sync.Mutex
+	open map[*handle]bool
+	// max is the number of open files, 0 is unlimited
+	max int
+	// idleTimeout closes files that weren't used, 0 keeps them open
+	idleTimeout time.Duration
+	// evicted is called with the files closed by the limit or
+	// the idle timeout, hs.mu isn't held
+	evicted func(closed []*handle)
+}
+
+func newHandles() *handles {
+	return &handles{
+		open: make(map[*handle]bool),
+	}
+}
+
+func (hs *handles) handle(path string) *handle {
+	return &handle{path: path, handles: hs}
+}
+
+// opened records the opened file and closes the least recently
+// used idle files if there are more than max open. If all files
+// are in use the limit is exceeded until they are idle.
+func (hs *handles) opened(h *handle) {
+	hs.evict(hs.closeLeastRecentlyUsed(h))
+}
+
+func (hs *handles) closeLeastRecentlyUsed(h *handle) []*handle {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+
+	// closed meanwhile
+	if !h.isOpen() {
+		return nil
+	}
+	hs.open[h] = true
+	if hs.max <= 0 || len(hs.open) <= hs.max {
+		return nil
+	}
+
+	type candidate struct {
+		h        *handle
+		lastUsed time.Time
+	}
+	var idle []candidate
+	for o := range hs.open {
+		if lastUsed, ok := o.idleSince(); ok && o != h {
+			idle = append(idle, candidate{o, lastUsed})
+		}
+	}
+	sort.Slice(idle, func(i, j int) bool { return idle[i].lastUsed.Before(idle[j].lastUsed) })
+	var closed []*handle
+	for _, c := range idle {
+		if len(hs.open) <= hs.max {
+			break
+		}
+		if c.h.closeIdle() {
+			delete(hs.open, c.h)
+			closed = append(closed, c.h)
+		}
+	}
+	return closed
+}
+
+func (hs *handles) evict(closed []*handle) {
+	if len(closed) > 0 && hs.evicted != nil {
+		hs.evicted(closed)
+	}
+}
+
+func (hs *handles) closed(h *handle) {
+	hs.mu.Lock()
+	delete(hs.open, h)
+	hs.mu.Unlock()
+}
+
+// closeIdle closes the files that weren't used for the idle timeout
+func (hs *handles) closeIdle(now time.Time) {
+	if hs.idleTimeout <= 0 {
+		return
+	}
+	var closed []*handle
+	hs.mu.Lock()
+	for h := range hs.open {
+		if lastUsed, ok := h.idleSince(); ok && now.Sub(lastUsed) >= hs.idleTimeout && h.closeIdle() {
+			delete(hs.open, h)
+			closed = append(closed, h)
+		}
+	}
+	hs.mu.Unlock()
+	hs.evict(closed)
+}
+
+// count returns the number of open files
+func (hs *handles) count() int {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	return len(hs.open)
+}
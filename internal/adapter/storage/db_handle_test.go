@@ -0,0 +1,238 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/goydb/goydb/internal/adapter/logger"
+	"github.com/goydb/goydb/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// createDatabases creates databases with a document each and
+// reopens the storage with the options
+func createDatabases(t *testing.T, names []string, options ...StorageOption) *Storage {
+	t.Helper()
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	s, err := Open(dir, WithLogger(logger.NewNoLog()))
+	require.NoError(t, err)
+	for _, name := range names {
+		db, err := s.CreateDatabase(ctx, name)
+		require.NoError(t, err)
+		_, err = db.PutDocument(ctx, &model.Document{ID: "doc", Data: map[string]interface{}{"db": name}})
+		require.NoError(t, err)
+	}
+	require.NoError(t, s.Close())
+
+	s, err = Open(dir, append([]StorageOption{WithLogger(logger.NewNoLog())}, options...)...)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.Close() })
+	return s
+}
+
+func TestStorage_LazyOpen(t *testing.T) {
+	ctx := context.Background()
+	s := createDatabases(t, []string{"a", "b", "c"}, WithMaxOpenDatabases(2))
+
+	names, err := s.Databases(ctx)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"a", "b", "c"}, names)
+	loaded, err := s.LoadedDatabases(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, loaded)
+	assert.Equal(t, 0, s.OpenDatabases())
+
+	// the databases of the least recently used files are
+	// unloaded and loaded again
+	for i := 0; i < 2; i++ {
+		for _, name := range names {
+			db, err := s.Database(ctx, name)
+			require.NoError(t, err)
+			doc, err := db.GetDocument(ctx, "doc")
+			require.NoError(t, err)
+			assert.Equal(t, name, doc.Data["db"])
+			assert.LessOrEqual(t, s.OpenDatabases(), 2)
+		}
+	}
+	loaded, err = s.LoadedDatabases(ctx)
+	require.NoError(t, err)
+	assert.Len(t, loaded, 2)
+}
+
+func TestStorage_IdleTimeout(t *testing.T) {
+	ctx := context.Background()
+	s := createDatabases(t, []string{"a"}, WithIdleTimeout(time.Millisecond*20))
+
+	db, err := s.Database(ctx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, 1, s.OpenDatabases())
+	assert.Eventually(t, func() bool {
+		loaded, err := s.LoadedDatabases(ctx)
+		return err == nil && len(loaded) == 0 && s.OpenDatabases() == 0
+	}, time.Second, time.Millisecond*10)
+
+	// the unloaded database is closed, the database is loaded again
+	_, err = db.GetDocument(ctx, "doc")
+	assert.ErrorIs(t, err, errHandleClosed)
+	db, err = s.Database(ctx, "a")
+	require.NoError(t, err)
+	doc, err := db.GetDocument(ctx, "doc")
+	require.NoError(t, err)
+	assert.NotNil(t, doc)
+
+	// databases in use by a request stay loaded, the file
+	// is opened again by the next transaction
+	rctx, cancel := context.WithCancel(ctx)
+	db, err = s.Database(rctx, "a")
+	require.NoError(t, err)
+	time.Sleep(time.Millisecond * 100)
+	assert.Equal(t, 0, s.OpenDatabases())
+	loaded, err := s.LoadedDatabases(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a"}, loaded)
+	doc, err = db.GetDocument(rctx, "doc")
+	require.NoError(t, err)
+	assert.NotNil(t, doc)
+	cancel()
+	assert.Eventually(t, func() bool {
+		loaded, err := s.LoadedDatabases(ctx)
+		return err == nil && len(loaded) == 0 && s.OpenDatabases() == 0
+	}, time.Second, time.Millisecond*10)
+
+	// change listeners don't keep the database loaded,
+	// they are told by the done channel
+	db, err = s.Database(ctx, "a")
+	require.NoError(t, err)
+	lctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	require.NoError(t, db.AddListener(lctx, nil))
+	select {
+	case <-db.Done():
+	case <-time.After(time.Second):
+		t.Fatal("database wasn't unloaded")
+	}
+	loaded, err = s.LoadedDatabases(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, loaded)
+}
+
+func TestStorage_ScheduleAtStartup(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	s, err := Open(dir, WithLogger(logger.NewNoLog()))
+	require.NoError(t, err)
+	for _, name := range []string{"a", "b"} {
+		_, err := s.CreateDatabase(ctx, name)
+		require.NoError(t, err)
+	}
+	pdb, err := s.Database(ctx, "a")
+	require.NoError(t, err)
+	require.NoError(t, pdb.(*Database).AddTasks(ctx, []*model.Task{{
+		Action:      model.ActionUpdateView,
+		DBName:      "a",
+		DesignDocFn: "_design/app/_view/x",
+	}}))
+	require.NoError(t, s.Close())
+
+	s, err = Open(dir, WithLogger(logger.NewNoLog()))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.Close() })
+
+	// the databases with tasks left over are queued, they are
+	// loaded by the task workers
+	next, _, _ := s.scheduler.pop(time.Now())
+	assert.Equal(t, "a", next)
+	s.scheduler.Done(next)
+	next, _, _ = s.scheduler.pop(time.Now())
+	assert.Empty(t, next)
+	loaded, err := s.LoadedDatabases(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, loaded)
+	assert.Equal(t, 0, s.OpenDatabases())
+}
+
+func TestStorage_Quarantine(t *testing.T) {
+	ctx := context.Background()
+	s := createDatabases(t, []string{"a"})
+	broken := path.Join(s.Path(), "broken")
+	require.NoError(t, os.WriteFile(broken, bytes.Repeat([]byte("x"), 1<<14), 0666))
+	require.NoError(t, s.ReloadDatabases(ctx))
+
+	// the broken database doesn't affect the others
+	_, err := s.Database(ctx, "broken")
+	require.Error(t, err)
+	_, err = s.Database(ctx, "broken")
+	assert.ErrorIs(t, err, ErrQuarantinedDatabase)
+	assert.Contains(t, s.QuarantinedDatabases(), "broken")
+	_, err = s.Database(ctx, "a")
+	require.NoError(t, err)
+
+	// the quarantine ends after the backoff, e.g. after
+	// temporary errors like too many open files
+	retry := func() {
+		s.mu.Lock()
+		s.quarantined["broken"].retryAt = time.Now()
+		s.mu.Unlock()
+	}
+	retry()
+	_, err = s.Database(ctx, "broken")
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, ErrQuarantinedDatabase)
+	assert.Equal(t, 2, s.quarantined["broken"].attempts)
+	assert.Equal(t, 2*time.Second, quarantineBackoff(2))
+	assert.Equal(t, 5*time.Minute, quarantineBackoff(20))
+	_, err = s.Database(ctx, "broken")
+	assert.ErrorIs(t, err, ErrQuarantinedDatabase)
+	assert.Contains(t, s.QuarantinedDatabases(), "broken")
+
+	// quarantined databases can be deleted
+	require.NoError(t, s.DeleteDatabase(ctx, "broken"))
+	assert.Empty(t, s.QuarantinedDatabases())
+	names, err := s.Databases(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a"}, names)
+}
+
+func TestStorage_QuarantineRepaired(t *testing.T) {
+	ctx := context.Background()
+	s := createDatabases(t, []string{"a"})
+	broken := path.Join(s.Path(), "broken")
+	require.NoError(t, os.WriteFile(broken, bytes.Repeat([]byte("x"), 1<<14), 0666))
+	require.NoError(t, s.ReloadDatabases(ctx))
+	_, err := s.Database(ctx, "broken")
+	require.Error(t, err)
+	assert.Contains(t, s.QuarantinedDatabases(), "broken")
+
+	// a repaired database is loaded by the first access after the backoff
+	require.NoError(t, os.Remove(broken))
+	_, err = s.Database(ctx, "broken")
+	assert.ErrorIs(t, err, ErrQuarantinedDatabase)
+	s.mu.Lock()
+	s.quarantined["broken"].retryAt = time.Now()
+	s.mu.Unlock()
+	_, err = s.Database(ctx, "broken")
+	require.NoError(t, err)
+	assert.Empty(t, s.QuarantinedDatabases())
+}
+
+func TestStorage_DeletedHandle(t *testing.T) {
+	ctx := context.Background()
+	s := createDatabases(t, []string{"a"})
+	db, err := s.Database(ctx, "a")
+	require.NoError(t, err)
+	require.NoError(t, s.DeleteDatabase(ctx, "a"))
+
+	// the file of a deleted database isn't created again
+	_, err = db.GetDocument(ctx, "doc")
+	assert.Error(t, err)
+	_, err = os.Stat(path.Join(s.Path(), "a"))
+	assert.True(t, os.IsNotExist(err))
+}
@@ -2,6 +2,7 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"path/filepath"
 
@@ -51,14 +52,27 @@ func (d *Database) BuildDesignDocIndices(ctx context.Context, tx *Transaction, d
 	return nil
 }
 
-func (d *Database) BuildFnIndices(ctx context.Context, tx port.EngineWriteTransaction, doc *model.Document, vf *model.Function, update bool) error {
+func (d *Database) BuildFnIndices(ctx context.Context, tx *Transaction, doc *model.Document, vf *model.Function, update bool) error {
 	var err error
 
 	ddfn := vf.DesignDocFn()
 	indexName := ddfn.String()
 
-	idx, ok := d.indices[indexName]
+	// materialized views are updated in the background after changes,
+	// the index records the changed keys to copy only the changed groups
+	view, ok := doc.View(vf.Name)
+	copied := vf.Type == model.ViewFn && ok && view.DBCopy != "" && view.ReduceFn != ""
+	if copied {
+		d.dbCopies.Store(indexName, true)
+	} else {
+		d.dbCopies.Delete(indexName)
+	}
+
+	idx, ok := d.index(indexName)
 	if ok {
+		if t, ok := idx.(port.KeyChangeTracker); ok {
+			t.TrackKeyChanges(copied)
+		}
 		// index already exists, check if update is required
 		// check if already view index, update source,
 		// this is only possible if the function is of the same type
@@ -66,11 +80,27 @@ func (d *Database) BuildFnIndices(ctx context.Context, tx port.EngineWriteTransa
 		// from scratch
 		disu, ok := idx.(port.DocumentIndexSourceUpdate)
 		if ok && disu.SourceType() == vf.Type {
+			err = d.switchIndexMode(ctx, tx, ddfn, designDocIndexMode(tx, doc))
+			if err != nil {
+				return err
+			}
+
+			// the index is current if the function didn't change
+			source := functionSource(doc, vf)
+			if prev, ok := d.indexSources.Load(indexName); ok && prev == source {
+				// saving the design document again resumes a canceled build
+				if _, building := indexSeq(tx, indexName); building && errors.Is(d.indexBuildErr(tx, indexName), ErrIndexBuildCanceled) {
+					return d.queueIndexBuild(ctx, tx, ddfn, false)
+				}
+				return nil
+			}
+
 			// compile the source
 			err = disu.UpdateSource(ctx, doc, vf)
 			if err != nil {
 				return err
 			}
+			d.indexSources.Store(indexName, source)
 
 			// add all documents
 			err = d.UpdateAllDocuments(ctx, tx, ddfn)
@@ -84,22 +114,28 @@ func (d *Database) BuildFnIndices(ctx context.Context, tx port.EngineWriteTransa
 		return nil
 	}
 
-	// index doesn't exist yet
+	// index doesn't exist yet, the buckets of the index may have
+	// a different name if it was promoted from a staged design document
+	bucketFn := indexBucketFn(tx, doc, ddfn)
 	var disu port.DocumentIndexSourceUpdate
 	switch vf.Type {
 	case model.ViewFn:
-		disu = index.NewViewIndex(ddfn, d.viewEngines, d.logger.With("index", ddfn.String()))
+		disu = index.NewViewIndex(bucketFn, d.viewEngines, d.logger.With("index", ddfn.String()))
 	case model.SearchFn:
 		if searchIndexFactory == nil {
 			return fmt.Errorf("search index support not compiled in (build with default tags to enable)")
 		}
-		disu = searchIndexFactory(ddfn, d.viewEngines, d.searchIndexPath(ddfn.String()), d.logger.With("index", ddfn.String()))
+		disu = searchIndexFactory(bucketFn, d.viewEngines, d.searchIndexPath(bucketFn.String()), d.logger.With("index", ddfn.String()))
 	case model.MangoFn:
-		disu = index.NewMangoIndex(ddfn, d.logger.With("index", ddfn.String()))
+		disu = index.NewMangoIndex(bucketFn, d.logger.With("index", ddfn.String()))
 	default:
 		return fmt.Errorf("invalid view function type %q for function %q", vf.Type, ddfn.String())
 	}
 
+	if t, ok := disu.(port.KeyChangeTracker); ok {
+		t.TrackKeyChanges(copied)
+	}
+
 	// create new index
 	err = disu.Ensure(ctx, tx)
 	if err != nil {
@@ -111,23 +147,50 @@ func (d *Database) BuildFnIndices(ctx context.Context, tx port.EngineWriteTransa
 	if err != nil {
 		return err
 	}
+	d.indexSources.Store(indexName, functionSource(doc, vf))
+
+	err = d.switchIndexMode(ctx, tx, ddfn, designDocIndexMode(tx, doc))
+	if err != nil {
+		return err
+	}
 
-	// add all documents
-	if update {
-		err = d.UpdateAllDocuments(ctx, tx, ddfn)
+	// add all documents, or resume the build that was
+	// interrupted by a restart at its checkpoint
+	if _, building := indexSeq(tx, indexName); update || building {
+		err = d.queueIndexBuild(ctx, tx, ddfn, update)
 		if err != nil {
 			return err
 		}
 	}
 
 	// add new index
-	d.indices[indexName] = disu
+	d.setIndex(indexName, disu)
 
 	return nil
 }
 
 // UpdateAllDocuments triggers rebuild with all documents
 func (d *Database) UpdateAllDocuments(ctx context.Context, tx port.EngineWriteTransaction, ddfn *model.DesignDocFn) error {
+	return d.queueIndexBuild(ctx, tx, ddfn, true)
+}
+
+// queueIndexBuild adds the task that builds the index from its
+// checkpoint, if restart is true the checkpoint is reset first.
+// Without any changes in the database the index is built already.
+// Async indices are built by the background indexer instead.
+func (d *Database) queueIndexBuild(ctx context.Context, tx port.EngineWriteTransaction, ddfn *model.DesignDocFn, restart bool) error {
+	async := d.isAsyncIndex(ddfn.String())
+	if restart {
+		if tx.Sequence([]byte(index.ChangesIndexName)) == 0 && !async {
+			tx.Delete(indexSeqBucket, []byte(ddfn.String()))
+		} else {
+			setIndexSeq(tx, ddfn.String(), 0)
+		}
+	}
+	if async {
+		return nil
+	}
+
 	action := model.ActionUpdateView
 	switch ddfn.Type {
 	case model.SearchFn:
@@ -146,7 +209,7 @@ func (d *Database) UpdateAllDocuments(ctx context.Context, tx port.EngineWriteTr
 }
 
 func (d *Database) SearchDocuments(ctx context.Context, ddfn *model.DesignDocFn, sq *port.SearchQuery) (*port.SearchResult, error) {
-	idx, ok := d.indices[ddfn.String()]
+	idx, ok := d.index(ddfn.String())
 	if !ok {
 		return nil, ErrNotFound
 	}
@@ -156,7 +219,15 @@ func (d *Database) SearchDocuments(ctx context.Context, ddfn *model.DesignDocFn,
 		return nil, fmt.Errorf("can't SearchDocuments on non search index: %q", ddfn)
 	}
 
-	return si.SearchDocuments(ctx, ddfn, sq)
+	// the transaction keeps the database from being unloaded
+	// and its search index from being closed during the search
+	var result *port.SearchResult
+	err := d.db.ReadTransaction(func(tx port.EngineReadTransaction) error {
+		var err error
+		result, err = si.SearchDocuments(ctx, ddfn, sq)
+		return err
+	})
+	return result, err
 }
 
 func (d *Database) searchIndexPath(name string) string {
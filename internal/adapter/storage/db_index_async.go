@@ -0,0 +1,148 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/goydb/goydb/internal/adapter/index"
+	"github.com/goydb/goydb/pkg/model"
+	"github.com/goydb/goydb/pkg/port"
+)
+
+// indexMode returns the index mode of the database
+func indexMode(tx port.EngineReadTransaction) string {
+	v, err := tx.Get(model.MetaBucket, model.IndexModeKey)
+	if err != nil || string(v) != model.IndexModeAsync {
+		return model.IndexModeSync
+	}
+	return model.IndexModeAsync
+}
+
+// GetIndexMode returns the index mode of the design document
+// indices that don't configure options.index_mode
+func (d *Database) GetIndexMode(ctx context.Context) (string, error) {
+	var mode string
+	err := d.db.ReadTransaction(func(tx port.EngineReadTransaction) error {
+		mode = indexMode(tx)
+		return nil
+	})
+	return mode, err
+}
+
+// SetIndexMode persists the index mode of the database and
+// switches the indices without options.index_mode
+func (d *Database) SetIndexMode(ctx context.Context, mode string) error {
+	if mode != model.IndexModeSync && mode != model.IndexModeAsync {
+		return fmt.Errorf("invalid index mode %q", mode)
+	}
+
+	docs, _, err := d.AllDesignDocs(ctx)
+	if err != nil {
+		return err
+	}
+
+	return d.rawTx(func(tx *Transaction) error {
+		tx.Put(model.MetaBucket, model.IndexModeKey, []byte(mode))
+
+		for _, doc := range docs {
+			if doc.IndexMode() != "" {
+				continue
+			}
+			for _, f := range doc.Functions() {
+				ddfn := f.DesignDocFn()
+				if _, ok := d.index(ddfn.String()); !ok {
+					continue
+				}
+				err := d.switchIndexMode(ctx, tx, ddfn, mode == model.IndexModeAsync)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// AsyncIndices returns the names of the indices that are
+// updated in the background
+func (d *Database) AsyncIndices() []string {
+	var names []string
+	d.asyncIndices.Range(func(key, _ interface{}) bool {
+		names = append(names, key.(string))
+		return true
+	})
+	return names
+}
+
+// isAsyncIndex returns true if the index is updated in the background
+func (d *Database) isAsyncIndex(name string) bool {
+	_, ok := d.asyncIndices.Load(name)
+	return ok
+}
+
+// syncIndices returns the indices that are updated in
+// the transaction of the document write
+func (d *Database) syncIndices() map[string]port.DocumentIndex {
+	d.indicesMu.RLock()
+	defer d.indicesMu.RUnlock()
+	indices := make(map[string]port.DocumentIndex, len(d.indices))
+	for name, idx := range d.indices {
+		if !d.isAsyncIndex(name) {
+			indices[name] = idx
+		}
+	}
+	return indices
+}
+
+// designDocIndexMode returns true if the indices of the design
+// document are async, options.index_mode overrides the database
+func designDocIndexMode(tx port.EngineReadTransaction, doc *model.Document) bool {
+	mode := doc.IndexMode()
+	if mode == "" {
+		mode = indexMode(tx)
+	}
+	return mode == model.IndexModeAsync
+}
+
+// switchIndexMode makes the index async or sync. An async index
+// keeps its checkpoint, writes only record the change. An index
+// that becomes sync catches up from its checkpoint. The switch is
+// reverted if the transaction fails.
+func (d *Database) switchIndexMode(ctx context.Context, tx *Transaction, ddfn *model.DesignDocFn, async bool) error {
+	name := ddfn.String()
+	if async == d.isAsyncIndex(name) {
+		return nil
+	}
+	if _, ok := tx.indexModes[name]; !ok {
+		if tx.indexModes == nil {
+			tx.indexModes = make(map[string]bool)
+		}
+		tx.indexModes[name] = !async
+	}
+
+	if async {
+		d.asyncIndices.Store(name, true)
+		if _, ok := indexSeq(tx, name); !ok {
+			setIndexSeq(tx, name, tx.Sequence([]byte(index.ChangesIndexName)))
+		}
+		return nil
+	}
+
+	d.asyncIndices.Delete(name)
+	if _, ok := indexSeq(tx, name); !ok {
+		return nil
+	}
+	return d.queueIndexBuild(ctx, tx, ddfn, false)
+}
+
+// restoreIndexModes reverts the index modes switched by a failed
+// transaction, the persisted checkpoints match the restored modes
+func (d *Database) restoreIndexModes(modes map[string]bool) {
+	for name, async := range modes {
+		if async {
+			d.asyncIndices.Store(name, true)
+		} else {
+			d.asyncIndices.Delete(name)
+		}
+	}
+}
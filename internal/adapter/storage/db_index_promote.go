@@ -0,0 +1,218 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/goydb/goydb/internal/adapter/index"
+	"github.com/goydb/goydb/pkg/model"
+	"github.com/goydb/goydb/pkg/port"
+)
+
+// ErrIndexBuilding is returned if a staged design document is
+// promoted before its indices processed all changes
+var ErrIndexBuilding = errors.New("staged indices are still building")
+
+// indexBucketsBucket maps index names to the names of the index
+// buckets if they differ, e.g. after the promotion of a staged
+// design document
+var indexBucketsBucket = []byte("index_buckets")
+
+// indexBucketFn returns the name of the buckets of the index. Staged
+// design documents get unique buckets that are kept by the promotion.
+func indexBucketFn(tx port.EngineWriteTransaction, doc *model.Document, ddfn *model.DesignDocFn) *model.DesignDocFn {
+	name := []byte(ddfn.String())
+	v, err := tx.Get(indexBucketsBucket, name)
+	if err == nil {
+		if bucketFn, err := model.ParseDesignDocFn(string(v)); err == nil {
+			return bucketFn
+		}
+	}
+	if !doc.IsStagedDesignDoc() {
+		return ddfn
+	}
+
+	seq := tx.Sequence([]byte(index.ChangesIndexName))
+	bucketFn := &model.DesignDocFn{
+		Type:        ddfn.Type,
+		DesignDocID: ddfn.DesignDocID + "@" + strconv.FormatUint(seq, 10),
+		FnName:      ddfn.FnName,
+	}
+	tx.EnsureBucket(indexBucketsBucket)
+	tx.Put(indexBucketsBucket, name, []byte(bucketFn.String()))
+	return bucketFn
+}
+
+// functionSource returns the source of the index function, indices
+// with the same source don't need to be rebuilt
+func functionSource(doc *model.Document, vf *model.Function) string {
+	views, _ := doc.Data["views"].(map[string]interface{})
+	data, _ := json.Marshal([]interface{}{doc.Language(), views[model.ViewLib], vf})
+	return string(data)
+}
+
+// promotedIndex is an index of a staged design
+// document that is swapped in under the live name
+type promotedIndex struct {
+	live, staged string
+	idx          port.DocumentIndex
+	source       interface{}
+	async        bool
+	seq          uint64
+	checkpoint   bool
+
+	// the live index before the swap
+	swapped    bool
+	prev       port.DocumentIndex
+	prevSource interface{}
+	prevAsync  bool
+}
+
+// PromoteDesignDoc replaces the design document with its staged
+// version. The built indices of the staged version are swapped in
+// under the live names in the same transaction that writes the
+// design document and deletes the staged version.
+func (d *Database) PromoteDesignDoc(ctx context.Context, docID string) (string, error) {
+	var rev string
+	var promoted []*promotedIndex
+	var live *model.Document
+	err := d.rawTx(func(tx *Transaction) error {
+		staged, err := tx.GetDocument(ctx, model.StagedDesignDocID(docID))
+		if err != nil {
+			return err
+		}
+		if staged == nil || staged.Deleted {
+			return ErrNotFound
+		}
+		old, err := tx.GetDocument(ctx, docID)
+		if err != nil {
+			return err
+		}
+
+		current := tx.Sequence([]byte(index.ChangesIndexName))
+		for _, f := range staged.Functions() {
+			stagedFn := f.DesignDocFn()
+			p := &promotedIndex{staged: stagedFn.String()}
+			var ok bool
+			p.idx, ok = d.index(p.staged)
+			if !ok {
+				continue
+			}
+			p.seq, p.checkpoint = indexSeq(tx, p.staged)
+			if p.checkpoint && p.seq < current {
+				return ErrIndexBuilding
+			}
+			p.source, _ = d.indexSources.Load(p.staged)
+			p.async = d.isAsyncIndex(p.staged)
+			liveFn := &model.DesignDocFn{Type: stagedFn.Type, DesignDocID: docID, FnName: stagedFn.FnName}
+			p.live = liveFn.String()
+
+			// the live index keeps using the buckets of the staged index
+			bucketFn := indexBucketFn(tx, staged, stagedFn)
+			tx.EnsureBucket(indexBucketsBucket)
+			tx.Put(indexBucketsBucket, []byte(p.live), []byte(bucketFn.String()))
+			tx.Delete(indexBucketsBucket, []byte(p.staged))
+			promoted = append(promoted, p)
+		}
+
+		// swap the indices before the design document is written,
+		// the unchanged sources don't trigger a rebuild
+		d.indicesMu.Lock()
+		for _, p := range promoted {
+			p.prev = d.indices[p.live]
+			p.prevSource, _ = d.indexSources.Load(p.live)
+			p.prevAsync = d.isAsyncIndex(p.live)
+			p.swapped = true
+			d.indices[p.live] = p.idx
+			delete(d.indices, p.staged)
+			d.indexSources.Store(p.live, p.source)
+			d.indexSources.Delete(p.staged)
+			d.asyncIndices.Delete(p.staged)
+			if p.async {
+				d.asyncIndices.Store(p.live, true)
+			} else {
+				d.asyncIndices.Delete(p.live)
+			}
+		}
+		d.indicesMu.Unlock()
+
+		live = &model.Document{
+			ID:          docID,
+			Data:        make(map[string]interface{}),
+			Attachments: staged.Attachments,
+		}
+		if old != nil {
+			live.Rev = old.Rev
+		}
+		for k, v := range staged.Data {
+			if !strings.HasPrefix(k, "_") {
+				live.Data[k] = v
+			}
+		}
+		rev, err = tx.PutDocument(ctx, live)
+		if err != nil {
+			return err
+		}
+
+		// the replaced live indices are removed by the cleanup task
+		tx.indicesOrphaned = tx.indicesOrphaned || len(promoted) > 0
+
+		// the live indices continue at the checkpoints of the staged indices
+		for _, p := range promoted {
+			if p.checkpoint {
+				setIndexSeq(tx, p.live, p.seq)
+			} else {
+				tx.Delete(indexSeqBucket, []byte(p.live))
+			}
+			tx.Delete(indexSeqBucket, []byte(p.staged))
+		}
+
+		_, err = tx.DeleteDocument(ctx, staged.ID, staged.Rev)
+		return err
+	})
+	if err != nil {
+		// restore the indices of the failed promotion
+		d.indicesMu.Lock()
+		for _, p := range promoted {
+			if !p.swapped {
+				continue
+			}
+			d.indices[p.staged] = p.idx
+			d.indexSources.Store(p.staged, p.source)
+			if p.async {
+				d.asyncIndices.Store(p.staged, true)
+			}
+			if p.prev != nil {
+				d.indices[p.live] = p.prev
+				d.indexSources.Store(p.live, p.prevSource)
+			} else {
+				delete(d.indices, p.live)
+				d.indexSources.Delete(p.live)
+			}
+			if p.prevAsync {
+				d.asyncIndices.Store(p.live, true)
+			} else {
+				d.asyncIndices.Delete(p.live)
+			}
+		}
+		d.indicesMu.Unlock()
+		return "", err
+	}
+
+	// the cleanup task can only remove the directories
+	// of closed search indices
+	for _, p := range promoted {
+		if c, ok := p.prev.(io.Closer); ok {
+			if err := c.Close(); err != nil {
+				d.logger.Warnf(ctx, "failed to close replaced index", "index", p.live, "error", err)
+			}
+		}
+	}
+
+	d.NotifyDocumentUpdate(live)
+	return rev, nil
+}
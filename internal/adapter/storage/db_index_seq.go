@@ -0,0 +1,184 @@
+package storage
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/goydb/goydb/internal/adapter/index"
+	"github.com/goydb/goydb/pkg/model"
+	"github.com/goydb/goydb/pkg/port"
+)
+
+// indexSeqBucket contains the checkpoints of the indices that are
+// building or async: the sequence of the last change of the _changes
+// index that was processed. Indices without checkpoint processed all
+// changes and are updated with every document write.
+var indexSeqBucket = []byte("index_seqs")
+
+// waitIndexInterval is the interval WaitIndex checks the checkpoint
+const waitIndexInterval = 50 * time.Millisecond
+
+// ErrIndexBuildCanceled is returned by WaitIndex if the index is
+// building without a build task, e.g. the task was canceled by an
+// admin or failed too often. Saving the design document again
+// resumes the build.
+var ErrIndexBuildCanceled = errors.New("index build was canceled")
+
+// ErrIndexBuildPaused is returned by WaitIndex if the build task
+// of the index was paused by an admin
+var ErrIndexBuildPaused = errors.New("index build is paused")
+
+// indexSeq returns the checkpoint of the index, false if the
+// index has no checkpoint
+func indexSeq(tx port.EngineReadTransaction, name string) (uint64, bool) {
+	v, err := tx.Get(indexSeqBucket, []byte(name))
+	if err != nil || len(v) != 8 {
+		return 0, false
+	}
+	return binary.BigEndian.Uint64(v), true
+}
+
+// setIndexSeq stores the checkpoint of a building index
+func setIndexSeq(tx port.EngineWriteTransaction, name string, seq uint64) {
+	v := make([]byte, 8)
+	binary.BigEndian.PutUint64(v, seq)
+	tx.EnsureBucket(indexSeqBucket)
+	tx.Put(indexSeqBucket, []byte(name), v)
+}
+
+// IndexSeq returns the checkpoint of the index, false if the
+// index processed all changes and is updated with every write
+func (tx *Transaction) IndexSeq(name string) (uint64, bool) {
+	return indexSeq(tx, name)
+}
+
+// SetIndexSeq stores the checkpoint of the building index
+func (tx *Transaction) SetIndexSeq(name string, seq uint64) {
+	setIndexSeq(tx, name, seq)
+}
+
+// IndexBuilt removes the checkpoint of the index after
+// it processed all changes, async indices keep it
+func (tx *Transaction) IndexBuilt(name string) {
+	if tx.Database.isAsyncIndex(name) {
+		return
+	}
+	tx.Delete(indexSeqBucket, []byte(name))
+}
+
+// ChangesSince returns up to limit documents changed after the
+// sequence and the sequence of the last returned change
+func (tx *Transaction) ChangesSince(ctx context.Context, since uint64, limit int) ([]*model.Document, uint64, error) {
+	var docs []*model.Document
+	last := since
+
+	c := tx.Cursor([]byte(index.ChangesIndexName))
+	start := make([]byte, 8)
+	binary.BigEndian.PutUint64(start, since+1)
+	for k, v := c.Seek(start); k != nil && len(docs) < limit; k, v = c.Next() {
+		last = binary.BigEndian.Uint64(k)
+		doc, err := tx.GetDocument(ctx, string(v))
+		if err != nil {
+			return nil, 0, err
+		}
+		if doc != nil {
+			docs = append(docs, doc)
+		}
+	}
+
+	return docs, last, nil
+}
+
+// CountChangesSince returns the number of changes after the sequence
+func (tx *Transaction) CountChangesSince(since uint64) int {
+	var n int
+	c := tx.Cursor([]byte(index.ChangesIndexName))
+	start := make([]byte, 8)
+	binary.BigEndian.PutUint64(start, since+1)
+	for k, _ := c.Seek(start); k != nil; k, _ = c.Next() {
+		n++
+	}
+	return n
+}
+
+// IndexSeq returns the sequence of the database changes the index
+// processed and whether the index is still building. Built indices
+// are at the current sequence of the database.
+func (d *Database) IndexSeq(ctx context.Context, name string) (seq uint64, building bool, err error) {
+	var current uint64
+	seq, current, err = d.indexState(name)
+	return seq, seq < current, err
+}
+
+// indexState returns the checkpoint of the index and the
+// current sequence of the database
+func (d *Database) indexState(name string) (seq, current uint64, err error) {
+	err = d.db.ReadTransaction(func(tx port.EngineReadTransaction) error {
+		current = tx.Sequence([]byte(index.ChangesIndexName))
+		var ok bool
+		seq, ok = indexSeq(tx, name)
+		if !ok {
+			seq = current
+		}
+		return nil
+	})
+	return
+}
+
+// indexBuildErr returns ErrIndexBuildCanceled if the building index
+// has no build task and ErrIndexBuildPaused if the task is paused.
+// Async indices are built without tasks.
+func (d *Database) indexBuildErr(tx port.EngineReadTransaction, name string) error {
+	if d.isAsyncIndex(name) {
+		return nil
+	}
+	tasks, err := readTasks(tx)
+	if err != nil {
+		return err
+	}
+	for _, task := range tasks {
+		if task.DesignDocFn != name || task.Action == model.ActionViewCleanup {
+			continue
+		}
+		if task.Paused {
+			return fmt.Errorf("%w: %s", ErrIndexBuildPaused, name)
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrIndexBuildCanceled, name)
+}
+
+// WaitIndex blocks until the index processed all changes that
+// were made before the call or the context is done. It fails
+// if the build of the index was canceled or paused.
+func (d *Database) WaitIndex(ctx context.Context, name string) error {
+	t := time.NewTicker(waitIndexInterval)
+	defer t.Stop()
+	var target uint64
+	for i := 0; ; i++ {
+		seq, current, err := d.indexState(name)
+		if err != nil {
+			return err
+		}
+		if i == 0 {
+			target = current
+		}
+		if seq >= target {
+			return nil
+		}
+		err = d.db.ReadTransaction(func(tx port.EngineReadTransaction) error {
+			return d.indexBuildErr(tx, name)
+		})
+		if err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
@@ -165,8 +165,8 @@ func (i *Iterator) First() *model.Document {
 				continue
 			}
 
-			// skip local docs if requested (same behaviour as Next())
-			if i.SkipLocalDoc && doc.IsLocalDoc() {
+			// skip design and local docs if requested (same behaviour as Next())
+			if (i.SkipDesignDoc && doc.IsDesignDoc()) || (i.SkipLocalDoc && doc.IsLocalDoc()) {
 				if i.Descending {
 					i.key, v = i.cursor.Prev()
 				} else {
@@ -274,6 +274,15 @@ func (i *Iterator) Remaining() int {
 	return remaining
 }
 
+// Key returns a copy of the raw key of the current entry,
+// nil if the iteration ended
+func (i *Iterator) Key() []byte {
+	if i.key == nil {
+		return nil
+	}
+	return append([]byte(nil), i.key...)
+}
+
 func (i *Iterator) IncLimit() {
 	i.Limit++
 }
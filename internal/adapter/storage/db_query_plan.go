@@ -0,0 +1,193 @@
+package storage
+
+import (
+	"context"
+	"math"
+	"sort"
+
+	"github.com/goydb/goydb/internal/adapter/index"
+	"github.com/goydb/goydb/pkg/model"
+	"github.com/goydb/goydb/pkg/port"
+)
+
+// plannerSampleSize is the maximum number of index keys inspected
+// to estimate the key cardinality of a mango index.
+const plannerSampleSize = 1000
+
+// ExplainFind returns the plan that FindDocs would use to execute the query.
+func (d *Database) ExplainFind(ctx context.Context, query model.FindQuery) (*model.QueryPlan, error) {
+	var plan *model.QueryPlan
+	err := d.db.ReadTransaction(func(tx port.EngineReadTransaction) error {
+		plan, _ = d.planFind(ctx, tx, query)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// planCandidate is a mango index considered by the planner
+type planCandidate struct {
+	model.IndexCandidate
+	key      string
+	mi       *index.MangoIndex
+	values   []interface{}
+	excluded bool
+}
+
+// planFind selects the index used to execute the query. Mango indices
+// whose fields are all covered by equality conditions are usable, among
+// those the index with the lowest estimated cost is chosen. Ties are
+// broken by the number of fields and then by name, so the same query
+// always results in the same plan. The returned MangoIndex is nil if
+// the query has to be executed as a full scan.
+func (d *Database) planFind(ctx context.Context, tx port.EngineReadTransaction, query model.FindQuery) (*model.QueryPlan, *index.MangoIndex) {
+	eqFields := query.EqConditions()
+	hasSort := len(query.Sort) > 0
+	hintDdoc, hintName, hinted := query.UseIndexHint()
+
+	totalDocs := tx.BucketStats(model.DocsBucket).Keys
+	allDocs := model.IndexCandidate{
+		Index:         model.NewAllDocsQueryIndex(),
+		Analysis:      model.CandidateAnalysis{Usable: true},
+		EstimatedRows: totalDocs,
+		EstimatedCost: float64(totalDocs),
+	}
+
+	var usable, unusable []*planCandidate
+	for key, idx := range d.Indices() {
+		mi, ok := idx.(*index.MangoIndex)
+		if !ok {
+			continue
+		}
+		ddfn, err := model.ParseDesignDocFn(key)
+		if err != nil {
+			continue
+		}
+		fields := mi.Fields()
+		c := &planCandidate{
+			key: key,
+			mi:  mi,
+			IndexCandidate: model.IndexCandidate{
+				Index: model.NewJSONQueryIndex(&model.MangoIndex{
+					Name:   ddfn.FnName,
+					Ddoc:   string(model.DesignDocPrefix) + ddfn.DesignDocID,
+					Fields: fields,
+				}),
+			},
+		}
+		covering := false
+		c.Analysis.Covering = &covering
+
+		if hinted && (ddfn.DesignDocID != hintDdoc || (hintName != "" && ddfn.FnName != hintName)) {
+			c.excluded = true
+			c.addReason(model.ReasonExcludedByUser)
+		}
+
+		// every index field needs an equality condition
+		values := make([]interface{}, len(fields))
+		covered := len(fields) > 0
+		for i, f := range fields {
+			v, ok := eqFields[f]
+			if !ok {
+				covered = false
+				break
+			}
+			values[i] = v
+		}
+		if !covered {
+			c.addReason(model.ReasonFieldMismatch)
+			unusable = append(unusable, c)
+			continue
+		}
+		if hasSort {
+			c.addReason(model.ReasonSortOrderMismatch)
+			unusable = append(unusable, c)
+			continue
+		}
+
+		keys, distinct := mi.SampleCardinality(ctx, tx, plannerSampleSize)
+		if distinct > 0 {
+			c.EstimatedRows = (keys + distinct - 1) / distinct
+		}
+		c.EstimatedCost = float64(c.EstimatedRows) + math.Log2(float64(keys)+1)
+		c.Analysis.Usable = true
+		c.values = values
+		usable = append(usable, c)
+	}
+
+	// candidates that honor the use_index hint come first, then by cost
+	sort.Slice(usable, func(i, j int) bool {
+		a, b := usable[i], usable[j]
+		if a.excluded != b.excluded {
+			return !a.excluded
+		}
+		if a.EstimatedCost != b.EstimatedCost {
+			return a.EstimatedCost < b.EstimatedCost
+		}
+		if len(a.values) != len(b.values) {
+			return len(a.values) > len(b.values)
+		}
+		return a.key < b.key
+	})
+	sort.Slice(unusable, func(i, j int) bool {
+		return unusable[i].key < unusable[j].key
+	})
+
+	plan := &model.QueryPlan{Index: allDocs}
+	var selected *planCandidate
+	if len(usable) > 0 {
+		selected = usable[0]
+		usable = usable[1:]
+		selected.Analysis.Reasons = nil
+		plan.Index = selected.IndexCandidate
+		plan.Selected = &model.MangoIndex{
+			Name:   selected.Index.Name,
+			Ddoc:   *selected.Index.Ddoc,
+			Fields: selected.mi.Fields(),
+		}
+		plan.Values = selected.values
+	}
+
+	if hinted && (selected == nil || selected.excluded) {
+		hint := string(model.DesignDocPrefix) + hintDdoc
+		if hintName != "" {
+			hint += ", " + hintName
+		}
+		plan.Warning = hint + " was not used because it does not contain a valid index for this query."
+	}
+
+	for _, c := range usable {
+		if !c.excluded {
+			switch {
+			case c.EstimatedCost > selected.EstimatedCost:
+				c.addReason(model.ReasonHigherCost)
+			case len(c.values) < len(selected.values):
+				c.addReason(model.ReasonLessOverlap)
+			default:
+				c.addReason(model.ReasonAlphabeticallyAfter)
+			}
+		}
+		plan.Candidates = append(plan.Candidates, c.IndexCandidate)
+	}
+	if selected != nil {
+		allDocs.Analysis.Reasons = []model.CandidateReason{{Name: model.ReasonUnfavoredType}}
+		plan.Candidates = append(plan.Candidates, allDocs)
+	}
+	for _, c := range unusable {
+		plan.Candidates = append(plan.Candidates, c.IndexCandidate)
+	}
+	for i := range plan.Candidates {
+		plan.Candidates[i].Analysis.Ranking = i + 1
+	}
+
+	if selected == nil {
+		return plan, nil
+	}
+	return plan, selected.mi
+}
+
+func (c *planCandidate) addReason(name string) {
+	c.Analysis.Reasons = append(c.Analysis.Reasons, model.CandidateReason{Name: name})
+}
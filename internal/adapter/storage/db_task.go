@@ -2,6 +2,8 @@ package storage
 
 import (
 	"context"
+	"errors"
+	"sort"
 	"time"
 
 	"github.com/fxamacker/cbor/v2"
@@ -22,6 +24,9 @@ func (d *Database) AddTasksTx(ctx context.Context, tx port.EngineWriteTransactio
 	tx.EnsureBucket(taskBucket)
 
 	for _, task := range tasks {
+		if task.CreatedAt.IsZero() {
+			task.CreatedAt = time.Now()
+		}
 		data, err := bson.Marshal(task)
 		if err != nil {
 			return err
@@ -36,37 +41,60 @@ func (d *Database) AddTasksTx(ctx context.Context, tx port.EngineWriteTransactio
 		})
 	}
 
+	// the scheduler is signaled after the commit, see rawTx
+	if t, ok := tx.(*Transaction); ok {
+		t.tasksQueued = true
+	} else {
+		d.scheduler.Schedule(d.Name(), time.Now(), 0)
+	}
+
 	return nil
 }
 
+// GetTasks returns the next tasks that can be processed and marks them
+// as active. Tasks with a higher priority are returned first, paused
+// tasks and tasks waiting for a retry are skipped.
 func (d *Database) GetTasks(ctx context.Context, count int) ([]*model.Task, error) {
+	// the write transaction is only started if a task can be
+	// processed, most databases are scheduled by changed documents
+	runnable := false
+	err := d.db.ReadTransaction(func(tx port.EngineReadTransaction) error {
+		queued, err := readTasks(tx)
+		now := time.Now()
+		for _, task := range queued {
+			if task.Runnable(now) {
+				runnable = true
+				break
+			}
+		}
+		return err
+	})
+	if err != nil || !runnable {
+		return nil, err
+	}
+
 	var tasks []*model.Task
-	err := d.db.WriteTransaction(d.logger, func(tx port.EngineWriteTransaction) error {
-		c := tx.Cursor(taskBucket)
+	err = d.db.WriteTransaction(d.logger, func(tx port.EngineWriteTransaction) error {
+		queued, err := readTasks(tx)
+		if err != nil {
+			return err
+		}
 
-		i := 0
-		for k, v := c.First(); k != nil && i < count; k, v = c.Next() {
-			var task = new(model.Task)
-			err := bson.Unmarshal(v, task)
-			if err != nil {
-				return err
+		now := time.Now()
+		for _, task := range queued {
+			if len(tasks) == count {
+				break
 			}
-			err = cbor.Unmarshal(k, &task.ID)
-			if err != nil {
-				return err
+			if !task.Runnable(now) {
+				continue
 			}
-			// not active since 5 minutes
-			//if task.ActiveSince.Before(time.Now().Add(time.Minute * 5)) {
-			task.ActiveSince = time.Now()
-			data, err := bson.Marshal(task)
+			task.ActiveSince = now
+			err := putTask(tx, task)
 			if err != nil {
 				return err
 			}
-			tx.Put(taskBucket, k, data)
 
 			tasks = append(tasks, task)
-			//}
-			i++
 		}
 
 		return nil
@@ -75,49 +103,189 @@ func (d *Database) GetTasks(ctx context.Context, count int) ([]*model.Task, erro
 }
 
 func (d *Database) UpdateTask(ctx context.Context, task *model.Task) error {
-	task.UpdatedAt = time.Now()
+	var paused bool
+	err := d.db.WriteTransaction(d.logger, func(tx port.EngineWriteTransaction) error {
+		stored, err := getTask(tx, task.ID)
+		if err != nil {
+			return err
+		}
 
-	data, err := bson.Marshal(task)
-	if err != nil {
-		return err
+		// keep the controls that were changed by admins
+		task.Paused = stored.Paused
+		task.Priority = stored.Priority
+		task.UpdatedAt = time.Now()
+		// renew the lease of the task, paused tasks are released
+		if task.Paused {
+			task.ActiveSince = time.Time{}
+		} else if !task.ActiveSince.IsZero() {
+			task.ActiveSince = task.UpdatedAt
+		}
+		paused = task.Paused
+		return putTask(tx, task)
+	})
+	if errors.Is(err, ErrNotFound) {
+		return port.ErrTaskCanceled
 	}
-	key, err := cbor.Marshal(task.ID)
-	if err != nil {
-		return err
+	if err == nil && paused {
+		return port.ErrTaskPaused
 	}
 
-	err = d.db.WriteTransaction(d.logger, func(tx port.EngineWriteTransaction) error {
-		tx.Put(taskBucket, key, data)
+	return err
+}
+
+// CancelTask removes the task, a running task stops
+// after its current batch
+func (d *Database) CancelTask(ctx context.Context, id uint64) error {
+	return d.db.WriteTransaction(d.logger, func(tx port.EngineWriteTransaction) error {
+		_, err := getTask(tx, id)
+		if err != nil {
+			return err
+		}
+		key, err := cbor.Marshal(id)
+		if err != nil {
+			return err
+		}
+		tx.Delete(taskBucket, key)
 		return nil
 	})
+}
 
-	return err
+// ControlTask pauses, resumes or reprioritizes the task, a
+// running task is paused after its current batch
+func (d *Database) ControlTask(ctx context.Context, id uint64, controls model.TaskControls) (*model.Task, error) {
+	var task *model.Task
+	err := d.db.WriteTransaction(d.logger, func(tx port.EngineWriteTransaction) error {
+		var err error
+		task, err = getTask(tx, id)
+		if err != nil {
+			return err
+		}
+		if controls.Paused != nil {
+			task.Paused = *controls.Paused
+		}
+		if controls.Priority != nil {
+			task.Priority = *controls.Priority
+		}
+		return putTask(tx, task)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// resumed or reprioritized tasks are scheduled again
+	return task, d.ScheduleTasks(ctx)
+}
+
+// ScheduleTasks queues the database in the task scheduler at the time
+// its next task can be processed, with the highest priority of its tasks
+func (d *Database) ScheduleTasks(ctx context.Context) error {
+	return d.db.ReadTransaction(func(tx port.EngineReadTransaction) error {
+		return scheduleTasks(tx, d.scheduler, d.Name())
+	})
+}
+
+// scheduleTasks queues the database name if it has tasks
+// that aren't paused
+func scheduleTasks(tx port.EngineReadTransaction, scheduler *TaskScheduler, name string) error {
+	tasks, err := readTasks(tx)
+	if err != nil {
+		return err
+	}
+
+	var at time.Time
+	var priority int
+	runnable := false
+	for _, task := range tasks {
+		if task.Paused {
+			continue
+		}
+		// the tasks are sorted by priority
+		if !runnable {
+			at, priority, runnable = task.RunnableAt(), task.Priority, true
+		} else if task.RunnableAt().Before(at) {
+			at = task.RunnableAt()
+		}
+	}
+	if runnable {
+		scheduler.Schedule(name, at, priority)
+	}
+	return nil
 }
 
+// PeekTasks returns the tasks in the order they are processed
+// without marking them as active
 func (d *Database) PeekTasks(ctx context.Context, count int) ([]*model.Task, error) {
 	var tasks []*model.Task
 	err := d.db.ReadTransaction(func(tx port.EngineReadTransaction) error {
-		c := tx.Cursor(taskBucket)
-
-		i := 0
-		for k, v := c.First(); k != nil && i < count; k, v = c.Next() {
-			var task = new(model.Task)
-			err := bson.Unmarshal(v, task)
-			if err != nil {
-				return err
-			}
-			err = cbor.Unmarshal(k, &task.ID)
-			if err != nil {
-				return err
-			}
+		var err error
+		tasks, err = readTasks(tx)
+		if len(tasks) > count {
+			tasks = tasks[:count]
+		}
+		return err
+	})
+	return tasks, err
+}
 
-			tasks = append(tasks, task)
-			i++
+// readTasks returns all queued tasks, tasks with a higher priority
+// first, otherwise in the order they were queued
+func readTasks(tx port.EngineReadTransaction) ([]*model.Task, error) {
+	var tasks []*model.Task
+	c := tx.Cursor(taskBucket)
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		task, err := decodeTask(k, v)
+		if err != nil {
+			return nil, err
 		}
+		tasks = append(tasks, task)
+	}
 
-		return nil
+	sort.SliceStable(tasks, func(i, j int) bool {
+		return tasks[i].Priority > tasks[j].Priority
 	})
-	return tasks, err
+	return tasks, nil
+}
+
+// getTask returns the task with the id or ErrNotFound
+func getTask(tx port.EngineReadTransaction, id uint64) (*model.Task, error) {
+	key, err := cbor.Marshal(id)
+	if err != nil {
+		return nil, err
+	}
+	data, err := tx.Get(taskBucket, key)
+	if errors.Is(err, port.ErrNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeTask(key, data)
+}
+
+func decodeTask(key, data []byte) (*model.Task, error) {
+	var task = new(model.Task)
+	err := bson.Unmarshal(data, task)
+	if err != nil {
+		return nil, err
+	}
+	err = cbor.Unmarshal(key, &task.ID)
+	if err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+func putTask(tx port.EngineWriteTransaction, task *model.Task) error {
+	data, err := bson.Marshal(task)
+	if err != nil {
+		return err
+	}
+	key, err := cbor.Marshal(task.ID)
+	if err != nil {
+		return err
+	}
+	tx.Put(taskBucket, key, data)
+	return nil
 }
 
 func (d *Database) CompleteTasks(ctx context.Context, tasks []*model.Task) error {
@@ -24,11 +24,24 @@ import (
 var ErrNotFound = errors.New("resource not found")
 var ErrConflict = fmt.Errorf("rev doesn't match for update: %w", port.ErrConflict)
 var ErrUnknownDatabase = errors.New("unknown database")
+var ErrQuarantinedDatabase = errors.New("database is quarantined")
 
 type Transaction struct {
 	Database   *Database
 	BucketName []byte
 	port.EngineWriteTransaction
+
+	// indicesOrphaned is set if indices lost their function, e.g. the
+	// winner of a design document was deleted or a promotion replaced
+	// the indices, a task cleaning up the indices is queued
+	indicesOrphaned bool
+	// tasksQueued and documentsChanged signal the
+	// task scheduler after the commit
+	tasksQueued      bool
+	documentsChanged bool
+	// indexModes are the modes of the indices before they were
+	// switched, restored if the transaction fails (true = async)
+	indexModes map[string]bool
 }
 
 func (tx *Transaction) SetBucketName(bucketName []byte) {
@@ -172,7 +185,7 @@ func (tx *Transaction) PutDocument(ctx context.Context, doc *model.Document) (re
 
 	if oldDoc != nil {
 		// maintain indices - remove old value
-		for _, index := range tx.Database.Indices() {
+		for _, index := range tx.Database.syncIndices() {
 			err := index.DocumentDeleted(ctx, tx, oldDoc)
 			if err != nil {
 				return "", err
@@ -192,6 +205,7 @@ func (tx *Transaction) PutDocument(ctx context.Context, doc *model.Document) (re
 	_ = tx.putLeaf(doc) // non-critical; leaf is best-effort
 
 	if doc.IsDesignDoc() {
+		tx.indicesOrphaned = tx.indicesOrphaned || doc.Deleted
 		err = tx.Database.BuildDesignDocIndices(ctx, tx, doc, true)
 		if err != nil {
 			return
@@ -199,7 +213,8 @@ func (tx *Transaction) PutDocument(ctx context.Context, doc *model.Document) (re
 	}
 
 	// maintain Indices - add new value
-	for _, index := range tx.Database.Indices() {
+	tx.documentsChanged = true
+	for _, index := range tx.Database.syncIndices() {
 		err = index.DocumentStored(ctx, tx, doc)
 		if err != nil {
 			return
@@ -405,7 +420,7 @@ func (tx *Transaction) PutDocumentForReplication(ctx context.Context, doc *model
 	}
 	if winnerDoc != nil && winner != currentWinnerRev {
 		if oldDoc != nil {
-			for _, idx := range tx.Database.Indices() {
+			for _, idx := range tx.Database.syncIndices() {
 				if err := idx.DocumentDeleted(ctx, tx, oldDoc); err != nil {
 					return err
 				}
@@ -415,11 +430,13 @@ func (tx *Transaction) PutDocumentForReplication(ctx context.Context, doc *model
 			return err
 		}
 		if winnerDoc.IsDesignDoc() {
+			tx.indicesOrphaned = tx.indicesOrphaned || winnerDoc.Deleted
 			if err := tx.Database.BuildDesignDocIndices(ctx, tx, winnerDoc, true); err != nil {
 				return err
 			}
 		}
-		for _, idx := range tx.Database.Indices() {
+		tx.documentsChanged = true
+		for _, idx := range tx.Database.syncIndices() {
 			if err := idx.DocumentStored(ctx, tx, winnerDoc); err != nil {
 				return err
 			}
@@ -533,7 +550,7 @@ func (tx *Transaction) DeleteDocument(ctx context.Context, docID, rev string) (*
 
 	// Update docs bucket and indices if the winner changed.
 	if winnerDoc != nil && winner != oldDoc.Rev {
-		for _, idx := range tx.Database.Indices() {
+		for _, idx := range tx.Database.syncIndices() {
 			if err := idx.DocumentDeleted(ctx, tx, oldDoc); err != nil {
 				return nil, err
 			}
@@ -542,11 +559,13 @@ func (tx *Transaction) DeleteDocument(ctx context.Context, docID, rev string) (*
 			return nil, err
 		}
 		if winnerDoc.IsDesignDoc() {
+			tx.indicesOrphaned = tx.indicesOrphaned || winnerDoc.Deleted
 			if err := tx.Database.BuildDesignDocIndices(ctx, tx, winnerDoc, true); err != nil {
 				return nil, err
 			}
 		}
-		for _, idx := range tx.Database.Indices() {
+		tx.documentsChanged = true
+		for _, idx := range tx.Database.syncIndices() {
 			if err := idx.DocumentStored(ctx, tx, winnerDoc); err != nil {
 				return nil, err
 			}
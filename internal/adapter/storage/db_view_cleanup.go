@@ -0,0 +1,162 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/goydb/goydb/pkg/model"
+	"github.com/goydb/goydb/pkg/port"
+)
+
+// indexFnTypes are the function types that have indices
+var indexFnTypes = map[model.FnType]bool{
+	model.ViewFn:   true,
+	model.SearchFn: true,
+	model.MangoFn:  true,
+}
+
+// indexBucketSuffixes are the suffixes of the buckets an index keeps
+// next to its main bucket, e.g. "views:app:by_n:invalidation"
+var indexBucketSuffixes = []string{"", ":invalidation", ":changed", ":inv"}
+
+// isIndexBucket returns true if the bucket is a bucket of an index
+func isIndexBucket(bucket string) bool {
+	fnType, _, ok := strings.Cut(bucket, ":")
+	return ok && indexFnTypes[model.FnType(fnType)]
+}
+
+// indexBucketOwned returns true if the bucket belongs to one of the
+// index buckets. Design document IDs and function names can contain
+// colons, the names are matched instead of parsed from the bucket.
+func indexBucketOwned(bucket string, names map[string]bool) bool {
+	for _, suffix := range indexBucketSuffixes {
+		if name, ok := strings.CutSuffix(bucket, suffix); ok && names[name] {
+			return true
+		}
+	}
+	return false
+}
+
+// ViewCleanup removes the indices that don't belong to a function of
+// a design document anymore: the buckets, the search index directories
+// and the checkpoints. Returns the number of bytes freed.
+func (d *Database) ViewCleanup(ctx context.Context) (int64, error) {
+	var freed int64
+	var removed []port.DocumentIndex
+	live := make(map[string]bool)    // index names
+	buckets := make(map[string]bool) // names of the index buckets
+
+	err := d.rawTx(func(tx *Transaction) error {
+		c := tx.Cursor(model.DocsBucket)
+		prefix := []byte(model.DesignDocPrefix)
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			doc, err := tx.GetDocument(ctx, string(k))
+			if err != nil {
+				return err
+			}
+			if doc == nil || doc.Deleted {
+				continue
+			}
+			for _, f := range doc.Functions() {
+				ddfn := f.DesignDocFn()
+				if !indexFnTypes[ddfn.Type] {
+					continue
+				}
+				name := ddfn.String()
+				live[name] = true
+				if v, err := tx.Get(indexBucketsBucket, []byte(name)); err == nil {
+					name = string(v)
+				}
+				buckets[name] = true
+			}
+		}
+
+		for _, bucket := range tx.Buckets() {
+			if !isIndexBucket(string(bucket)) || indexBucketOwned(string(bucket), buckets) {
+				continue
+			}
+			freed += int64(tx.BucketStats(bucket).Allocated)
+			tx.DeleteBucket(bucket)
+		}
+
+		for _, bucket := range [][]byte{indexSeqBucket, indexBucketsBucket} {
+			c := tx.Cursor(bucket)
+			for k, _ := c.First(); k != nil; k, _ = c.Next() {
+				if !live[string(k)] {
+					tx.Delete(bucket, k)
+				}
+			}
+		}
+
+		d.indicesMu.Lock()
+		for name, idx := range d.indices {
+			if isIndexBucket(name) && !live[name] {
+				removed = append(removed, idx)
+				delete(d.indices, name)
+				d.indexSources.Delete(name)
+				d.asyncIndices.Delete(name)
+				d.dbCopies.Delete(name)
+			}
+		}
+		d.indicesMu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	// search indices
+	dir := filepath.Join(d.databaseDir, SearchDir)
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return freed, err
+	}
+	var orphaned []string
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), indexExt)
+		if name == entry.Name() || buckets[name] {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		freed += dirSize(path)
+		orphaned = append(orphaned, path)
+	}
+
+	// search indices have to be closed before their directories are removed
+	for _, idx := range removed {
+		if _, ok := idx.(port.Searcher); !ok {
+			continue
+		}
+		err := idx.Remove(ctx, nil)
+		if err != nil {
+			d.logger.Warnf(ctx, "failed to remove search index", "index", idx, "error", err)
+		}
+	}
+	for _, path := range orphaned {
+		err := os.RemoveAll(path)
+		if err != nil {
+			return freed, err
+		}
+	}
+
+	return freed, nil
+}
+
+// dirSize returns the size of all files in the directory
+func dirSize(path string) int64 {
+	var size int64
+	_ = filepath.WalkDir(path, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return nil
+		}
+		if info, err := entry.Info(); err == nil {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
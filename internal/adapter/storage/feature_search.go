@@ -9,7 +9,7 @@ import (
 )
 
 func init() {
-	RegisterSearchIndexFactory(func(ddfn *model.DesignDocFn, engines port.ViewEngines, path string, logger port.Logger) port.DocumentIndexSourceUpdate {
+	RegisterSearchIndexFactory(func(ddfn *model.DesignDocFn, engines map[string]port.DesignDocBuilder[port.ViewServer], path string, logger port.Logger) port.DocumentIndexSourceUpdate {
 		return index.NewExternalSearchIndex(ddfn, engines, path, logger)
 	})
 }
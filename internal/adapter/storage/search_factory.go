@@ -6,7 +6,7 @@ import (
 )
 
 // SearchIndexFactory creates a search index for a design document function.
-type SearchIndexFactory func(ddfn *model.DesignDocFn, engines port.ViewEngines, path string, logger port.Logger) port.DocumentIndexSourceUpdate
+type SearchIndexFactory func(ddfn *model.DesignDocFn, engines map[string]port.DesignDocBuilder[port.ViewServer], path string, logger port.Logger) port.DocumentIndexSourceUpdate
 
 var searchIndexFactory SearchIndexFactory
 
@@ -7,19 +7,32 @@ import (
 	"path"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/goydb/goydb/pkg/port"
 )
 
 type Storage struct {
-	path            string
-	dbs             map[string]*Database
-	mu              sync.RWMutex
-	viewEngines     port.ViewEngines
-	filterEngines   port.FilterEngines
-	reducerEngines  port.ReducerEngines
-	validateEngines port.ValidateEngines
-	updateEngines   port.UpdateEngines
+	path string
+	// dbs are the loaded databases, known are all database files
+	// in the directory, they are loaded on first access
+	dbs         map[string]*Database
+	known       map[string]bool
+	quarantined map[string]*quarantine
+	loading     map[string]chan struct{}
+	mu          sync.RWMutex
+	handles     *handles
+	done        chan struct{}
+
+	viewEngines     map[string]port.DesignDocBuilder[port.ViewServer]
+	filterEngines   map[string]port.DesignDocBuilder[port.FilterServer]
+	reducerEngines  map[string]port.DesignDocBuilder[port.Reducer]
+	validateEngines map[string]port.DesignDocBuilder[port.ValidateServer]
+	updateEngines   map[string]port.DesignDocBuilder[port.UpdateServer]
+	showEngines     map[string]port.DesignDocBuilder[port.ShowServer]
+	listEngines     map[string]port.DesignDocBuilder[port.ListServer]
+	rewriteEngines  map[string]port.DesignDocBuilder[port.RewriteServer]
+	scheduler       *TaskScheduler
 	logger          port.Logger
 }
 
@@ -28,11 +41,17 @@ type StorageOption func(s *Storage) error
 func Open(path string, options ...StorageOption) (*Storage, error) {
 	s := &Storage{
 		path:            path,
-		viewEngines:     make(port.ViewEngines),
-		filterEngines:   make(port.FilterEngines),
-		reducerEngines:  make(port.ReducerEngines),
-		validateEngines: make(port.ValidateEngines),
-		updateEngines:   make(port.UpdateEngines),
+		viewEngines:     make(map[string]port.DesignDocBuilder[port.ViewServer]),
+		filterEngines:   make(map[string]port.DesignDocBuilder[port.FilterServer]),
+		reducerEngines:  make(map[string]port.DesignDocBuilder[port.Reducer]),
+		validateEngines: make(map[string]port.DesignDocBuilder[port.ValidateServer]),
+		updateEngines:   make(map[string]port.DesignDocBuilder[port.UpdateServer]),
+		showEngines:     make(map[string]port.DesignDocBuilder[port.ShowServer]),
+		listEngines:     make(map[string]port.DesignDocBuilder[port.ListServer]),
+		rewriteEngines:  make(map[string]port.DesignDocBuilder[port.RewriteServer]),
+		scheduler:       newTaskScheduler(),
+		handles:         newHandles(),
+		done:            make(chan struct{}),
 	}
 
 	for _, option := range options {
@@ -46,25 +65,53 @@ func Open(path string, options ...StorageOption) (*Storage, error) {
 	if err != nil {
 		return nil, err
 	}
+
+	// databases are unloaded with their closed files
+	s.handles.evicted = s.unloadDatabases
+
+	// tasks left over from a previous run
+	s.scheduleStoredTasks(context.Background())
+
+	if s.handles.idleTimeout > 0 {
+		go s.closeIdleDatabases(s.handles.idleTimeout / 2)
+	}
 	return s, nil
 }
 
+// closeIdleDatabases closes the files of databases that weren't
+// used for the idle timeout until the storage is closed
+func (s *Storage) closeIdleDatabases(interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case now := <-t.C:
+			s.handles.closeIdle(now)
+		}
+	}
+}
+
 func (s *Storage) Path() string { return s.path }
 
+// TaskScheduler returns the queue of databases with pending tasks
+func (s *Storage) TaskScheduler() port.TaskScheduler { return s.scheduler }
+
 func (s *Storage) String() string {
 	return "<Storage path=" + s.path + ">"
 }
 
+// ReloadDatabases scans the directory for database files, the
+// databases are opened on first access. The quarantine of the
+// databases is lifted, they are loaded on their next access.
 func (s *Storage) ReloadDatabases(ctx context.Context) error {
 	files, err := os.ReadDir(s.path)
 	if err != nil {
 		return err
 	}
 
-	s.mu.Lock()
-	s.dbs = make(map[string]*Database)
-	s.mu.Unlock()
-
+	known := make(map[string]bool)
 	for _, f := range files {
 		if f.IsDir() {
 			continue
@@ -74,20 +121,62 @@ func (s *Storage) ReloadDatabases(ctx context.Context) error {
 		if strings.Contains(f.Name(), ".") {
 			continue
 		}
+		known[path.Base(f.Name())] = true
+	}
+
+	s.mu.Lock()
+	if s.dbs == nil {
+		s.dbs = make(map[string]*Database)
+		s.loading = make(map[string]chan struct{})
+	}
+	for name := range s.dbs {
+		known[name] = true
+	}
+	s.known = known
+	s.quarantined = make(map[string]*quarantine)
+	s.mu.Unlock()
+
+	s.logger.Infof(ctx, "databases found", "count", len(known))
+	return nil
+}
+
+// scheduleStoredTasks queues the databases with tasks in the task
+// scheduler, their files are only opened to read the tasks, the
+// databases are loaded by the task workers
+func (s *Storage) scheduleStoredTasks(ctx context.Context) {
+	s.mu.RLock()
+	names := make([]string, 0, len(s.known))
+	for name := range s.known {
+		if _, ok := s.dbs[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	s.mu.RUnlock()
 
-		s.logger.Infof(ctx, "loading database", "name", path.Base(f.Name()))
-		database, err := s.CreateDatabase(ctx, path.Base(f.Name()))
+	for _, name := range names {
+		h := s.handles.handle(path.Join(s.path, name))
+		err := h.ReadTransaction(func(tx port.EngineReadTransaction) error {
+			return scheduleTasks(tx, s.scheduler, name)
+		})
+		if cerr := h.Close(); err == nil {
+			err = cerr
+		}
 		if err != nil {
-			s.logger.Warnf(ctx, "database load failed", "name", f.Name(), "error", err)
-			return err
+			// the database is quarantined by its first access
+			s.logger.Warnf(ctx, "failed to schedule tasks", "name", name, "error", err)
 		}
-		s.logger.Infof(ctx, "database loaded", "name", database.Name())
 	}
-
-	return nil
 }
 
 func (s *Storage) RegisterViewEngine(name string, builder port.ViewServerBuilder) error {
+	return s.RegisterViewDesignDocEngine(name, port.IgnoreDesignDoc(builder))
+}
+
+// RegisterViewDesignDocEngine registers the builder of the map
+// functions of the language name. Unlike RegisterViewEngine the builder
+// receives the design document, so that map functions can require the
+// modules stored under views.lib.
+func (s *Storage) RegisterViewDesignDocEngine(name string, builder port.DesignDocBuilder[port.ViewServer]) error {
 	if _, ok := s.viewEngines[name]; ok {
 		return fmt.Errorf("view engine with name %q already registered", name)
 	}
@@ -96,6 +185,13 @@ func (s *Storage) RegisterViewEngine(name string, builder port.ViewServerBuilder
 }
 
 func (s *Storage) RegisterFilterEngine(name string, builder port.FilterServerBuilder) error {
+	return s.RegisterFilterDesignDocEngine(name, port.IgnoreDesignDoc(builder))
+}
+
+// RegisterFilterDesignDocEngine registers the builder of the filter
+// functions used by _changes and replication, the builder receives the
+// design document that defines the filter.
+func (s *Storage) RegisterFilterDesignDocEngine(name string, builder port.DesignDocBuilder[port.FilterServer]) error {
 	if _, ok := s.filterEngines[name]; ok {
 		return fmt.Errorf("filter engine with name %q already registered", name)
 	}
@@ -104,6 +200,12 @@ func (s *Storage) RegisterFilterEngine(name string, builder port.FilterServerBui
 }
 
 func (s *Storage) RegisterReducerEngine(name string, builder port.ReducerServerBuilder) error {
+	return s.RegisterReducerDesignDocEngine(name, port.IgnoreDesignDoc(builder))
+}
+
+// RegisterReducerDesignDocEngine registers the builder of custom
+// reduce functions, built-in reducers like _sum never reach it.
+func (s *Storage) RegisterReducerDesignDocEngine(name string, builder port.DesignDocBuilder[port.Reducer]) error {
 	if _, ok := s.reducerEngines[name]; ok {
 		return fmt.Errorf("reducer engine with name %q already registered", name)
 	}
@@ -112,6 +214,12 @@ func (s *Storage) RegisterReducerEngine(name string, builder port.ReducerServerB
 }
 
 func (s *Storage) RegisterValidateEngine(name string, builder port.ValidateServerBuilder) error {
+	return s.RegisterValidateDesignDocEngine(name, port.IgnoreDesignDoc(builder))
+}
+
+// RegisterValidateDesignDocEngine registers the builder of the
+// validate_doc_update functions that are run on every document write.
+func (s *Storage) RegisterValidateDesignDocEngine(name string, builder port.DesignDocBuilder[port.ValidateServer]) error {
 	if _, ok := s.validateEngines[name]; ok {
 		return fmt.Errorf("validate engine with name %q already registered", name)
 	}
@@ -120,6 +228,12 @@ func (s *Storage) RegisterValidateEngine(name string, builder port.ValidateServe
 }
 
 func (s *Storage) RegisterUpdateEngine(name string, builder port.UpdateServerBuilder) error {
+	return s.RegisterUpdateDesignDocEngine(name, port.IgnoreDesignDoc(builder))
+}
+
+// RegisterUpdateDesignDocEngine registers the builder of the update
+// handlers served by _update.
+func (s *Storage) RegisterUpdateDesignDocEngine(name string, builder port.DesignDocBuilder[port.UpdateServer]) error {
 	if _, ok := s.updateEngines[name]; ok {
 		return fmt.Errorf("update engine with name %q already registered", name)
 	}
@@ -127,16 +241,40 @@ func (s *Storage) RegisterUpdateEngine(name string, builder port.UpdateServerBui
 	return nil
 }
 
+func (s *Storage) RegisterShowEngine(name string, builder port.ShowServerBuilder) error {
+	return s.RegisterShowDesignDocEngine(name, port.IgnoreDesignDoc(builder))
+}
+
+// RegisterShowDesignDocEngine registers the builder of the show
+// functions served by _show.
+func (s *Storage) RegisterShowDesignDocEngine(name string, builder port.DesignDocBuilder[port.ShowServer]) error {
+	if _, ok := s.showEngines[name]; ok {
+		return fmt.Errorf("show engine with name %q already registered", name)
+	}
+	s.showEngines[name] = builder
+	return nil
+}
+
 func (s *Storage) Close() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	select {
+	case <-s.done:
+	default:
+		close(s.done)
+	}
+
 	for name, db := range s.dbs {
+		db.close()
+		db.closeIndices(context.Background())
 		// TODO: check on better options
 		err := db.db.Close()
 		if err != nil {
 			return fmt.Errorf("failed to close db %q: %w", name, err)
 		}
+		// requests still running don't unload the closed database again
+		delete(s.dbs, name)
 	}
 
 	return nil
@@ -148,30 +286,142 @@ func WithViewEngine(name string, builder port.ViewServerBuilder) StorageOption {
 	}
 }
 
+func WithViewDesignDocEngine(name string, builder port.DesignDocBuilder[port.ViewServer]) StorageOption {
+	return func(s *Storage) error {
+		return s.RegisterViewDesignDocEngine(name, builder)
+	}
+}
+
 func WithFilterEngine(name string, builder port.FilterServerBuilder) StorageOption {
 	return func(s *Storage) error {
 		return s.RegisterFilterEngine(name, builder)
 	}
 }
 
+func WithFilterDesignDocEngine(name string, builder port.DesignDocBuilder[port.FilterServer]) StorageOption {
+	return func(s *Storage) error {
+		return s.RegisterFilterDesignDocEngine(name, builder)
+	}
+}
+
 func WithReducerEngine(name string, builder port.ReducerServerBuilder) StorageOption {
 	return func(s *Storage) error {
 		return s.RegisterReducerEngine(name, builder)
 	}
 }
 
+func WithReducerDesignDocEngine(name string, builder port.DesignDocBuilder[port.Reducer]) StorageOption {
+	return func(s *Storage) error {
+		return s.RegisterReducerDesignDocEngine(name, builder)
+	}
+}
+
 func WithValidateEngine(name string, builder port.ValidateServerBuilder) StorageOption {
 	return func(s *Storage) error {
 		return s.RegisterValidateEngine(name, builder)
 	}
 }
 
+func WithValidateDesignDocEngine(name string, builder port.DesignDocBuilder[port.ValidateServer]) StorageOption {
+	return func(s *Storage) error {
+		return s.RegisterValidateDesignDocEngine(name, builder)
+	}
+}
+
 func WithUpdateEngine(name string, builder port.UpdateServerBuilder) StorageOption {
 	return func(s *Storage) error {
 		return s.RegisterUpdateEngine(name, builder)
 	}
 }
 
+func WithUpdateDesignDocEngine(name string, builder port.DesignDocBuilder[port.UpdateServer]) StorageOption {
+	return func(s *Storage) error {
+		return s.RegisterUpdateDesignDocEngine(name, builder)
+	}
+}
+
+func WithShowEngine(name string, builder port.ShowServerBuilder) StorageOption {
+	return func(s *Storage) error {
+		return s.RegisterShowEngine(name, builder)
+	}
+}
+
+func WithShowDesignDocEngine(name string, builder port.DesignDocBuilder[port.ShowServer]) StorageOption {
+	return func(s *Storage) error {
+		return s.RegisterShowDesignDocEngine(name, builder)
+	}
+}
+
+func (s *Storage) RegisterListEngine(name string, builder port.ListServerBuilder) error {
+	return s.RegisterListDesignDocEngine(name, port.IgnoreDesignDoc(builder))
+}
+
+// RegisterListDesignDocEngine registers the builder of the list
+// functions that render view rows under _list.
+func (s *Storage) RegisterListDesignDocEngine(name string, builder port.DesignDocBuilder[port.ListServer]) error {
+	if _, ok := s.listEngines[name]; ok {
+		return fmt.Errorf("list engine with name %q already registered", name)
+	}
+	s.listEngines[name] = builder
+	return nil
+}
+
+func WithListEngine(name string, builder port.ListServerBuilder) StorageOption {
+	return func(s *Storage) error {
+		return s.RegisterListEngine(name, builder)
+	}
+}
+
+func WithListDesignDocEngine(name string, builder port.DesignDocBuilder[port.ListServer]) StorageOption {
+	return func(s *Storage) error {
+		return s.RegisterListDesignDocEngine(name, builder)
+	}
+}
+
+func (s *Storage) RegisterRewriteEngine(name string, builder port.RewriteServerBuilder) error {
+	return s.RegisterRewriteDesignDocEngine(name, port.IgnoreDesignDoc(builder))
+}
+
+// RegisterRewriteDesignDocEngine registers the builder of rewrite
+// functions, used when the rewrites of a design document are a string.
+func (s *Storage) RegisterRewriteDesignDocEngine(name string, builder port.DesignDocBuilder[port.RewriteServer]) error {
+	if _, ok := s.rewriteEngines[name]; ok {
+		return fmt.Errorf("rewrite engine with name %q already registered", name)
+	}
+	s.rewriteEngines[name] = builder
+	return nil
+}
+
+func WithRewriteEngine(name string, builder port.RewriteServerBuilder) StorageOption {
+	return func(s *Storage) error {
+		return s.RegisterRewriteEngine(name, builder)
+	}
+}
+
+func WithRewriteDesignDocEngine(name string, builder port.DesignDocBuilder[port.RewriteServer]) StorageOption {
+	return func(s *Storage) error {
+		return s.RegisterRewriteDesignDocEngine(name, builder)
+	}
+}
+
+// WithMaxOpenDatabases limits the number of open database files, the
+// least recently used idle files are closed first. 0 is unlimited.
+func WithMaxOpenDatabases(max int) StorageOption {
+	return func(s *Storage) error {
+		s.handles.max = max
+		return nil
+	}
+}
+
+// WithIdleTimeout closes database files that weren't used for the
+// duration, they are opened again on the next access. 0 keeps them open.
+func WithIdleTimeout(timeout time.Duration) StorageOption {
+	return func(s *Storage) error {
+		s.handles.idleTimeout = timeout
+		return nil
+	}
+}
+
 func WithLogger(logger port.Logger) StorageOption {
 	return func(s *Storage) error {
 		s.logger = logger
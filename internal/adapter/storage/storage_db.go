@@ -3,12 +3,13 @@ package storage
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"path"
 	"strconv"
 	"sync"
+	"time"
 
-	"github.com/goydb/goydb/internal/adapter/bbolt_engine"
 	"github.com/goydb/goydb/internal/adapter/index"
 	"github.com/goydb/goydb/pkg/model"
 	"github.com/goydb/goydb/pkg/port"
@@ -21,21 +22,88 @@ type Database struct {
 
 	listener sync.Map
 
+	// users are the contexts of the requests and tasks that hold the
+	// database, it isn't unloaded while they are running
+	usersMu sync.Mutex
+	users   map[context.Context]struct{}
+	// unused is called after the last user released the database
+	unused func(d *Database)
+	// done is closed when the database is unloaded or deleted
+	done     chan struct{}
+	doneOnce sync.Once
+
+	indicesMu       sync.RWMutex
 	indices         map[string]port.DocumentIndex
-	viewEngines     map[string]port.ViewServerBuilder
-	filterEngines   map[string]port.FilterServerBuilder
-	reducerEngines  map[string]port.ReducerServerBuilder
-	validateEngines map[string]port.ValidateServerBuilder
-	updateEngines   map[string]port.UpdateServerBuilder
+	asyncIndices    sync.Map
+	indexSources    sync.Map
+	dbCopies        sync.Map // views with the dbcopy option
+	scheduler       *TaskScheduler
+	viewEngines     map[string]port.DesignDocBuilder[port.ViewServer]
+	filterEngines   map[string]port.DesignDocBuilder[port.FilterServer]
+	reducerEngines  map[string]port.DesignDocBuilder[port.Reducer]
+	validateEngines map[string]port.DesignDocBuilder[port.ValidateServer]
+	updateEngines   map[string]port.DesignDocBuilder[port.UpdateServer]
+	showEngines     map[string]port.DesignDocBuilder[port.ShowServer]
+	listEngines     map[string]port.DesignDocBuilder[port.ListServer]
+	rewriteEngines  map[string]port.DesignDocBuilder[port.RewriteServer]
 	logger          port.Logger
 }
 
+// quarantine is a database file that failed to load, it is loaded
+// again by the first access after the backoff of its attempts
+type quarantine struct {
+	err      error
+	attempts int
+	retryAt  time.Time
+}
+
+const (
+	quarantineMinBackoff = time.Second
+	quarantineMaxBackoff = 5 * time.Minute
+)
+
+// quarantineBackoff returns the exponential backoff of the attempt,
+// temporary errors like too many open files recover quickly while
+// broken files are only tried every few minutes
+func quarantineBackoff(attempts int) time.Duration {
+	backoff := quarantineMinBackoff
+	for i := 1; i < attempts && backoff < quarantineMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > quarantineMaxBackoff {
+		backoff = quarantineMaxBackoff
+	}
+	return backoff
+}
+
 func (d *Database) ChangesIndex() port.DocumentIndex {
-	return d.indices[index.ChangesIndexName]
+	idx, _ := d.index(index.ChangesIndexName)
+	return idx
 }
 
+// Indices returns a copy of the indices of the database
 func (d *Database) Indices() map[string]port.DocumentIndex {
-	return d.indices
+	d.indicesMu.RLock()
+	defer d.indicesMu.RUnlock()
+	indices := make(map[string]port.DocumentIndex, len(d.indices))
+	for name, idx := range d.indices {
+		indices[name] = idx
+	}
+	return indices
+}
+
+// index returns the index with the name
+func (d *Database) index(name string) (port.DocumentIndex, bool) {
+	d.indicesMu.RLock()
+	defer d.indicesMu.RUnlock()
+	idx, ok := d.indices[name]
+	return idx, ok
+}
+
+func (d *Database) setIndex(name string, idx port.DocumentIndex) {
+	d.indicesMu.Lock()
+	d.indices[name] = idx
+	d.indicesMu.Unlock()
 }
 
 func (d *Database) Name() string {
@@ -75,37 +143,139 @@ func (d *Database) Sequence(ctx context.Context) (string, error) {
 }
 
 func (d *Database) ViewEngine(name string) port.ViewServerBuilder {
+	return d.viewEngines[name].WithoutDesignDoc()
+}
+
+func (d *Database) ViewDesignDocEngine(name string) port.DesignDocBuilder[port.ViewServer] {
 	return d.viewEngines[name]
 }
 
 func (d *Database) FilterEngine(name string) port.FilterServerBuilder {
+	return d.filterEngines[name].WithoutDesignDoc()
+}
+
+func (d *Database) FilterDesignDocEngine(name string) port.DesignDocBuilder[port.FilterServer] {
 	return d.filterEngines[name]
 }
 
 func (d *Database) ReducerEngine(name string) port.ReducerServerBuilder {
+	return d.reducerEngines[name].WithoutDesignDoc()
+}
+
+func (d *Database) ReducerDesignDocEngine(name string) port.DesignDocBuilder[port.Reducer] {
 	return d.reducerEngines[name]
 }
 
 func (d *Database) ValidateEngine(name string) port.ValidateServerBuilder {
+	return d.validateEngines[name].WithoutDesignDoc()
+}
+
+func (d *Database) ValidateDesignDocEngine(name string) port.DesignDocBuilder[port.ValidateServer] {
 	return d.validateEngines[name]
 }
 
 func (d *Database) UpdateEngine(name string) port.UpdateServerBuilder {
+	return d.updateEngines[name].WithoutDesignDoc()
+}
+
+func (d *Database) UpdateDesignDocEngine(name string) port.DesignDocBuilder[port.UpdateServer] {
 	return d.updateEngines[name]
 }
 
+func (d *Database) ShowEngine(name string) port.ShowServerBuilder {
+	return d.showEngines[name].WithoutDesignDoc()
+}
+
+func (d *Database) ShowDesignDocEngine(name string) port.DesignDocBuilder[port.ShowServer] {
+	return d.showEngines[name]
+}
+
+func (d *Database) ListEngine(name string) port.ListServerBuilder {
+	return d.listEngines[name].WithoutDesignDoc()
+}
+
+func (d *Database) ListDesignDocEngine(name string) port.DesignDocBuilder[port.ListServer] {
+	return d.listEngines[name]
+}
+
+func (d *Database) RewriteEngine(name string) port.RewriteServerBuilder {
+	return d.rewriteEngines[name].WithoutDesignDoc()
+}
+
+func (d *Database) RewriteDesignDocEngine(name string) port.DesignDocBuilder[port.RewriteServer] {
+	return d.rewriteEngines[name]
+}
+
+// CreateDatabase creates the database file or loads an existing database
 func (s *Storage) CreateDatabase(ctx context.Context, name string) (port.Database, error) {
+	return s.loadDatabase(ctx, name)
+}
+
+// loadDatabase returns the loaded database or loads it, concurrent
+// calls for the same database wait for the first one. A database
+// file that exists but fails to load is quarantined until the
+// backoff of its failed attempts expired.
+func (s *Storage) loadDatabase(ctx context.Context, name string) (port.Database, error) {
 	s.mu.Lock()
-	defer s.mu.Unlock()
+	db, ok := s.waitLoading(name)
+	if ok {
+		db.use(ctx)
+		s.mu.Unlock()
+		return db, nil
+	}
+	existing := s.known[name]
+	done := make(chan struct{})
+	s.loading[name] = done
+	s.mu.Unlock()
 
-	databaseDir := path.Join(s.path, name+".d")
+	database, err := s.openDatabase(ctx, name)
 
-	s.logger.Debugf(ctx, "opening database")
-	db, err := bbolt_engine.Open(path.Join(s.path, name))
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.loading, name)
+	close(done)
 	if err != nil {
+		if existing {
+			q := &quarantine{err: err, attempts: 1}
+			if prev, ok := s.quarantined[name]; ok {
+				q.attempts = prev.attempts + 1
+			}
+			backoff := quarantineBackoff(q.attempts)
+			q.retryAt = time.Now().Add(backoff)
+			s.logger.Errorf(ctx, "database quarantined", "name", name, "error", err, "attempts", q.attempts, "retry_in", backoff.String())
+			s.quarantined[name] = q
+		}
 		return nil, err
 	}
-	s.logger.Debugf(ctx, "database opened")
+	database.use(ctx)
+	s.dbs[name] = database
+	s.known[name] = true
+	delete(s.quarantined, name)
+	return database, nil
+}
+
+// waitLoading waits until the database isn't loaded by another
+// call and returns it if it is loaded, s.mu has to be locked
+func (s *Storage) waitLoading(name string) (*Database, bool) {
+	for {
+		if db, ok := s.dbs[name]; ok {
+			return db, true
+		}
+		done, ok := s.loading[name]
+		if !ok {
+			return nil, false
+		}
+		s.mu.Unlock()
+		<-done
+		s.mu.Lock()
+	}
+}
+
+func (s *Storage) openDatabase(ctx context.Context, name string) (*Database, error) {
+	databaseDir := path.Join(s.path, name+".d")
+
+	s.logger.Debugf(ctx, "opening database", "name", name)
+	db := s.handles.handle(path.Join(s.path, name))
 
 	database := &Database{
 		name:        name,
@@ -120,13 +290,18 @@ func (s *Storage) CreateDatabase(ctx context.Context, name string) (port.Databas
 		reducerEngines:  s.reducerEngines,
 		validateEngines: s.validateEngines,
 		updateEngines:   s.updateEngines,
-		logger:         s.logger.With("database", name),
+		showEngines:     s.showEngines,
+		listEngines:     s.listEngines,
+		rewriteEngines:  s.rewriteEngines,
+		scheduler:       s.scheduler,
+		unused:          s.unloadUnused,
+		done:            make(chan struct{}),
+		logger:          s.logger.With("database", name),
 	}
-	s.dbs[name] = database
 
 	// create all required database Indices
 	database.logger.Debugf(ctx, "building indices")
-	err = database.rawTx(func(tx *Transaction) error {
+	err := database.rawTx(func(tx *Transaction) error {
 		tx.EnsureBucket(model.DocsBucket)
 		tx.EnsureBucket(model.AttRefsBucket)
 		tx.EnsureBucket(model.DocLeavesBucket)
@@ -148,15 +323,24 @@ func (s *Storage) CreateDatabase(ctx context.Context, name string) (port.Databas
 		return nil
 	})
 	if err != nil {
+		_ = db.Close()
 		return nil, err
 	}
 
 	// Migrate attachment storage from per-document paths to content-addressed
 	// paths.  No-op for new databases or already-migrated ones.
 	if err := database.migrateAttachments(ctx); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	// tasks left over from a previous run
+	if err := database.ScheduleTasks(ctx); err != nil {
+		_ = db.Close()
 		return nil, err
 	}
 
+	s.logger.Debugf(ctx, "database opened", "name", name)
 	return database, nil
 }
 
@@ -164,52 +348,207 @@ func (s *Storage) DeleteDatabase(ctx context.Context, name string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	db, ok := s.dbs[name]
-	if !ok {
+	db, loaded := s.waitLoading(name)
+	if !s.known[name] {
 		return fmt.Errorf("%w: %q", ErrUnknownDatabase, name)
 	}
 
-	err := db.db.Close()
-	if err != nil {
-		return err
+	if loaded {
+		db.close()
+		db.closeIndices(ctx)
+		err := db.db.Close()
+		if err != nil {
+			return err
+		}
 	}
 
-	err = os.Remove(path.Join(s.path, name))
+	err := os.Remove(path.Join(s.path, name))
 	if err != nil {
 		return err
 	}
 
-	if err := os.RemoveAll(db.databaseDir); err != nil && !os.IsNotExist(err) {
+	if err := os.RemoveAll(path.Join(s.path, name+".d")); err != nil && !os.IsNotExist(err) {
 		return err
 	}
 
 	delete(s.dbs, name)
+	delete(s.known, name)
+	delete(s.quarantined, name)
+	s.scheduler.remove(name)
 
 	return nil
 }
 
+// Databases returns the names of all databases, including
+// the databases that weren't loaded yet
 func (s *Storage) Databases(ctx context.Context) ([]string, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	names := make([]string, len(s.dbs))
-	var i int
+	names := make([]string, 0, len(s.known))
+	for name := range s.known {
+		names = append(names, name)
+	}
+
+	return names, nil
+}
+
+// LoadedDatabases returns the names of the databases that were
+// accessed since the storage was opened
+func (s *Storage) LoadedDatabases(ctx context.Context) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	names := make([]string, 0, len(s.dbs))
 	for name := range s.dbs {
-		names[i] = name
-		i++
+		names = append(names, name)
 	}
 
 	return names, nil
 }
 
+// Database returns the database, it is loaded on first access
 func (s *Storage) Database(ctx context.Context, name string) (port.Database, error) {
+	s.mu.RLock()
+	db, ok := s.dbs[name]
+	if ok {
+		db.use(ctx)
+	}
+	known := s.known[name]
+	q := s.quarantined[name]
+	s.mu.RUnlock()
+
+	switch {
+	case ok:
+		return db, nil
+	case q != nil && time.Now().Before(q.retryAt):
+		return nil, fmt.Errorf("%w: %q: %v", ErrQuarantinedDatabase, name, q.err)
+	case !known:
+		return nil, fmt.Errorf("%w: %q not found", ErrUnknownDatabase, name)
+	}
+
+	return s.loadDatabase(ctx, name)
+}
+
+// QuarantinedDatabases returns the databases that failed to load
+// with the reason, they are loaded again by the first access after
+// a backoff that doubles with every failed attempt
+func (s *Storage) QuarantinedDatabases() map[string]string {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	db, ok := s.dbs[name]
-	if !ok {
-		return nil, fmt.Errorf("database %q not found", name)
+	quarantined := make(map[string]string, len(s.quarantined))
+	for name, q := range s.quarantined {
+		quarantined[name] = q.err.Error()
 	}
+	return quarantined
+}
+
+// unloadDatabases unloads the databases of the closed files, the
+// indices and search indexes are released and loaded again by the
+// next access. Databases in use by a request or task stay loaded,
+// their files are opened again by the next transaction.
+func (s *Storage) unloadDatabases(closed []*handle) {
+	var unloaded []*Database
+	s.mu.Lock()
+	for _, h := range closed {
+		name := path.Base(h.path)
+		db, ok := s.dbs[name]
+		if !ok || db.db != port.DatabaseEngine(h) || db.inUse() {
+			continue
+		}
+		// references to the unloaded database fail instead
+		// of opening the file next to the reloaded database
+		if !h.closeUnused() {
+			continue
+		}
+		delete(s.dbs, name)
+		unloaded = append(unloaded, db)
+	}
+	s.mu.Unlock()
+
+	for _, db := range unloaded {
+		db.close()
+		db.closeIndices(context.Background())
+		s.logger.Debugf(context.Background(), "database unloaded", "name", db.name)
+	}
+}
+
+// unloadUnused unloads the database released by its last user
+// if its file was closed while it was in use
+func (s *Storage) unloadUnused(d *Database) {
+	h, ok := d.db.(*handle)
+	if !ok || h.isOpen() {
+		return
+	}
+	s.unloadDatabases([]*handle{h})
+}
+
+// use records the context as a user of the database until it is
+// done, contexts that are never done like context.Background
+// aren't recorded. s.mu has to be locked.
+func (d *Database) use(ctx context.Context) {
+	if ctx.Done() == nil || ctx.Err() != nil {
+		return
+	}
+	d.usersMu.Lock()
+	if _, ok := d.users[ctx]; ok {
+		d.usersMu.Unlock()
+		return
+	}
+	if d.users == nil {
+		d.users = make(map[context.Context]struct{})
+	}
+	d.users[ctx] = struct{}{}
+	d.usersMu.Unlock()
+
+	context.AfterFunc(ctx, func() { d.release(ctx) })
+}
+
+func (d *Database) release(ctx context.Context) {
+	d.usersMu.Lock()
+	delete(d.users, ctx)
+	unused := len(d.users) == 0
+	d.usersMu.Unlock()
+
+	if unused && d.unused != nil {
+		d.unused(d)
+	}
+}
+
+// inUse returns true if a request or task holds the database
+func (d *Database) inUse() bool {
+	d.usersMu.Lock()
+	defer d.usersMu.Unlock()
+	return len(d.users) > 0
+}
+
+// Done returns a channel that is closed when the database is unloaded
+// or deleted, e.g. to release resources held for the database. The
+// database is loaded again by the next access to the storage.
+func (d *Database) Done() <-chan struct{} {
+	return d.done
+}
+
+func (d *Database) close() {
+	d.doneOnce.Do(func() { close(d.done) })
+}
+
+// closeIndices closes the indices that keep resources outside
+// of the database file, e.g. search indexes
+func (d *Database) closeIndices(ctx context.Context) {
+	for name, idx := range d.Indices() {
+		c, ok := idx.(io.Closer)
+		if !ok {
+			continue
+		}
+		if err := c.Close(); err != nil {
+			d.logger.Warnf(ctx, "failed to close index", "index", name, "error", err)
+		}
+	}
+}
 
-	return db, nil
+// OpenDatabases returns the number of open database files
+func (s *Storage) OpenDatabases() int {
+	return s.handles.count()
 }
@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/goydb/goydb/pkg/model"
+	"github.com/goydb/goydb/pkg/port"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testFilterServer string
+
+func (testFilterServer) ExecuteFilter(context.Context, *model.Document, map[string]interface{}) (bool, error) {
+	return false, nil
+}
+
+func TestStorage_DesignDocEngines(t *testing.T) {
+	s, cleanup := openStorage(t)
+	defer cleanup()
+
+	require.NoError(t, s.RegisterFilterEngine("plain", func(fn string) (port.FilterServer, error) {
+		return testFilterServer(fn), nil
+	}))
+	require.NoError(t, s.RegisterFilterDesignDocEngine("modules", func(fn string, ddoc *model.Document) (port.FilterServer, error) {
+		if ddoc == nil {
+			return testFilterServer(fn), nil
+		}
+		return testFilterServer(ddoc.ID + "/" + fn), nil
+	}))
+	assert.Error(t, s.RegisterFilterDesignDocEngine("plain", nil), "already registered")
+
+	db, err := s.CreateDatabase(context.Background(), "testdb")
+	require.NoError(t, err)
+	ddoc := &model.Document{ID: "_design/app"}
+
+	// engines without modules ignore the design document
+	f, err := db.FilterDesignDocEngine("plain")("fn", ddoc)
+	require.NoError(t, err)
+	assert.Equal(t, testFilterServer("fn"), f)
+
+	f, err = db.FilterDesignDocEngine("modules")("fn", ddoc)
+	require.NoError(t, err)
+	assert.Equal(t, testFilterServer("_design/app/fn"), f)
+	f, err = db.FilterEngine("modules")("fn")
+	require.NoError(t, err)
+	assert.Equal(t, testFilterServer("fn"), f)
+
+	assert.Nil(t, db.FilterEngine("unknown"))
+	assert.Nil(t, db.FilterDesignDocEngine("unknown"))
+}
+
+func TestDatabase_Indices(t *testing.T) {
+	s, cleanup := openStorage(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	db, err := s.CreateDatabase(ctx, "testdb")
+	require.NoError(t, err)
+
+	// the returned map is a copy
+	indices := db.Indices()
+	n := len(indices)
+	for name := range indices {
+		delete(indices, name)
+	}
+	assert.NotEmpty(t, db.Indices())
+
+	// indices are added while they are read
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 20; i++ {
+			_, err := db.PutDocument(ctx, &model.Document{
+				ID: fmt.Sprintf("_design/idx%d", i),
+				Data: map[string]interface{}{
+					"mango_indexes": map[string]interface{}{
+						"by_n": map[string]interface{}{"fields": []interface{}{"n"}},
+					},
+				},
+			})
+			assert.NoError(t, err)
+		}
+	}()
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+			_ = db.Indices()
+		}
+	}
+	assert.Len(t, db.Indices(), n+20)
+}
+
+func TestDatabase_SwitchIndexModeRollback(t *testing.T) {
+	s, cleanup := openStorage(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	pdb, err := s.CreateDatabase(ctx, "testdb")
+	require.NoError(t, err)
+	db := pdb.(*Database)
+	_, err = db.PutDocument(ctx, &model.Document{
+		ID: "_design/idx",
+		Data: map[string]interface{}{
+			"mango_indexes": map[string]interface{}{
+				"by_n": map[string]interface{}{"fields": []interface{}{"n"}},
+			},
+		},
+	})
+	require.NoError(t, err)
+	ddfn := &model.DesignDocFn{Type: model.MangoFn, DesignDocID: "_design/idx", FnName: "by_n"}
+	failed := fmt.Errorf("failed")
+
+	// a failed transaction keeps the index sync
+	err = db.rawTx(func(tx *Transaction) error {
+		require.NoError(t, db.switchIndexMode(ctx, tx, ddfn, true))
+		assert.True(t, db.isAsyncIndex(ddfn.String()))
+		return failed
+	})
+	require.ErrorIs(t, err, failed)
+	assert.False(t, db.isAsyncIndex(ddfn.String()))
+
+	// a failed transaction keeps the index async
+	require.NoError(t, db.SetIndexMode(ctx, model.IndexModeAsync))
+	assert.True(t, db.isAsyncIndex(ddfn.String()))
+	err = db.rawTx(func(tx *Transaction) error {
+		require.NoError(t, db.switchIndexMode(ctx, tx, ddfn, false))
+		return failed
+	})
+	require.ErrorIs(t, err, failed)
+	assert.True(t, db.isAsyncIndex(ddfn.String()))
+	require.NoError(t, db.db.ReadTransaction(func(tx port.EngineReadTransaction) error {
+		_, ok := indexSeq(tx, ddfn.String())
+		assert.True(t, ok, "the async index keeps its checkpoint")
+		return nil
+	}))
+}
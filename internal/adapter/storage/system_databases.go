@@ -110,7 +110,20 @@ const validateDocUpdateJS = `function(newDoc, oldDoc, userCtx, secObj) {
 // EnsureSystemDatabases creates system databases (e.g. _users) and seeds
 // them with the required design documents if they do not already exist.
 func (s *Storage) EnsureSystemDatabases(ctx context.Context) error {
-	return s.ensureUsersDB(ctx)
+	if err := s.ensureUsersDB(ctx); err != nil {
+		return err
+	}
+	return s.ensureVHostsDB(ctx)
+}
+
+// ensureVHostsDB ensures the _vhosts database exists. No security object
+// is stored, the default security restricts the database to admins.
+func (s *Storage) ensureVHostsDB(ctx context.Context) error {
+	if _, err := s.Database(ctx, "_vhosts"); err == nil {
+		return nil
+	}
+	_, err := s.CreateDatabase(ctx, "_vhosts")
+	return err
 }
 
 // ensureUsersDB ensures the _users database exists and contains _design/_auth.
@@ -22,6 +22,25 @@ func TestEnsureSystemDatabases_CreatesUsersDB(t *testing.T) {
 	assert.NotNil(t, db)
 }
 
+func TestEnsureSystemDatabases_CreatesVHostsDB(t *testing.T) {
+	s, cleanup := openStorage(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	err := s.EnsureSystemDatabases(ctx)
+	require.NoError(t, err)
+
+	db, err := s.Database(ctx, "_vhosts")
+	require.NoError(t, err)
+
+	// only admins may configure virtual hosts
+	sec, err := db.GetSecurity(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"_admin"}, sec.Members.Roles)
+	assert.Equal(t, []string{"_admin"}, sec.Admins.Roles)
+}
+
 func TestEnsureSystemDatabases_SeedsAuthDesignDoc(t *testing.T) {
 	s, cleanup := openStorage(t)
 	defer cleanup()
@@ -0,0 +1,159 @@
+package storage
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/goydb/goydb/pkg/port"
+)
+
+var _ port.TaskScheduler = (*TaskScheduler)(nil)
+
+// scheduledDatabase is a database in the ready-queue
+type scheduledDatabase struct {
+	at       time.Time
+	priority int
+	// changed is set if documents changed, the async indices
+	// and materialized views are updated by the next pass
+	changed bool
+}
+
+// TaskScheduler is the ready-queue of the databases that have tasks,
+// async indices or materialized views to update. Databases are added
+// when tasks are queued or documents change, idle databases are
+// never visited.
+type TaskScheduler struct {
+	mu      sync.Mutex
+	queued  map[string]scheduledDatabase
+	running map[string]bool
+	// wake is signaled if the queue changed
+	wake chan struct{}
+}
+
+func newTaskScheduler() *TaskScheduler {
+	return &TaskScheduler{
+		queued:  make(map[string]scheduledDatabase),
+		running: make(map[string]bool),
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// Schedule queues the database to be processed at the passed time. A
+// database that is queued already keeps the earlier time and the
+// higher priority.
+func (s *TaskScheduler) Schedule(name string, at time.Time, priority int) {
+	s.schedule(name, scheduledDatabase{at: at, priority: priority})
+}
+
+// ScheduleChanges queues the database to apply the changed documents
+// to its async indices and materialized views at the passed time
+func (s *TaskScheduler) ScheduleChanges(name string, at time.Time) {
+	s.schedule(name, scheduledDatabase{at: at, changed: true})
+}
+
+func (s *TaskScheduler) schedule(name string, sd scheduledDatabase) {
+	s.mu.Lock()
+	if q, ok := s.queued[name]; ok {
+		if q.at.Before(sd.at) {
+			sd.at = q.at
+		}
+		if q.priority > sd.priority {
+			sd.priority = q.priority
+		}
+		sd.changed = sd.changed || q.changed
+	}
+	s.queued[name] = sd
+	s.mu.Unlock()
+	s.signal()
+}
+
+// Next blocks until a queued database is due and returns its name, due
+// databases with a higher priority first, changed is true if documents
+// changed since it was returned last. The database is not returned
+// again until Done is called.
+func (s *TaskScheduler) Next(ctx context.Context) (name string, changed bool, err error) {
+	for {
+		name, changed, wait := s.pop(time.Now())
+		if name != "" {
+			// another waiting worker may take the next database
+			s.signal()
+			return name, changed, nil
+		}
+
+		var timeout <-chan time.Time
+		var t *time.Timer
+		if wait > 0 {
+			t = time.NewTimer(wait)
+			timeout = t.C
+		}
+		select {
+		case <-ctx.Done():
+		case <-s.wake:
+		case <-timeout:
+		}
+		if t != nil {
+			t.Stop()
+		}
+		if ctx.Err() != nil {
+			return "", false, ctx.Err()
+		}
+	}
+}
+
+// Done marks the processing of the database as finished, if it was
+// scheduled meanwhile it is returned by Next again
+func (s *TaskScheduler) Done(name string) {
+	s.mu.Lock()
+	delete(s.running, name)
+	s.mu.Unlock()
+	s.signal()
+}
+
+// pop removes the next due database from the queue, if no database
+// is due it returns the time until the next one is due or 0 if there
+// is none
+func (s *TaskScheduler) pop(now time.Time) (string, bool, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var next string
+	var wait time.Duration
+	for name, q := range s.queued {
+		if s.running[name] {
+			continue
+		}
+		if q.at.After(now) {
+			if d := q.at.Sub(now); wait == 0 || d < wait {
+				wait = d
+			}
+			continue
+		}
+		if next == "" || q.priority > s.queued[next].priority ||
+			(q.priority == s.queued[next].priority && q.at.Before(s.queued[next].at)) {
+			next = name
+		}
+	}
+	var changed bool
+	if next != "" {
+		changed = s.queued[next].changed
+		delete(s.queued, next)
+		s.running[next] = true
+	}
+	return next, changed, wait
+}
+
+// remove drops the database from the queue
+func (s *TaskScheduler) remove(name string) {
+	s.mu.Lock()
+	delete(s.queued, name)
+	s.mu.Unlock()
+}
+
+// signal wakes up a waiting Next call
+func (s *TaskScheduler) signal() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,130 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/goydb/goydb/pkg/model"
+	"github.com/goydb/goydb/pkg/port"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTaskScheduler_Order(t *testing.T) {
+	s := newTaskScheduler()
+	now := time.Now()
+	s.Schedule("a", now.Add(-time.Second), 0)
+	s.Schedule("b", now, 0)
+	s.Schedule("c", now, 5)
+	s.Schedule("later", now.Add(time.Hour), 10)
+
+	// higher priority first, then the earlier time
+	for _, name := range []string{"c", "a", "b"} {
+		next, _, _ := s.pop(now)
+		assert.Equal(t, name, next)
+	}
+	next, _, wait := s.pop(now)
+	assert.Empty(t, next)
+	assert.Equal(t, time.Hour, wait)
+
+	// a queued database keeps the earlier time and the higher priority
+	s.Schedule("later", now, 0)
+	next, _, _ = s.pop(now)
+	assert.Equal(t, "later", next)
+}
+
+func TestTaskScheduler_Changes(t *testing.T) {
+	s := newTaskScheduler()
+	now := time.Now()
+	s.ScheduleChanges("a", now)
+	s.Schedule("a", now, 5)
+	s.Schedule("b", now, 0)
+
+	// only databases with changed documents update
+	// their async indices and materialized views
+	next, changed, _ := s.pop(now)
+	assert.Equal(t, "a", next)
+	assert.True(t, changed)
+	next, changed, _ = s.pop(now)
+	assert.Equal(t, "b", next)
+	assert.False(t, changed)
+}
+
+func TestTaskScheduler_Running(t *testing.T) {
+	ctx := context.Background()
+	s := newTaskScheduler()
+	s.Schedule("a", time.Now(), 0)
+	next, _, err := s.Next(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "a", next)
+
+	// a running database isn't returned until it is done
+	s.Schedule("a", time.Now(), 0)
+	next, _, _ = s.pop(time.Now())
+	assert.Empty(t, next)
+	s.Done("a")
+	next, _, err = s.Next(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "a", next)
+
+	// Next waits for databases to be scheduled
+	go s.Schedule("b", time.Now().Add(time.Millisecond*10), 0)
+	next, _, err = s.Next(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "b", next)
+
+	ctx, cancel := context.WithTimeout(ctx, time.Millisecond*10)
+	defer cancel()
+	_, _, err = s.Next(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestTaskScheduler_Database(t *testing.T) {
+	s, cleanup := openStorage(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	pdb, err := s.CreateDatabase(ctx, "testdb")
+	require.NoError(t, err)
+	db := pdb.(*Database)
+
+	// idle databases aren't queued
+	next, _, _ := s.scheduler.pop(time.Now())
+	assert.Empty(t, next)
+
+	require.NoError(t, db.AddTasks(ctx, []*model.Task{{
+		Action:      model.ActionUpdateView,
+		DBName:      "testdb",
+		DesignDocFn: "_design/app/_view/x",
+	}}))
+	next, _, _ = s.scheduler.pop(time.Now())
+	assert.Equal(t, "testdb", next)
+	s.scheduler.Done(next)
+
+	// leased tasks are skipped until the lease expires
+	tasks, err := db.GetTasks(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+	assert.False(t, tasks[0].ActiveSince.IsZero())
+	tasks, err = db.GetTasks(ctx, 10)
+	require.NoError(t, err)
+	assert.Empty(t, tasks)
+
+	// the database is scheduled again when the lease expires
+	require.NoError(t, db.ScheduleTasks(ctx))
+	next, _, wait := s.scheduler.pop(time.Now())
+	assert.Empty(t, next)
+	assert.InDelta(t, model.TaskLeaseTimeout, wait, float64(time.Second))
+
+	err = db.db.WriteTransaction(db.logger, func(tx port.EngineWriteTransaction) error {
+		queued, err := readTasks(tx)
+		require.NoError(t, err)
+		queued[0].ActiveSince = time.Now().Add(-model.TaskLeaseTimeout)
+		return putTask(tx, queued[0])
+	})
+	require.NoError(t, err)
+	tasks, err = db.GetTasks(ctx, 10)
+	require.NoError(t, err)
+	assert.Len(t, tasks, 1)
+}
@@ -0,0 +1,375 @@
+//go:build !nogoja && !notengo
+
+package view_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/goydb/goydb/internal/adapter/logger"
+	"github.com/goydb/goydb/internal/adapter/reducer"
+	"github.com/goydb/goydb/internal/adapter/view/gojaview"
+	"github.com/goydb/goydb/internal/adapter/view/tengoview"
+	"github.com/goydb/goydb/pkg/model"
+	"github.com/goydb/goydb/pkg/port"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// engine is a script engine under test, the conformance tests run
+// the same design document functions on every engine
+type engine struct {
+	name     string
+	view     port.DesignDocBuilder[port.ViewServer]
+	reducer  port.DesignDocBuilder[port.Reducer]
+	filter   port.DesignDocBuilder[port.FilterServer]
+	validate port.DesignDocBuilder[port.ValidateServer]
+	update   port.DesignDocBuilder[port.UpdateServer]
+	show     port.DesignDocBuilder[port.ShowServer]
+	list     port.DesignDocBuilder[port.ListServer]
+	rewrite  port.DesignDocBuilder[port.RewriteServer]
+}
+
+var engines = []engine{
+	{
+		name:     "goja",
+		view:     gojaview.NewViewServer,
+		reducer:  gojaview.NewReducerBuilder(logger.NewNoLog()),
+		filter:   gojaview.NewFilterServer,
+		validate: gojaview.NewValidateServerBuilder(logger.NewNoLog()),
+		update:   gojaview.NewUpdateServer,
+		show:     gojaview.NewShowServer,
+		list:     gojaview.NewListServer,
+		rewrite:  gojaview.NewRewriteServer,
+	},
+	{
+		name:     "tengo",
+		view:     port.IgnoreDesignDoc(tengoview.NewViewServer),
+		reducer:  port.IgnoreDesignDoc(tengoview.NewReducerBuilder(logger.NewNoLog())),
+		filter:   port.IgnoreDesignDoc(tengoview.NewFilterServer),
+		validate: port.IgnoreDesignDoc(tengoview.NewValidateServerBuilder(logger.NewNoLog())),
+		update:   port.IgnoreDesignDoc(tengoview.NewUpdateServer),
+		show:     port.IgnoreDesignDoc(tengoview.NewShowServer),
+		list:     port.IgnoreDesignDoc(tengoview.NewListServer),
+		rewrite:  port.IgnoreDesignDoc(tengoview.NewRewriteServer),
+	},
+}
+
+// source is a function in the language of every engine
+type source map[string]string
+
+// normalize converts the value to its JSON representation to
+// compare the number types of the engines
+func normalize(t *testing.T, v interface{}) interface{} {
+	t.Helper()
+	data, err := json.Marshal(v)
+	require.NoError(t, err)
+	var n interface{}
+	require.NoError(t, json.Unmarshal(data, &n))
+	return n
+}
+
+// conform runs the function of the source on every engine and
+// checks the result
+func conform(t *testing.T, src source, want interface{}, run func(t *testing.T, e engine, fn string) interface{}) {
+	t.Helper()
+	for _, e := range engines {
+		fn, ok := src[e.name]
+		require.True(t, ok, "no source for %s", e.name)
+		t.Run(e.name, func(t *testing.T) {
+			assert.Equal(t, normalize(t, want), normalize(t, run(t, e, fn)))
+		})
+	}
+}
+
+func conformanceDocs() []*model.Document {
+	return []*model.Document{
+		{ID: "a", Rev: "1-a", Data: map[string]interface{}{"type": "post", "title": "Hello", "n": 1}},
+		{ID: "b", Rev: "1-b", Data: map[string]interface{}{"type": "comment", "n": 2}},
+		{ID: "c", Rev: "1-c", Data: map[string]interface{}{"type": "post", "title": "World", "n": 3}},
+	}
+}
+
+func TestConformance_View(t *testing.T) {
+	conform(t, source{
+		"goja": `function(doc) {
+			if (doc.type == "post") {
+				emit([doc.type, doc.title], doc.n * 2);
+			}
+		}`,
+		"tengo": `func(doc) {
+			if doc.type == "post" {
+				emit([doc.type, doc.title], doc.n * 2)
+			}
+		}`,
+	}, []*model.Document{
+		{ID: "a", Key: []interface{}{"post", "Hello"}, Value: 2},
+		{ID: "c", Key: []interface{}{"post", "World"}, Value: 6},
+	}, func(t *testing.T, e engine, fn string) interface{} {
+		s, err := e.view(fn, nil)
+		require.NoError(t, err)
+		res, err := s.ExecuteView(context.Background(), conformanceDocs())
+		require.NoError(t, err)
+		return res
+	})
+}
+
+func TestConformance_Search(t *testing.T) {
+	conform(t, source{
+		"goja": `function(doc) {
+			if (doc.title) {
+				index("title", doc.title, {"store": true});
+				index("n", doc.n, {});
+			}
+		}`,
+		"tengo": `func(doc) {
+			if doc.title {
+				index("title", doc.title, {store: true})
+				index("n", doc.n)
+			}
+		}`,
+	}, []*model.Document{
+		{
+			ID:      "a",
+			Fields:  map[string]interface{}{"title": "Hello", "n": 1},
+			Options: map[string]model.SearchIndexOption{"title": {Store: true}, "n": {}},
+		},
+		{
+			ID:      "c",
+			Fields:  map[string]interface{}{"title": "World", "n": 3},
+			Options: map[string]model.SearchIndexOption{"title": {Store: true}, "n": {}},
+		},
+	}, func(t *testing.T, e engine, fn string) interface{} {
+		s, err := e.view(fn, nil)
+		require.NoError(t, err)
+		res, err := s.ExecuteSearch(context.Background(), conformanceDocs())
+		require.NoError(t, err)
+		return res
+	})
+}
+
+func TestConformance_Reduce(t *testing.T) {
+	// the count of a key is rereduced if it exceeds the batch size
+	n := reducer.BatchSize + 5
+	reduce := func(t *testing.T, e engine, fn string) interface{} {
+		r, err := e.reducer(fn, nil)
+		require.NoError(t, err)
+		for i := 0; i < n; i++ {
+			r.Reduce(&model.Document{ID: fmt.Sprint(i), Key: "a", Value: 1})
+		}
+		r.Reduce(&model.Document{ID: "x", Key: "b", Value: 2})
+		result := r.Result()
+		if re, ok := r.(port.ReducerError); ok {
+			require.NoError(t, re.Err())
+		}
+		return []interface{}{result[0], result[1]}
+	}
+
+	t.Run("sum", func(t *testing.T) {
+		conform(t, source{
+			"goja":  `function(keys, values, rereduce) { return sum(values); }`,
+			"tengo": `func(keys, values, rereduce) { return sum(values) }`,
+		}, []*model.Document{
+			{Key: "a", Value: n},
+			{Key: "b", Value: 2},
+		}, reduce)
+	})
+
+	t.Run("rereduce", func(t *testing.T) {
+		conform(t, source{
+			"goja": `function(keys, values, rereduce) {
+				if (rereduce) {
+					return sum(values);
+				}
+				return keys.length;
+			}`,
+			"tengo": `func(keys, values, rereduce) {
+				if rereduce {
+					return sum(values)
+				}
+				return len(keys)
+			}`,
+		}, []*model.Document{
+			{Key: "a", Value: n},
+			{Key: "b", Value: 1},
+		}, reduce)
+	})
+}
+
+func TestConformance_Filter(t *testing.T) {
+	conform(t, source{
+		"goja":  `function(doc, req) { return doc.type == req.query.type; }`,
+		"tengo": `func(doc, req) { return doc.type == req.query.type }`,
+	}, []bool{true, false, true}, func(t *testing.T, e engine, fn string) interface{} {
+		s, err := e.filter(fn, nil)
+		require.NoError(t, err)
+		var pass []bool
+		for _, doc := range conformanceDocs() {
+			ok, err := s.ExecuteFilter(context.Background(), doc, map[string]interface{}{
+				"query": map[string]interface{}{"type": "post"},
+			})
+			require.NoError(t, err)
+			pass = append(pass, ok)
+		}
+		return pass
+	})
+}
+
+func TestConformance_Validate(t *testing.T) {
+	conform(t, source{
+		"goja": `function(newDoc, oldDoc, userCtx, secObj) {
+			if (!userCtx.name) {
+				throw({unauthorized: "login required"});
+			}
+			if (!newDoc.title) {
+				throw({forbidden: "title required"});
+			}
+		}`,
+		"tengo": `func(newDoc, oldDoc, userCtx, secObj) {
+			if !userCtx.name {
+				return unauthorized("login required")
+			}
+			if !newDoc.title {
+				return forbidden("title required")
+			}
+		}`,
+	}, []string{
+		"<nil>",
+		"*model.ErrForbidden: forbidden: title required",
+		"*model.ErrUnauthorized: unauthorized: login required",
+	}, func(t *testing.T, e engine, fn string) interface{} {
+		s, err := e.validate(fn, nil)
+		require.NoError(t, err)
+		var results []string
+		for _, c := range []struct {
+			doc  map[string]interface{}
+			user string
+		}{
+			{map[string]interface{}{"title": "a"}, "alice"},
+			{map[string]interface{}{}, "alice"},
+			{map[string]interface{}{"title": "a"}, ""},
+		} {
+			err := s.ExecuteValidation(context.Background(), c.doc, nil, map[string]interface{}{"name": c.user}, map[string]interface{}{})
+			if err == nil {
+				results = append(results, "<nil>")
+				continue
+			}
+			results = append(results, fmt.Sprintf("%T: %s", err, err.Error()))
+		}
+		return results
+	})
+}
+
+func TestConformance_Update(t *testing.T) {
+	conform(t, source{
+		"goja": `function(doc, req) {
+			doc.count = (doc.count || 0) + 1;
+			return [doc, {code: 202, json: {count: doc.count}}];
+		}`,
+		"tengo": `func(doc, req) {
+			doc.count = (doc.count || 0) + 1
+			return [doc, {code: 202, json: {count: doc.count}}]
+		}`,
+	}, &port.UpdateResult{
+		Doc:  map[string]interface{}{"_id": "a", "count": 2},
+		Code: 202,
+		JSON: map[string]interface{}{"count": 2},
+	}, func(t *testing.T, e engine, fn string) interface{} {
+		s, err := e.update(fn, nil)
+		require.NoError(t, err)
+		res, err := s.ExecuteUpdate(context.Background(), map[string]interface{}{"_id": "a", "count": 1}, map[string]interface{}{})
+		require.NoError(t, err)
+		return res
+	})
+}
+
+func TestConformance_Show(t *testing.T) {
+	conform(t, source{
+		"goja": `function(doc, req) {
+			return {code: 200, headers: {"X-Id": doc._id}, body: "Hello " + req.query.name};
+		}`,
+		"tengo": `func(doc, req) {
+			return {code: 200, headers: {"X-Id": doc._id}, body: "Hello " + req.query.name}
+		}`,
+	}, map[string]interface{}{"code": 200, "id": "a", "body": "Hello world"}, func(t *testing.T, e engine, fn string) interface{} {
+		s, err := e.show(fn, nil)
+		require.NoError(t, err)
+		res, err := s.ExecuteShow(context.Background(), map[string]interface{}{"_id": "a"}, map[string]interface{}{
+			"query": map[string]interface{}{"name": "world"},
+		})
+		require.NoError(t, err)
+		return map[string]interface{}{"code": res.Code, "id": res.Headers["X-Id"], "body": res.Body}
+	})
+}
+
+type conformanceWriter struct {
+	code   int
+	chunks []string
+}
+
+func (w *conformanceWriter) Start(code int, headers map[string]string) error {
+	w.code = code
+	return nil
+}
+
+func (w *conformanceWriter) Send(chunk string) error {
+	w.chunks = append(w.chunks, chunk)
+	return nil
+}
+
+func TestConformance_List(t *testing.T) {
+	conform(t, source{
+		"goja": `function(head, req) {
+			start({code: 200});
+			var row;
+			while ((row = getRow())) {
+				send(row.key + "\n");
+			}
+			return "total " + head.total_rows;
+		}`,
+		"tengo": `func(head, req) {
+			start({code: 200})
+			for row := getRow(); !is_undefined(row); row = getRow() {
+				send(row.key + "\n")
+			}
+			return "total " + string(head.total_rows)
+		}`,
+	}, &conformanceWriter{code: 200, chunks: []string{"a\n", "b\n", "total 2"}}, func(t *testing.T, e engine, fn string) interface{} {
+		s, err := e.list(fn, nil)
+		require.NoError(t, err)
+		rows := []map[string]interface{}{{"key": "a"}, {"key": "b"}}
+		var w conformanceWriter
+		err = s.ExecuteList(context.Background(), map[string]interface{}{"total_rows": 2}, map[string]interface{}{},
+			func() (map[string]interface{}, error) {
+				if len(rows) == 0 {
+					return nil, nil
+				}
+				row := rows[0]
+				rows = rows[1:]
+				return row, nil
+			}, &w)
+		require.NoError(t, err)
+		return &w
+	})
+}
+
+func TestConformance_Rewrite(t *testing.T) {
+	conform(t, source{
+		"goja": `function(req) {
+			return {path: "_show/post/" + req.path[2], query: {format: "html"}};
+		}`,
+		"tengo": `func(req) {
+			return {path: "_show/post/" + req.path[2], query: {format: "html"}}
+		}`,
+	}, &port.RewriteResult{Path: "_show/post/a", Query: map[string]interface{}{"format": "html"}}, func(t *testing.T, e engine, fn string) interface{} {
+		s, err := e.rewrite(fn, nil)
+		require.NoError(t, err)
+		res, err := s.ExecuteRewrite(context.Background(), map[string]interface{}{
+			"path": []interface{}{"db", "_rewrite", "a"},
+		})
+		require.NoError(t, err)
+		return res
+	})
+}
@@ -12,13 +12,11 @@ import (
 var _ port.FilterServer = (*FilterServer)(nil)
 
 type FilterServer struct {
-	vm *goja.Runtime
+	pool *pool
 }
 
 // NewFilterServer compiles a filter function into a FilterServer
-func NewFilterServer(fn string) (port.FilterServer, error) {
-	vm := goja.New()
-
+func NewFilterServer(fn string, ddoc *model.Document) (port.FilterServer, error) {
 	// Wrap filter function
 	script := fmt.Sprintf(`
 		var filterFn = %s;
@@ -27,12 +25,19 @@ func NewFilterServer(fn string) (port.FilterServer, error) {
 		}
 	`, fn)
 
-	_, err := vm.RunString(script)
+	p, err := getPool("filter", fn, ddoc, func(vm *goja.Runtime) error {
+		enableRequire(vm, ddoc, false)
+		_, err := vm.RunString(script)
+		if err != nil {
+			return fmt.Errorf("failed to compile filter function: %w", err)
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to compile filter function: %w", err)
+		return nil, err
 	}
 
-	return &FilterServer{vm: vm}, nil
+	return &FilterServer{pool: p}, nil
 }
 
 // ExecuteFilter tests if a document passes the filter
@@ -51,17 +56,22 @@ func (f *FilterServer) ExecuteFilter(ctx context.Context, doc *model.Document, r
 		}
 	}
 
-	// Call filter function
-	var executeFilter goja.Callable
-	if err := f.vm.ExportTo(f.vm.Get("executeFilter"), &executeFilter); err != nil {
-		return false, err
-	}
+	var pass bool
+	err := f.pool.Do(ctx, func(vm *goja.Runtime) error {
+		// Call filter function
+		var executeFilter goja.Callable
+		if err := vm.ExportTo(vm.Get("executeFilter"), &executeFilter); err != nil {
+			return err
+		}
 
-	result, err := executeFilter(goja.Undefined(), f.vm.ToValue(simpleDoc), f.vm.ToValue(req))
-	if err != nil {
-		return false, err
-	}
+		result, err := executeFilter(goja.Undefined(), vm.ToValue(simpleDoc), vm.ToValue(req))
+		if err != nil {
+			return err
+		}
 
-	// Convert result to boolean
-	return result.ToBoolean(), nil
+		// Convert result to boolean
+		pass = result.ToBoolean()
+		return nil
+	})
+	return pass, err
 }
@@ -0,0 +1,197 @@
+package gojaview
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dop251/goja"
+	"github.com/goydb/goydb/pkg/model"
+	"github.com/goydb/goydb/pkg/port"
+)
+
+var _ port.ListServer = (*ListServer)(nil)
+
+// ListServer executes a compiled list function using goja.
+type ListServer struct {
+	pool *pool
+}
+
+// NewListServer compiles a list function into a ListServer.
+func NewListServer(fn string, ddoc *model.Document) (port.ListServer, error) {
+	script := fmt.Sprintf(`
+		var isArray = Array.isArray;
+		var __provides = [];
+		function provides(type, fn) {
+			__provides.push([type, fn]);
+		}
+		function registerType(key) {
+			__registerType(key, Array.prototype.slice.call(arguments, 1));
+		}
+		function toJSON(obj) {
+			return JSON.stringify(obj);
+		}
+		function start(resp) {
+			__start(resp || {});
+		}
+		function send(chunk) {
+			__send(String(chunk));
+		}
+		function getRow() {
+			return __getRow();
+		}
+		var listFn = %s;
+		function executeList(head, req) {
+			__provides = [];
+			return listFn(head, req);
+		}
+	`, fn)
+
+	p, err := getPool("list", fn, ddoc, func(vm *goja.Runtime) error {
+		enableRequire(vm, ddoc, false)
+		_, err := vm.RunString(script)
+		if err != nil {
+			return fmt.Errorf("failed to compile list function: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &ListServer{pool: p}, nil
+}
+
+// ExecuteList runs the list function, if the function registered formats
+// using provides() the format acceptable for the request is rendered.
+// The timeout restarts with every row fetched and chunk sent.
+func (s *ListServer) ExecuteList(ctx context.Context, head, req map[string]interface{}, rows port.ListRows, out port.ListWriter) error {
+	return s.pool.Stream(ctx, func(vm *goja.Runtime, touch func()) error {
+		return executeList(ctx, vm, touch, head, req, rows, out)
+	})
+}
+
+func executeList(ctx context.Context, vm *goja.Runtime, touch func(), head, req map[string]interface{}, rows port.ListRows, out port.ListWriter) error {
+	var executeList goja.Callable
+	if err := vm.ExportTo(vm.Get("executeList"), &executeList); err != nil {
+		return err
+	}
+
+	mimeTypes := model.NewMimeTypes()
+	resp := &listResponse{out: out, headers: make(map[string]string)}
+	err := setFuncs(vm, map[string]interface{}{
+		"__registerType": func(key string, types []string) {
+			mimeTypes.Register(key, types...)
+		},
+		"__start": func(v map[string]interface{}) error {
+			res, err := parseShowResponse(v)
+			if err != nil {
+				return err
+			}
+			resp.set(res.Code, res.Headers)
+			return nil
+		},
+		"__send": func(chunk string) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			touch()
+			return resp.send(chunk)
+		},
+		"__getRow": func() (goja.Value, error) {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			touch()
+			if err := resp.start(); err != nil {
+				return nil, err
+			}
+			row, err := rows()
+			if err != nil {
+				return nil, err
+			}
+			if row == nil {
+				return goja.Null(), nil
+			}
+			return vm.ToValue(row), nil
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	result, err := executeList(goja.Undefined(), vm.ToValue(head), vm.ToValue(req))
+	if err != nil {
+		return fmt.Errorf("list function error: %w", err)
+	}
+
+	if goja.IsUndefined(result) || goja.IsNull(result) {
+		var provided goja.Callable
+		provided, resp.contentType, err = negotiateProvided(vm, mimeTypes, req)
+		if err != nil {
+			return err
+		}
+		if provided != nil {
+			result, err = provided(goja.Undefined())
+			if err != nil {
+				return fmt.Errorf("list function error: %w", err)
+			}
+		}
+	}
+
+	// the return value is the last chunk of the response
+	var tail string
+	if !goja.IsUndefined(result) && !goja.IsNull(result) {
+		tail = result.String()
+	}
+	return resp.send(tail)
+}
+
+func setFuncs(vm *goja.Runtime, funcs map[string]interface{}) error {
+	for name, fn := range funcs {
+		if err := vm.Set(name, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listResponse starts the response of a list function
+// on the first row fetched or chunk sent.
+type listResponse struct {
+	out         port.ListWriter
+	code        int
+	headers     map[string]string
+	contentType string // of the format selected by provides()
+	started     bool
+}
+
+// set records the code and headers passed to start()
+func (l *listResponse) set(code int, headers map[string]string) {
+	if code != 0 {
+		l.code = code
+	}
+	for k, v := range headers {
+		l.headers[k] = v
+	}
+}
+
+func (l *listResponse) start() error {
+	if l.started {
+		return nil
+	}
+	l.started = true
+	if _, ok := l.headers["Content-Type"]; !ok && l.contentType != "" {
+		l.headers["Content-Type"] = l.contentType
+	}
+	return l.out.Start(l.code, l.headers)
+}
+
+func (l *listResponse) send(chunk string) error {
+	if err := l.start(); err != nil {
+		return err
+	}
+	if chunk == "" {
+		return nil
+	}
+	return l.out.Send(chunk)
+}
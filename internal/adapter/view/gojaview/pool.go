@@ -0,0 +1,319 @@
+package gojaview
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/goydb/goydb/pkg/model"
+	"github.com/goydb/goydb/pkg/port"
+)
+
+// maxPools limits the number of runtime pools kept in the pool
+// registry, the least recently used pools are evicted first
+const maxPools = 256
+
+// Limits restrict the execution of JavaScript functions
+type Limits struct {
+	// PoolSize is the maximum number of runtimes executing
+	// a function concurrently, changes apply to existing pools
+	PoolSize int
+	// Timeout is the maximum duration of a single call, calls
+	// exceeding the timeout are interrupted with port.ErrTimeout
+	Timeout time.Duration
+	// MaxCalls is the number of calls after which a runtime is
+	// replaced by a fresh one to release the memory it accumulated
+	MaxCalls int
+	// MaxStackSize is the maximum call stack size of a runtime
+	MaxStackSize int
+}
+
+// DefaultLimits are used if no config is set
+var DefaultLimits = Limits{
+	PoolSize:     4,
+	Timeout:      5 * time.Second,
+	MaxCalls:     10000,
+	MaxStackSize: 10000,
+}
+
+// ConfigDefaults are the config values of the DefaultLimits
+var ConfigDefaults = map[string]map[string]string{
+	"couchdb": {
+		"os_process_timeout": "5000",
+	},
+	"query_server_config": {
+		"os_process_limit":  "4",
+		"vm_max_calls":      "10000",
+		"vm_max_stack_size": "10000",
+	},
+}
+
+var configGetter atomic.Pointer[func(section, key string) (string, bool)]
+
+// Configure reads the limits from the config on every call,
+// changes of the config apply to subsequent calls.
+func Configure(get func(section, key string) (string, bool)) {
+	configGetter.Store(&get)
+}
+
+// currentLimits returns the configured limits
+func currentLimits() Limits {
+	l := DefaultLimits
+	get := configGetter.Load()
+	if get == nil {
+		return l
+	}
+	readInt := func(section, key string, v *int) {
+		s, ok := (*get)(section, key)
+		if !ok {
+			return
+		}
+		if n, err := strconv.Atoi(s); err == nil && n >= 0 {
+			*v = n
+		}
+	}
+	timeout := int(l.Timeout / time.Millisecond)
+	readInt("couchdb", "os_process_timeout", &timeout)
+	l.Timeout = time.Duration(timeout) * time.Millisecond
+	readInt("query_server_config", "os_process_limit", &l.PoolSize)
+	readInt("query_server_config", "vm_max_calls", &l.MaxCalls)
+	readInt("query_server_config", "vm_max_stack_size", &l.MaxStackSize)
+	return l
+}
+
+// poolKey identifies the pool of a function of a design document revision
+type poolKey struct {
+	kind, fn, id, rev string
+}
+
+// pools shares the pools of compiled functions, servers of the same
+// function e.g. created per request reuse the compiled runtimes.
+var pools = struct {
+	sync.Mutex
+	m map[poolKey]*pool
+}{m: make(map[poolKey]*pool)}
+
+// runtime is a pooled runtime
+type runtime struct {
+	vm    *goja.Runtime
+	calls int
+}
+
+// pool executes calls of a function on a limited number of runtimes.
+// Goja doesn't account memory per runtime, memory is bounded by the
+// call stack size and by replacing runtimes after MaxCalls calls.
+type pool struct {
+	setup func(vm *goja.Runtime) error
+	// lastUsed is the unix nano time of the last use, used to
+	// evict the least recently used pools from the registry
+	lastUsed atomic.Int64
+
+	mu     sync.Mutex
+	idle   []*runtime
+	active int
+	// released is closed if a runtime is released
+	released chan struct{}
+}
+
+// getPool returns the pool of the function fn of kind, setup compiles
+// the function into a fresh runtime. The first runtime is created
+// immediately to report compilation errors.
+func getPool(kind, fn string, ddoc *model.Document, setup func(vm *goja.Runtime) error) (*pool, error) {
+	var key poolKey
+	// without revision the content of the modules is unknown
+	shared := ddoc == nil || ddoc.Rev != ""
+	if shared {
+		key = poolKey{kind: kind, fn: fn}
+		if ddoc != nil {
+			key.id, key.rev = ddoc.ID, ddoc.Rev
+		}
+		pools.Lock()
+		p, ok := pools.m[key]
+		pools.Unlock()
+		if ok {
+			p.touch()
+			return p, nil
+		}
+	}
+
+	l := currentLimits()
+	p := &pool{setup: setup}
+	p.touch()
+	rt, err := p.newRuntime(l)
+	if err != nil {
+		return nil, err
+	}
+	p.idle = append(p.idle, rt)
+
+	if !shared {
+		return p, nil
+	}
+	pools.Lock()
+	defer pools.Unlock()
+	if existing, ok := pools.m[key]; ok {
+		return existing, nil
+	}
+	if len(pools.m) >= maxPools {
+		evictPool()
+	}
+	pools.m[key] = p
+	return p, nil
+}
+
+// evictPool removes the least recently used pool from the registry,
+// servers using the pool keep it, pools.Mutex must be held
+func evictPool() {
+	var (
+		oldest   poolKey
+		lastUsed int64
+		found    bool
+	)
+	for key, p := range pools.m {
+		if t := p.lastUsed.Load(); !found || t < lastUsed {
+			oldest, lastUsed, found = key, t, true
+		}
+	}
+	delete(pools.m, oldest)
+}
+
+func (p *pool) touch() {
+	p.lastUsed.Store(time.Now().UnixNano())
+}
+
+// newRuntime creates a runtime using setup, setup is subject to the timeout
+func (p *pool) newRuntime(l Limits) (*runtime, error) {
+	vm := goja.New()
+	if l.MaxStackSize > 0 {
+		vm.SetMaxCallStackSize(l.MaxStackSize)
+	}
+
+	var timer *time.Timer
+	if l.Timeout > 0 {
+		timer = time.AfterFunc(l.Timeout, func() {
+			vm.Interrupt(port.ErrTimeout)
+		})
+	}
+	err := p.setup(vm)
+	if timer != nil && !timer.Stop() {
+		return nil, port.ErrTimeout
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &runtime{vm: vm}, nil
+}
+
+// Do executes fn on a runtime of the pool, fn is interrupted
+// if it exceeds the timeout or the context is done.
+func (p *pool) Do(ctx context.Context, fn func(vm *goja.Runtime) error) error {
+	return p.Stream(ctx, func(vm *goja.Runtime, _ func()) error {
+		return fn(vm)
+	})
+}
+
+// Stream is like Do but fn can call touch to restart the timeout,
+// used by functions that stream e.g. list functions fetching rows.
+func (p *pool) Stream(ctx context.Context, fn func(vm *goja.Runtime, touch func()) error) error {
+	l := currentLimits()
+	if err := p.acquire(ctx, l.PoolSize); err != nil {
+		return err
+	}
+	defer p.release()
+	p.touch()
+
+	rt, err := p.get(l)
+	if err != nil {
+		return err
+	}
+
+	var interrupted atomic.Bool
+	interrupt := func(v interface{}) {
+		interrupted.Store(true)
+		rt.vm.Interrupt(v)
+	}
+	var timer *time.Timer
+	touch := func() {}
+	if l.Timeout > 0 {
+		timer = time.AfterFunc(l.Timeout, func() { interrupt(port.ErrTimeout) })
+		touch = func() { timer.Reset(l.Timeout) }
+	}
+	stop := context.AfterFunc(ctx, func() { interrupt(ctx.Err()) })
+
+	err = fn(rt.vm, touch)
+
+	discard := !stop()
+	if timer != nil && !timer.Stop() {
+		discard = true
+	}
+	rt.calls++
+	// the state of an interrupted runtime is undefined
+	if discard || interrupted.Load() || (l.MaxCalls > 0 && rt.calls >= l.MaxCalls) {
+		return err
+	}
+	p.put(rt, l.PoolSize)
+	return err
+}
+
+// acquire waits until less than size runtimes are executing, the
+// size is read on every call so config changes apply to the pool
+func (p *pool) acquire(ctx context.Context, size int) error {
+	if size < 1 {
+		size = 1
+	}
+	for {
+		p.mu.Lock()
+		if p.active < size {
+			p.active++
+			p.mu.Unlock()
+			return nil
+		}
+		if p.released == nil {
+			p.released = make(chan struct{})
+		}
+		released := p.released
+		p.mu.Unlock()
+
+		select {
+		case <-released:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// release wakes up the calls waiting in acquire
+func (p *pool) release() {
+	p.mu.Lock()
+	p.active--
+	if p.released != nil {
+		close(p.released)
+		p.released = nil
+	}
+	p.mu.Unlock()
+}
+
+// get returns an idle runtime or creates a new one
+func (p *pool) get(l Limits) (*runtime, error) {
+	p.mu.Lock()
+	if n := len(p.idle); n > 0 {
+		rt := p.idle[n-1]
+		p.idle = p.idle[:n-1]
+		p.mu.Unlock()
+		return rt, nil
+	}
+	p.mu.Unlock()
+	return p.newRuntime(l)
+}
+
+// put returns the runtime to the pool, runtimes exceeding
+// the size of a shrunk pool are dropped
+func (p *pool) put(rt *runtime, size int) {
+	p.mu.Lock()
+	if len(p.idle) < size || len(p.idle) == 0 {
+		p.idle = append(p.idle, rt)
+	}
+	p.mu.Unlock()
+}
@@ -0,0 +1,213 @@
+//go:build !nogoja
+
+package gojaview
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/goydb/goydb/internal/adapter/logger"
+	"github.com/goydb/goydb/pkg/model"
+	"github.com/goydb/goydb/pkg/port"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setTestConfig(t *testing.T, config map[string]map[string]string) {
+	t.Helper()
+	Configure(func(section, key string) (string, bool) {
+		v, ok := config[section][key]
+		return v, ok
+	})
+	t.Cleanup(func() { configGetter.Store(nil) })
+}
+
+func poolTestDocs() []*model.Document {
+	return []*model.Document{{ID: "a", Data: map[string]interface{}{}}}
+}
+
+func TestPool_Timeout(t *testing.T) {
+	setTestConfig(t, map[string]map[string]string{
+		"couchdb": {"os_process_timeout": "50"},
+	})
+
+	s, err := NewViewServer(`function(doc) { if (doc.loop) { while (true) {} } emit(doc._id, 1); }`, nil)
+	require.NoError(t, err)
+
+	start := time.Now()
+	_, err = s.ExecuteView(context.Background(), []*model.Document{
+		{ID: "a", Data: map[string]interface{}{"loop": true}},
+	})
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, port.ErrTimeout), err)
+	assert.Less(t, time.Since(start), 5*time.Second)
+
+	// the interrupted runtime is replaced
+	res, err := s.ExecuteView(context.Background(), poolTestDocs())
+	require.NoError(t, err)
+	assert.Len(t, res, 1)
+
+	// compilation is limited as well
+	_, err = NewFilterServer(`(function() { while (true) {} })()`, nil)
+	assert.True(t, errors.Is(err, port.ErrTimeout), err)
+}
+
+func TestPool_Context(t *testing.T) {
+	s, err := NewUpdateServer(`function(doc, req) { while (true) {} }`, nil)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = s.ExecuteUpdate(ctx, nil, map[string]interface{}{})
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), err)
+}
+
+func TestPool_Recycle(t *testing.T) {
+	setTestConfig(t, map[string]map[string]string{
+		"query_server_config": {"os_process_limit": "1", "vm_max_calls": "2"},
+	})
+
+	s, err := NewViewServer(`function(doc) { this.calls = (this.calls || 0) + 1; emit(null, this.calls); }`, nil)
+	require.NoError(t, err)
+
+	var calls []interface{}
+	for i := 0; i < 3; i++ {
+		res, err := s.ExecuteView(context.Background(), poolTestDocs())
+		require.NoError(t, err)
+		calls = append(calls, res[0].Value)
+	}
+	assert.EqualValues(t, []interface{}{int64(1), int64(2), int64(1)}, calls)
+}
+
+func TestPool_StackSize(t *testing.T) {
+	setTestConfig(t, map[string]map[string]string{
+		"query_server_config": {"vm_max_stack_size": "100"},
+	})
+
+	s, err := NewShowServer(`function(doc, req) { function f(n) { return f(n + 1); } return f(0); }`, nil)
+	require.NoError(t, err)
+	_, err = s.ExecuteShow(context.Background(), nil, map[string]interface{}{})
+	require.Error(t, err)
+}
+
+func TestPool_Concurrent(t *testing.T) {
+	s, err := NewViewServer(`function(doc) { emit(doc._id, doc.n * 2); }`, nil)
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				res, err := s.ExecuteView(context.Background(), []*model.Document{
+					{ID: "a", Data: map[string]interface{}{"n": n}},
+				})
+				if assert.NoError(t, err) && assert.Len(t, res, 1) {
+					assert.EqualValues(t, n*2, res[0].Value)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestPool_Shared(t *testing.T) {
+	ddoc := &model.Document{ID: "_design/shared", Rev: "1-a"}
+	a, err := NewFilterServer(`function(doc, req) { return true; }`, ddoc)
+	require.NoError(t, err)
+	b, err := NewFilterServer(`function(doc, req) { return true; }`, ddoc)
+	require.NoError(t, err)
+	assert.Same(t, a.(*FilterServer).pool, b.(*FilterServer).pool)
+
+	// without revision the modules of the design document are unknown
+	ddoc.Rev = ""
+	c, err := NewFilterServer(`function(doc, req) { return true; }`, ddoc)
+	require.NoError(t, err)
+	assert.NotSame(t, a.(*FilterServer).pool, c.(*FilterServer).pool)
+}
+
+func TestPool_SharedLogger(t *testing.T) {
+	fn := `function(newDoc) { log(newDoc._id); }`
+	var a, b bytes.Buffer
+	sa, err := NewValidateServer(fn, nil, logger.New(model.LogLevelInfo, &a))
+	require.NoError(t, err)
+	sb, err := NewValidateServer(fn, nil, logger.New(model.LogLevelInfo, &b))
+	require.NoError(t, err)
+	assert.Same(t, sa.(*ValidateServer).pool, sb.(*ValidateServer).pool)
+
+	// each server logs to its own logger
+	require.NoError(t, sb.ExecuteValidation(context.Background(), map[string]interface{}{"_id": "to-b"}, nil, nil, nil))
+	require.NoError(t, sa.ExecuteValidation(context.Background(), map[string]interface{}{"_id": "to-a"}, nil, nil, nil))
+	assert.Contains(t, a.String(), "to-a")
+	assert.NotContains(t, a.String(), "to-b")
+	assert.Contains(t, b.String(), "to-b")
+	assert.NotContains(t, b.String(), "to-a")
+}
+
+func TestPool_Resize(t *testing.T) {
+	config := map[string]map[string]string{
+		"query_server_config": {"os_process_limit": "1"},
+	}
+	setTestConfig(t, config)
+
+	p, err := getPool("test", "resize", nil, func(vm *goja.Runtime) error { return nil })
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, p.acquire(ctx, currentLimits().PoolSize))
+	defer p.release()
+	timeout, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, p.acquire(timeout, currentLimits().PoolSize), context.DeadlineExceeded)
+
+	// the existing pool grows with the config
+	config["query_server_config"]["os_process_limit"] = "2"
+	require.NoError(t, p.Do(ctx, func(vm *goja.Runtime) error { return nil }))
+}
+
+func TestPool_Evict(t *testing.T) {
+	setup := func(vm *goja.Runtime) error { return nil }
+	first, err := getPool("test", "first", &model.Document{ID: "_design/evict", Rev: "1-a"}, setup)
+	require.NoError(t, err)
+	for i := 1; i < maxPools; i++ {
+		_, err := getPool("test", strconv.Itoa(i), &model.Document{ID: "_design/evict", Rev: "1-a"}, setup)
+		require.NoError(t, err)
+	}
+	// the first pool is used recently
+	again, err := getPool("test", "first", &model.Document{ID: "_design/evict", Rev: "1-a"}, setup)
+	require.NoError(t, err)
+	assert.Same(t, first, again)
+
+	_, err = getPool("test", "new", &model.Document{ID: "_design/evict", Rev: "1-a"}, setup)
+	require.NoError(t, err)
+
+	pools.Lock()
+	defer pools.Unlock()
+	assert.LessOrEqual(t, len(pools.m), maxPools)
+	assert.Same(t, first, pools.m[poolKey{kind: "test", fn: "first", id: "_design/evict", rev: "1-a"}])
+	_, ok := pools.m[poolKey{kind: "test", fn: "new", id: "_design/evict", rev: "1-a"}]
+	assert.True(t, ok)
+}
+
+func TestReducer_Timeout(t *testing.T) {
+	setTestConfig(t, map[string]map[string]string{
+		"couchdb": {"os_process_timeout": "50"},
+	})
+
+	r, err := NewReducer(`function(keys, values, rereduce) { while (true) {} }`, nil, logger.NewNoLog())
+	require.NoError(t, err)
+	r.Reduce(&model.Document{Key: "a", Value: 1})
+	r.Reduce(&model.Document{Key: "b", Value: 1})
+	r.Result()
+
+	err = r.(port.ReducerError).Err()
+	assert.True(t, errors.Is(err, port.ErrTimeout), err)
+}
@@ -3,7 +3,6 @@ package gojaview
 import (
 	"context"
 	"fmt"
-	"reflect"
 
 	"github.com/dop251/goja"
 	"github.com/goydb/goydb/pkg/model"
@@ -12,25 +11,28 @@ import (
 
 const reduceOver = 1000
 
+var _ port.ReducerError = (*Reducer)(nil)
+
 type Reducer struct {
-	vm          *goja.Runtime
+	pool        *pool
+	err         error // first error of the reduce function
 	reducedDocs []*model.Document
 	keys        []interface{}
+	group       string   // of the collected keys, see model.ViewKeyString
 	docIDs      []string // parallel to keys; only populated during first-pass reduce
 	values      []interface{}
 	reduceOver  int
 	logger      port.Logger
 }
 
-// NewReducerBuilder returns a ReducerServerBuilder that captures the logger
-func NewReducerBuilder(logger port.Logger) port.ReducerServerBuilder {
-	return func(source string) (port.Reducer, error) {
-		return NewReducer(source, logger)
+// NewReducerBuilder returns a reducer builder that captures the logger
+func NewReducerBuilder(logger port.Logger) port.DesignDocBuilder[port.Reducer] {
+	return func(source string, ddoc *model.Document) (port.Reducer, error) {
+		return NewReducer(source, ddoc, logger)
 	}
 }
 
-func NewReducer(source string, logger port.Logger) (port.Reducer, error) {
-	vm := goja.New()
+func NewReducer(source string, ddoc *model.Document, logger port.Logger) (port.Reducer, error) {
 	fn := `
 	var _result = [];
 	var _keys = [];
@@ -43,18 +45,25 @@ func NewReducer(source string, logger port.Logger) (port.Reducer, error) {
 		});
 		return _sum;
 	}`
-	_ = vm.Set("println", fmt.Println)
-	_, err := vm.RunString(fn)
-	if err != nil {
-		return nil, fmt.Errorf("script error %v: %w", fn, err)
-	}
-	_, err = vm.RunScript("reducer.js", "var reduceFn = "+source+";")
+	p, err := getPool("reduce", source, ddoc, func(vm *goja.Runtime) error {
+		enableRequire(vm, ddoc, true)
+		_ = vm.Set("println", fmt.Println)
+		_, err := vm.RunString(fn)
+		if err != nil {
+			return fmt.Errorf("script error %v: %w", fn, err)
+		}
+		_, err = vm.RunScript("reducer.js", "var reduceFn = "+source+";")
+		if err != nil {
+			return fmt.Errorf("script error %v: %w", fn, err)
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("script error %v: %w", fn, err)
+		return nil, err
 	}
 
 	return &Reducer{
-		vm:         vm,
+		pool:       p,
 		reduceOver: reduceOver,
 		logger:     logger,
 	}, nil
@@ -65,13 +74,15 @@ func (r *Reducer) Reduce(doc *model.Document) {
 }
 
 func (r *Reducer) reduceDoc(doc *model.Document, rereduce bool) {
+	group := model.ViewKeyString(doc.Key)
 	tooManyElements := len(r.keys) > 0 && len(r.keys)%r.reduceOver == 0
-	keyChange := len(r.keys) > 0 && !reflect.DeepEqual(r.keys[len(r.keys)-1], doc.Key)
+	keyChange := len(r.keys) > 0 && r.group != group
 
 	if tooManyElements || keyChange {
 		r.reduce(rereduce)
 	}
 
+	r.group = group
 	r.keys = append(r.keys, doc.Key)
 	if !rereduce {
 		r.docIDs = append(r.docIDs, doc.ID)
@@ -99,15 +110,27 @@ func (r *Reducer) reduce(rereduce bool) {
 		jsKeys = pairs
 	} // rereduce: jsKeys stays nil → JS null
 
-	_ = r.vm.Set("rereduce", rereduce)
-	_ = r.vm.Set("_keys", jsKeys)
-	_ = r.vm.Set("_values", values)
-	_, err := r.vm.RunString(`_result = reduceFn(_keys, _values, rereduce);`)
-	if err != nil {
-		r.logger.Errorf(context.Background(), "javascript error", "error", err)
+	var resultData interface{}
+	// once failed the result is discarded, further calls would only
+	// run into the same error or timeout
+	if r.err == nil {
+		err := r.pool.Do(context.Background(), func(vm *goja.Runtime) error {
+			_ = vm.Set("rereduce", rereduce)
+			_ = vm.Set("_keys", jsKeys)
+			_ = vm.Set("_values", values)
+			_, err := vm.RunString(`_result = reduceFn(_keys, _values, rereduce);`)
+			if err != nil {
+				return err
+			}
+			resultData = vm.Get("_result").Export()
+			return nil
+		})
+		if err != nil {
+			r.logger.Errorf(context.Background(), "javascript error", "error", err)
+			r.err = err
+		}
 	}
 
-	resultData := r.vm.Get("_result").Export()
 	r.reducedDocs = append(r.reducedDocs, &model.Document{
 		Key:   keys[0],
 		Value: resultData,
@@ -172,3 +195,8 @@ func (r *Reducer) Result() map[interface{}]interface{} {
 	}
 	return result
 }
+
+// Err returns the first error of the reduce function
+func (r *Reducer) Err() error {
+	return r.err
+}
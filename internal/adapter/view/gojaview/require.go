@@ -0,0 +1,192 @@
+package gojaview
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/dop251/goja"
+	"github.com/goydb/goydb/pkg/model"
+)
+
+// maxCachedModules limits the number of compiled modules
+// kept in the module cache
+const maxCachedModules = 1024
+
+// moduleKey identifies a module of a design document revision
+type moduleKey struct {
+	id, rev, path string
+}
+
+// moduleCache caches the compiled modules, programs can be
+// shared between runtimes.
+var moduleCache = struct {
+	sync.Mutex
+	programs map[moduleKey]*goja.Program
+}{programs: make(map[moduleKey]*goja.Program)}
+
+// moduleLoader implements CommonJS require for a runtime, modules are
+// strings of the design document addressed by their property path
+// e.g. views/lib/foo.
+type moduleLoader struct {
+	vm   *goja.Runtime
+	ddoc *model.Document
+	// viewsLib restricts require to modules below views.lib
+	viewsLib bool
+	// modules are the loaded modules of the runtime
+	modules map[string]*goja.Object
+}
+
+// enableRequire defines require in the runtime. If viewsLib is set
+// (map and reduce functions) only modules below views.lib can be
+// required, other functions can require any string of the design
+// document.
+func enableRequire(vm *goja.Runtime, ddoc *model.Document, viewsLib bool) {
+	l := &moduleLoader{
+		vm:       vm,
+		ddoc:     ddoc,
+		viewsLib: viewsLib,
+		modules:  make(map[string]*goja.Object),
+	}
+	_ = vm.Set("require", l.require(nil))
+}
+
+// require returns the require function of the module at parent,
+// relative paths are resolved against the parent.
+func (l *moduleLoader) require(parent []string) func(call goja.FunctionCall) goja.Value {
+	return func(call goja.FunctionCall) goja.Value {
+		name := call.Argument(0).String()
+		path, err := l.resolve(parent, name)
+		if err != nil {
+			l.throw(err)
+		}
+
+		id := strings.Join(path, "/")
+		if module, ok := l.modules[id]; ok {
+			return module.Get("exports")
+		}
+
+		program, err := l.compile(path)
+		if err != nil {
+			l.throw(err)
+		}
+		fn, err := l.vm.RunProgram(program)
+		if err != nil {
+			l.throw(fmt.Errorf("require('%s') failed: %w", name, err))
+		}
+		moduleFn, ok := goja.AssertFunction(fn)
+		if !ok {
+			l.throw(fmt.Errorf("require('%s') failed: module is not a function", name))
+		}
+
+		module := l.vm.NewObject()
+		exports := l.vm.NewObject()
+		_ = module.Set("id", id)
+		_ = module.Set("exports", exports)
+		// registered before execution to support circular requires
+		l.modules[id] = module
+
+		dir := path[:len(path)-1]
+		_, err = moduleFn(goja.Undefined(), module, exports, l.vm.ToValue(l.require(dir)))
+		if err != nil {
+			delete(l.modules, id)
+			if ex, ok := err.(*goja.Exception); ok {
+				panic(ex.Value())
+			}
+			l.throw(err)
+		}
+
+		return module.Get("exports")
+	}
+}
+
+// resolve resolves the name against the parent path
+func (l *moduleLoader) resolve(parent []string, name string) ([]string, error) {
+	if l.ddoc == nil {
+		return nil, fmt.Errorf("invalid_require_path: require('%s') is only available in design documents", name)
+	}
+
+	var path []string
+	if strings.HasPrefix(name, "./") || strings.HasPrefix(name, "../") {
+		path = append(path, parent...)
+	}
+	for _, part := range strings.Split(name, "/") {
+		switch part {
+		case "", ".":
+		case "..":
+			if len(path) == 0 {
+				return nil, fmt.Errorf("invalid_require_path: require('%s') leaves the design document", name)
+			}
+			path = path[:len(path)-1]
+		default:
+			path = append(path, part)
+		}
+	}
+	if len(path) == 0 {
+		return nil, fmt.Errorf("invalid_require_path: require('%s') has an empty path", name)
+	}
+	if l.viewsLib && (len(path) < 3 || path[0] != "views" || path[1] != "lib") {
+		return nil, fmt.Errorf("invalid_require_path: require('%s') must be below views/lib in views", name)
+	}
+	return path, nil
+}
+
+// compile returns the compiled module at path, the module source
+// is wrapped in a function taking module, exports and require.
+func (l *moduleLoader) compile(path []string) (*goja.Program, error) {
+	id := strings.Join(path, "/")
+	key := moduleKey{id: l.ddoc.ID, rev: l.ddoc.Rev, path: id}
+
+	// without revision the content of the module is unknown
+	cacheable := l.ddoc.Rev != ""
+	if cacheable {
+		moduleCache.Lock()
+		program, ok := moduleCache.programs[key]
+		moduleCache.Unlock()
+		if ok {
+			return program, nil
+		}
+	}
+
+	var value interface{} = l.ddoc.Data
+	for i, part := range path {
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid_require_path: %q is not an object (require('%s'))", strings.Join(path[:i], "/"), id)
+		}
+		value, ok = obj[part]
+		if !ok {
+			return nil, fmt.Errorf("invalid_require_path: object has no property %q (require('%s'))", part, id)
+		}
+	}
+	source, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid_require_path: %q is not a string (require('%s'))", id, id)
+	}
+
+	program, err := goja.Compile(id, "(function (module, exports, require) {"+source+"\n})", false)
+	if err != nil {
+		return nil, fmt.Errorf("compilation of module %q failed: %w", id, err)
+	}
+
+	if !cacheable {
+		return program, nil
+	}
+	moduleCache.Lock()
+	if len(moduleCache.programs) >= maxCachedModules {
+		moduleCache.programs = make(map[moduleKey]*goja.Program)
+	}
+	moduleCache.programs[key] = program
+	moduleCache.Unlock()
+
+	return program, nil
+}
+
+// throw throws the error as JavaScript Error
+func (l *moduleLoader) throw(err error) {
+	obj, e := l.vm.New(l.vm.Get("Error"), l.vm.ToValue(err.Error()))
+	if e != nil {
+		panic(l.vm.NewGoError(err))
+	}
+	panic(obj)
+}
@@ -0,0 +1,124 @@
+//go:build !nogoja
+
+package gojaview
+
+import (
+	"context"
+	"testing"
+
+	"github.com/goydb/goydb/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func requireTestDDoc() *model.Document {
+	return &model.Document{
+		ID:  "_design/app",
+		Rev: "1-abc",
+		Data: map[string]interface{}{
+			"views": map[string]interface{}{
+				"lib": map[string]interface{}{
+					"util":   `exports.double = function(x) { return require('./math').mul(x, 2); };`,
+					"math":   `exports.mul = function(a, b) { return a * b; };`,
+					"cycle":  `exports.a = 1; exports.b = require('./cycle2').b;`,
+					"cycle2": `exports.b = require('./cycle').a + 1;`,
+					"throws": `throw new Error("broken module");`,
+				},
+			},
+			"lib": map[string]interface{}{
+				"greeting": `module.exports = function(name) { return "Hello " + name; };`,
+				"upper":    `var g = require('./greeting'); exports.greet = function(n) { return g(n).toUpperCase(); };`,
+			},
+		},
+	}
+}
+
+func TestRequire_View(t *testing.T) {
+	ddoc := requireTestDDoc()
+	s, err := NewViewServer(`function(doc) {
+		var util = require('views/lib/util');
+		emit(doc._id, util.double(doc.n));
+		emit("cycle", require('views/lib/cycle').b);
+		emit("same", require('views/lib/util') === util);
+	}`, ddoc)
+	require.NoError(t, err)
+
+	res, err := s.ExecuteView(context.Background(), []*model.Document{
+		{ID: "a", Data: map[string]interface{}{"n": 21}},
+	})
+	require.NoError(t, err)
+	require.Len(t, res, 3)
+	assert.EqualValues(t, 42, res[0].Value)
+	assert.EqualValues(t, 2, res[1].Value)
+	assert.Equal(t, true, res[2].Value)
+}
+
+func TestRequire_Errors(t *testing.T) {
+	ddoc := requireTestDDoc()
+	tests := []struct {
+		name   string
+		script string
+		err    string
+	}{
+		{"missing", `function(doc) { require('views/lib/missing'); }`, `object has no property "missing"`},
+		{"outside views lib", `function(doc) { require('lib/greeting'); }`, "must be below views/lib"},
+		{"leaves ddoc", `function(doc) { require('../views/lib/util'); }`, "leaves the design document"},
+		{"not a string", `function(doc) { require('views/lib'); }`, "must be below views/lib"},
+		{"module error", `function(doc) { require('views/lib/throws'); }`, "broken module"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewViewServer(tt.script, ddoc)
+			require.NoError(t, err)
+			_, err = s.ExecuteView(context.Background(), []*model.Document{
+				{ID: "a", Data: map[string]interface{}{}},
+			})
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.err)
+		})
+	}
+
+	s, err := NewViewServer(`function(doc) { require('views/lib/util'); }`, nil)
+	require.NoError(t, err)
+	_, err = s.ExecuteView(context.Background(), []*model.Document{
+		{ID: "a", Data: map[string]interface{}{}},
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "only available in design documents")
+}
+
+func TestRequire_Show(t *testing.T) {
+	ddoc := requireTestDDoc()
+	s, err := NewShowServer(`function(doc, req) {
+		return require('lib/upper').greet(doc.name);
+	}`, ddoc)
+	require.NoError(t, err)
+
+	res, err := s.ExecuteShow(context.Background(), map[string]interface{}{"name": "goydb"}, map[string]interface{}{})
+	require.NoError(t, err)
+	assert.Equal(t, "HELLO GOYDB", res.Body)
+}
+
+func TestRequire_CacheByRev(t *testing.T) {
+	ddoc := requireTestDDoc()
+	ddoc.ID = "_design/cache"
+	run := func() interface{} {
+		s, err := NewViewServer(`function(doc) { emit(null, require('views/lib/math').mul(2, 3)); }`, ddoc)
+		require.NoError(t, err)
+		res, err := s.ExecuteView(context.Background(), []*model.Document{
+			{ID: "a", Data: map[string]interface{}{}},
+		})
+		require.NoError(t, err)
+		return res[0].Value
+	}
+	assert.EqualValues(t, 6, run())
+
+	lib := ddoc.Data["views"].(map[string]interface{})["lib"].(map[string]interface{})
+	lib["math"] = `exports.mul = function(a, b) { return a + b; };`
+
+	// same revision, the compiled module is used
+	assert.EqualValues(t, 6, run())
+
+	ddoc.Rev = "2-def"
+	assert.EqualValues(t, 5, run())
+}
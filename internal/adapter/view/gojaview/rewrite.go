@@ -0,0 +1,105 @@
+package gojaview
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dop251/goja"
+	"github.com/goydb/goydb/pkg/model"
+	"github.com/goydb/goydb/pkg/port"
+)
+
+var _ port.RewriteServer = (*RewriteServer)(nil)
+
+// RewriteServer executes a compiled rewrite function using goja.
+type RewriteServer struct {
+	pool *pool
+}
+
+// NewRewriteServer compiles a rewrite function into a RewriteServer.
+func NewRewriteServer(fn string, ddoc *model.Document) (port.RewriteServer, error) {
+	script := fmt.Sprintf(`
+		var isArray = Array.isArray;
+		var rewriteFn = %s;
+		function executeRewrite(req) {
+			return rewriteFn(req);
+		}
+	`, fn)
+
+	p, err := getPool("rewrite", fn, ddoc, func(vm *goja.Runtime) error {
+		enableRequire(vm, ddoc, false)
+		_, err := vm.RunString(script)
+		if err != nil {
+			return fmt.Errorf("failed to compile rewrite function: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &RewriteServer{pool: p}, nil
+}
+
+// ExecuteRewrite runs the rewrite function and parses the path or object result.
+func (s *RewriteServer) ExecuteRewrite(ctx context.Context, req map[string]interface{}) (*port.RewriteResult, error) {
+	var exported interface{}
+	err := s.pool.Do(ctx, func(vm *goja.Runtime) error {
+		var executeRewrite goja.Callable
+		if err := vm.ExportTo(vm.Get("executeRewrite"), &executeRewrite); err != nil {
+			return err
+		}
+
+		result, err := executeRewrite(goja.Undefined(), vm.ToValue(req))
+		if err != nil {
+			return fmt.Errorf("rewrite function error: %w", err)
+		}
+		exported = result.Export()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return parseRewriteResponse(exported)
+}
+
+// parseRewriteResponse parses the response (string or object) of a rewrite function.
+func parseRewriteResponse(resp interface{}) (*port.RewriteResult, error) {
+	res := &port.RewriteResult{}
+
+	switch resp := resp.(type) {
+	case string:
+		res.Path = resp
+	case map[string]interface{}:
+		res.Path, _ = resp["path"].(string)
+		res.Method, _ = resp["method"].(string)
+		if query, ok := resp["query"].(map[string]interface{}); ok {
+			res.Query = query
+		}
+		if headers, ok := resp["headers"].(map[string]interface{}); ok {
+			res.Headers = make(map[string]string, len(headers))
+			for k, v := range headers {
+				if s, ok := v.(string); ok {
+					res.Headers[k] = s
+				}
+			}
+		}
+		if body, ok := resp["body"].(string); ok {
+			res.Body = &body
+		}
+		switch c := resp["code"].(type) {
+		case int64:
+			res.Code = int(c)
+		case float64:
+			res.Code = int(c)
+		}
+		if res.Path == "" && res.Code == 0 {
+			return nil, fmt.Errorf("rewrite function must return a path or a code")
+		}
+	default:
+		return nil, fmt.Errorf("rewrite function must return a string or object, got %T", resp)
+	}
+
+	return res, nil
+}
@@ -0,0 +1,197 @@
+package gojaview
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/dop251/goja"
+	"github.com/goydb/goydb/pkg/model"
+	"github.com/goydb/goydb/pkg/port"
+)
+
+var _ port.ShowServer = (*ShowServer)(nil)
+
+// ShowServer executes a compiled show function using goja.
+type ShowServer struct {
+	pool *pool
+}
+
+// NewShowServer compiles a show function into a ShowServer.
+func NewShowServer(fn string, ddoc *model.Document) (port.ShowServer, error) {
+	script := fmt.Sprintf(`
+		var isArray = Array.isArray;
+		var __provides = [];
+		function provides(type, fn) {
+			__provides.push([type, fn]);
+		}
+		function registerType(key) {
+			__registerType(key, Array.prototype.slice.call(arguments, 1));
+		}
+		function toJSON(obj) {
+			return JSON.stringify(obj);
+		}
+		var showFn = %s;
+		function executeShow(doc, req) {
+			__provides = [];
+			return showFn(doc, req);
+		}
+	`, fn)
+
+	p, err := getPool("show", fn, ddoc, func(vm *goja.Runtime) error {
+		enableRequire(vm, ddoc, false)
+		_, err := vm.RunString(script)
+		if err != nil {
+			return fmt.Errorf("failed to compile show function: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &ShowServer{pool: p}, nil
+}
+
+// ExecuteShow runs the show function, if the function registered formats
+// using provides() the format acceptable for the request is rendered.
+func (s *ShowServer) ExecuteShow(ctx context.Context, doc map[string]interface{}, req map[string]interface{}) (*port.ShowResult, error) {
+	var res *port.ShowResult
+	err := s.pool.Do(ctx, func(vm *goja.Runtime) error {
+		var err error
+		res, err = executeShow(vm, doc, req)
+		return err
+	})
+	return res, err
+}
+
+func executeShow(vm *goja.Runtime, doc map[string]interface{}, req map[string]interface{}) (*port.ShowResult, error) {
+	var executeShow goja.Callable
+	if err := vm.ExportTo(vm.Get("executeShow"), &executeShow); err != nil {
+		return nil, err
+	}
+
+	mimeTypes := model.NewMimeTypes()
+	err := vm.Set("__registerType", func(key string, types []string) {
+		mimeTypes.Register(key, types...)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var docVal goja.Value
+	if doc == nil {
+		docVal = goja.Null()
+	} else {
+		docVal = vm.ToValue(doc)
+	}
+
+	result, err := executeShow(goja.Undefined(), docVal, vm.ToValue(req))
+	if err != nil {
+		return nil, fmt.Errorf("show function error: %w", err)
+	}
+
+	var contentType string
+	if goja.IsUndefined(result) || goja.IsNull(result) {
+		var provided goja.Callable
+		provided, contentType, err = negotiateProvided(vm, mimeTypes, req)
+		if err != nil {
+			return nil, err
+		}
+		if provided != nil {
+			result, err = provided(goja.Undefined())
+			if err != nil {
+				return nil, fmt.Errorf("show function error: %w", err)
+			}
+		}
+	}
+
+	res, err := parseShowResponse(result.Export())
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" && res.JSON == nil {
+		if _, ok := res.Headers["Content-Type"]; !ok {
+			res.Headers["Content-Type"] = contentType
+		}
+	}
+	return res, nil
+}
+
+// negotiateProvided returns the function registered with provides() for
+// the format acceptable for the request and the content type of the
+// format. The function is nil if provides() wasn't called.
+func negotiateProvided(vm *goja.Runtime, mimeTypes model.MimeTypes, req map[string]interface{}) (goja.Callable, string, error) {
+	provides := vm.Get("__provides").ToObject(vm)
+	n := int(provides.Get("length").ToInteger())
+	if n == 0 {
+		return nil, "", nil
+	}
+
+	keys := make([]string, n)
+	fns := make([]goja.Callable, n)
+	for i := 0; i < n; i++ {
+		p := provides.Get(fmt.Sprint(i)).ToObject(vm)
+		keys[i] = p.Get("0").String()
+		fn, ok := goja.AssertFunction(p.Get("1"))
+		if !ok {
+			return nil, "", fmt.Errorf("provides(%q) needs a function", keys[i])
+		}
+		fns[i] = fn
+	}
+
+	format, accept := model.NegotiationParams(req)
+	key, ok := mimeTypes.Negotiate(keys, format, accept)
+	if !ok {
+		return nil, "", port.ErrNotAcceptable
+	}
+	for i := range keys {
+		if keys[i] == key {
+			return fns[i], mimeTypes.ContentType(key), nil
+		}
+	}
+	return nil, "", nil
+}
+
+// parseShowResponse parses the response (string or object) of a show function.
+func parseShowResponse(resp interface{}) (*port.ShowResult, error) {
+	res := &port.ShowResult{Headers: make(map[string]string)}
+
+	switch resp := resp.(type) {
+	case string:
+		res.Body = resp
+	case map[string]interface{}:
+		switch c := resp["code"].(type) {
+		case int64:
+			res.Code = int(c)
+		case float64:
+			res.Code = int(c)
+		}
+		if headers, ok := resp["headers"].(map[string]interface{}); ok {
+			for k, v := range headers {
+				if s, ok := v.(string); ok {
+					res.Headers[k] = s
+				}
+			}
+		}
+		if body, ok := resp["body"].(string); ok {
+			res.Body = body
+		}
+		if b64, ok := resp["base64"].(string); ok {
+			data, err := base64.StdEncoding.DecodeString(b64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid base64 body: %w", err)
+			}
+			res.Body = string(data)
+		}
+		if jsonVal, ok := resp["json"]; ok {
+			res.JSON = jsonVal
+		}
+	case nil:
+		// empty response
+	default:
+		return nil, fmt.Errorf("show function must return a string or object, got %T", resp)
+	}
+
+	return res, nil
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	"github.com/dop251/goja"
+	"github.com/goydb/goydb/pkg/model"
 	"github.com/goydb/goydb/pkg/port"
 )
 
@@ -12,13 +13,11 @@ var _ port.UpdateServer = (*UpdateServer)(nil)
 
 // UpdateServer executes a compiled update function using goja.
 type UpdateServer struct {
-	vm *goja.Runtime
+	pool *pool
 }
 
 // NewUpdateServer compiles an update function into an UpdateServer.
-func NewUpdateServer(fn string) (port.UpdateServer, error) {
-	vm := goja.New()
-
+func NewUpdateServer(fn string, ddoc *model.Document) (port.UpdateServer, error) {
 	script := fmt.Sprintf(`
 		var isArray = Array.isArray;
 		var updateFn = %s;
@@ -27,34 +26,48 @@ func NewUpdateServer(fn string) (port.UpdateServer, error) {
 		}
 	`, fn)
 
-	_, err := vm.RunString(script)
+	p, err := getPool("update", fn, ddoc, func(vm *goja.Runtime) error {
+		enableRequire(vm, ddoc, false)
+		_, err := vm.RunString(script)
+		if err != nil {
+			return fmt.Errorf("failed to compile update function: %w", err)
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to compile update function: %w", err)
+		return nil, err
 	}
 
-	return &UpdateServer{vm: vm}, nil
+	return &UpdateServer{pool: p}, nil
 }
 
 // ExecuteUpdate runs the update function and parses the [doc, response] result.
 func (u *UpdateServer) ExecuteUpdate(ctx context.Context, doc map[string]interface{}, req map[string]interface{}) (*port.UpdateResult, error) {
-	var executeUpdate goja.Callable
-	if err := u.vm.ExportTo(u.vm.Get("executeUpdate"), &executeUpdate); err != nil {
-		return nil, err
-	}
+	var exported interface{}
+	err := u.pool.Do(ctx, func(vm *goja.Runtime) error {
+		var executeUpdate goja.Callable
+		if err := vm.ExportTo(vm.Get("executeUpdate"), &executeUpdate); err != nil {
+			return err
+		}
 
-	var docVal goja.Value
-	if doc == nil {
-		docVal = goja.Null()
-	} else {
-		docVal = u.vm.ToValue(doc)
-	}
+		var docVal goja.Value
+		if doc == nil {
+			docVal = goja.Null()
+		} else {
+			docVal = vm.ToValue(doc)
+		}
 
-	result, err := executeUpdate(goja.Undefined(), docVal, u.vm.ToValue(req))
+		result, err := executeUpdate(goja.Undefined(), docVal, vm.ToValue(req))
+		if err != nil {
+			return fmt.Errorf("update function error: %w", err)
+		}
+		exported = result.Export()
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("update function error: %w", err)
+		return nil, err
 	}
 
-	exported := result.Export()
 	arr, ok := exported.([]interface{})
 	if !ok {
 		return nil, fmt.Errorf("update function must return an array, got %T", exported)
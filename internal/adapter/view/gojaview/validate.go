@@ -13,26 +13,19 @@ var _ port.ValidateServer = (*ValidateServer)(nil)
 
 // ValidateServer executes a compiled validate_doc_update function using goja.
 type ValidateServer struct {
-	vm     *goja.Runtime
+	pool   *pool
 	logger port.Logger
 }
 
-// NewValidateServerBuilder returns a ValidateServerBuilder that captures the logger.
-func NewValidateServerBuilder(logger port.Logger) port.ValidateServerBuilder {
-	return func(fn string) (port.ValidateServer, error) {
-		return NewValidateServer(fn, logger)
+// NewValidateServerBuilder returns a validate builder that captures the logger.
+func NewValidateServerBuilder(logger port.Logger) port.DesignDocBuilder[port.ValidateServer] {
+	return func(fn string, ddoc *model.Document) (port.ValidateServer, error) {
+		return NewValidateServer(fn, ddoc, logger)
 	}
 }
 
 // NewValidateServer compiles a validate_doc_update function into a ValidateServer.
-func NewValidateServer(fn string, logger port.Logger) (port.ValidateServer, error) {
-	vm := goja.New()
-
-	// Inject log() so VDU functions can write to the server log.
-	_ = vm.Set("log", func(msg interface{}) {
-		logger.Infof(context.Background(), "vdu log", "message", msg)
-	})
-
+func NewValidateServer(fn string, ddoc *model.Document, logger port.Logger) (port.ValidateServer, error) {
 	// Wrap the user function with isArray and an executeValidation wrapper.
 	script := fmt.Sprintf(`
 		var isArray = Array.isArray;
@@ -42,12 +35,20 @@ func NewValidateServer(fn string, logger port.Logger) (port.ValidateServer, erro
 		}
 	`, fn)
 
-	_, err := vm.RunString(script)
+	p, err := getPool("validate", fn, ddoc, func(vm *goja.Runtime) error {
+		enableRequire(vm, ddoc, false)
+
+		_, err := vm.RunString(script)
+		if err != nil {
+			return fmt.Errorf("failed to compile validate_doc_update function: %w", err)
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to compile validate_doc_update function: %w", err)
+		return nil, err
 	}
 
-	return &ValidateServer{vm: vm, logger: logger}, nil
+	return &ValidateServer{pool: p, logger: logger}, nil
 }
 
 // ExecuteValidation runs the validate_doc_update function.
@@ -55,30 +56,39 @@ func (v *ValidateServer) ExecuteValidation(ctx context.Context,
 	newDoc, oldDoc map[string]interface{},
 	userCtx, secObj map[string]interface{}) error {
 
-	var executeValidation goja.Callable
-	if err := v.vm.ExportTo(v.vm.Get("executeValidation"), &executeValidation); err != nil {
-		return err
-	}
+	return v.pool.Do(ctx, func(vm *goja.Runtime) error {
+		// Inject log() so VDU functions can write to the server log,
+		// set per call as the pooled runtimes are shared by servers
+		// of different loggers.
+		_ = vm.Set("log", func(msg interface{}) {
+			v.logger.Infof(ctx, "vdu log", "message", msg)
+		})
+
+		var executeValidation goja.Callable
+		if err := vm.ExportTo(vm.Get("executeValidation"), &executeValidation); err != nil {
+			return err
+		}
 
-	// Convert oldDoc: pass null to JS if nil.
-	var oldDocVal goja.Value
-	if oldDoc == nil {
-		oldDocVal = goja.Null()
-	} else {
-		oldDocVal = v.vm.ToValue(oldDoc)
-	}
+		// Convert oldDoc: pass null to JS if nil.
+		var oldDocVal goja.Value
+		if oldDoc == nil {
+			oldDocVal = goja.Null()
+		} else {
+			oldDocVal = vm.ToValue(oldDoc)
+		}
 
-	_, err := executeValidation(goja.Undefined(),
-		v.vm.ToValue(newDoc),
-		oldDocVal,
-		v.vm.ToValue(userCtx),
-		v.vm.ToValue(secObj),
-	)
-	if err != nil {
-		return classifyJSError(err)
-	}
+		_, err := executeValidation(goja.Undefined(),
+			vm.ToValue(newDoc),
+			oldDocVal,
+			vm.ToValue(userCtx),
+			vm.ToValue(secObj),
+		)
+		if err != nil {
+			return classifyJSError(err)
+		}
 
-	return nil
+		return nil
+	})
 }
 
 // classifyJSError converts a goja exception into ErrForbidden or ErrUnauthorized.
@@ -12,12 +12,11 @@ import (
 var _ port.ViewServer = (*ViewServer)(nil)
 
 type ViewServer struct {
-	vm *goja.Runtime
+	pool *pool
 }
 
-func NewViewServer(fn string) (port.ViewServer, error) {
-	vm := goja.New()
-	fn = `
+func NewViewServer(fn string, ddoc *model.Document) (port.ViewServer, error) {
+	script := `
 	var _result = [];
 	var _doc = {};
 	var _index = [];
@@ -29,13 +28,20 @@ func NewViewServer(fn string) (port.ViewServer, error) {
 		_index.push([name, value, opts || {}]);
 	}
 	var docFn = ` + fn + `;`
-	_, err := vm.RunString(fn)
+	p, err := getPool("view", fn, ddoc, func(vm *goja.Runtime) error {
+		enableRequire(vm, ddoc, true)
+		_, err := vm.RunString(script)
+		if err != nil {
+			return fmt.Errorf("script error %v: %w", script, err)
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("script error %v: %w", fn, err)
+		return nil, err
 	}
 
 	return &ViewServer{
-		vm: vm,
+		pool: p,
 	}, nil
 }
 
@@ -47,9 +53,7 @@ func (s *ViewServer) ExecuteView(ctx context.Context, docs []*model.Document) ([
 		simpleDocs[i] = doc.Data
 	}
 
-	_ = s.vm.Set("docs", simpleDocs)
-
-	_, err := s.vm.RunString(`_result = [];
+	resultData, err := s.run(ctx, simpleDocs, `_result = [];
 	docs.forEach(function (doc) {
 		_doc = doc;
 		docFn(doc);
@@ -57,11 +61,6 @@ func (s *ViewServer) ExecuteView(ctx context.Context, docs []*model.Document) ([
 	if err != nil {
 		return nil, err
 	}
-
-	resultData, ok := s.vm.Get("_result").Export().([]interface{})
-	if !ok {
-		return nil, fmt.Errorf("unable to export")
-	}
 	result := make([]*model.Document, len(resultData))
 
 	for i, rd := range resultData {
@@ -85,9 +84,7 @@ func (s *ViewServer) ExecuteSearch(ctx context.Context, docs []*model.Document)
 		simpleDocs[i] = doc.Data
 	}
 
-	_ = s.vm.Set("docs", simpleDocs)
-
-	_, err := s.vm.RunString(`_result = [];
+	resultData, err := s.run(ctx, simpleDocs, `_result = [];
 	docs.forEach(function (doc) {
 		_doc = doc;
 		_index = [];
@@ -99,11 +96,6 @@ func (s *ViewServer) ExecuteSearch(ctx context.Context, docs []*model.Document)
 	if err != nil {
 		return nil, err
 	}
-
-	resultData, ok := s.vm.Get("_result").Export().([]interface{})
-	if !ok {
-		return nil, fmt.Errorf("unable to export")
-	}
 	result := make([]*model.Document, len(resultData))
 
 	for i, rd := range resultData {
@@ -138,3 +130,24 @@ func (s *ViewServer) ExecuteSearch(ctx context.Context, docs []*model.Document)
 
 	return result, nil
 }
+
+// run executes the script for the docs and returns the exported _result
+func (s *ViewServer) run(ctx context.Context, docs []interface{}, script string) ([]interface{}, error) {
+	var resultData []interface{}
+	err := s.pool.Do(ctx, func(vm *goja.Runtime) error {
+		_ = vm.Set("docs", docs)
+
+		_, err := vm.RunString(script)
+		if err != nil {
+			return err
+		}
+
+		var ok bool
+		resultData, ok = vm.Get("_result").Export().([]interface{})
+		if !ok {
+			return fmt.Errorf("unable to export")
+		}
+		return nil
+	})
+	return resultData, err
+}
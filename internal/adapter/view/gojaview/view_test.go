@@ -82,7 +82,7 @@ func TestViewServer_ExecuteView(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			s, err := NewViewServer(tt.script)
+			s, err := NewViewServer(tt.script, nil)
 			assert.NoError(t, err)
 			got, err := s.ExecuteView(context.Background(), tt.docs)
 			if err != nil && !tt.wantErr {
@@ -178,7 +178,7 @@ func TestViewServer_ExecuteSearch(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			s, err := NewViewServer(tt.script)
+			s, err := NewViewServer(tt.script, nil)
 			assert.NoError(t, err)
 			got, err := s.ExecuteSearch(context.Background(), tt.docs)
 			if err != nil && !tt.wantErr {
@@ -0,0 +1,31 @@
+package nativeview
+
+import (
+	"context"
+
+	"github.com/goydb/goydb/pkg/model"
+	"github.com/goydb/goydb/pkg/port"
+)
+
+var _ port.FilterServer = (*FilterServer)(nil)
+
+// FilterServer executes a native filter function
+type FilterServer struct {
+	name string
+	fn   FilterFunc
+}
+
+// NewFilterServer resolves the filter function referenced by fn
+func (r *Registry) NewFilterServer(fn string) (port.FilterServer, error) {
+	f, err := r.lookup(kindFilter, fn)
+	if err != nil {
+		return nil, err
+	}
+	return &FilterServer{name: fn, fn: f.(FilterFunc)}, nil
+}
+
+// ExecuteFilter tests if a document passes the filter
+func (f *FilterServer) ExecuteFilter(ctx context.Context, doc *model.Document, req map[string]interface{}) (pass bool, err error) {
+	defer recoverError(f.name, &err)
+	return f.fn(ctx, doc, req)
+}
@@ -0,0 +1,19 @@
+package nativeview
+
+import (
+	"github.com/goydb/goydb/internal/adapter/reducer"
+	"github.com/goydb/goydb/pkg/port"
+)
+
+// NewReducer resolves the reduce function referenced by fn
+func (r *Registry) NewReducer(fn string) (port.Reducer, error) {
+	f, err := r.lookup(kindReduce, fn)
+	if err != nil {
+		return nil, err
+	}
+	reduceFn := f.(ReduceFunc)
+	return reducer.NewBatch(func(keys []ReduceKey, values []interface{}, rereduce bool) (value interface{}, err error) {
+		defer recoverError(fn, &err)
+		return reduceFn(keys, values, rereduce)
+	}), nil
+}
@@ -0,0 +1,111 @@
+// Package nativeview executes design document functions implemented
+// in Go and registered by the application embedding goydb.
+package nativeview
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/goydb/goydb/internal/adapter/reducer"
+	"github.com/goydb/goydb/pkg/model"
+)
+
+// MapFunc emits the view rows of a document
+type MapFunc func(ctx context.Context, doc *model.Document, emit func(key, value interface{})) error
+
+// ReduceKey is a key of the first reduce pass with the id of the
+// document that emitted it
+type ReduceKey = reducer.Key
+
+// ReduceFunc reduces the values, keys are nil if rereduce is set
+type ReduceFunc func(keys []ReduceKey, values []interface{}, rereduce bool) (interface{}, error)
+
+// FilterFunc tests if a document passes the filter
+type FilterFunc func(ctx context.Context, doc *model.Document, req map[string]interface{}) (bool, error)
+
+// ValidateFunc validates a document update, updates are rejected by
+// returning *model.ErrForbidden or *model.ErrUnauthorized
+type ValidateFunc func(ctx context.Context, newDoc, oldDoc, userCtx, secObj map[string]interface{}) error
+
+// function kinds
+const (
+	kindMap      = "map"
+	kindReduce   = "reduce"
+	kindFilter   = "filter"
+	kindValidate = "validate"
+)
+
+// entry is a registered function
+type entry struct {
+	version string
+	fn      interface{}
+}
+
+// Registry holds the named and versioned functions. Design documents
+// reference functions by "name" or "name@version", a referenced version
+// must match the registered version. Views are only rebuilt if the design
+// document changes, map and reduce functions therefore must be referenced
+// with their version so that a new function version changes the reference.
+type Registry struct {
+	mu        sync.RWMutex
+	functions map[string]entry
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{functions: make(map[string]entry)}
+}
+
+func (r *Registry) register(kind, name, version string, fn interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.functions[kind+"\x00"+name] = entry{version: version, fn: fn}
+}
+
+// RegisterMap registers the map function under name and version
+func (r *Registry) RegisterMap(name, version string, fn MapFunc) {
+	r.register(kindMap, name, version, fn)
+}
+
+// RegisterReduce registers the reduce function under name and version
+func (r *Registry) RegisterReduce(name, version string, fn ReduceFunc) {
+	r.register(kindReduce, name, version, fn)
+}
+
+// RegisterFilter registers the filter function under name and version
+func (r *Registry) RegisterFilter(name, version string, fn FilterFunc) {
+	r.register(kindFilter, name, version, fn)
+}
+
+// RegisterValidate registers the validate function under name and version
+func (r *Registry) RegisterValidate(name, version string, fn ValidateFunc) {
+	r.register(kindValidate, name, version, fn)
+}
+
+// lookup resolves the reference of a design document to the function
+func (r *Registry) lookup(kind, ref string) (interface{}, error) {
+	name, version, versioned := strings.Cut(strings.TrimSpace(ref), "@")
+	if !versioned && (kind == kindMap || kind == kindReduce) {
+		return nil, fmt.Errorf("%s function %q must be referenced with its version e.g. %q", kind, name, name+"@1")
+	}
+
+	r.mu.RLock()
+	e, ok := r.functions[kind+"\x00"+name]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%s function %q is not registered", kind, name)
+	}
+	if versioned && version != e.version {
+		return nil, fmt.Errorf("%s function %q has version %q, the design document requires %q", kind, name, e.version, version)
+	}
+	return e.fn, nil
+}
+
+// recoverError converts a panic of a function into an error
+func recoverError(name string, err *error) {
+	if v := recover(); v != nil {
+		*err = fmt.Errorf("function %q panicked: %v", name, v)
+	}
+}
@@ -0,0 +1,103 @@
+package nativeview
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/goydb/goydb/internal/adapter/reducer"
+	"github.com/goydb/goydb/pkg/model"
+	"github.com/goydb/goydb/pkg/port"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry_Lookup(t *testing.T) {
+	r := NewRegistry()
+	r.RegisterFilter("app.typed", "2", func(ctx context.Context, doc *model.Document, req map[string]interface{}) (bool, error) {
+		return doc.Data["type"] == req["type"], nil
+	})
+
+	for _, ref := range []string{"app.typed", "app.typed@2", " app.typed@2 "} {
+		f, err := r.NewFilterServer(ref)
+		require.NoError(t, err, ref)
+		pass, err := f.ExecuteFilter(context.Background(), &model.Document{Data: map[string]interface{}{"type": "post"}}, map[string]interface{}{"type": "post"})
+		require.NoError(t, err)
+		assert.True(t, pass)
+	}
+
+	_, err := r.NewFilterServer("app.typed@1")
+	assert.ErrorContains(t, err, `has version "2", the design document requires "1"`)
+	_, err = r.NewFilterServer("app.missing")
+	assert.ErrorContains(t, err, "not registered")
+	// names are registered per kind
+	_, err = r.NewViewServer("app.typed@2")
+	assert.Error(t, err)
+
+	// views are rebuilt if the reference changes, map and
+	// reduce functions must be referenced with their version
+	r.RegisterMap("app.byType", "1", func(ctx context.Context, doc *model.Document, emit func(key, value interface{})) error {
+		return nil
+	})
+	_, err = r.NewViewServer("app.byType")
+	assert.ErrorContains(t, err, `must be referenced with its version e.g. "app.byType@1"`)
+	_, err = r.NewViewServer("app.byType@1")
+	assert.NoError(t, err)
+}
+
+func TestViewServer_Panic(t *testing.T) {
+	r := NewRegistry()
+	r.RegisterMap("app.panics", "1", func(ctx context.Context, doc *model.Document, emit func(key, value interface{})) error {
+		emit(doc.ID, nil)
+		if doc.ID == "b" {
+			panic("boom")
+		}
+		return nil
+	})
+	vs, err := r.NewViewServer("app.panics@1")
+	require.NoError(t, err)
+
+	res, err := vs.ExecuteView(context.Background(), []*model.Document{{ID: "a"}})
+	require.NoError(t, err)
+	assert.Equal(t, []*model.Document{{ID: "a", Key: "a"}}, res)
+
+	_, err = vs.ExecuteView(context.Background(), []*model.Document{{ID: "b"}})
+	assert.ErrorContains(t, err, "panicked: boom")
+}
+
+func TestReducer_Rereduce(t *testing.T) {
+	r := NewRegistry()
+	var rereduced bool
+	r.RegisterReduce("app.count", "1", func(keys []ReduceKey, values []interface{}, rereduce bool) (interface{}, error) {
+		if !rereduce {
+			return len(values), nil
+		}
+		rereduced = true
+		var n int
+		for _, v := range values {
+			n += v.(int)
+		}
+		return n, nil
+	})
+	red, err := r.NewReducer("app.count@1")
+	require.NoError(t, err)
+	for i := 0; i < reducer.BatchSize+5; i++ {
+		red.Reduce(&model.Document{ID: fmt.Sprint(i), Key: "a"})
+	}
+	red.Reduce(&model.Document{ID: "x", Key: "b"})
+
+	result := red.Result()
+	assert.True(t, rereduced)
+	assert.Equal(t, &model.Document{Key: "a", Value: reducer.BatchSize + 5}, result[0])
+	assert.Equal(t, &model.Document{Key: "b", Value: 1}, result[1])
+
+	r.RegisterReduce("app.fails", "1", func(keys []ReduceKey, values []interface{}, rereduce bool) (interface{}, error) {
+		return nil, errors.New("failed")
+	})
+	red, err = r.NewReducer("app.fails@1")
+	require.NoError(t, err)
+	red.Reduce(&model.Document{Key: "a"})
+	red.Result()
+	assert.EqualError(t, red.(port.ReducerError).Err(), "failed")
+}
@@ -0,0 +1,32 @@
+package nativeview
+
+import (
+	"context"
+
+	"github.com/goydb/goydb/pkg/port"
+)
+
+var _ port.ValidateServer = (*ValidateServer)(nil)
+
+// ValidateServer executes a native validate function
+type ValidateServer struct {
+	name string
+	fn   ValidateFunc
+}
+
+// NewValidateServer resolves the validate function referenced by fn
+func (r *Registry) NewValidateServer(fn string) (port.ValidateServer, error) {
+	f, err := r.lookup(kindValidate, fn)
+	if err != nil {
+		return nil, err
+	}
+	return &ValidateServer{name: fn, fn: f.(ValidateFunc)}, nil
+}
+
+// ExecuteValidation runs the validate function
+func (v *ValidateServer) ExecuteValidation(ctx context.Context,
+	newDoc, oldDoc map[string]interface{},
+	userCtx, secObj map[string]interface{}) (err error) {
+	defer recoverError(v.name, &err)
+	return v.fn(ctx, newDoc, oldDoc, userCtx, secObj)
+}
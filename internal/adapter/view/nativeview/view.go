@@ -0,0 +1,53 @@
+package nativeview
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/goydb/goydb/pkg/model"
+	"github.com/goydb/goydb/pkg/port"
+)
+
+var _ port.ViewServer = (*ViewServer)(nil)
+
+// ViewServer executes a native map function
+type ViewServer struct {
+	name string
+	fn   MapFunc
+}
+
+// NewViewServer resolves the map function referenced by fn
+func (r *Registry) NewViewServer(fn string) (port.ViewServer, error) {
+	f, err := r.lookup(kindMap, fn)
+	if err != nil {
+		return nil, err
+	}
+	return &ViewServer{name: fn, fn: f.(MapFunc)}, nil
+}
+
+func (s *ViewServer) ExecuteView(ctx context.Context, docs []*model.Document) ([]*model.Document, error) {
+	var result []*model.Document
+	for _, doc := range docs {
+		emit := func(key, value interface{}) {
+			result = append(result, &model.Document{
+				Key:   key,
+				Value: value,
+				ID:    doc.ID,
+			})
+		}
+		if err := s.call(ctx, doc, emit); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func (s *ViewServer) call(ctx context.Context, doc *model.Document, emit func(key, value interface{})) (err error) {
+	defer recoverError(s.name, &err)
+	return s.fn(ctx, doc, emit)
+}
+
+// ExecuteSearch isn't supported by native functions
+func (s *ViewServer) ExecuteSearch(ctx context.Context, docs []*model.Document) ([]*model.Document, error) {
+	return nil, fmt.Errorf("search functions are not supported by native functions")
+}
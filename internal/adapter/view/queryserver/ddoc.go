@@ -0,0 +1,34 @@
+package queryserver
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/goydb/goydb/pkg/model"
+)
+
+// errNoDDoc is returned for functions without design document
+var errNoDDoc = errors.New("query server functions need a design document")
+
+// functionName returns the name of the function fn in the section
+// (e.g. filters) of the design document, design document functions
+// are addressed by their path in the query server protocol.
+func functionName(ddoc *model.Document, section, fn string) (string, error) {
+	if ddoc == nil {
+		return "", errNoDDoc
+	}
+	fns, _ := ddoc.Data[section].(map[string]interface{})
+	for name, source := range fns {
+		if source == fn {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("function not found in %s of %q", section, ddoc.ID)
+}
+
+// ddocCommand returns the command calling the function at path of
+// the design document, the arguments are passed as one array:
+// ["ddoc", id, path, [args...]]
+func ddocCommand(id string, path []string, args ...interface{}) []interface{} {
+	return []interface{}{"ddoc", id, path, args}
+}
@@ -0,0 +1,56 @@
+package queryserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/goydb/goydb/pkg/model"
+	"github.com/goydb/goydb/pkg/port"
+)
+
+var _ port.FilterServer = (*FilterServer)(nil)
+
+// FilterServer executes a filter function in a query server
+type FilterServer struct {
+	server *Server
+	ddoc   *model.Document
+	name   string
+}
+
+// NewFilterServer returns a FilterServer for the filter function
+func (s *Server) NewFilterServer(fn string, ddoc *model.Document) (port.FilterServer, error) {
+	name, err := functionName(ddoc, "filters", fn)
+	if err != nil {
+		return nil, err
+	}
+	return &FilterServer{server: s, ddoc: ddoc, name: name}, nil
+}
+
+// ExecuteFilter tests if a document passes the filter
+func (f *FilterServer) ExecuteFilter(ctx context.Context, doc *model.Document, req map[string]interface{}) (bool, error) {
+	var pass bool
+	err := f.server.do(ctx, func(p *process, timeout time.Duration) error {
+		if err := p.ensureDDoc(ctx, timeout, f.ddoc); err != nil {
+			return err
+		}
+		line, err := p.call(ctx, timeout, ddocCommand(f.ddoc.ID, []string{"filters", f.name},
+			[]interface{}{docJSON(doc)}, req)...)
+		if err != nil {
+			return err
+		}
+		// [true, [pass]]
+		var resp []json.RawMessage
+		var results []bool
+		if err := json.Unmarshal(line, &resp); err != nil || len(resp) != 2 {
+			return fmt.Errorf("invalid filter response: %s", line)
+		}
+		if err := json.Unmarshal(resp[1], &results); err != nil || len(results) != 1 {
+			return fmt.Errorf("invalid filter response: %s", line)
+		}
+		pass = results[0]
+		return nil
+	})
+	return pass, err
+}
@@ -0,0 +1,259 @@
+package queryserver
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/goydb/goydb/pkg/model"
+	"github.com/goydb/goydb/pkg/port"
+)
+
+// ErrExited is returned if the query server process exited
+var ErrExited = errors.New("query server exited")
+
+// Error is an error reported by the query server
+type Error struct {
+	ID     string
+	Reason string
+}
+
+func (e *Error) Error() string {
+	return e.ID + ": " + e.Reason
+}
+
+// process is a running query server speaking the
+// CouchDB line protocol on stdin and stdout
+type process struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	lines  chan []byte
+	done   chan struct{}
+	logger port.Logger
+	// dead processes are not returned to the pool
+	dead bool
+	// ddocs are the revisions of the design documents sent with "ddoc new"
+	ddocs map[string]string
+	// fun identifies the map function loaded with "add_fun"
+	fun string
+}
+
+// startProcess starts the query server command, the command
+// is split into the program and its arguments at spaces.
+func startProcess(command string, logger port.Logger) (*process, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return nil, fmt.Errorf("query server command is empty")
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start query server %q: %w", command, err)
+	}
+
+	p := &process{
+		cmd:    cmd,
+		stdin:  stdin,
+		lines:  make(chan []byte),
+		done:   make(chan struct{}),
+		logger: logger,
+		ddocs:  make(map[string]string),
+	}
+	go p.read(stdout)
+	go p.logStderr(stderr)
+	go func() { _ = cmd.Wait() }()
+	return p, nil
+}
+
+// read forwards the lines of the output, the channel
+// is closed when the process exits
+func (p *process) read(r io.Reader) {
+	defer close(p.lines)
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			select {
+			case p.lines <- line:
+			case <-p.done:
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (p *process) logStderr(r io.Reader) {
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		p.logger.Warnf(context.Background(), "query server stderr", "line", s.Text())
+	}
+}
+
+// call sends the command and returns the response line. Log messages
+// of the query server are forwarded to the logger. If the call exceeds
+// the timeout or the context is done the process is killed.
+func (p *process) call(ctx context.Context, timeout time.Duration, command ...interface{}) (json.RawMessage, error) {
+	if p.dead {
+		return nil, ErrExited
+	}
+	data, err := json.Marshal(command)
+	if err != nil {
+		return nil, err
+	}
+
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	// the write blocks if the process doesn't read its input
+	written := make(chan error, 1)
+	go func() {
+		_, err := p.stdin.Write(append(data, '\n'))
+		written <- err
+	}()
+
+	for {
+		select {
+		case err := <-written:
+			if err != nil {
+				p.kill()
+				return nil, fmt.Errorf("%w: %v", ErrExited, err)
+			}
+			written = nil
+		case line, ok := <-p.lines:
+			if !ok {
+				p.kill()
+				return nil, ErrExited
+			}
+			if msg, ok := logMessage(line); ok {
+				p.logger.Infof(ctx, "query server log", "message", msg)
+				continue
+			}
+			return line, responseError(line)
+		case <-deadline:
+			p.kill()
+			return nil, port.ErrTimeout
+		case <-ctx.Done():
+			p.kill()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// kill terminates the process
+func (p *process) kill() {
+	if p.dead {
+		return
+	}
+	p.dead = true
+	close(p.done)
+	_ = p.stdin.Close()
+	if p.cmd.Process != nil {
+		_ = p.cmd.Process.Kill()
+	}
+}
+
+// ensureDDoc sends the design document to the query
+// server unless the revision was sent before
+func (p *process) ensureDDoc(ctx context.Context, timeout time.Duration, ddoc *model.Document) error {
+	if ddoc.Rev != "" && p.ddocs[ddoc.ID] == ddoc.Rev {
+		return nil
+	}
+	_, err := p.call(ctx, timeout, "ddoc", "new", ddoc.ID, docJSON(ddoc))
+	if err != nil {
+		return err
+	}
+	p.ddocs[ddoc.ID] = ddoc.Rev
+	return nil
+}
+
+// logMessage returns the message of a ["log", message] line
+func logMessage(line []byte) (interface{}, bool) {
+	if !bytes.HasPrefix(bytes.TrimSpace(line), []byte(`["log"`)) {
+		return nil, false
+	}
+	var msg []interface{}
+	if err := json.Unmarshal(line, &msg); err != nil || len(msg) < 2 {
+		return nil, false
+	}
+	return msg[1], true
+}
+
+// responseError returns the error of an error response:
+// ["error", id, reason], {"error": id, "reason": reason},
+// {"forbidden": msg} or {"unauthorized": msg}
+func responseError(line []byte) error {
+	trimmed := bytes.TrimSpace(line)
+	switch {
+	case bytes.HasPrefix(trimmed, []byte(`["error"`)):
+		var resp []interface{}
+		if err := json.Unmarshal(trimmed, &resp); err != nil {
+			return fmt.Errorf("invalid query server response: %w", err)
+		}
+		e := &Error{ID: "error"}
+		if len(resp) > 1 {
+			e.ID = fmt.Sprint(resp[1])
+		}
+		if len(resp) > 2 {
+			e.Reason = fmt.Sprint(resp[2])
+		}
+		return e
+	case bytes.HasPrefix(trimmed, []byte(`{`)):
+		var resp map[string]interface{}
+		if err := json.Unmarshal(trimmed, &resp); err != nil {
+			return fmt.Errorf("invalid query server response: %w", err)
+		}
+		if msg, ok := resp["forbidden"]; ok {
+			return &model.ErrForbidden{Msg: fmt.Sprint(msg)}
+		}
+		if msg, ok := resp["unauthorized"]; ok {
+			return &model.ErrUnauthorized{Msg: fmt.Sprint(msg)}
+		}
+		if id, ok := resp["error"]; ok {
+			return &Error{ID: fmt.Sprint(id), Reason: fmt.Sprint(resp["reason"])}
+		}
+	}
+	return nil
+}
+
+// docJSON returns the document with its _id and _rev as
+// sent to the query server
+func docJSON(doc *model.Document) map[string]interface{} {
+	m := make(map[string]interface{}, len(doc.Data)+3)
+	for k, v := range doc.Data {
+		m[k] = v
+	}
+	m["_id"] = doc.ID
+	if doc.Rev != "" {
+		m["_rev"] = doc.Rev
+	}
+	if doc.Deleted {
+		m["_deleted"] = true
+	}
+	return m
+}
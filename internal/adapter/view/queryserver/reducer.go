@@ -0,0 +1,48 @@
+package queryserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/goydb/goydb/internal/adapter/reducer"
+	"github.com/goydb/goydb/pkg/port"
+)
+
+// NewReducer returns a Reducer for the reduce function
+func (s *Server) NewReducer(fn string) (port.Reducer, error) {
+	return reducer.NewBatch(func(keys []reducer.Key, values []interface{}, rereduce bool) (interface{}, error) {
+		if rereduce {
+			return s.reduce("rereduce", fn, values)
+		}
+		pairs := make([]interface{}, len(keys))
+		for i, k := range keys {
+			pairs[i] = []interface{}{[]interface{}{k.Key, k.ID}, values[i]}
+		}
+		return s.reduce("reduce", fn, pairs)
+	}), nil
+}
+
+// reduce executes the reduce or rereduce command
+func (s *Server) reduce(command, fn string, args interface{}) (interface{}, error) {
+	var value interface{}
+	ctx := context.Background()
+	err := s.do(ctx, func(p *process, timeout time.Duration) error {
+		line, err := p.call(ctx, timeout, command, []string{fn}, args)
+		if err != nil {
+			return err
+		}
+		var resp []json.RawMessage
+		if err := json.Unmarshal(line, &resp); err != nil || len(resp) != 2 {
+			return fmt.Errorf("invalid %s response: %s", command, line)
+		}
+		var results []interface{}
+		if err := json.Unmarshal(resp[1], &results); err != nil || len(results) != 1 {
+			return fmt.Errorf("invalid %s response: %s", command, line)
+		}
+		value = results[0]
+		return nil
+	})
+	return value, err
+}
@@ -0,0 +1,147 @@
+// Package queryserver executes design document functions in external
+// query servers (e.g. couchpy) using the CouchDB line protocol.
+package queryserver
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goydb/goydb/pkg/port"
+)
+
+// defaultTimeout is used if couchdb/os_process_timeout isn't set
+const defaultTimeout = 5 * time.Second
+
+// defaultPoolSize is used if query_server_config/os_process_limit isn't set
+const defaultPoolSize = 4
+
+// EnvPrefix is the prefix of the environment variables configuring
+// query servers, e.g. GOYDB_QUERY_SERVER_PYTHON=/usr/bin/couchpy
+const EnvPrefix = "GOYDB_QUERY_SERVER_"
+
+// ConfigDefaults are the config values of the query servers,
+// the query_servers section maps languages to commands.
+var ConfigDefaults = map[string]map[string]string{
+	"couchdb": {
+		"os_process_timeout": "5000",
+	},
+	"query_server_config": {
+		"os_process_limit": "4",
+	},
+	"query_servers": {},
+}
+
+// Commands returns the commands of the languages, read from the
+// query_servers section of the config file and from environment
+// variables with EnvPrefix, the environment takes precedence. The
+// commands are read once at startup, they can't be changed by clients.
+func Commands(section map[string]string, environ []string) map[string]string {
+	commands := make(map[string]string, len(section))
+	for language, command := range section {
+		commands[language] = command
+	}
+	for _, kv := range environ {
+		name, command, ok := strings.Cut(kv, "=")
+		if !ok || !strings.HasPrefix(name, EnvPrefix) || len(name) == len(EnvPrefix) {
+			continue
+		}
+		commands[strings.ToLower(strings.TrimPrefix(name, EnvPrefix))] = command
+	}
+	return commands
+}
+
+// Server is a pool of query server processes of a language
+type Server struct {
+	language string
+	command  string
+	config   func(section, key string) (string, bool)
+	logger   port.Logger
+	sem      chan struct{}
+
+	mu   sync.Mutex
+	idle []*process
+}
+
+// New creates the query server of the language, processes are started
+// with the command, see Commands.
+func New(language, command string, config func(section, key string) (string, bool), logger port.Logger) *Server {
+	size := defaultPoolSize
+	if v, ok := config("query_server_config", "os_process_limit"); ok {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			size = n
+		}
+	}
+	return &Server{
+		language: language,
+		command:  command,
+		config:   config,
+		logger:   logger,
+		sem:      make(chan struct{}, size),
+	}
+}
+
+// timeout returns the configured timeout of a single command
+func (s *Server) timeout() time.Duration {
+	if v, ok := s.config("couchdb", "os_process_timeout"); ok {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			return time.Duration(n) * time.Millisecond
+		}
+	}
+	return defaultTimeout
+}
+
+// do executes fn with a process of the pool. Processes that crashed
+// or were killed are replaced by a new process on the next call.
+func (s *Server) do(ctx context.Context, fn func(p *process, timeout time.Duration) error) error {
+	select {
+	case s.sem <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-s.sem }()
+
+	p, err := s.get()
+	if err != nil {
+		return err
+	}
+	err = fn(p, s.timeout())
+	if p.dead {
+		s.logger.Warnf(ctx, "query server process terminated", "language", s.language, "error", err)
+		return err
+	}
+	s.mu.Lock()
+	s.idle = append(s.idle, p)
+	s.mu.Unlock()
+	return err
+}
+
+// get returns an idle process or starts a new one
+func (s *Server) get() (*process, error) {
+	s.mu.Lock()
+	if n := len(s.idle); n > 0 {
+		p := s.idle[n-1]
+		s.idle = s.idle[:n-1]
+		s.mu.Unlock()
+		return p, nil
+	}
+	s.mu.Unlock()
+
+	if s.command == "" {
+		return nil, fmt.Errorf("no query server configured for language %q", s.language)
+	}
+	return startProcess(s.command, s.logger)
+}
+
+// Close terminates the idle processes
+func (s *Server) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, p := range s.idle {
+		p.kill()
+	}
+	s.idle = nil
+}
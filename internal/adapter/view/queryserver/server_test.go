@@ -0,0 +1,407 @@
+package queryserver
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/goydb/goydb/internal/adapter/logger"
+	"github.com/goydb/goydb/internal/adapter/reducer"
+	"github.com/goydb/goydb/pkg/model"
+	"github.com/goydb/goydb/pkg/port"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMain runs the test binary as fake query server if requested
+func TestMain(m *testing.M) {
+	switch os.Getenv("GOYDB_FAKE_QUERY_SERVER") {
+	case "1":
+		fakeQueryServer()
+		os.Exit(0)
+	case "transcript":
+		replayTranscript(os.Getenv("GOYDB_FAKE_QUERY_SERVER_TRANSCRIPT"))
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// fakeQueryServer implements the line protocol, functions are
+// "<op>:<field>" strings instead of source code:
+//
+//	map:      emit:<field>, hang, crash
+//	reduce:   sum
+//	filter:   has:<field>
+//	validate: require:<field>
+//	update:   set:<field>
+func fakeQueryServer() {
+	var funs []string
+	ddocs := make(map[string]map[string]interface{})
+	in := bufio.NewScanner(os.Stdin)
+	in.Buffer(make([]byte, 1<<20), 1<<20)
+	out := json.NewEncoder(os.Stdout)
+
+	for in.Scan() {
+		var cmd []interface{}
+		if err := json.Unmarshal(in.Bytes(), &cmd); err != nil {
+			_ = out.Encode([]interface{}{"error", "bad_json", err.Error()})
+			continue
+		}
+		switch cmd[0] {
+		case "reset":
+			funs = nil
+			_ = out.Encode(true)
+		case "add_fun":
+			if !strings.Contains(cmd[1].(string), ":") && cmd[1] != "hang" && cmd[1] != "crash" {
+				_ = out.Encode([]interface{}{"error", "compilation_error", "invalid function"})
+				continue
+			}
+			funs = append(funs, cmd[1].(string))
+			_ = out.Encode(true)
+		case "map_doc":
+			doc := cmd[1].(map[string]interface{})
+			var results []interface{}
+			for _, fn := range funs {
+				op, field, _ := strings.Cut(fn, ":")
+				switch op {
+				case "hang":
+					time.Sleep(time.Hour)
+				case "crash":
+					os.Exit(1)
+				}
+				_ = out.Encode([]interface{}{"log", "mapping " + doc["_id"].(string)})
+				rows := []interface{}{}
+				if v, ok := doc[field]; ok {
+					rows = append(rows, []interface{}{v, 1})
+				}
+				results = append(results, rows)
+			}
+			_ = out.Encode(results)
+		case "reduce", "rereduce":
+			var sum float64
+			for _, v := range cmd[2].([]interface{}) {
+				if cmd[0] == "reduce" {
+					v = v.([]interface{})[1]
+				}
+				sum += v.(float64)
+			}
+			_ = out.Encode([]interface{}{true, []interface{}{sum}})
+		case "ddoc":
+			if cmd[1] == "new" {
+				ddocs[cmd[2].(string)] = cmd[3].(map[string]interface{})
+				_ = out.Encode(true)
+				continue
+			}
+			ddoc, ok := ddocs[cmd[1].(string)]
+			if !ok {
+				_ = out.Encode([]interface{}{"error", "query_protocol_error", "unknown ddoc"})
+				continue
+			}
+			path := cmd[2].([]interface{})
+			// the arguments are passed as one array
+			args, ok := cmd[3].([]interface{})
+			if len(cmd) != 4 || !ok {
+				_ = out.Encode([]interface{}{"error", "query_protocol_error", "invalid ddoc arguments"})
+				continue
+			}
+			var fn string
+			if len(path) == 1 {
+				fn, _ = ddoc[path[0].(string)].(string)
+			} else {
+				fn, _ = ddoc[path[0].(string)].(map[string]interface{})[path[1].(string)].(string)
+			}
+			_, field, _ := strings.Cut(fn, ":")
+			switch path[0] {
+			case "filters":
+				if len(args) != 2 {
+					_ = out.Encode([]interface{}{"error", "query_protocol_error", "filters take [docs, req]"})
+					continue
+				}
+				var pass []bool
+				for _, doc := range args[0].([]interface{}) {
+					_, ok := doc.(map[string]interface{})[field]
+					pass = append(pass, ok)
+				}
+				_ = out.Encode([]interface{}{true, pass})
+			case "validate_doc_update":
+				if len(args) != 4 {
+					_ = out.Encode([]interface{}{"error", "query_protocol_error", "validate_doc_update takes [newDoc, oldDoc, userCtx, secObj]"})
+					continue
+				}
+				if _, ok := args[0].(map[string]interface{})[field]; !ok {
+					_ = out.Encode(map[string]interface{}{"forbidden": "missing " + field})
+					continue
+				}
+				_ = out.Encode(1)
+			case "updates":
+				if len(args) != 2 {
+					_ = out.Encode([]interface{}{"error", "query_protocol_error", "updates take [doc, req]"})
+					continue
+				}
+				doc, _ := args[0].(map[string]interface{})
+				if doc == nil {
+					doc = map[string]interface{}{"_id": "new"}
+				}
+				doc[field] = true
+				_ = out.Encode([]interface{}{"up", doc, map[string]interface{}{"code": 201, "body": "ok"}})
+			}
+		default:
+			_ = out.Encode([]interface{}{"error", "unknown_command", fmt.Sprint(cmd[0])})
+		}
+	}
+}
+
+// replayTranscript answers the commands of a transcript, every command
+// must match the next "> " line, it is answered with the "< " line.
+func replayTranscript(path string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		panic(err)
+	}
+	type exchange struct{ request, response string }
+	var transcript []exchange
+	for _, line := range strings.Split(string(data), "\n") {
+		switch {
+		case strings.HasPrefix(line, "> "):
+			transcript = append(transcript, exchange{request: line[2:]})
+		case strings.HasPrefix(line, "< "):
+			transcript[len(transcript)-1].response = line[2:]
+		}
+	}
+
+	in := bufio.NewScanner(os.Stdin)
+	in.Buffer(make([]byte, 1<<20), 1<<20)
+	out := json.NewEncoder(os.Stdout)
+	for in.Scan() {
+		if len(transcript) == 0 {
+			_ = out.Encode([]interface{}{"error", "transcript_mismatch", "unexpected " + in.Text()})
+			continue
+		}
+		next := transcript[0]
+		var got, want interface{}
+		_ = json.Unmarshal(in.Bytes(), &got)
+		_ = json.Unmarshal([]byte(next.request), &want)
+		if !reflect.DeepEqual(got, want) {
+			_ = out.Encode([]interface{}{"error", "transcript_mismatch", "expected " + next.request + " got " + in.Text()})
+			continue
+		}
+		transcript = transcript[1:]
+		fmt.Println(next.response)
+	}
+}
+
+func newTestServer(t *testing.T, config map[string]string) *Server {
+	t.Helper()
+	t.Setenv("GOYDB_FAKE_QUERY_SERVER", "1")
+	values := map[string]map[string]string{
+		"couchdb":             {"os_process_timeout": config["timeout"]},
+		"query_server_config": {"os_process_limit": "2"},
+	}
+	s := New("fake", os.Args[0], func(section, key string) (string, bool) {
+		v, ok := values[section][key]
+		return v, ok && v != ""
+	}, logger.NewNoLog())
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestViewServer(t *testing.T) {
+	s := newTestServer(t, nil)
+
+	vs, err := s.NewViewServer("emit:name", nil)
+	require.NoError(t, err)
+	res, err := vs.ExecuteView(context.Background(), []*model.Document{
+		{ID: "a", Data: map[string]interface{}{"name": "alice"}},
+		{ID: "b", Data: map[string]interface{}{}},
+	})
+	require.NoError(t, err)
+	require.Len(t, res, 1)
+	assert.Equal(t, "alice", res[0].Key)
+	assert.EqualValues(t, 1, res[0].Value)
+	assert.Equal(t, "a", res[0].ID)
+
+	// a second function reuses the process
+	vs2, err := s.NewViewServer("emit:age", nil)
+	require.NoError(t, err)
+	res, err = vs2.ExecuteView(context.Background(), []*model.Document{
+		{ID: "a", Data: map[string]interface{}{"age": 42}},
+	})
+	require.NoError(t, err)
+	require.Len(t, res, 1)
+	assert.EqualValues(t, 42, res[0].Key)
+
+	invalid, err := s.NewViewServer("nonsense", nil)
+	require.NoError(t, err)
+	_, err = invalid.ExecuteView(context.Background(), []*model.Document{{ID: "a"}})
+	var qsErr *Error
+	require.ErrorAs(t, err, &qsErr)
+	assert.Equal(t, "compilation_error", qsErr.ID)
+
+	_, err = vs.ExecuteSearch(context.Background(), nil)
+	assert.Error(t, err)
+}
+
+func TestViewServer_Restart(t *testing.T) {
+	s := newTestServer(t, map[string]string{"timeout": "200"})
+
+	hang, err := s.NewViewServer("hang", nil)
+	require.NoError(t, err)
+	_, err = hang.ExecuteView(context.Background(), []*model.Document{{ID: "a"}})
+	assert.True(t, errors.Is(err, port.ErrTimeout), err)
+
+	crash, err := s.NewViewServer("crash", nil)
+	require.NoError(t, err)
+	_, err = crash.ExecuteView(context.Background(), []*model.Document{{ID: "a"}})
+	assert.True(t, errors.Is(err, ErrExited), err)
+
+	// the killed processes are replaced
+	vs, err := s.NewViewServer("emit:name", nil)
+	require.NoError(t, err)
+	res, err := vs.ExecuteView(context.Background(), []*model.Document{
+		{ID: "a", Data: map[string]interface{}{"name": "alice"}},
+	})
+	require.NoError(t, err)
+	assert.Len(t, res, 1)
+}
+
+func TestReducer(t *testing.T) {
+	s := newTestServer(t, nil)
+
+	r, err := s.NewReducer("sum")
+	require.NoError(t, err)
+	for i := 0; i < reducer.BatchSize+10; i++ {
+		r.Reduce(&model.Document{ID: fmt.Sprint(i), Key: "a", Value: 1})
+	}
+	r.Reduce(&model.Document{ID: "x", Key: "b", Value: 2})
+	result := r.Result()
+	require.NoError(t, r.(port.ReducerError).Err())
+	require.Len(t, result, 2)
+	assert.Equal(t, &model.Document{Key: "a", Value: float64(reducer.BatchSize + 10)}, result[0])
+	assert.Equal(t, &model.Document{Key: "b", Value: float64(2)}, result[1])
+}
+
+func TestDesignDocFunctions(t *testing.T) {
+	s := newTestServer(t, nil)
+	ddoc := &model.Document{
+		ID:  "_design/app",
+		Rev: "1-a",
+		Data: map[string]interface{}{
+			"language":            "fake",
+			"filters":             map[string]interface{}{"typed": "has:type"},
+			"updates":             map[string]interface{}{"touch": "set:touched"},
+			"validate_doc_update": "require:title",
+		},
+	}
+
+	f, err := s.NewFilterServer("has:type", ddoc)
+	require.NoError(t, err)
+	pass, err := f.ExecuteFilter(context.Background(), &model.Document{ID: "a", Data: map[string]interface{}{"type": "post"}}, nil)
+	require.NoError(t, err)
+	assert.True(t, pass)
+	pass, err = f.ExecuteFilter(context.Background(), &model.Document{ID: "b", Data: map[string]interface{}{}}, nil)
+	require.NoError(t, err)
+	assert.False(t, pass)
+
+	_, err = s.NewFilterServer("has:other", ddoc)
+	assert.Error(t, err)
+
+	v, err := s.NewValidateServer("require:title", ddoc)
+	require.NoError(t, err)
+	assert.NoError(t, v.ExecuteValidation(context.Background(), map[string]interface{}{"title": "x"}, nil, nil, nil))
+	err = v.ExecuteValidation(context.Background(), map[string]interface{}{}, nil, nil, nil)
+	var forbidden *model.ErrForbidden
+	require.ErrorAs(t, err, &forbidden)
+	assert.Equal(t, "missing title", forbidden.Msg)
+
+	u, err := s.NewUpdateServer("set:touched", ddoc)
+	require.NoError(t, err)
+	res, err := u.ExecuteUpdate(context.Background(), nil, map[string]interface{}{})
+	require.NoError(t, err)
+	assert.Equal(t, 201, res.Code)
+	assert.Equal(t, "ok", res.Body)
+	assert.Equal(t, map[string]interface{}{"_id": "new", "touched": true}, res.Doc)
+}
+
+func TestDesignDocFunctions_Transcript(t *testing.T) {
+	t.Setenv("GOYDB_FAKE_QUERY_SERVER", "transcript")
+	t.Setenv("GOYDB_FAKE_QUERY_SERVER_TRANSCRIPT", "testdata/ddoc.transcript")
+	s := New("javascript", os.Args[0], func(section, key string) (string, bool) {
+		if section == "query_server_config" && key == "os_process_limit" {
+			return "1", true
+		}
+		return "", false
+	}, logger.NewNoLog())
+	t.Cleanup(s.Close)
+
+	filter := "function(doc, req) { return doc.type === req.query.type; }"
+	update := "function(doc, req) { doc = doc || {_id: 'new'}; doc.touched = true; return [doc, {code: 201, body: 'ok'}]; }"
+	validate := "function(newDoc, oldDoc, userCtx, secObj) { if (!newDoc.title) throw({forbidden: 'missing title'}); }"
+	ddoc := &model.Document{
+		ID:  "_design/app",
+		Rev: "1-a",
+		Data: map[string]interface{}{
+			"language":            "javascript",
+			"filters":             map[string]interface{}{"typed": filter},
+			"updates":             map[string]interface{}{"touch": update},
+			"validate_doc_update": validate,
+		},
+	}
+	ctx := context.Background()
+
+	f, err := s.NewFilterServer(filter, ddoc)
+	require.NoError(t, err)
+	pass, err := f.ExecuteFilter(ctx, &model.Document{ID: "a", Data: map[string]interface{}{"type": "post"}},
+		map[string]interface{}{"query": map[string]interface{}{"type": "post"}})
+	require.NoError(t, err)
+	assert.True(t, pass)
+
+	v, err := s.NewValidateServer(validate, ddoc)
+	require.NoError(t, err)
+	err = v.ExecuteValidation(ctx,
+		map[string]interface{}{"_id": "a", "_rev": "2-b"},
+		map[string]interface{}{"_id": "a", "_rev": "1-b", "title": "x"},
+		map[string]interface{}{"name": "bob", "roles": []interface{}{"editor"}},
+		map[string]interface{}{"admins": map[string]interface{}{}, "members": map[string]interface{}{}})
+	var forbidden *model.ErrForbidden
+	require.ErrorAs(t, err, &forbidden)
+	assert.Equal(t, "missing title", forbidden.Msg)
+
+	u, err := s.NewUpdateServer(update, ddoc)
+	require.NoError(t, err)
+	res, err := u.ExecuteUpdate(ctx, nil, map[string]interface{}{"method": "POST"})
+	require.NoError(t, err)
+	assert.Equal(t, 201, res.Code)
+	assert.Equal(t, map[string]interface{}{"_id": "new", "touched": true}, res.Doc)
+}
+
+func TestServer_NotConfigured(t *testing.T) {
+	s := New("missing", "", func(section, key string) (string, bool) { return "", false }, logger.NewNoLog())
+	vs, err := s.NewViewServer("emit:name", nil)
+	require.NoError(t, err)
+	_, err = vs.ExecuteView(context.Background(), []*model.Document{{ID: "a"}})
+	assert.ErrorContains(t, err, `no query server configured for language "missing"`)
+}
+
+func TestCommands(t *testing.T) {
+	commands := Commands(map[string]string{
+		"python": "/usr/bin/couchpy",
+		"ruby":   "/usr/bin/couchrb",
+	}, []string{
+		"PATH=/usr/bin",
+		"GOYDB_QUERY_SERVER_RUBY=/opt/couchrb",
+		"GOYDB_QUERY_SERVER_LUA=/usr/bin/couchlua --fast",
+		"GOYDB_QUERY_SERVER_=/bin/sh",
+	})
+	assert.Equal(t, map[string]string{
+		"python": "/usr/bin/couchpy",
+		"ruby":   "/opt/couchrb",
+		"lua":    "/usr/bin/couchlua --fast",
+	}, commands)
+}
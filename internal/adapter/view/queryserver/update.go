@@ -0,0 +1,98 @@
+package queryserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/goydb/goydb/pkg/model"
+	"github.com/goydb/goydb/pkg/port"
+)
+
+var _ port.UpdateServer = (*UpdateServer)(nil)
+
+// UpdateServer executes an update function in a query server
+type UpdateServer struct {
+	server *Server
+	ddoc   *model.Document
+	name   string
+}
+
+// NewUpdateServer returns an UpdateServer for the update function
+func (s *Server) NewUpdateServer(fn string, ddoc *model.Document) (port.UpdateServer, error) {
+	name, err := functionName(ddoc, "updates", fn)
+	if err != nil {
+		return nil, err
+	}
+	return &UpdateServer{server: s, ddoc: ddoc, name: name}, nil
+}
+
+// ExecuteUpdate runs the update function and parses the ["up", doc, response] result.
+func (u *UpdateServer) ExecuteUpdate(ctx context.Context, doc map[string]interface{}, req map[string]interface{}) (*port.UpdateResult, error) {
+	var resp []interface{}
+	err := u.server.do(ctx, func(p *process, timeout time.Duration) error {
+		if err := p.ensureDDoc(ctx, timeout, u.ddoc); err != nil {
+			return err
+		}
+		var docVal interface{}
+		if doc != nil {
+			docVal = doc
+		}
+		line, err := p.call(ctx, timeout, ddocCommand(u.ddoc.ID, []string{"updates", u.name}, docVal, req)...)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(line, &resp)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("update function error: %w", err)
+	}
+	if len(resp) != 3 || resp[0] != "up" {
+		return nil, fmt.Errorf("update function must return [\"up\", doc, response], got %v", resp)
+	}
+
+	return parseUpdateResult(resp[1], resp[2])
+}
+
+// parseUpdateResult parses the document and response of an update function.
+func parseUpdateResult(doc, resp interface{}) (*port.UpdateResult, error) {
+	res := &port.UpdateResult{}
+
+	if doc != nil {
+		docMap, ok := doc.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("document of update result must be an object or null, got %T", doc)
+		}
+		res.Doc = docMap
+	}
+
+	switch resp := resp.(type) {
+	case string:
+		res.Body = resp
+	case map[string]interface{}:
+		if code, ok := resp["code"].(float64); ok {
+			res.Code = int(code)
+		}
+		if headers, ok := resp["headers"].(map[string]interface{}); ok {
+			res.Headers = make(map[string]string)
+			for k, v := range headers {
+				if s, ok := v.(string); ok {
+					res.Headers[k] = s
+				}
+			}
+		}
+		if body, ok := resp["body"].(string); ok {
+			res.Body = body
+		}
+		if jsonVal, ok := resp["json"]; ok {
+			res.JSON = jsonVal
+		}
+	case nil:
+		// empty response
+	default:
+		return nil, fmt.Errorf("response of update result must be a string or object, got %T", resp)
+	}
+
+	return res, nil
+}
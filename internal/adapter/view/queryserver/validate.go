@@ -0,0 +1,46 @@
+package queryserver
+
+import (
+	"context"
+	"time"
+
+	"github.com/goydb/goydb/pkg/model"
+	"github.com/goydb/goydb/pkg/port"
+)
+
+var _ port.ValidateServer = (*ValidateServer)(nil)
+
+// ValidateServer executes a validate_doc_update function in a query server
+type ValidateServer struct {
+	server *Server
+	ddoc   *model.Document
+}
+
+// NewValidateServer returns a ValidateServer for the validate_doc_update
+// function of the design document
+func (s *Server) NewValidateServer(_ string, ddoc *model.Document) (port.ValidateServer, error) {
+	if ddoc == nil {
+		return nil, errNoDDoc
+	}
+	return &ValidateServer{server: s, ddoc: ddoc}, nil
+}
+
+// ExecuteValidation runs the validate_doc_update function, rejections
+// are returned as ErrForbidden or ErrUnauthorized.
+func (v *ValidateServer) ExecuteValidation(ctx context.Context,
+	newDoc, oldDoc map[string]interface{},
+	userCtx, secObj map[string]interface{}) error {
+
+	return v.server.do(ctx, func(p *process, timeout time.Duration) error {
+		if err := p.ensureDDoc(ctx, timeout, v.ddoc); err != nil {
+			return err
+		}
+		var old interface{}
+		if oldDoc != nil {
+			old = oldDoc
+		}
+		_, err := p.call(ctx, timeout, ddocCommand(v.ddoc.ID, []string{"validate_doc_update"},
+			newDoc, old, userCtx, secObj)...)
+		return err
+	})
+}
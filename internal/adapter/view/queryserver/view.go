@@ -0,0 +1,102 @@
+package queryserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/goydb/goydb/pkg/model"
+	"github.com/goydb/goydb/pkg/port"
+)
+
+var _ port.ViewServer = (*ViewServer)(nil)
+
+// ViewServer executes a map function in a query server
+type ViewServer struct {
+	server *Server
+	fn     string
+	lib    interface{}
+	// key identifies the function and library loaded in a process
+	key string
+}
+
+// NewViewServer returns a ViewServer for the map function, the
+// views.lib of the design document is sent with "add_lib".
+func (s *Server) NewViewServer(fn string, ddoc *model.Document) (port.ViewServer, error) {
+	vs := &ViewServer{server: s, fn: fn, key: fn}
+	if ddoc != nil {
+		if views, ok := ddoc.Data["views"].(map[string]interface{}); ok {
+			vs.lib = views[model.ViewLib]
+		}
+	}
+	if vs.lib != nil {
+		lib, err := json.Marshal(vs.lib)
+		if err != nil {
+			return nil, fmt.Errorf("invalid views.lib: %w", err)
+		}
+		vs.key += "\x00" + string(lib)
+	}
+	return vs, nil
+}
+
+// load resets the process and adds the map function
+// unless it is loaded already
+func (s *ViewServer) load(ctx context.Context, p *process, timeout time.Duration) error {
+	if p.fun == s.key {
+		return nil
+	}
+	p.fun = ""
+	if _, err := p.call(ctx, timeout, "reset"); err != nil {
+		return err
+	}
+	if s.lib != nil {
+		if _, err := p.call(ctx, timeout, "add_lib", s.lib); err != nil {
+			return err
+		}
+	}
+	if _, err := p.call(ctx, timeout, "add_fun", s.fn); err != nil {
+		return fmt.Errorf("failed to compile map function: %w", err)
+	}
+	p.fun = s.key
+	return nil
+}
+
+func (s *ViewServer) ExecuteView(ctx context.Context, docs []*model.Document) ([]*model.Document, error) {
+	var result []*model.Document
+	err := s.server.do(ctx, func(p *process, timeout time.Duration) error {
+		if err := s.load(ctx, p, timeout); err != nil {
+			return err
+		}
+		for _, doc := range docs {
+			line, err := p.call(ctx, timeout, "map_doc", docJSON(doc))
+			if err != nil {
+				return err
+			}
+			// one list of [key, value] rows per function
+			var rows [][][]interface{}
+			if err := json.Unmarshal(line, &rows); err != nil {
+				return fmt.Errorf("invalid map_doc response: %w", err)
+			}
+			for _, fnRows := range rows {
+				for _, row := range fnRows {
+					if len(row) != 2 {
+						return fmt.Errorf("invalid map_doc row: %v", row)
+					}
+					result = append(result, &model.Document{
+						Key:   row[0],
+						Value: row[1],
+						ID:    doc.ID,
+					})
+				}
+			}
+		}
+		return nil
+	})
+	return result, err
+}
+
+// ExecuteSearch isn't part of the query server protocol
+func (s *ViewServer) ExecuteSearch(ctx context.Context, docs []*model.Document) ([]*model.Document, error) {
+	return nil, fmt.Errorf("search functions are not supported by the %q query server", s.server.language)
+}
@@ -0,0 +1,13 @@
+package queryview
+
+import (
+	"fmt"
+
+	"github.com/goydb/goydb/pkg/port"
+)
+
+// NewReducer rejects custom reduce functions, query views only
+// support the built-in reducers (_sum, _count, _stats, ...)
+func NewReducer(fn string) (port.Reducer, error) {
+	return nil, fmt.Errorf("query views only support built-in reducers, got %q", fn)
+}
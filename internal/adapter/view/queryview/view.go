@@ -0,0 +1,198 @@
+// Package queryview evaluates declarative views without a script
+// engine. Design documents with "language": "query" define the map
+// function as object:
+//
+//	{"fields": ["type", "createdAt"], "value": "$doc.total", "filter": {"type": "order"}}
+//
+// Documents matching the Mango selector of filter emit the values of
+// fields as key, a single field is emitted as scalar key. Documents
+// missing a field of the key are skipped. The value is resolved
+// recursively, strings starting with "$doc" reference the document.
+package queryview
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/goydb/goydb/pkg/model"
+	"github.com/goydb/goydb/pkg/port"
+)
+
+// Language is the design document language of declarative views
+const Language = "query"
+
+// docRef is the prefix of values referencing the document
+const docRef = "$doc"
+
+var _ port.ViewServer = (*ViewServer)(nil)
+
+// Map is a declarative map function
+type Map struct {
+	Fields []string             `json:"fields"`
+	Value  interface{}          `json:"value"`
+	Filter *model.SelectorGroup `json:"filter"`
+}
+
+// ViewServer evaluates a declarative map function natively
+type ViewServer struct {
+	m Map
+}
+
+// NewViewServer parses the declarative map function
+func NewViewServer(fn string) (port.ViewServer, error) {
+	var m Map
+	dec := json.NewDecoder(strings.NewReader(fn))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&m); err != nil {
+		return nil, fmt.Errorf("invalid query map function: %w", err)
+	}
+	if len(m.Fields) == 0 {
+		return nil, errors.New("invalid query map function: fields are required")
+	}
+	for _, field := range m.Fields {
+		if field == "" {
+			return nil, errors.New("invalid query map function: empty field")
+		}
+	}
+	if err := checkValue(m.Value); err != nil {
+		return nil, err
+	}
+
+	return &ViewServer{m: m}, nil
+}
+
+func (s *ViewServer) ExecuteView(ctx context.Context, docs []*model.Document) ([]*model.Document, error) {
+	var result []*model.Document
+	for _, doc := range docs {
+		df := documentFields{doc}
+		if s.m.Filter != nil && len(s.m.Filter.Members) > 0 {
+			ok, err := s.m.Filter.Match(df)
+			if err != nil {
+				return nil, fmt.Errorf("filter of %q failed: %w", doc.ID, err)
+			}
+			if !ok {
+				continue
+			}
+		}
+
+		key, ok := s.key(df)
+		if !ok {
+			continue
+		}
+		result = append(result, &model.Document{
+			ID:    doc.ID,
+			Key:   key,
+			Value: resolve(df, s.m.Value),
+		})
+	}
+	return result, nil
+}
+
+func (s *ViewServer) ExecuteSearch(ctx context.Context, docs []*model.Document) ([]*model.Document, error) {
+	return nil, errors.New("search indexes are not supported by query views")
+}
+
+// key returns the key of the document, false if a field is missing
+func (s *ViewServer) key(df documentFields) (interface{}, bool) {
+	if len(s.m.Fields) == 1 {
+		v := df.Field(s.m.Fields[0])
+		return v, v != nil
+	}
+	key := make([]interface{}, len(s.m.Fields))
+	for i, field := range s.m.Fields {
+		key[i] = df.Field(field)
+		if key[i] == nil {
+			return nil, false
+		}
+	}
+	return key, true
+}
+
+// resolve replaces the document references of the value
+func resolve(df documentFields, v interface{}) interface{} {
+	switch v := v.(type) {
+	case string:
+		if v == docRef {
+			return df.data()
+		}
+		if path, ok := strings.CutPrefix(v, docRef+"."); ok {
+			return df.Field(path)
+		}
+		return v
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, e := range v {
+			list[i] = resolve(df, e)
+		}
+		return list
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[k] = resolve(df, e)
+		}
+		return m
+	}
+	return v
+}
+
+// checkValue validates the document references of the value
+func checkValue(v interface{}) error {
+	switch v := v.(type) {
+	case string:
+		if strings.HasPrefix(v, docRef) && v != docRef && !strings.HasPrefix(v, docRef+".") {
+			return fmt.Errorf("invalid query map function: invalid reference %q", v)
+		}
+	case []interface{}:
+		for _, e := range v {
+			if err := checkValue(e); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		for _, e := range v {
+			if err := checkValue(e); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// documentFields resolves the fields of a document
+// including its _id and _rev
+type documentFields struct {
+	*model.Document
+}
+
+func (df documentFields) Field(path string) interface{} {
+	switch path {
+	case "_id":
+		return df.ID
+	case "_rev":
+		if df.Rev == "" {
+			return nil
+		}
+		return df.Rev
+	}
+	return df.Document.Field(path)
+}
+
+func (df documentFields) Exists(path string) bool {
+	return df.Field(path) != nil
+}
+
+// data returns the document with its _id and _rev
+func (df documentFields) data() map[string]interface{} {
+	m := make(map[string]interface{}, len(df.Data)+2)
+	for k, v := range df.Data {
+		m[k] = v
+	}
+	m["_id"] = df.ID
+	if df.Rev != "" {
+		m["_rev"] = df.Rev
+	}
+	return m
+}
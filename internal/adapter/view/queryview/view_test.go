@@ -0,0 +1,84 @@
+package queryview
+
+import (
+	"context"
+	"testing"
+
+	"github.com/goydb/goydb/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testDocs() []*model.Document {
+	return []*model.Document{
+		{ID: "a", Rev: "1-a", Data: map[string]interface{}{"type": "order", "createdAt": "2024-01-01", "total": 10.0, "customer": map[string]interface{}{"name": "alice"}}},
+		{ID: "b", Rev: "1-b", Data: map[string]interface{}{"type": "order", "total": 5.0}},
+		{ID: "c", Rev: "1-c", Data: map[string]interface{}{"type": "invoice", "createdAt": "2024-01-02", "total": 7.0}},
+	}
+}
+
+func TestViewServer_ExecuteView(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   string
+		want []*model.Document
+	}{
+		{
+			name: "single field",
+			fn:   `{"fields": ["type"]}`,
+			want: []*model.Document{
+				{ID: "a", Key: "order"},
+				{ID: "b", Key: "order"},
+				{ID: "c", Key: "invoice"},
+			},
+		},
+		{
+			name: "compound key skips missing fields",
+			fn:   `{"fields": ["type", "createdAt"], "value": "$doc.total"}`,
+			want: []*model.Document{
+				{ID: "a", Key: []interface{}{"order", "2024-01-01"}, Value: 10.0},
+				{ID: "c", Key: []interface{}{"invoice", "2024-01-02"}, Value: 7.0},
+			},
+		},
+		{
+			name: "filter",
+			fn:   `{"fields": ["_id"], "value": 1, "filter": {"type": "order", "total": {"$gt": 6}}}`,
+			want: []*model.Document{
+				{ID: "a", Key: "a", Value: 1.0},
+			},
+		},
+		{
+			name: "nested value",
+			fn:   `{"fields": ["createdAt"], "value": {"name": "$doc.customer.name", "totals": ["$doc.total", "$doc.missing"], "rev": "$doc._rev"}}`,
+			want: []*model.Document{
+				{ID: "a", Key: "2024-01-01", Value: map[string]interface{}{"name": "alice", "totals": []interface{}{10.0, nil}, "rev": "1-a"}},
+				{ID: "c", Key: "2024-01-02", Value: map[string]interface{}{"name": nil, "totals": []interface{}{7.0, nil}, "rev": "1-c"}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewViewServer(tt.fn)
+			require.NoError(t, err)
+			got, err := s.ExecuteView(context.Background(), testDocs())
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestViewServer_Invalid(t *testing.T) {
+	for _, fn := range []string{
+		`function(doc) { emit(doc._id, 1); }`,
+		`{"fields": []}`,
+		`{"fields": ["type"], "emit": true}`,
+		`{"fields": ["type"], "value": "$document.total"}`,
+		`{"fields": ["type"], "filter": {"$or": 1}}`,
+	} {
+		_, err := NewViewServer(fn)
+		assert.Error(t, err, fn)
+	}
+
+	_, err := NewReducer("function(keys, values) { return 1; }")
+	assert.Error(t, err)
+}
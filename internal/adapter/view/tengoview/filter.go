@@ -11,20 +11,22 @@ import (
 
 var _ port.FilterServer = (*FilterServer)(nil)
 
+// FilterServer executes a compiled filter function using tengo.
 type FilterServer struct {
 	script *tengo.Compiled
 }
 
+// NewFilterServer compiles a filter function into a FilterServer.
 func NewFilterServer(fn string) (port.FilterServer, error) {
-	// Wrap filter function
 	source := fmt.Sprintf(`
 		filterFn := %s
-		export func(doc, req) {
-			return filterFn(doc, req)
-		}
+		_result := filterFn(doc, req)
 	`, fn)
 
-	script := tengo.NewScript([]byte(source))
+	script := newScript(source)
+	script.Add("doc", nil) //nolint:errcheck
+	script.Add("req", nil) //nolint:errcheck
+
 	compiled, err := script.Compile()
 	if err != nil {
 		return nil, fmt.Errorf("failed to compile filter: %w", err)
@@ -33,6 +35,8 @@ func NewFilterServer(fn string) (port.FilterServer, error) {
 	return &FilterServer{script: compiled}, nil
 }
 
+// ExecuteFilter runs the filter function, the document passes if
+// the result is truthy.
 func (f *FilterServer) ExecuteFilter(ctx context.Context, doc *model.Document, req map[string]interface{}) (bool, error) {
 	// Convert document to tengo object
 	simpleDoc := map[string]interface{}{
@@ -48,20 +52,17 @@ func (f *FilterServer) ExecuteFilter(ctx context.Context, doc *model.Document, r
 		}
 	}
 
-	// Set variables
-	_ = f.script.Set("doc", simpleDoc)
-	_ = f.script.Set("req", req)
+	c := f.script.Clone()
+	_ = c.Set("doc", simpleDoc)
+	_ = c.Set("req", req)
 
-	// Run script
-	if err := f.script.Run(); err != nil {
+	if err := c.RunContext(ctx); err != nil {
 		return false, err
 	}
 
-	// Get result
-	result := f.script.Get("result")
-	if result == nil {
-		return false, nil
+	result := c.Get("_result")
+	if err := result.Error(); err != nil {
+		return false, err
 	}
-
-	return result.Bool(), nil
+	return !result.Object().IsFalsy(), nil
 }
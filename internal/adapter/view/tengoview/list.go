@@ -0,0 +1,174 @@
+package tengoview
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/d5/tengo/v2"
+	"github.com/goydb/goydb/pkg/model"
+	"github.com/goydb/goydb/pkg/port"
+)
+
+var _ port.ListServer = (*ListServer)(nil)
+
+// ListServer executes a compiled list function using tengo.
+type ListServer struct {
+	script *tengo.Compiled
+}
+
+// NewListServer compiles a list function into a ListServer.
+//
+// The function reads the rows of the view using getRow() and writes
+// the response using start(resp) and send(chunk), the returned string
+// is sent as last chunk. Formats can be registered using provides()
+// and registerType() like for show functions.
+func NewListServer(fn string) (port.ListServer, error) {
+	source := fmt.Sprintf(`
+		_provided := []
+		provides := func(key, fn) {
+			_provided = append(_provided, [key, fn])
+		}
+		registerType := func(key, ...types) {
+			_register_type(key, types)
+		}
+		listFn := %s
+		_result := listFn(head, req)
+		if is_undefined(_result) && len(_provided) > 0 {
+			keys := []
+			for p in _provided {
+				keys = append(keys, p[0])
+			}
+			_format := _negotiate(keys)
+			for p in _provided {
+				if p[0] == _format {
+					_result = p[1]()
+					break
+				}
+			}
+		}
+	`, fn)
+
+	script := tengo.NewScript([]byte(source))
+	for _, name := range []string{"head", "req", "start", "send", "getRow", "_register_type", "_negotiate"} {
+		script.Add(name, nil) //nolint:errcheck
+	}
+
+	compiled, err := script.Compile()
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile list function: %w", err)
+	}
+
+	return &ListServer{script: compiled}, nil
+}
+
+// ExecuteList runs the list function streaming its output to out.
+func (s *ListServer) ExecuteList(ctx context.Context, head, req map[string]interface{}, rows port.ListRows, out port.ListWriter) error {
+	mimeTypes := model.NewMimeTypes()
+	format, accept := model.NegotiationParams(req)
+	negotiated := true
+	resp := &listResponse{out: out, headers: make(map[string]string)}
+
+	c := s.script.Clone()
+	_ = c.Set("head", head)
+	_ = c.Set("req", req)
+	_ = c.Set("_register_type", registerTypeFunc(mimeTypes))
+	_ = c.Set("_negotiate", negotiateFunc(mimeTypes, format, accept, func(key string, ok bool) {
+		negotiated = ok
+		resp.contentType = mimeTypes.ContentType(key)
+	}))
+	_ = c.Set("start", &tengo.UserFunction{
+		Name: "start",
+		Value: func(args ...tengo.Object) (tengo.Object, error) {
+			if len(args) != 1 {
+				return nil, tengo.ErrWrongNumArguments
+			}
+			res, err := parseTengoShowResponse(tengo.ToInterface(args[0]))
+			if err != nil {
+				return nil, err
+			}
+			resp.set(res.Code, res.Headers)
+			return tengo.UndefinedValue, nil
+		},
+	})
+	_ = c.Set("send", &tengo.UserFunction{
+		Name: "send",
+		Value: func(args ...tengo.Object) (tengo.Object, error) {
+			if len(args) != 1 {
+				return nil, tengo.ErrWrongNumArguments
+			}
+			chunk, _ := tengo.ToString(args[0])
+			return tengo.UndefinedValue, resp.send(chunk)
+		},
+	})
+	_ = c.Set("getRow", &tengo.UserFunction{
+		Name: "getRow",
+		Value: func(args ...tengo.Object) (tengo.Object, error) {
+			if err := resp.start(); err != nil {
+				return nil, err
+			}
+			row, err := rows()
+			if err != nil {
+				return nil, err
+			}
+			if row == nil {
+				return tengo.UndefinedValue, nil
+			}
+			return tengo.FromInterface(row)
+		},
+	})
+
+	if err := c.RunContext(ctx); err != nil {
+		return fmt.Errorf("list function error: %w", err)
+	}
+	if !negotiated {
+		return port.ErrNotAcceptable
+	}
+
+	// the return value is the last chunk of the response
+	var tail string
+	if v := c.Get("_result"); !v.IsUndefined() {
+		tail = v.String()
+	}
+	return resp.send(tail)
+}
+
+// listResponse starts the response of a list function
+// on the first row fetched or chunk sent.
+type listResponse struct {
+	out         port.ListWriter
+	code        int
+	headers     map[string]string
+	contentType string // of the format selected by provides()
+	started     bool
+}
+
+// set records the code and headers passed to start()
+func (l *listResponse) set(code int, headers map[string]string) {
+	if code != 0 {
+		l.code = code
+	}
+	for k, v := range headers {
+		l.headers[k] = v
+	}
+}
+
+func (l *listResponse) start() error {
+	if l.started {
+		return nil
+	}
+	l.started = true
+	if _, ok := l.headers["Content-Type"]; !ok && l.contentType != "" {
+		l.headers["Content-Type"] = l.contentType
+	}
+	return l.out.Start(l.code, l.headers)
+}
+
+func (l *listResponse) send(chunk string) error {
+	if err := l.start(); err != nil {
+		return err
+	}
+	if chunk == "" {
+		return nil
+	}
+	return l.out.Send(chunk)
+}
@@ -0,0 +1,109 @@
+package tengoview
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/goydb/goydb/pkg/port"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testListWriter struct {
+	code    int
+	headers map[string]string
+	chunks  []string
+}
+
+func (w *testListWriter) Start(code int, headers map[string]string) error {
+	w.code = code
+	w.headers = headers
+	return nil
+}
+
+func (w *testListWriter) Send(chunk string) error {
+	w.chunks = append(w.chunks, chunk)
+	return nil
+}
+
+func testListRows(rows ...map[string]interface{}) port.ListRows {
+	return func() (map[string]interface{}, error) {
+		if len(rows) == 0 {
+			return nil, nil
+		}
+		row := rows[0]
+		rows = rows[1:]
+		return row, nil
+	}
+}
+
+func TestListServer_ExecuteList(t *testing.T) {
+	s, err := NewListServer(`func(head, req) {
+		start({code: 201, headers: {"Content-Type": "text/csv"}})
+		send("id,value\n")
+		for row := getRow(); !is_undefined(row); row = getRow() {
+			send(row.id + "," + string(row.value) + "\n")
+		}
+		return "total " + string(head.total_rows)
+	}`)
+	require.NoError(t, err)
+
+	var w testListWriter
+	err = s.ExecuteList(context.Background(),
+		map[string]interface{}{"total_rows": 2},
+		map[string]interface{}{},
+		testListRows(
+			map[string]interface{}{"id": "a", "value": 1},
+			map[string]interface{}{"id": "b", "value": 2},
+		), &w)
+	require.NoError(t, err)
+	assert.Equal(t, 201, w.code)
+	assert.Equal(t, "text/csv", w.headers["Content-Type"])
+	assert.Equal(t, []string{"id,value\n", "a,1\n", "b,2\n", "total 2"}, w.chunks)
+}
+
+func TestListServer_Provides(t *testing.T) {
+	s, err := NewListServer(`func(head, req) {
+		provides("html", func() {
+			send("<ul>")
+			for row := getRow(); !is_undefined(row); row = getRow() {
+				send("<li>" + row.key + "</li>")
+			}
+			return "</ul>"
+		})
+		provides("text", func() {
+			for row := getRow(); !is_undefined(row); row = getRow() {
+				send(row.key + "\n")
+			}
+		})
+	}`)
+	require.NoError(t, err)
+
+	tests := []struct {
+		accept      string
+		body        string
+		contentType string
+		err         error
+	}{
+		{accept: "", body: "<ul><li>a</li></ul>", contentType: "text/html; charset=utf-8"},
+		{accept: "text/plain", body: "a\n", contentType: "text/plain; charset=utf-8"},
+		{accept: "image/png", err: port.ErrNotAcceptable},
+	}
+	for _, tt := range tests {
+		req := map[string]interface{}{
+			"query":   map[string]interface{}{},
+			"headers": map[string]interface{}{"Accept": tt.accept},
+		}
+		var w testListWriter
+		err := s.ExecuteList(context.Background(), map[string]interface{}{}, req,
+			testListRows(map[string]interface{}{"key": "a"}), &w)
+		if tt.err != nil {
+			assert.ErrorIs(t, err, tt.err)
+			continue
+		}
+		require.NoError(t, err, tt.accept)
+		assert.Equal(t, tt.body, strings.Join(w.chunks, ""), tt.accept)
+		assert.Equal(t, tt.contentType, w.headers["Content-Type"], tt.accept)
+	}
+}
@@ -0,0 +1,67 @@
+package tengoview
+
+import (
+	"fmt"
+
+	"github.com/goydb/goydb/internal/adapter/reducer"
+	"github.com/goydb/goydb/pkg/port"
+)
+
+// NewReducerBuilder returns a ReducerServerBuilder that captures the logger
+func NewReducerBuilder(logger port.Logger) port.ReducerServerBuilder {
+	return func(fn string) (port.Reducer, error) {
+		return NewReducer(fn, logger)
+	}
+}
+
+// NewReducer compiles a reduce function. The function is called with
+// keys, values and rereduce, keys are [key, id] pairs on the first pass
+// and undefined if the values are rereduced. Calls exceeding
+// couchdb/os_process_timeout are aborted, see Configure.
+func NewReducer(fn string, logger port.Logger) (port.Reducer, error) {
+	source := fmt.Sprintf(`
+		sum := func(values) {
+			_sum := 0
+			for v in values {
+				_sum += v
+			}
+			return _sum
+		}
+		reduceFn := %s
+		_result := reduceFn(keys, values, rereduce)
+	`, fn)
+
+	script := newScript(source)
+	for _, name := range []string{"keys", "values", "rereduce", "log"} {
+		script.Add(name, nil) //nolint:errcheck
+	}
+	compiled, err := script.Compile()
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile reduce function: %w", err)
+	}
+
+	return reducer.NewBatch(func(keys []reducer.Key, values []interface{}, rereduce bool) (interface{}, error) {
+		c := compiled.Clone()
+		var pairs interface{}
+		if !rereduce {
+			list := make([]interface{}, len(keys))
+			for i, k := range keys {
+				list[i] = []interface{}{k.Key, k.ID}
+			}
+			pairs = list
+		}
+		_ = c.Set("keys", pairs)
+		_ = c.Set("values", values)
+		_ = c.Set("rereduce", rereduce)
+		_ = c.Set("log", logFunc(logger, "reduce log"))
+
+		if err := runTimeout(c); err != nil {
+			return nil, err
+		}
+		result := c.Get("_result")
+		if err := result.Error(); err != nil {
+			return nil, err
+		}
+		return result.Value(), nil
+	}), nil
+}
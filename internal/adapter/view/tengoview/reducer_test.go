@@ -0,0 +1,31 @@
+package tengoview
+
+import (
+	"testing"
+	"time"
+
+	"github.com/goydb/goydb/internal/adapter/logger"
+	"github.com/goydb/goydb/pkg/model"
+	"github.com/goydb/goydb/pkg/port"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReducer_Timeout(t *testing.T) {
+	Configure(func(section, key string) (string, bool) {
+		if section == "couchdb" && key == "os_process_timeout" {
+			return "50", true
+		}
+		return "", false
+	})
+	t.Cleanup(func() { configGetter.Store(nil) })
+
+	r, err := NewReducer(`func(keys, values, rereduce) { for {} }`, logger.NewNoLog())
+	require.NoError(t, err)
+
+	start := time.Now()
+	r.Reduce(&model.Document{Key: "a", Value: 1})
+	r.Result()
+	assert.ErrorIs(t, r.(port.ReducerError).Err(), port.ErrTimeout)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
@@ -0,0 +1,90 @@
+package tengoview
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/d5/tengo/v2"
+	"github.com/goydb/goydb/pkg/port"
+)
+
+var _ port.RewriteServer = (*RewriteServer)(nil)
+
+// RewriteServer executes a compiled rewrite function using tengo.
+type RewriteServer struct {
+	script *tengo.Compiled
+}
+
+// NewRewriteServer compiles a rewrite function into a RewriteServer.
+func NewRewriteServer(fn string) (port.RewriteServer, error) {
+	source := fmt.Sprintf(`
+		rewriteFn := %s
+		_result := rewriteFn(req)
+	`, fn)
+
+	script := newScript(source)
+	script.Add("req", nil) //nolint:errcheck
+
+	compiled, err := script.Compile()
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile rewrite function: %w", err)
+	}
+
+	return &RewriteServer{script: compiled}, nil
+}
+
+// ExecuteRewrite runs the rewrite function and parses the path or map result.
+func (s *RewriteServer) ExecuteRewrite(ctx context.Context, req map[string]interface{}) (*port.RewriteResult, error) {
+	c := s.script.Clone()
+	_ = c.Set("req", req)
+
+	if err := c.RunContext(ctx); err != nil {
+		return nil, fmt.Errorf("rewrite function error: %w", err)
+	}
+
+	result := c.Get("_result")
+	if err := result.Error(); err != nil {
+		return nil, fmt.Errorf("rewrite function error: %w", err)
+	}
+	return parseRewriteResult(result.Value())
+}
+
+// parseRewriteResult parses the result (string or map) of a rewrite function.
+func parseRewriteResult(resp interface{}) (*port.RewriteResult, error) {
+	res := &port.RewriteResult{}
+
+	switch resp := resp.(type) {
+	case string:
+		res.Path = resp
+	case map[string]interface{}:
+		res.Path, _ = resp["path"].(string)
+		res.Method, _ = resp["method"].(string)
+		if query, ok := resp["query"].(map[string]interface{}); ok {
+			res.Query = query
+		}
+		if headers, ok := resp["headers"].(map[string]interface{}); ok {
+			res.Headers = make(map[string]string, len(headers))
+			for k, v := range headers {
+				if s, ok := v.(string); ok {
+					res.Headers[k] = s
+				}
+			}
+		}
+		if body, ok := resp["body"].(string); ok {
+			res.Body = &body
+		}
+		switch c := resp["code"].(type) {
+		case int64:
+			res.Code = int(c)
+		case float64:
+			res.Code = int(c)
+		}
+		if res.Path == "" && res.Code == 0 {
+			return nil, fmt.Errorf("rewrite function must return a path or a code")
+		}
+	default:
+		return nil, fmt.Errorf("rewrite function must return a string or map, got %T", resp)
+	}
+
+	return res, nil
+}
@@ -0,0 +1,89 @@
+package tengoview
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/d5/tengo/v2"
+	"github.com/d5/tengo/v2/stdlib"
+	"github.com/goydb/goydb/pkg/port"
+)
+
+// modules are the standard library modules that can be imported
+var modules = []string{
+	"text",   // regular expressions, string conversion, and manipulation
+	"math",   // mathematical constants and functions
+	"times",  // time-related functions
+	"rand",   // random functions
+	"fmt",    // formatting functions
+	"json",   // JSON functions
+	"enum",   // Enumeration functions
+	"hex",    // hex encoding and decoding functions
+	"base64", // base64 encoding and decoding functions
+}
+
+// newScript creates a script that can import the standard library
+func newScript(source string) *tengo.Script {
+	script := tengo.NewScript([]byte(source))
+	script.SetImports(stdlib.GetModuleMap(modules...))
+	return script
+}
+
+// logFunc returns a log(msg) function writing to the logger
+func logFunc(logger port.Logger, message string) *tengo.UserFunction {
+	return &tengo.UserFunction{
+		Name: "log",
+		Value: func(args ...tengo.Object) (tengo.Object, error) {
+			if len(args) != 1 {
+				return nil, tengo.ErrWrongNumArguments
+			}
+			logger.Infof(context.Background(), message, "message", tengo.ToInterface(args[0]))
+			return tengo.UndefinedValue, nil
+		},
+	}
+}
+
+// defaultTimeout is used if couchdb/os_process_timeout isn't set
+const defaultTimeout = 5 * time.Second
+
+var configGetter atomic.Pointer[func(section, key string) (string, bool)]
+
+// Configure reads couchdb/os_process_timeout on every call, the
+// timeout applies to functions that aren't bound to a request
+// context, e.g. reduce functions.
+func Configure(get func(section, key string) (string, bool)) {
+	configGetter.Store(&get)
+}
+
+// timeout returns the configured timeout, 0 disables the timeout
+func timeout() time.Duration {
+	get := configGetter.Load()
+	if get == nil {
+		return defaultTimeout
+	}
+	if v, ok := (*get)("couchdb", "os_process_timeout"); ok {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			return time.Duration(n) * time.Millisecond
+		}
+	}
+	return defaultTimeout
+}
+
+// runTimeout runs the compiled script, the run is aborted with
+// port.ErrTimeout if it exceeds the configured timeout
+func runTimeout(c *tengo.Compiled) error {
+	ctx := context.Background()
+	if d := timeout(); d > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d)
+		defer cancel()
+	}
+	err := c.RunContext(ctx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return port.ErrTimeout
+	}
+	return err
+}
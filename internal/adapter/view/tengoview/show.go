@@ -0,0 +1,191 @@
+package tengoview
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/d5/tengo/v2"
+	"github.com/goydb/goydb/pkg/model"
+	"github.com/goydb/goydb/pkg/port"
+)
+
+var _ port.ShowServer = (*ShowServer)(nil)
+
+// ShowServer executes a compiled show function using tengo.
+type ShowServer struct {
+	script *tengo.Compiled
+}
+
+// NewShowServer compiles a show function into a ShowServer.
+//
+// The function can register formats using provides(key, fn) and
+// registerType(key, ...types); if it returns no result the function
+// of the format acceptable for the request is called.
+func NewShowServer(fn string) (port.ShowServer, error) {
+	source := fmt.Sprintf(`
+		_provided := []
+		provides := func(key, fn) {
+			_provided = append(_provided, [key, fn])
+		}
+		registerType := func(key, ...types) {
+			_register_type(key, types)
+		}
+		showFn := %s
+		_result := showFn(doc, req)
+		_format := ""
+		if is_undefined(_result) && len(_provided) > 0 {
+			keys := []
+			for p in _provided {
+				keys = append(keys, p[0])
+			}
+			_format = _negotiate(keys)
+			for p in _provided {
+				if p[0] == _format {
+					_result = p[1]()
+					break
+				}
+			}
+		}
+	`, fn)
+
+	script := tengo.NewScript([]byte(source))
+	script.Add("doc", nil)            //nolint:errcheck
+	script.Add("req", nil)            //nolint:errcheck
+	script.Add("_register_type", nil) //nolint:errcheck
+	script.Add("_negotiate", nil)     //nolint:errcheck
+
+	compiled, err := script.Compile()
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile show function: %w", err)
+	}
+
+	return &ShowServer{script: compiled}, nil
+}
+
+// ExecuteShow runs the show function and parses the response.
+func (s *ShowServer) ExecuteShow(ctx context.Context, doc map[string]interface{}, req map[string]interface{}) (*port.ShowResult, error) {
+	mimeTypes := model.NewMimeTypes()
+	format, accept := model.NegotiationParams(req)
+	negotiated := true
+
+	c := s.script.Clone()
+	_ = c.Set("doc", doc)
+	_ = c.Set("req", req)
+	_ = c.Set("_register_type", registerTypeFunc(mimeTypes))
+	_ = c.Set("_negotiate", negotiateFunc(mimeTypes, format, accept, func(key string, ok bool) {
+		negotiated = ok
+	}))
+
+	if err := c.RunContext(ctx); err != nil {
+		return nil, fmt.Errorf("show function error: %w", err)
+	}
+	if !negotiated {
+		return nil, port.ErrNotAcceptable
+	}
+
+	var result interface{}
+	if v := c.Get("_result"); v != nil {
+		result = v.Value()
+	}
+	res, err := parseTengoShowResponse(result)
+	if err != nil {
+		return nil, err
+	}
+
+	if key := c.Get("_format").String(); key != "" && res.JSON == nil {
+		if _, ok := res.Headers["Content-Type"]; !ok {
+			res.Headers["Content-Type"] = mimeTypes.ContentType(key)
+		}
+	}
+	return res, nil
+}
+
+// registerTypeFunc returns the function backing registerType(key, ...types)
+func registerTypeFunc(mimeTypes model.MimeTypes) *tengo.UserFunction {
+	return &tengo.UserFunction{
+		Name: "registerType",
+		Value: func(args ...tengo.Object) (tengo.Object, error) {
+			if len(args) != 2 {
+				return nil, tengo.ErrWrongNumArguments
+			}
+			key, _ := tengo.ToString(args[0])
+			mimeTypes.Register(key, stringsArg(args[1])...)
+			return tengo.UndefinedValue, nil
+		},
+	}
+}
+
+// negotiateFunc returns a function that selects the key of the provided
+// keys acceptable for the request, selected is called with the result.
+func negotiateFunc(mimeTypes model.MimeTypes, format, accept string, selected func(key string, ok bool)) *tengo.UserFunction {
+	return &tengo.UserFunction{
+		Name: "negotiate",
+		Value: func(args ...tengo.Object) (tengo.Object, error) {
+			if len(args) != 1 {
+				return nil, tengo.ErrWrongNumArguments
+			}
+			key, ok := mimeTypes.Negotiate(stringsArg(args[0]), format, accept)
+			selected(key, ok)
+			return &tengo.String{Value: key}, nil
+		},
+	}
+}
+
+// stringsArg returns the strings of an array argument
+func stringsArg(arg tengo.Object) []string {
+	var values []string
+	if arr, ok := arg.(*tengo.Array); ok {
+		for _, v := range arr.Value {
+			if s, ok := tengo.ToString(v); ok {
+				values = append(values, s)
+			}
+		}
+	}
+	return values
+}
+
+// parseTengoShowResponse parses the response (string or map) of a tengo show function.
+func parseTengoShowResponse(resp interface{}) (*port.ShowResult, error) {
+	res := &port.ShowResult{Headers: make(map[string]string)}
+
+	switch resp := resp.(type) {
+	case string:
+		res.Body = resp
+	case map[string]interface{}:
+		switch c := resp["code"].(type) {
+		case int64:
+			res.Code = int(c)
+		case int:
+			res.Code = c
+		case float64:
+			res.Code = int(c)
+		}
+		if headers, ok := resp["headers"].(map[string]interface{}); ok {
+			for k, v := range headers {
+				if s, ok := v.(string); ok {
+					res.Headers[k] = s
+				}
+			}
+		}
+		if body, ok := resp["body"].(string); ok {
+			res.Body = body
+		}
+		if b64, ok := resp["base64"].(string); ok {
+			data, err := base64.StdEncoding.DecodeString(b64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid base64 body: %w", err)
+			}
+			res.Body = string(data)
+		}
+		if jsonVal, ok := resp["json"]; ok {
+			res.JSON = jsonVal
+		}
+	case nil:
+		// empty response
+	default:
+		return nil, fmt.Errorf("show function must return a string or map, got %T", resp)
+	}
+
+	return res, nil
+}
@@ -0,0 +1,64 @@
+package tengoview
+
+import (
+	"context"
+	"testing"
+
+	"github.com/goydb/goydb/pkg/port"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShowServer_ExecuteShow(t *testing.T) {
+	s, err := NewShowServer(`func(doc, req) {
+		return {code: 201, headers: {"X-Title": doc.title}, body: "<h1>" + doc.title + "</h1>"}
+	}`)
+	require.NoError(t, err)
+
+	res, err := s.ExecuteShow(context.Background(), map[string]interface{}{"title": "Hello"}, map[string]interface{}{})
+	require.NoError(t, err)
+	assert.Equal(t, 201, res.Code)
+	assert.Equal(t, "Hello", res.Headers["X-Title"])
+	assert.Equal(t, "<h1>Hello</h1>", res.Body)
+}
+
+func TestShowServer_Provides(t *testing.T) {
+	s, err := NewShowServer(`func(doc, req) {
+		registerType("vcard", "text/vcard")
+		provides("html", func() { return "<b>" + doc.name + "</b>" })
+		provides("vcard", func() { return "FN:" + doc.name })
+	}`)
+	require.NoError(t, err)
+	doc := map[string]interface{}{"name": "Alice"}
+
+	tests := []struct {
+		query       map[string]interface{}
+		accept      string
+		body        string
+		contentType string
+		err         error
+	}{
+		{accept: "", body: "<b>Alice</b>", contentType: "text/html; charset=utf-8"},
+		{accept: "text/vcard", body: "FN:Alice", contentType: "text/vcard"},
+		{accept: "text/vcard;q=0.5, text/html", body: "<b>Alice</b>", contentType: "text/html; charset=utf-8"},
+		{query: map[string]interface{}{"format": "vcard"}, accept: "text/html", body: "FN:Alice", contentType: "text/vcard"},
+		{accept: "application/json", err: port.ErrNotAcceptable},
+	}
+	for _, tt := range tests {
+		req := map[string]interface{}{
+			"query":   tt.query,
+			"headers": map[string]interface{}{"Accept": tt.accept},
+		}
+		if tt.query == nil {
+			req["query"] = map[string]interface{}{}
+		}
+		res, err := s.ExecuteShow(context.Background(), doc, req)
+		if tt.err != nil {
+			assert.ErrorIs(t, err, tt.err)
+			continue
+		}
+		require.NoError(t, err, tt.accept)
+		assert.Equal(t, tt.body, res.Body, tt.accept)
+		assert.Equal(t, tt.contentType, res.Headers["Content-Type"], tt.accept)
+	}
+}
@@ -36,14 +36,15 @@ func NewUpdateServer(fn string) (port.UpdateServer, error) {
 
 // ExecuteUpdate runs the update function and parses the [doc, response] result.
 func (u *UpdateServer) ExecuteUpdate(ctx context.Context, doc map[string]interface{}, req map[string]interface{}) (*port.UpdateResult, error) {
-	_ = u.script.Set("doc", doc)
-	_ = u.script.Set("req", req)
+	c := u.script.Clone()
+	_ = c.Set("doc", doc)
+	_ = c.Set("req", req)
 
-	if err := u.script.RunContext(ctx); err != nil {
+	if err := c.RunContext(ctx); err != nil {
 		return nil, fmt.Errorf("update function error: %w", err)
 	}
 
-	resultVar := u.script.Get("_result")
+	resultVar := c.Get("_result")
 	if resultVar == nil {
 		return nil, fmt.Errorf("update function did not return a result")
 	}
@@ -14,35 +14,39 @@ var _ port.ValidateServer = (*ValidateServer)(nil)
 // ValidateServer executes a compiled validate_doc_update function using tengo.
 type ValidateServer struct {
 	script *tengo.Compiled
+	logger port.Logger
 }
 
 // NewValidateServerBuilder returns a ValidateServerBuilder that captures the logger.
 func NewValidateServerBuilder(logger port.Logger) port.ValidateServerBuilder {
 	return func(fn string) (port.ValidateServer, error) {
-		return NewValidateServer(fn)
+		return NewValidateServer(fn, logger)
 	}
 }
 
 // NewValidateServer compiles a validate_doc_update function into a ValidateServer.
-func NewValidateServer(fn string) (port.ValidateServer, error) {
-	// Tengo doesn't have throw(), so we use error() to signal validation failures.
-	// The validate function must call forbidden(msg) or unauthorized(msg) helpers.
+//
+// Tengo has no throw, the function rejects a document by returning
+// forbidden(msg) or unauthorized(msg).
+func NewValidateServer(fn string, logger port.Logger) (port.ValidateServer, error) {
 	source := fmt.Sprintf(`
+		forbidden := func(msg) { return error({forbidden: msg}) }
+		unauthorized := func(msg) { return error({unauthorized: msg}) }
 		validateFn := %s
-		forbidden := func(msg) { error(immutable({forbidden: msg})) }
-		unauthorized := func(msg) { error(immutable({unauthorized: msg})) }
-		export func(newDoc, oldDoc, userCtx, secObj) {
-			return validateFn(newDoc, oldDoc, userCtx, secObj)
-		}
+		_result := validateFn(newDoc, oldDoc, userCtx, secObj)
 	`, fn)
 
-	script := tengo.NewScript([]byte(source))
+	script := newScript(source)
+	for _, name := range []string{"newDoc", "oldDoc", "userCtx", "secObj", "log"} {
+		script.Add(name, nil) //nolint:errcheck
+	}
+
 	compiled, err := script.Compile()
 	if err != nil {
 		return nil, fmt.Errorf("failed to compile validate_doc_update: %w", err)
 	}
 
-	return &ValidateServer{script: compiled}, nil
+	return &ValidateServer{script: compiled, logger: logger}, nil
 }
 
 // ExecuteValidation runs the validate_doc_update function.
@@ -50,23 +54,33 @@ func (v *ValidateServer) ExecuteValidation(ctx context.Context,
 	newDoc, oldDoc map[string]interface{},
 	userCtx, secObj map[string]interface{}) error {
 
-	_ = v.script.Set("newDoc", newDoc)
-	_ = v.script.Set("oldDoc", oldDoc)
-	_ = v.script.Set("userCtx", userCtx)
-	_ = v.script.Set("secObj", secObj)
+	c := v.script.Clone()
+	_ = c.Set("newDoc", newDoc)
+	_ = c.Set("oldDoc", oldDoc)
+	_ = c.Set("userCtx", userCtx)
+	_ = c.Set("secObj", secObj)
+	_ = c.Set("log", logFunc(v.logger, "vdu log"))
 
-	if err := v.script.RunContext(ctx); err != nil {
-		return classifyTengoError(err)
+	if err := c.RunContext(ctx); err != nil {
+		return fmt.Errorf("validate_doc_update error: %w", err)
 	}
 
+	if e, ok := c.Get("_result").Object().(*tengo.Error); ok {
+		return classifyTengoError(e)
+	}
 	return nil
 }
 
-// classifyTengoError converts a tengo error into ErrForbidden or ErrUnauthorized.
-func classifyTengoError(err error) error {
-	msg := err.Error()
-	// Tengo runtime errors from error() contain the object representation.
-	// Best-effort classification for tengo VDU functions.
-	_ = msg
-	return &model.ErrForbidden{Msg: err.Error()}
+// classifyTengoError converts the error returned by the validate function
+// into ErrForbidden or ErrUnauthorized, other errors are forbidden.
+func classifyTengoError(e *tengo.Error) error {
+	if m, ok := tengo.ToInterface(e.Value).(map[string]interface{}); ok {
+		if msg, ok := m["unauthorized"]; ok {
+			return &model.ErrUnauthorized{Msg: fmt.Sprint(msg)}
+		}
+		if msg, ok := m["forbidden"]; ok {
+			return &model.ErrForbidden{Msg: fmt.Sprint(msg)}
+		}
+	}
+	return &model.ErrForbidden{Msg: fmt.Sprint(tengo.ToInterface(e.Value))}
 }
@@ -5,7 +5,6 @@ import (
 	"fmt"
 
 	"github.com/d5/tengo/v2"
-	"github.com/d5/tengo/v2/stdlib"
 	"github.com/goydb/goydb/pkg/model"
 	"github.com/goydb/goydb/pkg/port"
 )
@@ -13,7 +12,6 @@ import (
 var _ port.ViewServer = (*ViewServer)(nil)
 
 type ViewServer struct {
-	script   *tengo.Script
 	compiled *tengo.Compiled
 }
 
@@ -34,8 +32,8 @@ func NewViewServer(fn string) (port.ViewServer, error) {
 	emit := func (key, value) {
 		_result = _result + [[ key, value, _doc._id ]]
 	}
-	index := func (name, value, opts) {
-		_index = _index + [[ name, value, opts ]]
+	index := func (name, value, ...opts) {
+		_index = _index + [[ name, value, len(opts) > 0 ? opts[0] : {} ]]
 	}
 	docFn := ` + fn + `
 	for doc in docs {
@@ -46,19 +44,7 @@ func NewViewServer(fn string) (port.ViewServer, error) {
 			_result = _result + [[_doc._id, _index]]
 		}
 	}`
-	script := tengo.NewScript([]byte(fn))
-	script.SetImports(stdlib.GetModuleMap(
-		"text",   // regular expressions, string conversion, and manipulation
-		"math",   // mathematical constants and functions
-		"times",  // time-related functions
-		"rand",   // random functions
-		"fmt",    // formatting functions
-		"json",   // JSON functions
-		"enum",   // Enumeration functions
-		"hex",    // hex encoding and decoding functions
-		"base64", // base64 encoding and decoding functions
-	))
-
+	script := newScript(fn)
 	err := script.Add("docs", []interface{}{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to add docs: %w", err)
@@ -70,23 +56,15 @@ func NewViewServer(fn string) (port.ViewServer, error) {
 	}
 
 	return &ViewServer{
-		script:   script,
 		compiled: compiled,
 	}, nil
 }
 
 func (s *ViewServer) ExecuteView(ctx context.Context, docs []*model.Document) ([]*model.Document, error) {
-	err := s.setDocs(docs)
-	if err != nil {
-		return nil, err
-	}
-
-	err = s.compiled.RunContext(ctx)
+	resultData, err := s.run(ctx, docs)
 	if err != nil {
 		return nil, err
 	}
-
-	resultData := s.compiled.Get("_result").Array()
 	result := make([]*model.Document, len(resultData))
 
 	for i, rd := range resultData {
@@ -103,17 +81,10 @@ func (s *ViewServer) ExecuteView(ctx context.Context, docs []*model.Document) ([
 }
 
 func (s *ViewServer) ExecuteSearch(ctx context.Context, docs []*model.Document) ([]*model.Document, error) {
-	err := s.setDocs(docs)
+	resultData, err := s.run(ctx, docs)
 	if err != nil {
 		return nil, err
 	}
-
-	err = s.compiled.RunContext(ctx)
-	if err != nil {
-		return nil, err
-	}
-
-	resultData := s.compiled.Get("_result").Array()
 	result := make([]*model.Document, len(resultData))
 
 	for i, rd := range resultData {
@@ -151,7 +122,9 @@ func (s *ViewServer) ExecuteSearch(ctx context.Context, docs []*model.Document)
 	return result, nil
 }
 
-func (s *ViewServer) setDocs(docs []*model.Document) error {
+// run executes the script for the docs on a copy of the compiled
+// script and returns the _result
+func (s *ViewServer) run(ctx context.Context, docs []*model.Document) ([]interface{}, error) {
 	simpleDocs := make([]interface{}, len(docs))
 	for i, doc := range docs {
 		doc.Data["_id"] = doc.ID
@@ -159,5 +132,12 @@ func (s *ViewServer) setDocs(docs []*model.Document) error {
 		simpleDocs[i] = doc.Data
 	}
 
-	return s.compiled.Set("docs", simpleDocs)
+	c := s.compiled.Clone()
+	if err := c.Set("docs", simpleDocs); err != nil {
+		return nil, err
+	}
+	if err := c.RunContext(ctx); err != nil {
+		return nil, err
+	}
+	return c.Get("_result").Array(), nil
 }
@@ -0,0 +1,401 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/fxamacker/cbor/v2"
+
+	"github.com/goydb/goydb/internal/adapter/storage"
+	"github.com/goydb/goydb/pkg/model"
+	"github.com/goydb/goydb/pkg/port"
+)
+
+// dbCopyCheckpointPrefix is the prefix of the local documents in the
+// target database that record the state of the copied views
+const dbCopyCheckpointPrefix = "_local/dbcopy:"
+
+// dbCopyCheckpoint is the sequence of the view index that was copied
+type dbCopyCheckpoint struct {
+	Seq uint64 `json:"seq"`
+}
+
+// AuthorizeDBCopy returns a model.ErrForbidden if the session isn't
+// allowed to write the target database of a dbcopy view. System
+// databases are never allowed, databases that don't exist yet may
+// only be created by server admins and existing databases require
+// the session to be an admin or member of the database.
+func AuthorizeDBCopy(ctx context.Context, s port.Storage, target string, session *model.Session) error {
+	if session == nil {
+		session = &model.Session{}
+	}
+	if strings.HasPrefix(target, "_") {
+		return &model.ErrForbidden{Msg: fmt.Sprintf("dbcopy target %q is a system database", target)}
+	}
+	if session.IsServerAdmin() {
+		return nil
+	}
+
+	db, err := s.Database(ctx, target)
+	if errors.Is(err, storage.ErrUnknownDatabase) {
+		return &model.ErrForbidden{Msg: fmt.Sprintf("only server admins can create the dbcopy target %q", target)}
+	} else if err != nil {
+		return err
+	}
+	sec, err := db.GetSecurity(ctx)
+	if err != nil {
+		return err
+	}
+	if !session.Authenticated() || !securityAdmits(sec, session) {
+		return &model.ErrForbidden{Msg: fmt.Sprintf("not allowed to write the dbcopy target %q", target)}
+	}
+	return nil
+}
+
+// securityAdmits returns true if the session is an admin or member of
+// the database, empty members admit any authenticated user
+func securityAdmits(sec *model.Security, session *model.Session) bool {
+	if len(sec.Members.Names) == 0 && len(sec.Members.Roles) == 0 {
+		return true
+	}
+	names := append(append([]string{}, sec.Admins.Names...), sec.Members.Names...)
+	for _, name := range names {
+		if name == session.Name {
+			return true
+		}
+	}
+	roles := append(append([]string{}, sec.Admins.Roles...), sec.Members.Roles...)
+	for _, role := range roles {
+		for _, srole := range session.Roles {
+			if role == srole {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// SetDBCopyAuthor records the session that wrote the revision of the
+// design document, the views of the revision are copied on behalf of
+// the author. The record is stored in the meta bucket which can't
+// be written by clients.
+func SetDBCopyAuthor(ctx context.Context, db port.Database, ddocID, rev string, session *model.Session) error {
+	if session == nil {
+		session = &model.Session{}
+	}
+	data, err := json.Marshal(model.DBCopyAuthor{Rev: rev, Name: session.Name, Roles: session.Roles})
+	if err != nil {
+		return err
+	}
+	return db.Transaction(ctx, func(tx port.DatabaseTx) error {
+		tx.EnsureBucket(model.MetaBucket)
+		tx.Put(model.MetaBucket, model.DBCopyAuthorKey(ddocID), data)
+		return nil
+	})
+}
+
+// dbCopyAuthor returns the author of the revision of the design
+// document or nil if the revision has no author
+func dbCopyAuthor(ctx context.Context, db port.Database, ddoc *model.Document) (*model.DBCopyAuthor, error) {
+	var author *model.DBCopyAuthor
+	err := db.Transaction(ctx, func(tx port.DatabaseTx) error {
+		data, err := tx.Get(model.MetaBucket, model.DBCopyAuthorKey(ddoc.ID))
+		if errors.Is(err, port.ErrNotFound) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		author = new(model.DBCopyAuthor)
+		return json.Unmarshal(data, author)
+	})
+	if err != nil || author == nil || author.Rev != ddoc.Rev {
+		return nil, err
+	}
+	return author, nil
+}
+
+// UpdateDBCopies copies the grouped reduce results of the views
+// with a dbcopy option into the target databases. Each group is
+// a document with the key and the value of the group, only the
+// changed groups are written and the groups that disappeared
+// are deleted.
+func (c Task) UpdateDBCopies(ctx context.Context, db port.Database) error {
+	ddocs, _, err := db.AllDesignDocs(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, ddoc := range ddocs {
+		if ddoc.Deleted || ddoc.IsStagedDesignDoc() {
+			continue
+		}
+		for _, f := range ddoc.Functions() {
+			if f.Type != model.ViewFn {
+				continue
+			}
+			view, ok := ddoc.View(f.Name)
+			if !ok || view.DBCopy == "" || view.ReduceFn == "" {
+				continue
+			}
+			ddfn := f.DesignDocFn()
+			err := c.updateDBCopy(ctx, db, ddoc, view, ddfn)
+			if err != nil {
+				c.Logger.Warnf(ctx, "failed to update dbcopy", "view", ddfn.String(), "target", view.DBCopy, "error", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (c Task) updateDBCopy(ctx context.Context, db port.Database, ddoc *model.Document, view *model.View, ddfn *model.DesignDocFn) error {
+	name := ddfn.String()
+	idx, ok := db.Indices()[name]
+	if !ok {
+		return nil
+	}
+	tracker, ok := idx.(port.KeyChangeTracker)
+	if !ok {
+		return fmt.Errorf("index %q doesn't track key changes", name)
+	}
+	seq, building, err := db.IndexSeq(ctx, name)
+	if err != nil || building {
+		return err
+	}
+
+	// the groups are written on behalf of the author of the
+	// design document, the permissions may have changed since
+	author, err := dbCopyAuthor(ctx, db, ddoc)
+	if err != nil {
+		return err
+	}
+	if author == nil {
+		return &model.ErrForbidden{Msg: fmt.Sprintf("revision %s of %q has no dbcopy author", ddoc.Rev, ddoc.ID)}
+	}
+	session := author.Session()
+	err = AuthorizeDBCopy(ctx, c.Storage, view.DBCopy, session)
+	if err != nil {
+		return err
+	}
+
+	target, err := c.Storage.Database(ctx, view.DBCopy)
+	if err != nil {
+		target, err = c.Storage.CreateDatabase(ctx, view.DBCopy)
+		if err != nil {
+			return err
+		}
+	}
+	w := dbCopyWriter{target: target, session: session, validate: c.ValidateDocUpdate}
+
+	// the view didn't change since the last copy
+	checkpointID := dbCopyCheckpointPrefix + db.Name() + ":" + name
+	checkpointDoc, err := target.GetDocument(ctx, checkpointID)
+	if err != nil {
+		return err
+	}
+	if checkpointDoc != nil {
+		var checkpoint dbCopyCheckpoint
+		err = decodeJSON(checkpointDoc.Data, &checkpoint)
+		if err != nil {
+			return err
+		}
+		if checkpoint.Seq == seq {
+			return nil
+		}
+	}
+
+	// without a checkpoint all groups are copied, otherwise
+	// only the groups of the keys changed since the last copy
+	var groups map[interface{}]interface{}
+	var changed []dbCopyGroup
+	var records [][]byte
+	err = db.Transaction(ctx, func(tx port.DatabaseTx) error {
+		var keys [][]byte
+		keys, records = tracker.ChangedKeys(tx)
+		var err error
+		if checkpointDoc == nil {
+			groups, _, err = DesignDoc{DB: db}.ReduceDocs(ctx, tx, idx, port.AllDocsQuery{ViewGroup: "true"}, ddoc, view)
+			return err
+		}
+		for _, key := range keys {
+			g := dbCopyGroup{}
+			g.value, g.found, err = DesignDoc{DB: db}.ReduceKey(ctx, tx, idx, ddoc, view, key)
+			if err != nil {
+				return err
+			}
+			err = cbor.Unmarshal(key, &g.key)
+			if err != nil {
+				return err
+			}
+			changed = append(changed, g)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for k, v := range groups {
+		key, value := k, v
+		if doc, ok := v.(*model.Document); ok {
+			key, value = doc.Key, doc.Value
+		}
+		changed = append(changed, dbCopyGroup{key: key, value: value, found: true})
+	}
+	for _, g := range changed {
+		if g.found {
+			err = w.put(ctx, g.key, g.value)
+		} else {
+			err = w.delete(ctx, g.key)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	checkpointData := make(map[string]interface{})
+	err = decodeJSON(dbCopyCheckpoint{Seq: seq}, &checkpointData)
+	if err != nil {
+		return err
+	}
+	newCheckpoint := &model.Document{ID: checkpointID, Data: checkpointData}
+	if checkpointDoc != nil {
+		newCheckpoint.Rev = checkpointDoc.Rev
+	}
+	_, err = target.PutDocument(ctx, newCheckpoint)
+	if err != nil {
+		return err
+	}
+
+	// changes recorded during the copy are kept for the next copy
+	return db.Transaction(ctx, func(tx port.DatabaseTx) error {
+		tracker.ClearChangedKeys(tx, records)
+		return nil
+	})
+}
+
+// dbCopyGroup is a group of the view, found is false if the
+// view has no rows for the key of the group anymore
+type dbCopyGroup struct {
+	key, value interface{}
+	found      bool
+}
+
+// dbCopyWriter writes the groups into the target database on
+// behalf of the session, the validate_doc_update functions of
+// the target are run if validate is set
+type dbCopyWriter struct {
+	target   port.Database
+	session  *model.Session
+	validate func(ctx context.Context, db port.Database, newDoc, oldDoc *model.Document, session *model.Session) error
+}
+
+// dbCopyDocID returns the ID of the document of the group
+// with the JSON encoded key
+func dbCopyDocID(keyData []byte) string {
+	hash := sha1.Sum(keyData)
+	return hex.EncodeToString(hash[:])
+}
+
+// put writes the group into the target database, if the
+// key or value changed
+func (w dbCopyWriter) put(ctx context.Context, key, value interface{}) error {
+	keyData, err := json.Marshal(key)
+	if err != nil {
+		return err
+	}
+	valueData, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	id := dbCopyDocID(keyData)
+
+	doc, err := w.target.GetDocument(ctx, id)
+	if err != nil {
+		return err
+	}
+	if doc != nil && !doc.Deleted &&
+		jsonEqual(doc.Data["key"], keyData) && jsonEqual(doc.Data["value"], valueData) {
+		return nil
+	}
+
+	newDoc := &model.Document{ID: id, Data: map[string]interface{}{"_id": id}}
+	if doc != nil {
+		newDoc.Rev = doc.Rev
+	}
+	err = json.Unmarshal(keyData, &key)
+	if err != nil {
+		return err
+	}
+	err = json.Unmarshal(valueData, &value)
+	if err != nil {
+		return err
+	}
+	newDoc.Data["key"] = key
+	newDoc.Data["value"] = value
+	err = w.validateDocUpdate(ctx, newDoc, doc)
+	if err != nil {
+		return err
+	}
+	_, err = w.target.PutDocument(ctx, newDoc)
+	return err
+}
+
+// delete removes the document of the group from the target database
+func (w dbCopyWriter) delete(ctx context.Context, key interface{}) error {
+	keyData, err := json.Marshal(key)
+	if err != nil {
+		return err
+	}
+	id := dbCopyDocID(keyData)
+
+	doc, err := w.target.GetDocument(ctx, id)
+	if err != nil {
+		return err
+	}
+	if doc == nil || doc.Deleted {
+		return nil
+	}
+	newDoc := &model.Document{
+		ID:      id,
+		Deleted: true,
+		Data:    map[string]interface{}{"_id": id, "_deleted": true},
+	}
+	err = w.validateDocUpdate(ctx, newDoc, doc)
+	if err != nil {
+		return err
+	}
+	_, err = w.target.DeleteDocument(ctx, id, doc.Rev)
+	return err
+}
+
+func (w dbCopyWriter) validateDocUpdate(ctx context.Context, newDoc, oldDoc *model.Document) error {
+	if w.validate == nil {
+		return nil
+	}
+	if oldDoc != nil && oldDoc.Deleted {
+		oldDoc = nil
+	}
+	return w.validate(ctx, w.target, newDoc, oldDoc, w.session)
+}
+
+// jsonEqual returns true if the JSON encoding of v is data
+func jsonEqual(v interface{}, data []byte) bool {
+	vData, err := json.Marshal(v)
+	return err == nil && bytes.Equal(vData, data)
+}
+
+// decodeJSON converts v into out using its JSON encoding
+func decodeJSON(v, out interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
@@ -0,0 +1,271 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/goydb/goydb/internal/adapter/logger"
+	"github.com/goydb/goydb/internal/adapter/storage"
+	"github.com/goydb/goydb/internal/adapter/view/queryview"
+	"github.com/goydb/goydb/pkg/model"
+	"github.com/goydb/goydb/pkg/port"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTask_UpdateDBCopies(t *testing.T) {
+	ctx := context.Background()
+	s, err := storage.Open(t.TempDir(),
+		storage.WithLogger(logger.NewNoLog()),
+		storage.WithViewEngine(queryview.Language, queryview.NewViewServer),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.Close() })
+	db, err := s.CreateDatabase(ctx, "sales")
+	require.NoError(t, err)
+
+	put := func(id, day string, total int) {
+		doc, err := db.GetDocument(ctx, id)
+		require.NoError(t, err)
+		newDoc := &model.Document{ID: id, Data: map[string]interface{}{"day": day, "total": float64(total)}}
+		if doc != nil {
+			newDoc.Rev = doc.Rev
+		}
+		_, err = db.PutDocument(ctx, newDoc)
+		require.NoError(t, err)
+	}
+	put("a", "2024-01-01", 10)
+	put("b", "2024-01-01", 5)
+	put("c", "2024-01-02", 7)
+	rev, err := db.PutDocument(ctx, &model.Document{
+		ID: "_design/app",
+		Data: map[string]interface{}{
+			"language": queryview.Language,
+			"views": map[string]interface{}{
+				"by_day": map[string]interface{}{
+					"map":    map[string]interface{}{"fields": []string{"day"}, "value": "$doc.total"},
+					"reduce": "_sum",
+					"dbcopy": "sales_by_day",
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+	admin := &model.Session{Name: "admin", Roles: []string{model.RoleServerAdmin}}
+	require.NoError(t, SetDBCopyAuthor(ctx, db, "_design/app", rev, admin))
+
+	tc := Task{Storage: s, Logger: logger.NewNoLog()}
+	copies := func() map[string]interface{} {
+		t.Helper()
+		require.NoError(t, tc.ProcessTasksForDatabase(ctx, db))
+		target, err := s.Database(ctx, "sales_by_day")
+		require.NoError(t, err)
+		docs, _, err := target.AllDocs(ctx, port.AllDocsQuery{SkipLocal: true, IncludeDocs: true})
+		require.NoError(t, err)
+		values := make(map[string]interface{})
+		for _, doc := range docs {
+			if doc.Deleted {
+				continue
+			}
+			assert.Equal(t, doc.ID, dbCopyID(t, doc.Data["key"]))
+			values[doc.Data["key"].(string)] = doc.Data["value"]
+		}
+
+		// the copied changes are cleared
+		tracker := db.Indices()[model.DesignDocFn{Type: model.ViewFn, DesignDocID: "_design/app", FnName: "by_day"}.String()].(port.KeyChangeTracker)
+		require.NoError(t, db.Transaction(ctx, func(tx port.DatabaseTx) error {
+			keys, _ := tracker.ChangedKeys(tx)
+			assert.Empty(t, keys)
+			return nil
+		}))
+		return values
+	}
+
+	values := copies()
+	require.Len(t, values, 2)
+	assert.EqualValues(t, 15, values["2024-01-01"])
+	assert.EqualValues(t, 7, values["2024-01-02"])
+
+	// only changed groups are updated, empty groups are deleted
+	target, err := s.Database(ctx, "sales_by_day")
+	require.NoError(t, err)
+	unchanged, err := target.GetDocument(ctx, dbCopyID(t, "2024-01-01"))
+	require.NoError(t, err)
+	put("c", "2024-01-03", 8)
+	values = copies()
+	require.Len(t, values, 2)
+	assert.EqualValues(t, 15, values["2024-01-01"])
+	assert.EqualValues(t, 8, values["2024-01-03"])
+	doc, err := target.GetDocument(ctx, dbCopyID(t, "2024-01-01"))
+	require.NoError(t, err)
+	assert.Equal(t, unchanged.Rev, doc.Rev)
+	doc, err = target.GetDocument(ctx, dbCopyID(t, "2024-01-02"))
+	require.NoError(t, err)
+	assert.True(t, doc.Deleted)
+
+	put("a", "2024-01-01", 20)
+	values = copies()
+	assert.EqualValues(t, 25, values["2024-01-01"])
+}
+
+func TestTask_UpdateDBCopies_Authorization(t *testing.T) {
+	ctx := context.Background()
+	setup := func(t *testing.T, target string) (port.Storage, port.Database, string) {
+		s, err := storage.Open(t.TempDir(),
+			storage.WithLogger(logger.NewNoLog()),
+			storage.WithViewEngine(queryview.Language, queryview.NewViewServer),
+		)
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = s.Close() })
+		db, err := s.CreateDatabase(ctx, "sales")
+		require.NoError(t, err)
+		_, err = db.PutDocument(ctx, &model.Document{ID: "a", Data: map[string]interface{}{"day": "2024-01-01", "total": float64(10)}})
+		require.NoError(t, err)
+		rev, err := db.PutDocument(ctx, &model.Document{
+			ID: "_design/app",
+			Data: map[string]interface{}{
+				"language": queryview.Language,
+				"views": map[string]interface{}{
+					"by_day": map[string]interface{}{
+						"map":    map[string]interface{}{"fields": []string{"day"}, "value": "$doc.total"},
+						"reduce": "_sum",
+						"dbcopy": target,
+					},
+				},
+			},
+		})
+		require.NoError(t, err)
+		return s, db, rev
+	}
+	copied := func(t *testing.T, s port.Storage, target string) bool {
+		db, err := s.Database(ctx, target)
+		if err != nil {
+			return false
+		}
+		doc, err := db.GetDocument(ctx, dbCopyID(t, "2024-01-01"))
+		require.NoError(t, err)
+		return doc != nil && !doc.Deleted
+	}
+	alice := &model.Session{Name: "alice", Roles: []string{"sales"}}
+
+	t.Run("no author", func(t *testing.T) {
+		s, db, _ := setup(t, "sales_by_day")
+		tc := Task{Storage: s, Logger: logger.NewNoLog()}
+		require.NoError(t, tc.ProcessTasksForDatabase(ctx, db))
+		assert.False(t, copied(t, s, "sales_by_day"))
+	})
+
+	t.Run("author of an older revision", func(t *testing.T) {
+		s, db, _ := setup(t, "sales_by_day")
+		require.NoError(t, SetDBCopyAuthor(ctx, db, "_design/app", "1-old", &model.Session{Name: "admin", Roles: []string{model.RoleServerAdmin}}))
+		tc := Task{Storage: s, Logger: logger.NewNoLog()}
+		require.NoError(t, tc.ProcessTasksForDatabase(ctx, db))
+		assert.False(t, copied(t, s, "sales_by_day"))
+	})
+
+	t.Run("system database", func(t *testing.T) {
+		s, db, rev := setup(t, "_users")
+		require.NoError(t, SetDBCopyAuthor(ctx, db, "_design/app", rev, &model.Session{Name: "admin", Roles: []string{model.RoleServerAdmin}}))
+		tc := Task{Storage: s, Logger: logger.NewNoLog()}
+		require.NoError(t, tc.ProcessTasksForDatabase(ctx, db))
+		assert.False(t, copied(t, s, "_users"))
+	})
+
+	t.Run("missing target of a non admin", func(t *testing.T) {
+		s, db, rev := setup(t, "sales_by_day")
+		require.NoError(t, SetDBCopyAuthor(ctx, db, "_design/app", rev, alice))
+		tc := Task{Storage: s, Logger: logger.NewNoLog()}
+		require.NoError(t, tc.ProcessTasksForDatabase(ctx, db))
+		_, err := s.Database(ctx, "sales_by_day")
+		assert.ErrorIs(t, err, storage.ErrUnknownDatabase)
+	})
+
+	t.Run("member of the target", func(t *testing.T) {
+		s, db, rev := setup(t, "sales_by_day")
+		target, err := s.CreateDatabase(ctx, "sales_by_day")
+		require.NoError(t, err)
+		require.NoError(t, target.PutSecurity(ctx, &model.Security{Members: model.Members{Roles: []string{"sales"}}}))
+		require.NoError(t, SetDBCopyAuthor(ctx, db, "_design/app", rev, alice))
+		tc := Task{Storage: s, Logger: logger.NewNoLog()}
+		require.NoError(t, tc.ProcessTasksForDatabase(ctx, db))
+		assert.True(t, copied(t, s, "sales_by_day"))
+	})
+
+	t.Run("no member of the target", func(t *testing.T) {
+		s, db, rev := setup(t, "sales_by_day")
+		target, err := s.CreateDatabase(ctx, "sales_by_day")
+		require.NoError(t, err)
+		require.NoError(t, target.PutSecurity(ctx, &model.Security{Members: model.Members{Roles: []string{"finance"}}}))
+		require.NoError(t, SetDBCopyAuthor(ctx, db, "_design/app", rev, alice))
+		tc := Task{Storage: s, Logger: logger.NewNoLog()}
+		require.NoError(t, tc.ProcessTasksForDatabase(ctx, db))
+		assert.False(t, copied(t, s, "sales_by_day"))
+	})
+
+	t.Run("validate_doc_update of the target", func(t *testing.T) {
+		s, db, rev := setup(t, "sales_by_day")
+		require.NoError(t, SetDBCopyAuthor(ctx, db, "_design/app", rev, &model.Session{Name: "admin", Roles: []string{model.RoleServerAdmin}}))
+		var sessions []*model.Session
+		tc := Task{Storage: s, Logger: logger.NewNoLog(),
+			ValidateDocUpdate: func(ctx context.Context, db port.Database, newDoc, oldDoc *model.Document, session *model.Session) error {
+				sessions = append(sessions, session)
+				return &model.ErrForbidden{Msg: "read only"}
+			},
+		}
+		require.NoError(t, tc.ProcessTasksForDatabase(ctx, db))
+		assert.False(t, copied(t, s, "sales_by_day"))
+		require.NotEmpty(t, sessions)
+		assert.Equal(t, "admin", sessions[0].Name)
+	})
+}
+
+func TestAuthorizeDBCopy(t *testing.T) {
+	ctx := context.Background()
+	s, err := storage.Open(t.TempDir(), storage.WithLogger(logger.NewNoLog()))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.Close() })
+	open, err := s.CreateDatabase(ctx, "open")
+	require.NoError(t, err)
+	require.NoError(t, open.PutSecurity(ctx, &model.Security{}))
+	closed, err := s.CreateDatabase(ctx, "closed")
+	require.NoError(t, err)
+	require.NoError(t, closed.PutSecurity(ctx, &model.Security{
+		Admins:  model.Admins{Names: []string{"bob"}},
+		Members: model.Members{Roles: []string{"sales"}},
+	}))
+
+	admin := &model.Session{Name: "admin", Roles: []string{model.RoleServerAdmin}}
+	tests := []struct {
+		target  string
+		session *model.Session
+		allowed bool
+	}{
+		{"_users", admin, false},
+		{"_replicator", admin, false},
+		{"missing", admin, true},
+		{"missing", &model.Session{Name: "alice"}, false},
+		{"open", &model.Session{Name: "alice"}, true},
+		{"open", nil, false},
+		{"closed", &model.Session{Name: "alice"}, false},
+		{"closed", &model.Session{Name: "alice", Roles: []string{"sales"}}, true},
+		{"closed", &model.Session{Name: "bob"}, true},
+		{"closed", admin, true},
+	}
+	for _, tt := range tests {
+		err := AuthorizeDBCopy(ctx, s, tt.target, tt.session)
+		if tt.allowed {
+			assert.NoError(t, err, "%s %v", tt.target, tt.session)
+		} else {
+			var forbidden *model.ErrForbidden
+			assert.ErrorAs(t, err, &forbidden, "%s %v", tt.target, tt.session)
+		}
+	}
+}
+
+func dbCopyID(t *testing.T, key interface{}) string {
+	t.Helper()
+	data, err := json.Marshal(key)
+	require.NoError(t, err)
+	return dbCopyDocID(data)
+}
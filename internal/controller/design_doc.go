@@ -1,7 +1,11 @@
 package controller
 
 import (
+	"bytes"
 	"context"
+	"fmt"
+
+	"github.com/fxamacker/cbor/v2"
 
 	"github.com/goydb/goydb/internal/adapter/reducer"
 	"github.com/goydb/goydb/internal/adapter/storage"
@@ -13,82 +17,164 @@ type DesignDoc struct {
 	DB port.Database
 }
 
-func (v DesignDoc) Rebuild(ctx context.Context, task *model.Task, idx port.DocumentIndex) error {
-	j := 0
-	batchSize := 1000
+// rebuildBatchSize is the number of changes processed per transaction
+const rebuildBatchSize = 1000
+
+// Rebuild walks the changes of the database starting at the checkpoint
+// of the index. The checkpoint is advanced with every batch, an
+// interrupted build resumes at the last processed change. The progress
+// is reported to the task, if any.
+func (v DesignDoc) Rebuild(ctx context.Context, name string, idx port.DocumentIndex, task *model.Task) error {
+	counted := task == nil
 	for {
-		var docs []*model.Document
-		err := v.DB.Iterator(ctx, nil, func(i port.Iterator) error {
-			total := i.Total()
-			if total == 0 {
+		// check if context should be canceled
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		var n int
+		done := false
+		err := v.DB.Transaction(ctx, func(tx port.DatabaseTx) error {
+			since, building := tx.IndexSeq(name)
+			if !building {
+				done = true
 				return nil
 			}
-			if task != nil {
-				task.ProcessingTotal = total
+			if !counted {
+				task.ProcessingTotal = task.Processed + tx.CountChangesSince(since)
+				counted = true
 			}
 
-			i.SetSkip(j * batchSize)
-			i.SetLimit(batchSize)
-			i.SetSkipDesignDoc(true)
-			i.SetSkipLocalDoc(true)
-
-			for doc := i.First(); i.Continue(); doc = i.Next() {
-				docs = append(docs, doc)
+			docs, last, err := tx.ChangesSince(ctx, since, rebuildBatchSize)
+			if err != nil {
+				return err
+			}
+			if last == since {
+				// all changes processed, writes keep the index current
+				tx.IndexBuilt(name)
+				done = true
+				return nil
 			}
 
+			err = idx.UpdateStored(ctx, tx, docs)
+			if err != nil {
+				return err
+			}
+			tx.SetIndexSeq(name, last)
+			n = len(docs)
 			return nil
-
 		})
 		if err != nil {
 			return err
 		}
-		if len(docs) == 0 {
+		if done {
 			break
 		}
-
-		err = v.DB.Transaction(ctx, func(tx port.DatabaseTx) error {
-			return idx.UpdateStored(ctx, tx, docs)
-		})
-		if err != nil {
-			return err
+		if task == nil {
+			continue
 		}
 
-		if task != nil {
-			task.Processed += len(docs)
-			err := v.DB.UpdateTask(ctx, task)
-			if err != nil {
-				return err
-			}
+		task.Processed += n
+		if task.Processed > task.ProcessingTotal {
+			task.ProcessingTotal = task.Processed
 		}
-
-		j++
-		if len(docs) < batchSize {
-			break
+		err = v.DB.UpdateTask(ctx, task)
+		if err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
 
-func (v DesignDoc) ReduceDocs(ctx context.Context, tx port.EngineReadTransaction, idx port.DocumentIndex, opts port.AllDocsQuery, view *model.View) (map[interface{}]interface{}, int, error) {
-	var r port.Reducer
+// newReducer returns the reducer of the view, originalKeys is true if
+// the reducer needs the original keys and document ids of the rows
+func (v DesignDoc) newReducer(ddoc *model.Document, view *model.View) (r port.Reducer, originalKeys bool, err error) {
 	switch view.ReduceFn {
 	case "_sum":
-		r = reducer.NewSum()
+		return reducer.NewSum(), false, nil
 	case "_count":
-		r = reducer.NewCount()
+		return reducer.NewCount(), false, nil
 	case "_stats":
-		r = reducer.NewStats()
+		return reducer.NewStats(), false, nil
 	case "_approx_count_distinct":
-		r = reducer.NewApproxCountDistinct()
+		return reducer.NewApproxCountDistinct(), false, nil
 	case "": // NONE
-		r = reducer.NewNone()
-	default: // CUSTOM
-		var err error
-		r, err = v.DB.ReducerEngine(view.Language)(view.ReduceFn)
-		if err != nil {
-			return nil, 0, err
+		return reducer.NewNone(), false, nil
+	}
+	native, ok, err := reducer.NewNative(view.ReduceFn)
+	if err != nil {
+		return nil, false, err
+	}
+	if ok { // goydb extension
+		return native, native.OriginalKeys(), nil
+	}
+	// CUSTOM
+	r, err = v.DB.ReducerDesignDocEngine(view.Language)(view.ReduceFn, ddoc)
+	return r, false, err
+}
+
+// reduceValue sets the value of the row passed to the reducer
+func reduceValue(doc *model.Document, originalKey interface{}, view *model.View, originalKeys bool) {
+	if view.ReduceFn == "_approx_count_distinct" {
+		doc.Value = originalKey
+	} else if originalKeys {
+		doc.Value = reducer.KeyValue{Key: originalKey, ID: doc.ID, Value: doc.Value}
+	}
+}
+
+// ReduceKey reduces the rows of the view with the CBOR encoded key,
+// found is false if the view has no rows with the key
+func (v DesignDoc) ReduceKey(ctx context.Context, tx port.EngineReadTransaction, idx port.DocumentIndex, ddoc *model.Document, view *model.View, key []byte) (value interface{}, found bool, err error) {
+	r, originalKeys, err := v.newReducer(ddoc, view)
+	if err != nil {
+		return nil, false, err
+	}
+	var decoded interface{}
+	err = cbor.Unmarshal(key, &decoded)
+	if err != nil {
+		return nil, false, err
+	}
+
+	io, err := idx.IteratorOptions(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	// the rows of the key are suffixed with a sequence and the key length,
+	// unlike key ranges the bounds of a single key match the collation
+	io.StartKey = key
+	io.EndKey = append(append([]byte{}, key...), bytes.Repeat([]byte{0xFF}, 10)...)
+	i := storage.NewIterator(tx, storage.WithOptions(io))
+	for doc := i.First(); i.Continue(); doc = i.Next() {
+		if model.ViewKeyCmp(doc.Key, decoded) != 0 {
+			continue
 		}
+		reduceValue(doc, doc.Key, view, originalKeys)
+		r.Reduce(doc)
+		found = true
+	}
+	if !found {
+		return nil, false, nil
+	}
+
+	for _, result := range r.Result() {
+		value = result
+		if doc, ok := result.(*model.Document); ok {
+			value = doc.Value
+		}
+	}
+	if re, ok := r.(port.ReducerError); ok && re.Err() != nil {
+		return nil, false, fmt.Errorf("reduce function failed: %w", re.Err())
+	}
+	return value, true, nil
+}
+
+func (v DesignDoc) ReduceDocs(ctx context.Context, tx port.EngineReadTransaction, idx port.DocumentIndex, opts port.AllDocsQuery, ddoc *model.Document, view *model.View) (map[interface{}]interface{}, int, error) {
+	r, originalKeys, err := v.newReducer(ddoc, view)
+	if err != nil {
+		return nil, 0, err
 	}
 
 	var total int
@@ -145,8 +231,9 @@ func (v DesignDoc) ReduceDocs(ctx context.Context, tx port.EngineReadTransaction
 		// Apply group / group_level key reduction before passing to the reducer.
 		// Map-only views (no ReduceFn) skip this: they preserve keys like reduce=false.
 		if view.ReduceFn != "" {
-			// _approx_count_distinct counts distinct keys, so save the
-			// original key into Value before group-level collapsing.
+			// _approx_count_distinct counts distinct keys and _first/_last
+			// compare them, so save the original key into Value before
+			// group-level collapsing.
 			originalKey := doc.Key
 
 			if opts.ViewGroupLevel > 0 {
@@ -160,14 +247,15 @@ func (v DesignDoc) ReduceDocs(ctx context.Context, tx port.EngineReadTransaction
 			}
 			// group=true: keep full key unchanged
 
-			if view.ReduceFn == "_approx_count_distinct" {
-				doc.Value = originalKey
-			}
+			reduceValue(doc, originalKey, view, originalKeys)
 		}
 
 		r.Reduce(doc)
 	}
 
 	docs := r.Result()
+	if re, ok := r.(port.ReducerError); ok && re.Err() != nil {
+		return nil, 0, fmt.Errorf("reduce function failed: %w", re.Err())
+	}
 	return docs, total, nil
 }
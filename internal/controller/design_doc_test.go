@@ -0,0 +1,303 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/goydb/goydb/internal/adapter/logger"
+	"github.com/goydb/goydb/internal/adapter/storage"
+	"github.com/goydb/goydb/internal/adapter/view/queryview"
+	"github.com/goydb/goydb/pkg/model"
+	"github.com/goydb/goydb/pkg/port"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// interruptDB cancels the build after the first batch
+type interruptDB struct {
+	port.Database
+	cancel context.CancelFunc
+}
+
+func (db interruptDB) UpdateTask(ctx context.Context, task *model.Task) error {
+	db.cancel()
+	return db.Database.UpdateTask(ctx, task)
+}
+
+func viewRows(t *testing.T, db port.Database, name string) map[string]interface{} {
+	t.Helper()
+	rows := make(map[string]interface{})
+	err := db.Transaction(context.Background(), func(tx port.DatabaseTx) error {
+		i, err := db.IndexIterator(context.Background(), tx, db.Indices()[name])
+		if err != nil {
+			return err
+		}
+		for doc := i.First(); i.Continue(); doc = i.Next() {
+			rows[doc.ID] = doc.Key
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	return rows
+}
+
+func TestDesignDoc_Rebuild(t *testing.T) {
+	ctx := context.Background()
+	s, err := storage.Open(t.TempDir(),
+		storage.WithLogger(logger.NewNoLog()),
+		storage.WithViewEngine(queryview.Language, queryview.NewViewServer),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.Close() })
+	db, err := s.CreateDatabase(ctx, "test")
+	require.NoError(t, err)
+
+	docs := rebuildBatchSize + 500
+	for i := 0; i < docs; i++ {
+		_, err := db.PutDocument(ctx, &model.Document{
+			ID:   fmt.Sprintf("doc%04d", i),
+			Data: map[string]interface{}{"n": i},
+		})
+		require.NoError(t, err)
+	}
+	_, err = db.PutDocument(ctx, &model.Document{
+		ID: "_design/app",
+		Data: map[string]interface{}{
+			"language": queryview.Language,
+			"views": map[string]interface{}{
+				"by_n": map[string]interface{}{"map": map[string]interface{}{"fields": []string{"n"}}},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	name := model.DesignDocFn{Type: model.ViewFn, DesignDocID: "_design/app", FnName: "by_n"}.String()
+	seq, building, err := db.IndexSeq(ctx, name)
+	require.NoError(t, err)
+	assert.True(t, building)
+	assert.EqualValues(t, 0, seq)
+
+	tasks, err := db.PeekTasks(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+	task := tasks[0]
+
+	// the build is interrupted after the first batch
+	buildCtx, cancel := context.WithCancel(ctx)
+	dd := DesignDoc{DB: interruptDB{Database: db, cancel: cancel}}
+	err = dd.Rebuild(buildCtx, name, db.Indices()[name], task)
+	assert.ErrorIs(t, err, context.Canceled)
+	seq, building, err = db.IndexSeq(ctx, name)
+	require.NoError(t, err)
+	assert.True(t, building)
+	assert.EqualValues(t, rebuildBatchSize, seq)
+	assert.Equal(t, rebuildBatchSize, task.Processed)
+
+	// documents written during the build are not missed
+	doc, err := db.GetDocument(ctx, "doc0000")
+	require.NoError(t, err)
+	doc.Data["n"] = -1
+	_, err = db.PutDocument(ctx, doc)
+	require.NoError(t, err)
+	_, err = db.PutDocument(ctx, &model.Document{ID: "new", Data: map[string]interface{}{"n": docs}})
+	require.NoError(t, err)
+
+	// the build resumes at the checkpoint
+	err = DesignDoc{DB: db}.Rebuild(ctx, name, db.Indices()[name], task)
+	require.NoError(t, err)
+	_, building, err = db.IndexSeq(ctx, name)
+	require.NoError(t, err)
+	assert.False(t, building)
+	assert.Equal(t, docs+3, task.ProcessingTotal) // including the design document
+	require.NoError(t, db.WaitIndex(ctx, name))
+
+	rows := viewRows(t, db, name)
+	assert.Len(t, rows, docs+1)
+	assert.EqualValues(t, -1, rows["doc0000"])
+	assert.EqualValues(t, docs, rows["new"])
+	assert.EqualValues(t, docs-1, rows[fmt.Sprintf("doc%04d", docs-1)])
+}
+
+func TestTask_UpdateAsyncIndices(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	open := func() (*storage.Storage, port.Database) {
+		s, err := storage.Open(dir,
+			storage.WithLogger(logger.NewNoLog()),
+			storage.WithViewEngine(queryview.Language, queryview.NewViewServer),
+		)
+		require.NoError(t, err)
+		db, err := s.Database(ctx, "test")
+		if err != nil {
+			db, err = s.CreateDatabase(ctx, "test")
+		}
+		require.NoError(t, err)
+		return s, db
+	}
+	s, db := open()
+
+	_, err := db.PutDocument(ctx, &model.Document{
+		ID: "_design/app",
+		Data: map[string]interface{}{
+			"language": queryview.Language,
+			"options":  map[string]interface{}{"index_mode": model.IndexModeAsync},
+			"views": map[string]interface{}{
+				"by_n": map[string]interface{}{"map": map[string]interface{}{"fields": []string{"n"}}},
+			},
+		},
+	})
+	require.NoError(t, err)
+	name := model.DesignDocFn{Type: model.ViewFn, DesignDocID: "_design/app", FnName: "by_n"}.String()
+	assert.Equal(t, []string{name}, db.AsyncIndices())
+
+	_, err = db.PutDocument(ctx, &model.Document{ID: "a", Data: map[string]interface{}{"n": 1}})
+	require.NoError(t, err)
+	assert.Empty(t, viewRows(t, db, name))
+
+	// the recorded changes are applied after a restart
+	require.NoError(t, s.Close())
+	s, db = open()
+	t.Cleanup(func() { _ = s.Close() })
+	_, behind, err := db.IndexSeq(ctx, name)
+	require.NoError(t, err)
+	assert.True(t, behind)
+
+	tc := Task{Storage: s, Logger: logger.NewNoLog()}
+	require.NoError(t, tc.UpdateAsyncIndices(ctx, db))
+	seq, behind, err := db.IndexSeq(ctx, name)
+	require.NoError(t, err)
+	assert.False(t, behind)
+	assert.EqualValues(t, 2, seq)
+	rows := viewRows(t, db, name)
+	require.Len(t, rows, 1)
+	assert.EqualValues(t, 1, rows["a"])
+}
+
+func TestDesignDoc_PromoteRestart(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	open := func() (*storage.Storage, port.Database) {
+		s, err := storage.Open(dir,
+			storage.WithLogger(logger.NewNoLog()),
+			storage.WithViewEngine(queryview.Language, queryview.NewViewServer),
+		)
+		require.NoError(t, err)
+		db, err := s.Database(ctx, "test")
+		if err != nil {
+			db, err = s.CreateDatabase(ctx, "test")
+		}
+		require.NoError(t, err)
+		return s, db
+	}
+	s, db := open()
+
+	_, err := db.PutDocument(ctx, &model.Document{ID: "a", Data: map[string]interface{}{"n": 1}})
+	require.NoError(t, err)
+	_, err = db.PutDocument(ctx, &model.Document{
+		ID: "_design/app.staged",
+		Data: map[string]interface{}{
+			"language": queryview.Language,
+			"views": map[string]interface{}{
+				"by_n": map[string]interface{}{"map": map[string]interface{}{"fields": []string{"n"}}},
+			},
+		},
+	})
+	require.NoError(t, err)
+	tc := Task{Storage: s, Logger: logger.NewNoLog()}
+	require.NoError(t, tc.ProcessTasksForDatabase(ctx, db))
+	_, err = db.PromoteDesignDoc(ctx, "_design/app")
+	require.NoError(t, err)
+	// the deleted staged design document is cleaned up
+	tasks, err := db.PeekTasks(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+	assert.Equal(t, model.ActionViewCleanup, tasks[0].Action)
+	require.NoError(t, tc.ProcessTasksForDatabase(ctx, db))
+
+	// the promoted index keeps its buckets after a restart
+	require.NoError(t, s.Close())
+	s, db = open()
+	t.Cleanup(func() { _ = s.Close() })
+	name := model.DesignDocFn{Type: model.ViewFn, DesignDocID: "_design/app", FnName: "by_n"}.String()
+	_, building, err := db.IndexSeq(ctx, name)
+	require.NoError(t, err)
+	assert.False(t, building)
+	tasks, err = db.PeekTasks(ctx, 10)
+	require.NoError(t, err)
+	assert.Empty(t, tasks)
+	rows := viewRows(t, db, name)
+	require.Len(t, rows, 1)
+	assert.EqualValues(t, 1, rows["a"])
+}
+
+func TestDesignDoc_ReduceDocsNative(t *testing.T) {
+	ctx := context.Background()
+	s, err := storage.Open(t.TempDir(),
+		storage.WithLogger(logger.NewNoLog()),
+		storage.WithViewEngine(queryview.Language, queryview.NewViewServer),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.Close() })
+	db, err := s.CreateDatabase(ctx, "test")
+	require.NoError(t, err)
+
+	for i, day := range []string{"2024-01-02", "2024-01-01", "2024-01-03", "2024-01-01"} {
+		_, err := db.PutDocument(ctx, &model.Document{
+			ID:   fmt.Sprintf("doc%d", i),
+			Data: map[string]interface{}{"user": fmt.Sprintf("u%d", i%2), "day": day, "total": float64(i + 1)},
+		})
+		require.NoError(t, err)
+	}
+	view := func(reduce string) map[string]interface{} {
+		return map[string]interface{}{
+			"map":    map[string]interface{}{"fields": []string{"user", "day"}, "value": "$doc.total"},
+			"reduce": reduce,
+		}
+	}
+	ddoc := &model.Document{
+		ID: "_design/app",
+		Data: map[string]interface{}{
+			"language": queryview.Language,
+			"views": map[string]interface{}{
+				"last":    view("_last"),
+				"top":     view("_top_n:1"),
+				"invalid": view("_top_n:x"),
+			},
+		},
+	}
+	_, err = db.PutDocument(ctx, ddoc)
+	require.NoError(t, err)
+	require.NoError(t, Task{Storage: s, Logger: logger.NewNoLog()}.ProcessTasksForDatabase(ctx, db))
+
+	reduce := func(name string) (map[interface{}]interface{}, error) {
+		var values map[interface{}]interface{}
+		err := db.Transaction(ctx, func(tx port.DatabaseTx) error {
+			ddfn := model.DesignDocFn{Type: model.ViewFn, DesignDocID: ddoc.ID, FnName: name}
+			v, _ := ddoc.View(name)
+			docs, _, err := DesignDoc{DB: db}.ReduceDocs(ctx, tx, db.Indices()[ddfn.String()], port.AllDocsQuery{ViewGroupLevel: 1}, ddoc, v)
+			if err != nil {
+				return err
+			}
+			values = make(map[interface{}]interface{})
+			for _, v := range docs {
+				doc := v.(*model.Document)
+				values[doc.Key.([]interface{})[0]] = doc.Value
+			}
+			return nil
+		})
+		return values, err
+	}
+
+	// the value of the latest day per user, equal days are ordered by ID
+	values, err := reduce("last")
+	require.NoError(t, err)
+	assert.EqualValues(t, map[interface{}]interface{}{"u0": 3.0, "u1": 4.0}, values)
+
+	values, err = reduce("top")
+	require.NoError(t, err)
+	assert.EqualValues(t, map[interface{}]interface{}{"u0": []interface{}{3.0}, "u1": []interface{}{4.0}}, values)
+
+	_, err = reduce("invalid")
+	assert.Error(t, err)
+}
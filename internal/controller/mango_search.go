@@ -0,0 +1,155 @@
+package controller
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/goydb/goydb/pkg/model"
+	"github.com/goydb/goydb/pkg/port"
+)
+
+// RowFunc converts an index record into the document
+// the selector of a MangoSearch is matched against.
+type RowFunc func(rec *model.Document) (*model.Document, error)
+
+// MangoSearch executes a FindQuery on the records of an arbitrary
+// index, e.g. the rows of a view or the hits of a search index.
+//
+// Records are passed in index order using Add (or Iterate). Without
+// a sort, skip and limit are applied while adding so that the caller
+// can stop early, otherwise all matches are collected and sorted
+// when calling Result.
+type MangoSearch struct {
+	query   model.FindQuery
+	row     RowFunc
+	docs    []*model.Document
+	skip    int
+	stats   model.ExecutionStats
+	started time.Time
+}
+
+// NewMangoSearch creates a new search for the query. If row is nil
+// the selector is matched against the records as they are.
+func NewMangoSearch(query model.FindQuery, row RowFunc) *MangoSearch {
+	if row == nil {
+		row = func(rec *model.Document) (*model.Document, error) { return rec, nil }
+	}
+	return &MangoSearch{
+		query:   query,
+		row:     row,
+		skip:    query.Skip,
+		started: time.Now(),
+	}
+}
+
+// Add matches the record against the selector. done is true if the
+// limit is reached and no further records need to be added.
+func (m *MangoSearch) Add(rec *model.Document) (done bool, err error) {
+	m.stats.TotalDocsExamined++
+
+	doc, err := m.row(rec)
+	if err != nil {
+		return false, err
+	}
+	if doc == nil {
+		return false, nil
+	}
+
+	ok, err := m.query.Match(doc)
+	if err != nil {
+		return false, fmt.Errorf("find failed: %w", err)
+	}
+	if !ok {
+		return false, nil
+	}
+
+	if len(m.query.Sort) > 0 {
+		m.docs = append(m.docs, doc)
+		return false, nil
+	}
+
+	if m.skip > 0 {
+		m.skip--
+		return false, nil
+	}
+	m.docs = append(m.docs, doc)
+	return m.query.Limit > 0 && len(m.docs) >= m.query.Limit, nil
+}
+
+// Iterate adds all records of the iterator until the limit is reached.
+func (m *MangoSearch) Iterate(i port.Iterator) error {
+	if i.Total() == 0 {
+		return nil
+	}
+	for rec := i.First(); i.Continue(); rec = i.Next() {
+		done, err := m.Add(rec)
+		if err != nil {
+			return err
+		}
+		if done {
+			break
+		}
+	}
+	return nil
+}
+
+// Result returns the matched documents in the requested order.
+func (m *MangoSearch) Result() ([]*model.Document, *model.ExecutionStats) {
+	docs := m.docs
+	if len(m.query.Sort) > 0 {
+		m.query.SortDocuments(docs)
+		skip := m.query.Skip
+		if skip > len(docs) {
+			skip = len(docs)
+		}
+		docs = docs[skip:]
+		if limit := m.query.Limit; limit > 0 && limit < len(docs) {
+			docs = docs[:limit]
+		}
+	}
+
+	stats := m.stats
+	stats.ResultsReturned = len(docs)
+	stats.ExecutionTime = float64(time.Since(m.started)) / float64(time.Millisecond)
+	return docs, &stats
+}
+
+// NeedsDocs returns true if the query references the doc field
+// of a row, meaning that the documents need to be loaded.
+func (m *MangoSearch) NeedsDocs() bool {
+	for _, f := range m.query.ReferencedFields() {
+		if f == "doc" || strings.HasPrefix(f, "doc.") {
+			return true
+		}
+	}
+	return false
+}
+
+// ViewRow returns the document representing a view row with the
+// fields id, key, value and, if doc is not nil, doc.
+func ViewRow(rec, doc *model.Document) *model.Document {
+	data := map[string]interface{}{
+		"id":    rec.ID,
+		"key":   rec.Key,
+		"value": rec.Value,
+	}
+	if doc != nil {
+		data["doc"] = doc.Data
+	}
+	return &model.Document{ID: rec.ID, Key: rec.Key, Value: rec.Value, Data: data}
+}
+
+// SearchRow returns the document representing a search hit with the
+// fields id, order, fields and, if doc is not nil, doc.
+func SearchRow(rec *port.SearchRecord, doc *model.Document) *model.Document {
+	data := map[string]interface{}{
+		"id":     rec.ID,
+		"order":  rec.Order,
+		"fields": rec.Fields,
+	}
+	if doc != nil {
+		data["doc"] = doc.Data
+	}
+	return &model.Document{ID: rec.ID, Data: data}
+}
@@ -2,7 +2,9 @@ package controller
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/goydb/goydb/pkg/model"
@@ -11,29 +13,93 @@ import (
 
 const taskProcessCount = 10
 
+const (
+	// taskMaxAttempts is the number of attempts after
+	// which a failing task is dropped
+	taskMaxAttempts = 10
+	// the delay before the retry of a failed task doubles with
+	// every attempt, starting at taskRetryDelay
+	taskRetryDelay    = time.Second
+	taskMaxRetryDelay = time.Minute * 5
+)
+
 type Task struct {
 	Storage port.Storage
 	Logger  port.Logger
+	// Workers is the number of databases whose tasks are
+	// processed in parallel, at least one
+	Workers int
+	// ValidateDocUpdate runs the validate_doc_update functions of the
+	// database for writes of the tasks, e.g. the dbcopy groups. The
+	// functions aren't run if nil.
+	ValidateDocUpdate func(ctx context.Context, db port.Database, newDoc, oldDoc *model.Document, session *model.Session) error
 }
 
+// Run processes the tasks of the databases queued in the task
+// scheduler of the storage, the workers wait for the next
+// database instead of polling all databases
 func (c Task) Run(ctx context.Context) {
-	t := time.NewTicker(time.Millisecond * 500)
-	defer t.Stop()
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-t.C:
-			err := c.ProcessAllTasks(ctx)
-			if err != nil {
-				c.Logger.Warnf(ctx, "failed processing tasks", "error", err)
+	workers := c.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	scheduler := c.Storage.TaskScheduler()
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for {
+				name, changed, err := scheduler.Next(ctx)
+				if err != nil {
+					return
+				}
+				c.processDatabase(ctx, scheduler, name, changed)
 			}
+		}()
+	}
+	wg.Wait()
+}
+
+// processDatabase processes the tasks of the database taken from
+// the scheduler and queues it again if tasks are left, e.g. retries.
+// The async indices and materialized views are only updated if
+// documents changed.
+func (c Task) processDatabase(ctx context.Context, scheduler port.TaskScheduler, name string, changed bool) {
+	defer scheduler.Done(name)
+
+	// the database stays loaded until the pass is done, the
+	// context of the worker would hold it until shutdown
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	db, err := c.Storage.Database(ctx, name)
+	if err != nil {
+		// the database was deleted meanwhile
+		return
+	}
+	err = c.processTasks(ctx, db)
+	if err == nil && changed {
+		err = c.updateChanges(ctx, db)
+	}
+	if err != nil && ctx.Err() == nil {
+		c.Logger.Warnf(ctx, "failed processing tasks", "db", name, "error", err)
+		if changed {
+			scheduler.ScheduleChanges(name, time.Now().Add(taskRetryDelay))
+		} else {
+			scheduler.Schedule(name, time.Now().Add(taskRetryDelay), 0)
 		}
 	}
+	err = db.ScheduleTasks(ctx)
+	if err != nil && ctx.Err() == nil {
+		c.Logger.Warnf(ctx, "failed to schedule tasks", "db", name, "error", err)
+	}
 }
 
+// ProcessAllTasks processes the tasks of all loaded databases one after another
 func (c Task) ProcessAllTasks(ctx context.Context) error {
-	dbs, err := c.Storage.Databases(ctx)
+	dbs, err := c.Storage.LoadedDatabases(ctx)
 	if err != nil {
 		return err
 	}
@@ -53,7 +119,17 @@ func (c Task) ProcessAllTasks(ctx context.Context) error {
 	return nil
 }
 
+// ProcessTasksForDatabase processes the tasks of the database and
+// updates its async indices and materialized views
 func (c Task) ProcessTasksForDatabase(ctx context.Context, db port.Database) error {
+	if err := c.processTasks(ctx, db); err != nil {
+		return err
+	}
+	return c.updateChanges(ctx, db)
+}
+
+// processTasks processes the runnable tasks of the database
+func (c Task) processTasks(ctx context.Context, db port.Database) error {
 	for {
 		// check if context should be canceled
 		select {
@@ -66,18 +142,89 @@ func (c Task) ProcessTasksForDatabase(ctx context.Context, db port.Database) err
 		if err != nil {
 			return err
 		}
+		var completed []*model.Task
 		for _, task := range tasks {
 			err := c.ProcessTask(ctx, task)
-			if err != nil {
-				c.Logger.Warnf(ctx, "failed to process task", "task", task, "error", err)
+			switch {
+			case err == nil:
+				completed = append(completed, task)
+			case errors.Is(err, port.ErrTaskCanceled), errors.Is(err, port.ErrTaskPaused):
+				// removed or kept by the admin
+			case ctx.Err() != nil:
+				return ctx.Err()
+			default:
+				if c.retryTask(ctx, db, task, err) {
+					continue
+				}
+				completed = append(completed, task)
 			}
 		}
-		err = db.CompleteTasks(ctx, tasks)
+		err = db.CompleteTasks(ctx, completed)
 		if err != nil {
 			return err
 		}
 		if len(tasks) < taskProcessCount {
-			break
+			return nil
+		}
+	}
+}
+
+// updateChanges applies the changed documents to the
+// async indices and materialized views of the database
+func (c Task) updateChanges(ctx context.Context, db port.Database) error {
+	err := c.UpdateAsyncIndices(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	return c.UpdateDBCopies(ctx, db)
+}
+
+// retryTask schedules the retry of the failed task with an exponential
+// backoff, it returns false if the task should be dropped
+func (c Task) retryTask(ctx context.Context, db port.Database, task *model.Task, taskErr error) bool {
+	task.Attempts++
+	task.LastError = taskErr.Error()
+	if task.Attempts >= taskMaxAttempts || errors.Is(taskErr, port.ErrNotFound) {
+		c.Logger.Errorf(ctx, "failed to process task, dropped", "task", task, "attempts", task.Attempts, "error", taskErr)
+		return false
+	}
+
+	delay := taskRetryDelay << (task.Attempts - 1)
+	if delay > taskMaxRetryDelay {
+		delay = taskMaxRetryDelay
+	}
+	task.RetryAt = time.Now().Add(delay)
+	// release the lease, the retry is due at RetryAt
+	task.ActiveSince = time.Time{}
+	c.Logger.Warnf(ctx, "failed to process task, retrying", "task", task, "attempts", task.Attempts, "retry_in", delay, "error", taskErr)
+
+	err := db.UpdateTask(ctx, task)
+	if err != nil && !errors.Is(err, port.ErrTaskCanceled) && !errors.Is(err, port.ErrTaskPaused) {
+		c.Logger.Warnf(ctx, "failed to schedule task retry", "task", task, "error", err)
+	}
+	return true
+}
+
+// UpdateAsyncIndices applies the changes that were recorded
+// since the checkpoint of every async index of the database
+func (c Task) UpdateAsyncIndices(ctx context.Context, db port.Database) error {
+	vc := DesignDoc{
+		DB: db,
+	}
+	for _, name := range db.AsyncIndices() {
+		_, behind, err := db.IndexSeq(ctx, name)
+		if err != nil {
+			return err
+		}
+		idx, ok := db.Indices()[name]
+		if !behind || !ok {
+			continue
+		}
+		err = vc.Rebuild(ctx, name, idx, nil)
+		if err != nil {
+			c.Logger.Warnf(ctx, "failed to update async index", "index", name, "error", err)
+			c.Storage.TaskScheduler().ScheduleChanges(db.Name(), time.Now().Add(taskRetryDelay))
 		}
 	}
 
@@ -93,14 +240,24 @@ func (c Task) ProcessTask(ctx context.Context, task *model.Task) error {
 		DB: db,
 	}
 
-	idx, ok := db.Indices()[task.DesignDocFn]
-	if !ok {
-		return fmt.Errorf("failed to update index %q doesn't exist", task.DesignDocFn)
+	// check that the task wasn't paused or canceled meanwhile
+	if task.StartedAt.IsZero() {
+		task.StartedAt = time.Now()
+	}
+	err = db.UpdateTask(ctx, task)
+	if err != nil {
+		return err
 	}
 
 	switch task.Action {
 	case model.ActionUpdateView, model.ActionUpdateSearch, model.ActionUpdateMango:
-		err = vc.Rebuild(ctx, task, idx)
+		idx, ok := db.Indices()[task.DesignDocFn]
+		if !ok {
+			return fmt.Errorf("failed to update index %q: %w", task.DesignDocFn, port.ErrNotFound)
+		}
+		err = vc.Rebuild(ctx, task.DesignDocFn, idx, task)
+	case model.ActionViewCleanup:
+		_, err = db.ViewCleanup(ctx)
 	default:
 		err = fmt.Errorf("unknown task action: %d", task.Action)
 	}
@@ -0,0 +1,172 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/goydb/goydb/internal/adapter/logger"
+	"github.com/goydb/goydb/internal/adapter/storage"
+	"github.com/goydb/goydb/internal/adapter/view/queryview"
+	"github.com/goydb/goydb/pkg/model"
+	"github.com/goydb/goydb/pkg/port"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingStorage returns databases that fail to process
+// tasks while failing is set
+type failingStorage struct {
+	port.Storage
+	failing *atomic.Bool
+}
+
+func (s failingStorage) Database(ctx context.Context, name string) (port.Database, error) {
+	db, err := s.Storage.Database(ctx, name)
+	return failingDB{Database: db, failing: s.failing}, err
+}
+
+type failingDB struct {
+	port.Database
+	failing *atomic.Bool
+}
+
+func (db failingDB) UpdateTask(ctx context.Context, task *model.Task) error {
+	if db.failing.Load() {
+		return errors.New("task failed")
+	}
+	return db.Database.UpdateTask(ctx, task)
+}
+
+func setupTaskTest(t *testing.T, dbs ...string) (*storage.Storage, *atomic.Bool, Task) {
+	t.Helper()
+	ctx := context.Background()
+	s, err := storage.Open(t.TempDir(),
+		storage.WithLogger(logger.NewNoLog()),
+		storage.WithViewEngine(queryview.Language, queryview.NewViewServer),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.Close() })
+
+	for _, name := range dbs {
+		db, err := s.CreateDatabase(ctx, name)
+		require.NoError(t, err)
+		for i := 0; i < 3; i++ {
+			_, err := db.PutDocument(ctx, &model.Document{ID: fmt.Sprintf("doc%d", i), Data: map[string]interface{}{"n": i}})
+			require.NoError(t, err)
+		}
+		_, err = db.PutDocument(ctx, &model.Document{
+			ID: "_design/app",
+			Data: map[string]interface{}{
+				"language": queryview.Language,
+				"views": map[string]interface{}{
+					"by_n": map[string]interface{}{"map": map[string]interface{}{"fields": []string{"n"}}},
+				},
+			},
+		})
+		require.NoError(t, err)
+	}
+
+	failing := new(atomic.Bool)
+	return s, failing, Task{Storage: failingStorage{Storage: s, failing: failing}, Logger: logger.NewNoLog()}
+}
+
+var taskTestIndex = model.DesignDocFn{Type: model.ViewFn, DesignDocID: "_design/app", FnName: "by_n"}.String()
+
+func singleTask(t *testing.T, db port.Database) *model.Task {
+	t.Helper()
+	tasks, err := db.PeekTasks(context.Background(), 10)
+	require.NoError(t, err)
+	if len(tasks) == 0 {
+		return nil
+	}
+	require.Len(t, tasks, 1)
+	return tasks[0]
+}
+
+func TestTask_Retry(t *testing.T) {
+	ctx := context.Background()
+	s, failing, tc := setupTaskTest(t, "test")
+	db, err := s.Database(ctx, "test")
+	require.NoError(t, err)
+
+	// the failed task is retried after the backoff
+	failing.Store(true)
+	require.NoError(t, tc.ProcessTasksForDatabase(ctx, db))
+	task := singleTask(t, db)
+	require.NotNil(t, task)
+	assert.Equal(t, 1, task.Attempts)
+	assert.Equal(t, "task failed", task.LastError)
+	assert.False(t, task.Runnable(time.Now()))
+	assert.True(t, task.Runnable(time.Now().Add(taskRetryDelay)))
+
+	// not processed before the retry is due
+	failing.Store(false)
+	require.NoError(t, tc.ProcessTasksForDatabase(ctx, db))
+	require.NotNil(t, singleTask(t, db))
+
+	task.RetryAt = time.Time{}
+	require.NoError(t, db.UpdateTask(ctx, task))
+	require.NoError(t, tc.ProcessTasksForDatabase(ctx, db))
+	assert.Nil(t, singleTask(t, db))
+	_, building, err := db.IndexSeq(ctx, taskTestIndex)
+	require.NoError(t, err)
+	assert.False(t, building)
+}
+
+func TestTask_RetryDropped(t *testing.T) {
+	ctx := context.Background()
+	s, failing, tc := setupTaskTest(t, "test")
+	db, err := s.Database(ctx, "test")
+	require.NoError(t, err)
+
+	failing.Store(true)
+	task := singleTask(t, db)
+	task.Attempts = taskMaxAttempts - 1
+	require.NoError(t, db.UpdateTask(ctx, task))
+	require.NoError(t, tc.ProcessTasksForDatabase(ctx, db))
+	assert.Nil(t, singleTask(t, db))
+}
+
+func TestTask_Controls(t *testing.T) {
+	ctx := context.Background()
+	s, _, tc := setupTaskTest(t, "a", "b")
+	a, err := s.Database(ctx, "a")
+	require.NoError(t, err)
+	b, err := s.Database(ctx, "b")
+	require.NoError(t, err)
+
+	// databases with higher priority tasks are processed first
+	priority := 5
+	_, err = b.ControlTask(ctx, singleTask(t, b).ID, model.TaskControls{Priority: &priority})
+	require.NoError(t, err)
+	scheduler := s.TaskScheduler()
+	for _, name := range []string{"b", "a"} {
+		next, _, err := scheduler.Next(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, name, next)
+		scheduler.Done(next)
+	}
+
+	// paused tasks stay queued
+	paused := true
+	task, err := a.ControlTask(ctx, singleTask(t, a).ID, model.TaskControls{Paused: &paused})
+	require.NoError(t, err)
+	assert.True(t, task.Paused)
+	require.NoError(t, tc.ProcessAllTasks(ctx))
+	assert.NotNil(t, singleTask(t, a))
+	assert.Nil(t, singleTask(t, b))
+	assert.ErrorIs(t, a.UpdateTask(ctx, task), port.ErrTaskPaused)
+
+	// a running task stops when it is canceled
+	require.NoError(t, a.CancelTask(ctx, task.ID))
+	assert.Nil(t, singleTask(t, a))
+	assert.ErrorIs(t, a.UpdateTask(ctx, task), port.ErrTaskCanceled)
+	assert.ErrorIs(t, a.CancelTask(ctx, task.ID), storage.ErrNotFound)
+	_, building, err := a.IndexSeq(ctx, taskTestIndex)
+	require.NoError(t, err)
+	assert.True(t, building)
+}
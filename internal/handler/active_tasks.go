@@ -21,7 +21,8 @@ func (s *ActiveTasks) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	names, err := s.Storage.Databases(r.Context())
+	// only loaded databases can have tasks
+	names, err := s.Storage.LoadedDatabases(r.Context())
 	if err != nil {
 		WriteError(w, http.StatusInternalServerError, err.Error())
 		return
@@ -42,39 +43,7 @@ func (s *ActiveTasks) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 
 		for _, task := range dbtasks {
-			// Map action to CouchDB-compatible type
-			taskType := "indexer"
-			switch task.Action {
-			case model.ActionUpdateSearch:
-				taskType = "search_indexer"
-			case model.ActionUpdateMango:
-				taskType = "indexer"
-			}
-
-			// Extract design document ID from DesignDocFn (format: "type:docname:fnname")
-			designDoc := task.DesignDocFn
-			if parts := strings.SplitN(designDoc, ":", 3); len(parts) == 3 {
-				designDoc = "_design/" + parts[1]
-			}
-
-			// Use UpdatedAt for updated_on if available, fall back to ActiveSince
-			updatedOn := task.ActiveSince
-			if !task.UpdatedAt.IsZero() {
-				updatedOn = task.UpdatedAt
-			}
-
-			tasks = append(tasks, &Task{
-				Node:           "nonode@nohost",
-				Pid:            fmt.Sprintf("<%d.%d>", os.Getpid(), task.ID),
-				ChangesDone:    task.Processed,
-				TotalChanges:   task.ProcessingTotal,
-				Database:       name,
-				DesignDocument: designDoc,
-				Progress:       int(float64(task.Processed) / float64(task.ProcessingTotal) * 100.0),
-				StartedOn:      int(task.ActiveSince.Unix()),
-				Type:           taskType,
-				UpdatedOn:      int(updatedOn.Unix()),
-			})
+			tasks = append(tasks, newActiveTask(name, task))
 		}
 	}
 
@@ -82,9 +51,77 @@ func (s *ActiveTasks) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(tasks) // nolint: errcheck
 }
 
+// newActiveTask maps the task of the database to its
+// CouchDB-compatible representation
+func newActiveTask(dbName string, task *model.Task) *Task {
+	// Map action to CouchDB-compatible type
+	taskType := "indexer"
+	switch task.Action {
+	case model.ActionUpdateSearch:
+		taskType = "search_indexer"
+	case model.ActionUpdateMango:
+		taskType = "indexer"
+	case model.ActionViewCleanup:
+		taskType = "view_cleanup"
+	}
+
+	// Extract design document ID from DesignDocFn (format: "type:docname:fnname")
+	designDoc := task.DesignDocFn
+	if parts := strings.SplitN(designDoc, ":", 3); len(parts) == 3 {
+		designDoc = "_design/" + parts[1]
+	}
+
+	// Use the start of the processing if available, fall back to the
+	// time the task was picked up or queued
+	startedOn := task.StartedAt
+	if startedOn.IsZero() {
+		startedOn = task.ActiveSince
+	}
+	if startedOn.IsZero() {
+		startedOn = task.CreatedAt
+	}
+	// Use UpdatedAt for updated_on if available, fall back to started_on
+	updatedOn := startedOn
+	if !task.UpdatedAt.IsZero() {
+		updatedOn = task.UpdatedAt
+	}
+
+	var progress int
+	if task.ProcessingTotal > 0 {
+		progress = int(float64(task.Processed) / float64(task.ProcessingTotal) * 100.0)
+	}
+
+	t := &Task{
+		Node:           "nonode@nohost",
+		Pid:            fmt.Sprintf("<%d.%d>", os.Getpid(), task.ID),
+		TaskID:         task.ID,
+		ChangesDone:    task.Processed,
+		TotalChanges:   task.ProcessingTotal,
+		Database:       dbName,
+		DesignDocument: designDoc,
+		Progress:       progress,
+		StartedOn:      int(startedOn.Unix()),
+		Type:           taskType,
+		UpdatedOn:      int(updatedOn.Unix()),
+		Throughput:     task.Throughput(),
+		Paused:         task.Paused,
+		Priority:       task.Priority,
+		Retries:        task.Attempts,
+		LastError:      task.LastError,
+	}
+	if eta := task.ETA(); !eta.IsZero() {
+		t.ETA = int(eta.Unix())
+	}
+	if !task.RetryAt.IsZero() {
+		t.NextRetryOn = int(task.RetryAt.Unix())
+	}
+	return t
+}
+
 type Task struct {
 	Node           string `json:"node"`
 	Pid            string `json:"pid"`
+	TaskID         uint64 `json:"task_id"`
 	ChangesDone    int    `json:"changes_done"`
 	Database       string `json:"database"`
 	DesignDocument string `json:"design_document"`
@@ -93,4 +130,12 @@ type Task struct {
 	TotalChanges   int    `json:"total_changes"`
 	Type           string `json:"type"`
 	UpdatedOn      int    `json:"updated_on"` // unix time
+
+	Throughput  float64 `json:"throughput"`    // changes per second
+	ETA         int     `json:"eta,omitempty"` // unix time
+	Paused      bool    `json:"paused"`
+	Priority    int     `json:"priority"`
+	Retries     int     `json:"retries"`
+	LastError   string  `json:"last_error,omitempty"`
+	NextRetryOn int     `json:"next_retry_on,omitempty"` // unix time
 }
@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/goydb/goydb/internal/adapter/storage"
+	"github.com/goydb/goydb/pkg/model"
+	"github.com/goydb/goydb/pkg/port"
+)
+
+// ActiveTasksPut pauses, resumes or reprioritizes a task or all
+// tasks of a database
+type ActiveTasksPut struct {
+	Base
+}
+
+func (s *ActiveTasksPut) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close() //nolint:errcheck
+
+	if _, ok := (Authenticator{Base: s.Base, RequiresAdmin: true}.Do(w, r)); !ok {
+		return
+	}
+	db := Database{Base: s.Base}.Do(w, r)
+	if db == nil {
+		return
+	}
+
+	var controls model.TaskControls
+	if err := json.NewDecoder(r.Body).Decode(&controls); err != nil {
+		WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if controls.Paused == nil && controls.Priority == nil {
+		WriteError(w, http.StatusBadRequest, "expected paused or priority")
+		return
+	}
+
+	ids, ok := taskIDs(w, r, db)
+	if !ok {
+		return
+	}
+	tasks := make([]*Task, 0, len(ids))
+	for _, id := range ids {
+		task, err := db.ControlTask(r.Context(), id, controls)
+		if writeTaskError(w, err) {
+			return
+		}
+		tasks = append(tasks, newActiveTask(db.Name(), task))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tasks) // nolint: errcheck
+}
+
+// ActiveTasksDelete cancels a task or all tasks of a database
+type ActiveTasksDelete struct {
+	Base
+}
+
+func (s *ActiveTasksDelete) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close() //nolint:errcheck
+
+	if _, ok := (Authenticator{Base: s.Base, RequiresAdmin: true}.Do(w, r)); !ok {
+		return
+	}
+	db := Database{Base: s.Base}.Do(w, r)
+	if db == nil {
+		return
+	}
+
+	ids, ok := taskIDs(w, r, db)
+	if !ok {
+		return
+	}
+	for _, id := range ids {
+		if writeTaskError(w, db.CancelTask(r.Context(), id)) {
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{ // nolint: errcheck
+		"ok":       true,
+		"canceled": len(ids),
+	})
+}
+
+// taskIDs returns the ID of the task in the path or
+// the IDs of all tasks of the database
+func taskIDs(w http.ResponseWriter, r *http.Request, db port.Database) ([]uint64, bool) {
+	if v, ok := pathVarOk(r, "task_id"); ok {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			WriteError(w, http.StatusBadRequest, "invalid task id "+strconv.Quote(v))
+			return nil, false
+		}
+		return []uint64{id}, true
+	}
+
+	tasks, err := db.PeekTasks(r.Context(), math.MaxInt)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, err.Error())
+		return nil, false
+	}
+	ids := make([]uint64, len(tasks))
+	for i, task := range tasks {
+		ids[i] = task.ID
+	}
+	return ids, true
+}
+
+func writeTaskError(w http.ResponseWriter, err error) bool {
+	if errors.Is(err, storage.ErrNotFound) {
+		WriteError(w, http.StatusNotFound, "task not found")
+		return true
+	} else if err != nil {
+		WriteError(w, http.StatusInternalServerError, err.Error())
+		return true
+	}
+	return false
+}
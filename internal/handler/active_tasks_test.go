@@ -4,9 +4,11 @@ package handler
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/gorilla/mux"
@@ -31,10 +33,10 @@ func setupActiveTasksTest(t *testing.T) (*storage.Storage, *mux.Router, func())
 	log := logger.NewNoLog()
 	s, err := storage.Open(dir,
 		storage.WithLogger(log),
-		storage.WithViewEngine("", gojaview.NewViewServer),
-		storage.WithViewEngine("javascript", gojaview.NewViewServer),
-		storage.WithReducerEngine("", gojaview.NewReducerBuilder(log)),
-		storage.WithReducerEngine("javascript", gojaview.NewReducerBuilder(log)),
+		storage.WithViewDesignDocEngine("", gojaview.NewViewServer),
+		storage.WithViewDesignDocEngine("javascript", gojaview.NewViewServer),
+		storage.WithReducerDesignDocEngine("", gojaview.NewReducerBuilder(log)),
+		storage.WithReducerDesignDocEngine("javascript", gojaview.NewReducerBuilder(log)),
 	)
 	require.NoError(t, err)
 
@@ -126,3 +128,122 @@ func TestActiveTasks_Empty(t *testing.T) {
 	require.Equal(t, http.StatusOK, code)
 	assert.Empty(t, tasks)
 }
+
+func TestActiveTasks_Control(t *testing.T) {
+	s, router, cleanup := setupActiveTasksTest(t)
+	defer cleanup()
+
+	ctx := t.Context()
+	_, err := s.CreateDatabase(ctx, "testdb")
+	require.NoError(t, err)
+	putDesignDoc(t, router, "testdb", "myddoc", map[string]interface{}{
+		"views": map[string]interface{}{
+			"myview": map[string]interface{}{
+				"map": "function(doc) { emit(doc._id, 1); }",
+			},
+		},
+	})
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.SetBasicAuth("admin", "secret")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	tasks, _ := getActiveTasks(t, router)
+	require.Len(t, tasks, 1)
+	assert.False(t, tasks[0].Paused)
+	assert.Zero(t, tasks[0].Retries)
+	taskPath := fmt.Sprintf("/_active_tasks/testdb/%d", tasks[0].TaskID)
+
+	w := do("PUT", "/_active_tasks/testdb", `{"paused": true, "priority": 3}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var updated []Task
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&updated))
+	require.Len(t, updated, 1)
+	assert.True(t, updated[0].Paused)
+
+	tasks, _ = getActiveTasks(t, router)
+	require.Len(t, tasks, 1)
+	assert.True(t, tasks[0].Paused)
+	assert.Equal(t, 3, tasks[0].Priority)
+
+	w = do("PUT", taskPath, `{"paused": false}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	tasks, _ = getActiveTasks(t, router)
+	assert.False(t, tasks[0].Paused)
+	assert.Equal(t, 3, tasks[0].Priority)
+
+	assert.Equal(t, http.StatusBadRequest, do("PUT", taskPath, `{}`).Code)
+	assert.Equal(t, http.StatusBadRequest, do("PUT", "/_active_tasks/testdb/x", `{"paused": true}`).Code)
+	assert.Equal(t, http.StatusNotFound, do("PUT", "/_active_tasks/missing", `{"paused": true}`).Code)
+
+	w = do("DELETE", taskPath, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	tasks, _ = getActiveTasks(t, router)
+	assert.Empty(t, tasks)
+	assert.Equal(t, http.StatusNotFound, do("DELETE", taskPath, "").Code)
+
+	// not allowed for non-admins
+	req := httptest.NewRequest("DELETE", "/_active_tasks/testdb", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestActiveTasks_ControlBuild(t *testing.T) {
+	s, router, cleanup := setupActiveTasksTest(t)
+	defer cleanup()
+
+	ctx := t.Context()
+	db, err := s.CreateDatabase(ctx, "testdb")
+	require.NoError(t, err)
+	_, err = db.PutDocument(ctx, &model.Document{ID: "a", Data: map[string]interface{}{}})
+	require.NoError(t, err)
+	ddoc := map[string]interface{}{
+		"views": map[string]interface{}{
+			"myview": map[string]interface{}{
+				"map": "function(doc) { emit(doc._id, 1); }",
+			},
+		},
+	}
+	putDesignDoc(t, router, "testdb", "myddoc", ddoc)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.SetBasicAuth("admin", "secret")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	tasks, _ := getActiveTasks(t, router)
+	require.Len(t, tasks, 1)
+	taskPath := fmt.Sprintf("/_active_tasks/testdb/%d", tasks[0].TaskID)
+
+	// queries of an index with a paused or canceled build fail
+	// instead of waiting for the build
+	require.Equal(t, http.StatusOK, do("PUT", taskPath, `{"paused": true}`).Code)
+	w := do("GET", "/testdb/_design/myddoc/_view/myview", "")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Body.String(), storage.ErrIndexBuildPaused.Error())
+
+	require.Equal(t, http.StatusOK, do("DELETE", taskPath, "").Code)
+	w = do("GET", "/testdb/_design/myddoc/_view/myview", "")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Body.String(), storage.ErrIndexBuildCanceled.Error())
+
+	// without waiting the partial index is queried
+	w = do("GET", "/testdb/_design/myddoc/_view/myview?update=false", "")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// saving the design document again resumes the build
+	doc, err := db.GetDocument(ctx, "_design/myddoc")
+	require.NoError(t, err)
+	ddoc["_rev"] = doc.Rev
+	putDesignDoc(t, router, "testdb", "myddoc", ddoc)
+	tasks, _ = getActiveTasks(t, router)
+	require.Len(t, tasks, 1)
+	assert.Equal(t, "indexer", tasks[0].Type)
+}
@@ -14,5 +14,6 @@ type Base struct {
 	Admins       model.AdminUsers
 	Config       *ConfigStore
 	Replication  *service.Replication
+	FindCache    *service.FindCache
 	Logger       port.Logger
 }
@@ -50,6 +50,21 @@ func (s *ConfigKey) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(val) // nolint: errcheck
 }
 
+// protectedConfigSections can only be changed in the config file,
+// query_servers holds commands that are executed by the server.
+var protectedConfigSections = map[string]bool{
+	"query_servers": true,
+}
+
+// configProtected writes 403 if the section can't be changed over HTTP
+func configProtected(w http.ResponseWriter, section string) bool {
+	if !protectedConfigSections[section] {
+		return false
+	}
+	WriteError(w, http.StatusForbidden, "the "+section+" config section can only be changed in the config file")
+	return true
+}
+
 // ConfigKeyPut handles PUT /_config/{section}/{key}.
 // The request body must be a JSON-encoded string; the old value is returned.
 type ConfigKeyPut struct {
@@ -58,6 +73,9 @@ type ConfigKeyPut struct {
 
 func (s *ConfigKeyPut) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close() //nolint:errcheck
+	if configProtected(w, pathVar(r, "section")) {
+		return
+	}
 	var value string
 	if err := json.NewDecoder(r.Body).Decode(&value); err != nil {
 		WriteError(w, http.StatusBadRequest, "bad_request")
@@ -88,6 +106,9 @@ type ConfigKeyDelete struct {
 
 func (s *ConfigKeyDelete) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close() //nolint:errcheck
+	if configProtected(w, pathVar(r, "section")) {
+		return
+	}
 	old, ok := s.Config.Delete(pathVar(r, "section"), pathVar(r, "key"))
 	if !ok {
 		WriteError(w, http.StatusNotFound, "unknown_config_value")
@@ -21,6 +21,8 @@ var defaultConfig = map[string]map[string]string{
 		"max_attachment_size":        "0",
 		"max_db_size":               "0",
 		"validate_on_replication":   "false",
+		"max_dbs_open":              "500",
+		"db_idle_timeout":           "300",
 	},
 	"chttpd": {
 		"max_http_request_size": "4294967296",
@@ -31,6 +33,9 @@ var defaultConfig = map[string]map[string]string{
 		"port":                    "7070",
 		"enable_cors":             "false",
 		"authentication_handlers": "cookie_authentication_handler,default_authentication_handler",
+		"rewrite_limit":           "100",
+		"secure_rewrites":         "true",
+		"vhost_global_handlers":   "_utils, _uuids, _session, _users",
 	},
 	"couch_httpd_auth": {
 		"proxy_use_secret": "false",
@@ -45,6 +50,18 @@ var defaultConfig = map[string]map[string]string{
 		"headers":     "accept, authorization, content-type, origin, referer",
 		"methods":     "GET, PUT, POST, HEAD, DELETE",
 	},
+	"aggregate": {
+		"max_groups": "10000",
+		"max_memory": "67108864",
+	},
+	"indexer": {
+		"workers": "1",
+	},
+	"find_cache": {
+		"enable":     "false",
+		"max_memory": "16777216",
+		"ttl":        "60",
+	},
 	"log": {
 		"level": "info",
 		"file":  "",
@@ -74,6 +91,8 @@ type ConfigStore struct {
 	path   string                      // empty → in-memory only
 	data   map[string]map[string]string // section → key → value
 	logger port.Logger                 // optional logger, can be nil during bootstrap
+	// version is incremented by every write
+	version uint64
 }
 
 // NewConfigStore returns a ConfigStore for the given file path.
@@ -139,10 +158,19 @@ func (cs *ConfigStore) Get(section, key string) (string, bool) {
 	return v, ok
 }
 
+// Version returns a number that changes with every write, values
+// derived from the configuration are cached until it changes.
+func (cs *ConfigStore) Version() uint64 {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	return cs.version
+}
+
 // Set stores a value and returns the previous value (empty string if absent).
 func (cs *ConfigStore) Set(section, key, value string) string {
 	cs.mu.Lock()
 	defer cs.mu.Unlock()
+	cs.version++
 	if cs.data[section] == nil {
 		cs.data[section] = map[string]string{}
 	}
@@ -173,6 +201,7 @@ func (cs *ConfigStore) Delete(section, key string) (string, bool) {
 		return "", false
 	}
 	delete(kv, key)
+	cs.version++
 	if cs.path != "" {
 		if err := cs.saveUnlocked(); err != nil {
 			if cs.logger != nil {
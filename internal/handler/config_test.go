@@ -189,6 +189,30 @@ func TestConfigKeyDelete_Missing(t *testing.T) {
 	assert.Equal(t, http.StatusNotFound, w.Code)
 }
 
+// TestConfigKey_Protected verifies that query_servers can't be changed
+// over HTTP, the commands would be executed by the server.
+func TestConfigKey_Protected(t *testing.T) {
+	_, router, cleanup := setupRevsDiffTest(t)
+	defer cleanup()
+
+	for _, path := range []string{"/_config/query_servers/python", "/_node/nonode@nohost/_config/query_servers/python"} {
+		req := httptest.NewRequest("PUT", path, strings.NewReader(`"/bin/sh"`))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		req = httptest.NewRequest("DELETE", path, nil)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	}
+
+	req := httptest.NewRequest("GET", "/_config/query_servers/python", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
 // TestNodeConfig verifies that the CouchDB 2.x /_node/{node}/_config/* routes
 // are wired to the same store as /_config/*.
 func TestNodeConfig(t *testing.T) {
@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/goydb/goydb/pkg/model"
+)
+
+// default limits if the aggregate config section is missing
+const (
+	defaultAggregateMaxGroups = 10000
+	defaultAggregateMaxMemory = 64 << 20
+)
+
+// AggregateResponse is the response of the _aggregate endpoint.
+type AggregateResponse struct {
+	Rows           []*model.AggregateRow `json:"rows"`
+	ExecutionStats *model.ExecutionStats `json:"execution_stats,omitempty"`
+}
+
+// DBAggregate handles POST /{db}/_aggregate.
+// It groups the documents matching a Mango selector and computes
+// accumulators per group. This is a goydb extension.
+type DBAggregate struct {
+	Base
+}
+
+func (s *DBAggregate) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close() //nolint:errcheck
+
+	db := Database{Base: s.Base}.Do(w, r)
+	if db == nil {
+		return
+	}
+
+	if _, ok := (Authenticator{Base: s.Base}.DB(w, r, db)); !ok {
+		return
+	}
+
+	var query model.AggregateQuery
+	if err := json.NewDecoder(r.Body).Decode(&query); err != nil {
+		WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	rows, stats, err := db.AggregateDocs(r.Context(), query, s.aggregateLimits())
+	if errors.Is(err, model.ErrAggregateLimitExceeded) {
+		WriteError(w, http.StatusRequestEntityTooLarge, err.Error())
+		return
+	} else if err != nil {
+		WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response := AggregateResponse{Rows: rows}
+	if query.ExecutionStats {
+		response.ExecutionStats = stats
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response) // nolint: errcheck
+}
+
+// aggregateLimits returns the limits of the aggregate config section,
+// falling back to the defaults if a key is missing.
+func (s *DBAggregate) aggregateLimits() model.AggregateLimits {
+	limits := model.AggregateLimits{
+		MaxGroups: defaultAggregateMaxGroups,
+		MaxMemory: defaultAggregateMaxMemory,
+	}
+	if s.Config == nil {
+		return limits
+	}
+	if _, ok := s.Config.Get("aggregate", "max_groups"); ok {
+		limits.MaxGroups = int(configInt64(s.Config, "aggregate", "max_groups"))
+	}
+	if _, ok := s.Config.Get("aggregate", "max_memory"); ok {
+		limits.MaxMemory = configInt64(s.Config, "aggregate", "max_memory")
+	}
+	return limits
+}
@@ -0,0 +1,219 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/goydb/goydb/internal/adapter/logger"
+	"github.com/goydb/goydb/internal/controller"
+	"github.com/goydb/goydb/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func postAggregate(t *testing.T, router http.Handler, body string) (AggregateResponse, int) {
+	t.Helper()
+	req := httptest.NewRequest("POST", "/testdb/_aggregate", strings.NewReader(body))
+	req.SetBasicAuth("admin", "secret")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var result AggregateResponse
+	if w.Code == http.StatusOK {
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&result))
+	}
+	return result, w.Code
+}
+
+func TestAggregate_GroupBy(t *testing.T) {
+	s, router, cleanup := setupRevsDiffTest(t)
+	defer cleanup()
+
+	ctx := t.Context()
+	db, err := s.CreateDatabase(ctx, "testdb")
+	require.NoError(t, err)
+
+	orders := []struct {
+		customer string
+		amount   float64
+		item     string
+	}{
+		{"bob", 10, "pen"},
+		{"alice", 5, "pen"},
+		{"bob", 30, "book"},
+		{"alice", 15, "ink"},
+		{"bob", 20, "pen"},
+	}
+	for i, o := range orders {
+		_, err = db.PutDocument(ctx, &model.Document{
+			ID: fmt.Sprintf("order%d", i),
+			Data: map[string]interface{}{
+				"type": "order", "customer": o.customer, "amount": o.amount, "item": o.item,
+			},
+		})
+		require.NoError(t, err)
+	}
+	_, err = db.PutDocument(ctx, &model.Document{
+		ID:   "customer-bob",
+		Data: map[string]interface{}{"type": "customer", "customer": "bob"},
+	})
+	require.NoError(t, err)
+
+	result, code := postAggregate(t, router, `{
+		"selector": {"type": "order"},
+		"group_by": ["customer"],
+		"accumulators": {
+			"n": {"$count": true},
+			"total": {"$sum": "amount"},
+			"lo": {"$min": "amount"},
+			"hi": {"$max": "amount"},
+			"mean": {"$avg": "amount"},
+			"items": {"$distinct": "item"},
+			"p": {"$percentiles": {"field": "amount", "percentiles": [50, 100]}}
+		},
+		"execution_stats": true
+	}`)
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, result.Rows, 2)
+
+	alice, bob := result.Rows[0], result.Rows[1]
+	assert.Equal(t, []interface{}{"alice"}, alice.Key)
+	assert.Equal(t, []interface{}{"bob"}, bob.Key)
+
+	assert.Equal(t, float64(2), alice.Value["n"])
+	assert.Equal(t, float64(20), alice.Value["total"])
+	assert.Equal(t, float64(10), alice.Value["mean"])
+	assert.Equal(t, []interface{}{"ink", "pen"}, alice.Value["items"])
+
+	assert.Equal(t, float64(3), bob.Value["n"])
+	assert.Equal(t, float64(60), bob.Value["total"])
+	assert.Equal(t, float64(10), bob.Value["lo"])
+	assert.Equal(t, float64(30), bob.Value["hi"])
+	assert.Equal(t, []interface{}{"book", "pen"}, bob.Value["items"])
+	assert.Equal(t, map[string]interface{}{"50": float64(20), "100": float64(30)}, bob.Value["p"])
+
+	require.NotNil(t, result.ExecutionStats)
+	assert.Equal(t, 2, result.ExecutionStats.ResultsReturned)
+}
+
+func TestAggregate_UsesMangoIndex(t *testing.T) {
+	s, router, cleanup := setupRevsDiffTest(t)
+	defer cleanup()
+
+	ctx := t.Context()
+	db, err := s.CreateDatabase(ctx, "testdb")
+	require.NoError(t, err)
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"index": map[string]interface{}{"fields": []string{"type"}}, "ddoc": "mango", "name": "by_type",
+	})
+	req := httptest.NewRequest("POST", "/testdb/_index", bytes.NewReader(body))
+	req.SetBasicAuth("admin", "secret")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	for i := 0; i < 10; i++ {
+		typ := "a"
+		if i%5 == 0 {
+			typ = "b"
+		}
+		_, err = db.PutDocument(ctx, &model.Document{
+			ID:   fmt.Sprintf("doc%d", i),
+			Data: map[string]interface{}{"type": typ, "v": float64(i)},
+		})
+		require.NoError(t, err)
+	}
+
+	result, code := postAggregate(t, router, `{
+		"selector": {"type": "b"},
+		"accumulators": {"total": {"$sum": "v"}},
+		"execution_stats": true
+	}`)
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, result.Rows, 1)
+	assert.Equal(t, []interface{}{}, result.Rows[0].Key)
+	assert.Equal(t, float64(5), result.Rows[0].Value["total"])
+	// only the documents found by the index are examined
+	assert.Equal(t, 2, result.ExecutionStats.TotalDocsExamined)
+}
+
+func TestAggregate_BuildingIndex(t *testing.T) {
+	s, router, cleanup := setupRevsDiffTest(t)
+	defer cleanup()
+
+	ctx := t.Context()
+	db, err := s.CreateDatabase(ctx, "testdb")
+	require.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		typ := "a"
+		if i%5 == 0 {
+			typ = "b"
+		}
+		_, err = db.PutDocument(ctx, &model.Document{
+			ID:   fmt.Sprintf("doc%d", i),
+			Data: map[string]interface{}{"type": typ, "v": float64(i)},
+		})
+		require.NoError(t, err)
+	}
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"index": map[string]interface{}{"fields": []string{"type"}}, "ddoc": "mango", "name": "by_type",
+	})
+	req := httptest.NewRequest("POST", "/testdb/_index", bytes.NewReader(body))
+	req.SetBasicAuth("admin", "secret")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	aggregate := func() AggregateResponse {
+		result, code := postAggregate(t, router, `{
+			"selector": {"type": "b"},
+			"accumulators": {"total": {"$sum": "v"}},
+			"execution_stats": true
+		}`)
+		require.Equal(t, http.StatusOK, code)
+		require.Len(t, result.Rows, 1)
+		assert.Equal(t, float64(5), result.Rows[0].Value["total"])
+		return result
+	}
+
+	// the building index isn't used, all documents are scanned
+	assert.Equal(t, 10, aggregate().ExecutionStats.TotalDocsExamined)
+
+	tc := controller.Task{Storage: s, Logger: logger.NewNoLog()}
+	require.NoError(t, tc.ProcessTasksForDatabase(ctx, db))
+	assert.Equal(t, 2, aggregate().ExecutionStats.TotalDocsExamined)
+}
+
+func TestAggregate_Limits(t *testing.T) {
+	s, router, cleanup := setupRevsDiffTest(t)
+	defer cleanup()
+
+	ctx := t.Context()
+	db, err := s.CreateDatabase(ctx, "testdb")
+	require.NoError(t, err)
+
+	for i := 0; i < defaultAggregateMaxGroups+1; i++ {
+		_, err = db.PutDocument(ctx, &model.Document{
+			ID:   fmt.Sprintf("doc%05d", i),
+			Data: map[string]interface{}{"n": float64(i)},
+		})
+		require.NoError(t, err)
+	}
+
+	_, code := postAggregate(t, router, `{"selector": {}, "group_by": ["n"]}`)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, code)
+}
+
+func TestAggregate_UnknownAccumulator(t *testing.T) {
+	s, router, cleanup := setupRevsDiffTest(t)
+	defer cleanup()
+
+	_, err := s.CreateDatabase(t.Context(), "testdb")
+	require.NoError(t, err)
+
+	_, code := postAggregate(t, router, `{"selector": {}, "accumulators": {"x": {"$median": "a"}}}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+}
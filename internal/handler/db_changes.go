@@ -615,13 +615,13 @@ func (s *DBChanges) filterByView(ctx context.Context, db port.Database, changes
 	}
 
 	// Get view server builder
-	builder := db.ViewEngine(ddoc.Language())
+	builder := db.ViewDesignDocEngine(ddoc.Language())
 	if builder == nil {
 		s.Logger.Warnf(ctx, "view engine not found", "language", ddoc.Language())
 		return changes
 	}
 
-	viewServer, err := builder(view.MapFn)
+	viewServer, err := builder(view.MapFn, ddoc)
 	if err != nil {
 		s.Logger.Warnf(ctx, "failed to compile view", "error", err)
 		return changes
@@ -666,14 +666,14 @@ func (s *DBChanges) filterByCustomFilter(ctx context.Context, db port.Database,
 	}
 
 	// Get filter server builder
-	builder := db.FilterEngine(ddoc.Language())
+	builder := db.FilterDesignDocEngine(ddoc.Language())
 	if builder == nil {
 		s.Logger.Warnf(ctx, "filter engine not found", "language", ddoc.Language())
 		return changes
 	}
 
 	// Compile filter
-	filterServer, err := builder(filter.FilterFn)
+	filterServer, err := builder(filter.FilterFn, ddoc)
 	if err != nil {
 		s.Logger.Warnf(ctx, "failed to compile filter", "error", err)
 		return changes
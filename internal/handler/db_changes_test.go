@@ -35,12 +35,12 @@ func setupChangesTest(t *testing.T) (*storage.Storage, *mux.Router, func()) {
 	s, err := storage.Open(
 		dir,
 		storage.WithLogger(log),
-		storage.WithViewEngine("", gojaview.NewViewServer),
-		storage.WithViewEngine("javascript", gojaview.NewViewServer),
-		storage.WithFilterEngine("", gojaview.NewFilterServer),
-		storage.WithFilterEngine("javascript", gojaview.NewFilterServer),
-		storage.WithReducerEngine("", gojaview.NewReducerBuilder(log)),
-		storage.WithReducerEngine("javascript", gojaview.NewReducerBuilder(log)),
+		storage.WithViewDesignDocEngine("", gojaview.NewViewServer),
+		storage.WithViewDesignDocEngine("javascript", gojaview.NewViewServer),
+		storage.WithFilterDesignDocEngine("", gojaview.NewFilterServer),
+		storage.WithFilterDesignDocEngine("javascript", gojaview.NewFilterServer),
+		storage.WithReducerDesignDocEngine("", gojaview.NewReducerBuilder(log)),
+		storage.WithReducerDesignDocEngine("javascript", gojaview.NewReducerBuilder(log)),
 	)
 	require.NoError(t, err)
 
@@ -31,7 +31,8 @@ func (s *DBCompact) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]interface{}{"ok": true}) // nolint: errcheck
 }
 
-// DBViewCleanup handles POST /{db}/_view_cleanup — no-op for embedded storage.
+// DBViewCleanup handles POST /{db}/_view_cleanup — removes the indices
+// that don't belong to a design document anymore.
 type DBViewCleanup struct {
 	Base
 }
@@ -47,7 +48,13 @@ func (s *DBViewCleanup) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	freed, err := db.ViewCleanup(r.Context())
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusAccepted)
-	json.NewEncoder(w).Encode(map[string]interface{}{"ok": true}) // nolint: errcheck
+	json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "bytes_freed": freed}) // nolint: errcheck
 }
@@ -2,8 +2,10 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 
+	"github.com/goydb/goydb/internal/adapter/storage"
 )
 
 type DBCreate struct {
@@ -18,8 +20,8 @@ func (s *DBCreate) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	dbName := pathVar(r, "db")
-	db, _ := s.Storage.Database(r.Context(), dbName)
-	if db != nil {
+	db, err := s.Storage.Database(r.Context(), dbName)
+	if db != nil || errors.Is(err, storage.ErrQuarantinedDatabase) {
 		WriteError(w, http.StatusConflict, "Database already exists.")
 		return
 	}
@@ -28,7 +30,7 @@ func (s *DBCreate) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err := s.Storage.CreateDatabase(r.Context(), dbName)
+	_, err = s.Storage.CreateDatabase(r.Context(), dbName)
 	if err != nil {
 		WriteError(w, http.StatusInternalServerError, err.Error())
 		return
@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/goydb/goydb/internal/adapter/storage"
+	"github.com/goydb/goydb/pkg/model"
+)
+
+// DBDesignDocPromote replaces the design document with its staged
+// version once the indices of the staged version are built
+type DBDesignDocPromote struct {
+	Base
+}
+
+func (s *DBDesignDocPromote) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close() //nolint:errcheck
+
+	db := Database{Base: s.Base}.Do(w, r)
+	if db == nil {
+		return
+	}
+	session, ok := Authenticator{Base: s.Base, RequiresAdmin: true}.DB(w, r, db)
+	if !ok {
+		return
+	}
+
+	docID := string(model.DesignDocPrefix) + pathVar(r, "docid")
+
+	// the promoted dbcopy views are copied on behalf of the promoter
+	staged, err := db.GetDocument(r.Context(), model.StagedDesignDocID(docID))
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if staged != nil && !staged.Deleted {
+		if writeValidationError(w, authorizeDBCopies(r.Context(), s.Storage, staged, session)) {
+			return
+		}
+	}
+
+	rev, err := db.PromoteDesignDoc(r.Context(), docID)
+	if errors.Is(err, storage.ErrNotFound) {
+		WriteError(w, http.StatusNotFound, "staged design document "+model.StagedDesignDocID(docID)+" not found")
+		return
+	} else if errors.Is(err, storage.ErrIndexBuilding) || errors.Is(err, storage.ErrConflict) {
+		WriteError(w, http.StatusConflict, err.Error())
+		return
+	} else if err != nil {
+		WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if staged != nil {
+		err = setDBCopyAuthor(r.Context(), db, &model.Document{ID: docID, Data: staged.Data}, rev, session)
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(SimpleDocResponse{ //nolint:errcheck
+		ID:  docID,
+		Ok:  true,
+		Rev: rev,
+	})
+}
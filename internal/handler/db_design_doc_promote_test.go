@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/goydb/goydb/internal/adapter/logger"
+	"github.com/goydb/goydb/internal/adapter/view/queryview"
+	"github.com/goydb/goydb/internal/controller"
+	"github.com/goydb/goydb/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDesignDocPromote(t *testing.T) {
+	ctx := context.Background()
+	s, router := setupQueryViewTest(t)
+	db, err := s.CreateDatabase(ctx, "testdb")
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		_, err := db.PutDocument(ctx, &model.Document{
+			ID:   fmt.Sprintf("doc%d", i),
+			Data: map[string]interface{}{"n": i, "m": -i},
+		})
+		require.NoError(t, err)
+	}
+	view := func(field string) map[string]interface{} {
+		return map[string]interface{}{
+			"language": queryview.Language,
+			"views": map[string]interface{}{
+				"by_n": map[string]interface{}{"map": map[string]interface{}{"fields": []string{field}}},
+			},
+		}
+	}
+	_, err = db.PutDocument(ctx, &model.Document{ID: "_design/app", Data: view("n")})
+	require.NoError(t, err)
+	tc := controller.Task{Storage: s, Logger: logger.NewNoLog()}
+	require.NoError(t, tc.ProcessTasksForDatabase(ctx, db))
+
+	do := func(method, path string) (int, map[string]interface{}) {
+		req := httptest.NewRequest(method, path, strings.NewReader(""))
+		req.SetBasicAuth("admin", "secret")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var result map[string]interface{}
+		_ = json.NewDecoder(w.Body).Decode(&result)
+		return w.Code, result
+	}
+	firstKey := func(n int) interface{} {
+		code, res := do("GET", "/testdb/_design/app/_view/by_n?update=false")
+		require.Equal(t, http.StatusOK, code)
+		rows := res["rows"].([]interface{})
+		require.Len(t, rows, n)
+		return rows[0].(map[string]interface{})["key"]
+	}
+	assert.EqualValues(t, 0, firstKey(3))
+
+	code, _ := do("POST", "/testdb/_design/app/_promote")
+	assert.Equal(t, http.StatusNotFound, code)
+
+	// the staged version builds in the background
+	_, err = db.PutDocument(ctx, &model.Document{ID: "_design/app.staged", Data: view("m")})
+	require.NoError(t, err)
+	req := httptest.NewRequest("GET", "/_active_tasks", nil)
+	req.SetBasicAuth("admin", "secret")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var tasks []map[string]interface{}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&tasks))
+	require.Len(t, tasks, 1)
+	assert.Equal(t, "_design/app.staged", tasks[0]["design_document"])
+
+	code, _ = do("POST", "/testdb/_design/app/_promote")
+	assert.Equal(t, http.StatusConflict, code)
+	assert.EqualValues(t, 0, firstKey(3))
+
+	require.NoError(t, tc.ProcessTasksForDatabase(ctx, db))
+	code, res := do("POST", "/testdb/_design/app/_promote")
+	require.Equal(t, http.StatusCreated, code)
+	assert.Equal(t, true, res["ok"])
+	assert.True(t, strings.HasPrefix(res["rev"].(string), "2-"))
+
+	// the built index is swapped in without a rebuild
+	assert.EqualValues(t, -2, firstKey(3))
+	// only the orphaned buckets of the replaced index are cleaned up
+	pending, err := db.PeekTasks(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, model.ActionViewCleanup, pending[0].Action)
+	require.NoError(t, tc.ProcessTasksForDatabase(ctx, db))
+	pending, err = db.PeekTasks(ctx, 10)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+	assert.EqualValues(t, -2, firstKey(3), "the cleanup keeps the promoted buckets")
+	freed, err := db.ViewCleanup(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, freed, "the replaced buckets are removed already")
+	code, res = do("GET", "/testdb/_design/app")
+	require.Equal(t, http.StatusOK, code)
+	fields := res["views"].(map[string]interface{})["by_n"].(map[string]interface{})["map"].(map[string]interface{})["fields"]
+	assert.Equal(t, []interface{}{"m"}, fields)
+	code, _ = do("GET", "/testdb/_design/app.staged")
+	assert.Equal(t, http.StatusNotFound, code)
+
+	// the promoted index is updated with every write
+	_, err = db.PutDocument(ctx, &model.Document{ID: "doc3", Data: map[string]interface{}{"m": -3}})
+	require.NoError(t, err)
+	assert.EqualValues(t, -3, firstKey(4))
+}
@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/goydb/goydb/pkg/model"
+)
+
+// DBDesignValidate compiles the functions of the design document in
+// the request body without storing it (dry run)
+type DBDesignValidate struct {
+	Base
+}
+
+// DesignValidateResponse lists the functions that failed to compile
+type DesignValidateResponse struct {
+	ID     string           `json:"id"`
+	Ok     bool             `json:"ok"`
+	Errors []DesignDocError `json:"errors"`
+}
+
+func (s *DBDesignValidate) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close() //nolint:errcheck
+
+	db := Database{Base: s.Base}.Do(w, r)
+	if db == nil {
+		return
+	}
+	// compiling functions runs their top-level code
+	if _, ok := (Authenticator{Base: s.Base, RequiresAdmin: true}.DB(w, r, db)); !ok {
+		return
+	}
+
+	var data map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	ddoc := &model.Document{ID: string(model.DesignDocPrefix) + "validate", Data: data}
+	if id, ok := data["_id"].(string); ok {
+		ddoc.ID = id
+	}
+	if !ddoc.IsDesignDoc() {
+		WriteError(w, http.StatusBadRequest, "_id has to start with "+string(model.DesignDocPrefix))
+		return
+	}
+
+	response := DesignValidateResponse{
+		ID:     ddoc.ID,
+		Errors: CompileDesignDoc(db, ddoc),
+	}
+	response.Ok = len(response.Errors) == 0
+	status := http.StatusOK
+	if !response.Ok {
+		status = http.StatusBadRequest
+	} else {
+		response.Errors = []DesignDocError{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response) //nolint:errcheck
+}
@@ -0,0 +1,183 @@
+//go:build !nogoja
+
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/goydb/goydb/internal/adapter/logger"
+	"github.com/goydb/goydb/internal/controller"
+	"github.com/goydb/goydb/pkg/model"
+	"github.com/goydb/goydb/pkg/port"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDesignDocCompile(t *testing.T) {
+	s, router, cleanup := setupVDUTest(t)
+	defer cleanup()
+	_, err := s.CreateDatabase(context.Background(), "testdb")
+	require.NoError(t, err)
+
+	do := func(method, path string, body interface{}) (int, map[string]interface{}) {
+		data, err := json.Marshal(body)
+		require.NoError(t, err)
+		req := httptest.NewRequest(method, path, bytes.NewReader(data))
+		req.SetBasicAuth("admin", "secret")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var result map[string]interface{}
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&result))
+		return w.Code, result
+	}
+	ddoc := func(mapFn, reduceFn, filterFn, validateFn string) map[string]interface{} {
+		return map[string]interface{}{
+			"views": map[string]interface{}{
+				"by_n": map[string]interface{}{"map": mapFn, "reduce": reduceFn},
+			},
+			"filters":             map[string]interface{}{"even": filterFn},
+			"validate_doc_update": validateFn,
+		}
+	}
+	valid := ddoc(
+		"function(doc) { emit(doc.n, 1); }",
+		"_top_n:3",
+		"function(doc, req) { return doc.n % 2 == 0; }",
+		"function(newDoc, oldDoc, userCtx) {}",
+	)
+	invalid := ddoc(
+		"function(doc) { emit(doc.n, 1); ",
+		"_top_n:x",
+		"function(doc, req) { return doc.n %% 2; }",
+		"function(newDoc, oldDoc, userCtx) { if ( }",
+	)
+
+	// dry run
+	code, res := do("POST", "/testdb/_design_validate", valid)
+	assert.Equal(t, http.StatusOK, code, res)
+	assert.Equal(t, true, res["ok"])
+	assert.Empty(t, res["errors"])
+
+	code, res = do("POST", "/testdb/_design_validate", invalid)
+	assert.Equal(t, http.StatusBadRequest, code, res)
+	assert.Equal(t, false, res["ok"])
+	errs := res["errors"].([]interface{})
+	var functions []interface{}
+	for _, e := range errs {
+		functions = append(functions, e.(map[string]interface{})["function"])
+		assert.NotEmpty(t, e.(map[string]interface{})["reason"])
+	}
+	assert.Equal(t, []interface{}{"filters.even", "validate_doc_update", "views.by_n.map", "views.by_n.reduce"}, functions)
+
+	code, _ = do("POST", "/testdb/_design_validate", map[string]interface{}{"_id": "doc"})
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	// invalid design documents are rejected
+	code, res = do("PUT", "/testdb/_design/app", invalid)
+	assert.Equal(t, http.StatusBadRequest, code, res)
+	assert.Contains(t, res["reason"], "views.by_n.map: ")
+	assert.Contains(t, res["reason"], `views.by_n.reduce: invalid reduce function "_top_n:x"`)
+	code, _ = do("GET", "/testdb/_design/app", nil)
+	assert.Equal(t, http.StatusNotFound, code)
+
+	bad := ddoc("function(doc) { emit(doc.n, 1); }", "_median", "function(doc, req) { return true; }", "")
+	code, res = do("PUT", "/testdb/_design/app", bad)
+	assert.Equal(t, http.StatusBadRequest, code, res)
+	assert.Contains(t, res["reason"], `unknown built-in reduce function "_median"`)
+
+	code, res = do("PUT", "/testdb/_design/app", valid)
+	assert.Equal(t, http.StatusCreated, code, res)
+
+	// design documents posted with an ID are checked as well
+	withID := func(ddoc map[string]interface{}, id string) map[string]interface{} {
+		ddoc["_id"] = id
+		return ddoc
+	}
+	code, res = do("POST", "/testdb", withID(invalid, "_design/posted"))
+	assert.Equal(t, http.StatusBadRequest, code, res)
+	assert.Contains(t, res["reason"], "views.by_n.map: ")
+	code, _ = do("GET", "/testdb/_design/posted", nil)
+	assert.Equal(t, http.StatusNotFound, code)
+	code, res = do("POST", "/testdb", withID(valid, "_design/posted"))
+	assert.Equal(t, http.StatusCreated, code, res)
+	assert.Equal(t, "_design/posted", res["id"])
+}
+
+func TestDesignDocCompile_BulkDocs(t *testing.T) {
+	s, router, cleanup := setupVDUTest(t)
+	defer cleanup()
+	_, err := s.CreateDatabase(context.Background(), "testdb")
+	require.NoError(t, err)
+
+	bulk := func(body interface{}) []map[string]interface{} {
+		data, err := json.Marshal(body)
+		require.NoError(t, err)
+		req := httptest.NewRequest("POST", "/testdb/_bulk_docs", bytes.NewReader(data))
+		req.SetBasicAuth("admin", "secret")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var result []map[string]interface{}
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&result))
+		return result
+	}
+	ddoc := func(id, mapFn string) map[string]interface{} {
+		return map[string]interface{}{
+			"_id":   id,
+			"views": map[string]interface{}{"by_n": map[string]interface{}{"map": mapFn}},
+		}
+	}
+
+	res := bulk(map[string]interface{}{"docs": []interface{}{
+		ddoc("_design/bad", "function(doc) { emit(doc.n, 1); "),
+		ddoc("_design/good", "function(doc) { emit(doc.n, 1); }"),
+		map[string]interface{}{"_id": "doc"},
+	}})
+	require.Len(t, res, 3)
+	assert.Equal(t, "bad_request", res[0]["error"], res[0])
+	assert.Contains(t, res[0]["reason"], "views.by_n.map: ")
+	assert.Equal(t, true, res[1]["ok"], res[1])
+	assert.Equal(t, true, res[2]["ok"], res[2])
+}
+
+func TestDesignDocDBCopy(t *testing.T) {
+	ctx := context.Background()
+	s, router, cleanup := setupVDUTest(t)
+	defer cleanup()
+	db, err := s.CreateDatabase(ctx, "testdb")
+	require.NoError(t, err)
+	_, err = db.PutDocument(ctx, &model.Document{ID: "a", Data: map[string]interface{}{"n": float64(1)}})
+	require.NoError(t, err)
+
+	ddoc := func(target string) map[string]interface{} {
+		return map[string]interface{}{
+			"views": map[string]interface{}{
+				"by_n": map[string]interface{}{
+					"map":    "function(doc) { emit(doc.n, 1); }",
+					"reduce": "_count",
+					"dbcopy": target,
+				},
+			},
+		}
+	}
+
+	// system databases can't be targets
+	code, res := vduPutDoc(t, router, "/testdb/_design/app", ddoc("_users"))
+	assert.Equal(t, http.StatusForbidden, code, res)
+
+	// the view is copied on behalf of the author
+	code, res = vduPutDoc(t, router, "/testdb/_design/app", ddoc("counts"))
+	require.Equal(t, http.StatusCreated, code, res)
+	tc := controller.Task{Storage: s, Logger: logger.NewNoLog()}
+	require.NoError(t, tc.ProcessTasksForDatabase(ctx, db))
+	target, err := s.Database(ctx, "counts")
+	require.NoError(t, err)
+	docs, total, err := target.AllDocs(ctx, port.AllDocsQuery{SkipLocal: true})
+	require.NoError(t, err)
+	assert.Equal(t, 1, total, docs)
+}
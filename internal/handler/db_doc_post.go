@@ -52,13 +52,17 @@ func (s *DBDocPost) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	b := make([]byte, 16)
-	if _, err := rand.Read(b); err != nil {
-		WriteError(w, http.StatusInternalServerError, err.Error())
-		return
+	// the ID of the body is used if present, like in CouchDB
+	docID, _ := doc["_id"].(string)
+	if docID == "" {
+		b := make([]byte, 16)
+		if _, err := rand.Read(b); err != nil {
+			WriteError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		docID = hex.EncodeToString(b)
+		doc["_id"] = docID
 	}
-	docID := hex.EncodeToString(b)
-	doc["_id"] = docID
 
 	mdoc := &model.Document{
 		ID:   docID,
@@ -71,11 +75,27 @@ func (s *DBDocPost) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Run validate_doc_update (POST is always a new document).
-	if err := ValidateDocUpdate(r.Context(), db, s.Logger, mdoc, nil, session); err != nil {
-		if writeValidationError(w, err) {
+	// Reject design documents with functions that don't compile.
+	if mdoc.IsDesignDoc() {
+		if writeDesignDocErrors(w, CompileDesignDoc(db, mdoc)) {
 			return
 		}
+		if writeValidationError(w, authorizeDBCopies(r.Context(), s.Storage, mdoc, session)) {
+			return
+		}
+	}
+
+	// Run validate_doc_update for non-local docs.
+	if !isLocalDoc(docID) {
+		var oldDoc *model.Document
+		if existing, err := db.GetDocument(r.Context(), docID); err == nil && existing != nil && !existing.Deleted {
+			oldDoc = existing
+		}
+		if err := ValidateDocUpdate(r.Context(), db, s.Logger, mdoc, oldDoc, session); err != nil {
+			if writeValidationError(w, err) {
+				return
+			}
+		}
 	}
 
 	rev, err := db.PutDocument(r.Context(), mdoc)
@@ -88,6 +108,15 @@ func (s *DBDocPost) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// the dbcopy views are copied on behalf of the author of the revision
+	if mdoc.IsDesignDoc() {
+		err = setDBCopyAuthor(r.Context(), db, mdoc, rev, session)
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(map[string]interface{}{ // nolint: errcheck
@@ -120,6 +120,17 @@ func (s *DBDocPut) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Reject design documents with functions that don't compile,
+	// replicated design documents are stored as they are.
+	if newEdits && !mdoc.Deleted && mdoc.IsDesignDoc() {
+		if writeDesignDocErrors(w, CompileDesignDoc(db, mdoc)) {
+			return
+		}
+		if writeValidationError(w, authorizeDBCopies(r.Context(), s.Storage, mdoc, session)) {
+			return
+		}
+	}
+
 	// Run validate_doc_update for non-local docs.
 	if !isLocalDoc(docID) {
 		var oldDoc *model.Document
@@ -179,6 +190,15 @@ func (s *DBDocPut) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// the dbcopy views are copied on behalf of the author of the revision
+	if newEdits && !mdoc.Deleted && mdoc.IsDesignDoc() {
+		err = setDBCopyAuthor(r.Context(), db, mdoc, rev, session)
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
 	status := http.StatusCreated
 	if batch {
 		status = http.StatusAccepted
@@ -242,6 +262,15 @@ func (s *DBDocPut) handleMultipart(w http.ResponseWriter, r *http.Request, db po
 		}
 	}
 
+	if !mdoc.Deleted && mdoc.IsDesignDoc() {
+		if writeDesignDocErrors(w, CompileDesignDoc(db, mdoc)) {
+			return
+		}
+		if writeValidationError(w, authorizeDBCopies(r.Context(), s.Storage, mdoc, session)) {
+			return
+		}
+	}
+
 	// Run validate_doc_update for non-local docs.
 	if !isLocalDoc(docID) {
 		var oldDoc *model.Document
@@ -297,6 +326,15 @@ func (s *DBDocPut) handleMultipart(w http.ResponseWriter, r *http.Request, db po
 		}
 	}
 
+	// the dbcopy views are copied on behalf of the author of the revision
+	if !mdoc.Deleted && mdoc.IsDesignDoc() {
+		err = setDBCopyAuthor(r.Context(), db, mdoc, rev, session)
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(map[string]interface{}{ // nolint: errcheck
@@ -88,6 +88,20 @@ func (s *DBDocsBulk) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// Pre-validate all docs against VDU functions.
 	vduSkip := make(map[int]bool)
 	resp := make([]SimpleDocResponse, len(req.Docs))
+	reject := func(i int, err error) {
+		resp[i].ID = req.Docs[i].ID
+		resp[i].Ok = false
+		var forbiddenErr *model.ErrForbidden
+		var unauthorizedErr *model.ErrUnauthorized
+		if errors.As(err, &forbiddenErr) {
+			resp[i].Error, resp[i].Reason = "forbidden", forbiddenErr.Msg
+		} else if errors.As(err, &unauthorizedErr) {
+			resp[i].Error, resp[i].Reason = "unauthorized", unauthorizedErr.Msg
+		} else {
+			resp[i].Error, resp[i].Reason = "internal_server_error", err.Error()
+		}
+		vduSkip[i] = true
+	}
 	for i, doc := range req.Docs {
 		// Hash plaintext passwords in _users docs before validation (normal edits only).
 		if newEdits && !doc.Deleted {
@@ -99,6 +113,21 @@ func (s *DBDocsBulk) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 				continue
 			}
 		}
+		// Reject design documents with functions that don't compile,
+		// replicated design documents are stored as they are.
+		if newEdits && !doc.Deleted && doc.IsDesignDoc() {
+			if errs := CompileDesignDoc(db, doc); len(errs) > 0 {
+				resp[i].ID = doc.ID
+				resp[i].Ok = false
+				resp[i].Error, resp[i].Reason = "bad_request", designDocErrorsReason(errs)
+				vduSkip[i] = true
+				continue
+			}
+			if err := authorizeDBCopies(r.Context(), s.Storage, doc, session); err != nil {
+				reject(i, err)
+				continue
+			}
+		}
 		if isLocalDoc(doc.ID) {
 			continue
 		}
@@ -115,18 +144,7 @@ func (s *DBDocsBulk) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			validateErr = ValidateDocUpdateForReplication(r.Context(), db, s.Logger, doc, oldDoc, session, s.Config)
 		}
 		if validateErr != nil {
-			resp[i].ID = doc.ID
-			resp[i].Ok = false
-			var forbiddenErr *model.ErrForbidden
-			var unauthorizedErr *model.ErrUnauthorized
-			if errors.As(validateErr, &forbiddenErr) {
-				resp[i].Error, resp[i].Reason = "forbidden", forbiddenErr.Msg
-			} else if errors.As(validateErr, &unauthorizedErr) {
-				resp[i].Error, resp[i].Reason = "unauthorized", unauthorizedErr.Msg
-			} else {
-				resp[i].Error, resp[i].Reason = "internal_server_error", validateErr.Error()
-			}
-			vduSkip[i] = true
+			reject(i, validateErr)
 		}
 	}
 
@@ -182,6 +200,17 @@ func (s *DBDocsBulk) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// the dbcopy views are copied on behalf of the author of the revision
+	for i, doc := range req.Docs {
+		if !resp[i].Ok || !newEdits || doc.Deleted || !doc.IsDesignDoc() {
+			continue
+		}
+		err := setDBCopyAuthor(r.Context(), db, doc, resp[i].Rev, session)
+		if err != nil {
+			s.Logger.Warnf(r.Context(), "failed to record the dbcopy author", "docID", doc.ID, "error", err)
+		}
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resp) // nolint: errcheck
 }
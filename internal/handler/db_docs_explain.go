@@ -35,16 +35,22 @@ func (s *DBDocsExplain) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		find.Limit = 25
 	}
 
-	// Default to the _all_docs special index
-	indexInfo := map[string]interface{}{
-		"ddoc": nil,
-		"name": "_all_docs",
-		"type": "special",
-		"def":  map[string]interface{}{"fields": []interface{}{map[string]string{"_id": "asc"}}},
+	plan, err := db.ExplainFind(r.Context(), find)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	useIndex := []interface{}{}
+	if ddoc, name, ok := find.UseIndexHint(); ok {
+		useIndex = append(useIndex, string(model.DesignDocPrefix)+ddoc)
+		if name != "" {
+			useIndex = append(useIndex, name)
+		}
 	}
 
 	opts := map[string]interface{}{
-		"use_index":  []interface{}{},
+		"use_index":  useIndex,
 		"bookmark":   "nil",
 		"limit":      find.Limit,
 		"skip":       find.Skip,
@@ -63,14 +69,25 @@ func (s *DBDocsExplain) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		opts["sort"] = map[string]interface{}{}
 	}
 
+	candidates := plan.Candidates
+	if candidates == nil {
+		candidates = []model.IndexCandidate{}
+	}
+
 	response := map[string]interface{}{
-		"dbname":   db.Name(),
-		"index":    indexInfo,
-		"selector": find.Selector,
-		"opts":     opts,
-		"limit":    find.Limit,
-		"skip":     find.Skip,
-		"fields":   find.Fields,
+		"dbname":           db.Name(),
+		"index":            plan.Index.Index,
+		"estimated_rows":   plan.Index.EstimatedRows,
+		"estimated_cost":   plan.Index.EstimatedCost,
+		"index_candidates": candidates,
+		"selector":         find.Selector,
+		"opts":             opts,
+		"limit":            find.Limit,
+		"skip":             find.Skip,
+		"fields":           find.Fields,
+	}
+	if plan.Warning != "" {
+		response["warning"] = plan.Warning
 	}
 
 	w.Header().Set("Content-Type", "application/json")
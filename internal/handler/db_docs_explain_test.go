@@ -1,12 +1,15 @@
 package handler
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
 
+	"github.com/goydb/goydb/pkg/model"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -80,3 +83,111 @@ func TestExplain_DBNotFound(t *testing.T) {
 
 	assert.Equal(t, http.StatusNotFound, w.Code)
 }
+
+func TestExplain_CostBasedCandidates(t *testing.T) {
+	s, router, cleanup := setupRevsDiffTest(t)
+	defer cleanup()
+
+	ctx := t.Context()
+	db, err := s.CreateDatabase(ctx, "testdb")
+	require.NoError(t, err)
+
+	for _, idx := range []map[string]interface{}{
+		{"index": map[string]interface{}{"fields": []string{"type"}}, "ddoc": "mango", "name": "by_type"},
+		{"index": map[string]interface{}{"fields": []string{"type", "color"}}, "ddoc": "mango", "name": "by_type_color"},
+		{"index": map[string]interface{}{"fields": []string{"size"}}, "ddoc": "mango", "name": "by_size"},
+	} {
+		body, _ := json.Marshal(idx)
+		req := httptest.NewRequest("POST", "/testdb/_index", bytes.NewReader(body))
+		req.SetBasicAuth("admin", "secret")
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	colors := []string{"red", "green", "blue", "black"}
+	for i := 0; i < 20; i++ {
+		_, err = db.PutDocument(ctx, &model.Document{
+			ID:   fmt.Sprintf("doc%02d", i),
+			Data: map[string]interface{}{"type": "widget", "color": colors[i%len(colors)]},
+		})
+		require.NoError(t, err)
+	}
+
+	explain := func() map[string]interface{} {
+		body := strings.NewReader(`{"selector":{"type":"widget","color":"red"}}`)
+		req := httptest.NewRequest("POST", "/testdb/_explain", body)
+		req.SetBasicAuth("admin", "secret")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		var result map[string]interface{}
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&result))
+		return result
+	}
+
+	result := explain()
+	idx := result["index"].(map[string]interface{})
+	assert.Equal(t, "by_type_color", idx["name"])
+	assert.Equal(t, "json", idx["type"])
+	assert.Equal(t, float64(5), result["estimated_rows"])
+
+	reasons := map[string]string{}
+	for _, c := range result["index_candidates"].([]interface{}) {
+		cand := c.(map[string]interface{})
+		name := cand["index"].(map[string]interface{})["name"].(string)
+		analysis := cand["analysis"].(map[string]interface{})
+		rs := analysis["reasons"].([]interface{})
+		require.NotEmpty(t, rs)
+		reasons[name] = rs[0].(map[string]interface{})["name"].(string)
+	}
+	assert.Equal(t, map[string]string{
+		"by_type":   "higher_cost",
+		"_all_docs": "unfavored_type",
+		"by_size":   "field_mismatch",
+	}, reasons)
+
+	// the plan is deterministic
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, "by_type_color", explain()["index"].(map[string]interface{})["name"])
+	}
+}
+
+func TestExplain_UseIndexWarning(t *testing.T) {
+	s, router, cleanup := setupRevsDiffTest(t)
+	defer cleanup()
+
+	ctx := t.Context()
+	db, err := s.CreateDatabase(ctx, "testdb")
+	require.NoError(t, err)
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"index": map[string]interface{}{"fields": []string{"type"}}, "ddoc": "mango", "name": "by_type",
+	})
+	req := httptest.NewRequest("POST", "/testdb/_index", bytes.NewReader(body))
+	req.SetBasicAuth("admin", "secret")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	_, err = db.PutDocument(ctx, &model.Document{ID: "doc1", Data: map[string]interface{}{"type": "widget", "n": 1}})
+	require.NoError(t, err)
+
+	for _, path := range []string{"/testdb/_explain", "/testdb/_find"} {
+		req := httptest.NewRequest("POST", path, strings.NewReader(`{"selector":{"type":"widget"},"use_index":["mango","by_n"]}`))
+		req.SetBasicAuth("admin", "secret")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var result map[string]interface{}
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&result))
+		assert.Equal(t, "_design/mango, by_n was not used because it does not contain a valid index for this query.", result["warning"], path)
+	}
+
+	// an honored hint produces no warning
+	req = httptest.NewRequest("POST", "/testdb/_explain", strings.NewReader(`{"selector":{"type":"widget"},"use_index":"mango"}`))
+	req.SetBasicAuth("admin", "secret")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var result map[string]interface{}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&result))
+	assert.Nil(t, result["warning"])
+	assert.Equal(t, "by_type", result["index"].(map[string]interface{})["name"])
+}
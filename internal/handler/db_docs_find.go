@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/goydb/goydb/internal/service"
 	"github.com/goydb/goydb/pkg/model"
 )
 
@@ -36,11 +37,41 @@ func (s *DBDocsFind) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		find.Limit = 25
 	}
 
-	docs, stats, err := db.FindDocs(r.Context(), find)
-	if err != nil {
-		s.Logger.Warnf(r.Context(), "failed to find docs", "error", err)
-		WriteError(w, http.StatusBadRequest, err.Error())
-		return
+	// serve from the result cache, unless the execution stats are requested
+	var docs []*model.Document
+	var stats *model.ExecutionStats
+	var token *service.FindCacheToken
+	cache := findCache(s.Config, s.FindCache)
+	cached := false
+	if cache != nil && !find.ExecutionStats {
+		docs, token, cached = cache.Get(r.Context(), db, find)
+	}
+
+	if !cached {
+		var err error
+		docs, stats, err = db.FindDocs(r.Context(), find)
+		if err != nil {
+			s.Logger.Warnf(r.Context(), "failed to find docs", "error", err)
+			WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if token != nil {
+			cache.Put(token, docs)
+		}
+	}
+
+	// report if the use_index hint couldn't be honored, cached
+	// results weren't planned by this request
+	var warning string
+	if stats != nil && stats.Plan != nil {
+		warning = stats.Plan.Warning
+	} else if cached && find.UseIndex != nil {
+		plan, err := db.ExplainFind(r.Context(), find)
+		if err != nil {
+			s.Logger.Warnf(r.Context(), "failed to explain find", "error", err)
+		} else {
+			warning = plan.Warning
+		}
 	}
 
 	// bookmark is simply the last document in the list
@@ -53,6 +84,7 @@ func (s *DBDocsFind) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		ExecutionStats: stats,
 		Docs:           make([]map[string]interface{}, len(docs)),
 		Bookmark:       bookmark,
+		Warning:        warning,
 	}
 	if !find.ExecutionStats {
 		response.ExecutionStats = nil
@@ -86,16 +118,18 @@ type FindResponse struct {
 
 // projectFields returns a new map containing only the requested fields.
 // _id and _rev are always included. Nested fields (e.g. "author.name") are
-// resolved one level deep and merged into a sub-map.
+// resolved and merged into a sub-map.
 func projectFields(doc map[string]interface{}, fields []string) map[string]interface{} {
-	out := map[string]interface{}{
-		"_id":  doc["_id"],
-		"_rev": doc["_rev"],
-	}
+	out := pickFields(doc, fields)
+	out["_id"] = doc["_id"]
+	out["_rev"] = doc["_rev"]
+	return out
+}
+
+// pickFields returns a new map containing only the requested fields.
+func pickFields(doc map[string]interface{}, fields []string) map[string]interface{} {
+	out := make(map[string]interface{})
 	for _, f := range fields {
-		if f == "_id" || f == "_rev" {
-			continue
-		}
 		parts := strings.SplitN(f, ".", 2)
 		if len(parts) == 1 {
 			if v, ok := doc[f]; ok {
@@ -103,9 +137,7 @@ func projectFields(doc map[string]interface{}, fields []string) map[string]inter
 			}
 		} else {
 			if sub, ok := doc[parts[0]].(map[string]interface{}); ok {
-				nested := projectFields(sub, []string{parts[1]})
-				delete(nested, "_id")
-				delete(nested, "_rev")
+				nested := pickFields(sub, []string{parts[1]})
 				if existing, ok := out[parts[0]].(map[string]interface{}); ok {
 					for k, v := range nested {
 						existing[k] = v
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/goydb/goydb/pkg/model"
 	"github.com/stretchr/testify/assert"
@@ -279,3 +280,50 @@ func TestFind_NoopParams(t *testing.T) {
 	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
 	assert.Len(t, resp.Docs, 1)
 }
+
+func TestFind_ResultCache(t *testing.T) {
+	s, router, cleanup := setupRevsDiffTest(t)
+	defer cleanup()
+
+	ctx := t.Context()
+	db, err := s.CreateDatabase(ctx, "testdb")
+	require.NoError(t, err)
+	_, err = db.PutDocument(ctx, &model.Document{ID: "a", Data: map[string]interface{}{"type": "user"}})
+	require.NoError(t, err)
+	time.Sleep(50 * time.Millisecond) // let the change notification pass
+
+	req := httptest.NewRequest("PUT", "/_config/find_cache/enable", bytes.NewBufferString(`"true"`))
+	req.SetBasicAuth("admin", "secret")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	find := func() []map[string]interface{} {
+		req := httptest.NewRequest("POST", "/testdb/_find", bytes.NewBufferString(`{"selector":{"type":"user"}}`))
+		req.SetBasicAuth("admin", "secret")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		var resp FindResponse
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+		return resp.Docs
+	}
+
+	assert.Len(t, find(), 1)
+	assert.Len(t, find(), 1)
+
+	_, err = db.PutDocument(ctx, &model.Document{ID: "b", Data: map[string]interface{}{"type": "user"}})
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool { return len(find()) == 2 }, time.Second, 10*time.Millisecond)
+
+	req = httptest.NewRequest("GET", "/_node/_local/_stats", nil)
+	req.SetBasicAuth("admin", "secret")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var stats map[string]map[string]map[string]interface{}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&stats))
+	assert.GreaterOrEqual(t, stats["mango"]["result_cache_hits"]["value"], float64(1))
+	assert.GreaterOrEqual(t, stats["mango"]["result_cache_misses"]["value"], float64(2))
+}
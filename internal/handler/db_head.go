@@ -1,8 +1,10 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 
+	"github.com/goydb/goydb/internal/adapter/storage"
 )
 
 type DBHead struct {
@@ -14,6 +16,10 @@ func (s *DBHead) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	dbName := pathVar(r, "db")
 	_, err := s.Storage.Database(r.Context(), dbName)
+	if errors.Is(err, storage.ErrQuarantinedDatabase) {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
 	if err != nil {
 		w.WriteHeader(http.StatusNotFound)
 		return
@@ -37,8 +37,28 @@ func (s *DBIndexInfo) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	response.ViewIndex.Language = doc.Language()
 
 	err = db.Transaction(r.Context(), func(tx port.DatabaseTx) error {
+		// indices of the design document that are building or async
+		// report their checkpoint and the changes they didn't process
+		current := tx.Sequence([]byte("_changes"))
+		updateSeq := current
 		for _, fn := range doc.Functions() {
-			idx, ok := db.Indices()[fn.DesignDocFn().String()]
+			name := fn.DesignDocFn().String()
+			if seq, ok := tx.IndexSeq(name); ok && seq < current {
+				response.ViewIndex.UpdaterRunning = true
+				if seq < updateSeq {
+					updateSeq = seq
+				}
+				pending := uint64(tx.CountChangesSince(seq))
+				if pending > response.ViewIndex.UpdatesPending.Total {
+					response.ViewIndex.UpdatesPending = UpdatesPending{
+						Minimum:   pending,
+						Preferred: pending,
+						Total:     pending,
+					}
+				}
+			}
+
+			idx, ok := db.Indices()[name]
 			if ok {
 				stat, err := idx.Stats(r.Context(), tx)
 				if err != nil {
@@ -48,6 +68,7 @@ func (s *DBIndexInfo) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 				response.ViewIndex.Sizes.External += stat.Used
 			}
 		}
+		response.ViewIndex.UpdateSeq = int(updateSeq)
 		return nil
 	})
 	if err != nil {
@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/goydb/goydb/pkg/model"
+)
+
+type DBIndexModeGet struct{ Base }
+type DBIndexModePut struct{ Base }
+
+func (s *DBIndexModeGet) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	db := Database{Base: s.Base}.Do(w, r)
+	if db == nil {
+		return
+	}
+	if _, ok := (Authenticator{Base: s.Base}.DB(w, r, db)); !ok {
+		return
+	}
+
+	mode, err := db.GetIndexMode(r.Context())
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(mode) //nolint:errcheck
+}
+
+func (s *DBIndexModePut) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close() //nolint:errcheck
+
+	db := Database{Base: s.Base}.Do(w, r)
+	if db == nil {
+		return
+	}
+	if _, ok := (Authenticator{Base: s.Base, RequiresAdmin: true}.DB(w, r, db)); !ok {
+		return
+	}
+
+	var mode string
+	if err := json.NewDecoder(r.Body).Decode(&mode); err != nil ||
+		(mode != model.IndexModeSync && mode != model.IndexModeAsync) {
+		WriteError(w, http.StatusBadRequest, "invalid index_mode")
+		return
+	}
+	if err := db.SetIndexMode(r.Context(), mode); err != nil {
+		WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"ok":true}`)) //nolint:errcheck
+}
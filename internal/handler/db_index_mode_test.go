@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/goydb/goydb/internal/adapter/logger"
+	"github.com/goydb/goydb/internal/adapter/view/queryview"
+	"github.com/goydb/goydb/internal/controller"
+	"github.com/goydb/goydb/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndexMode(t *testing.T) {
+	ctx := context.Background()
+	s, router := setupQueryViewTest(t)
+	db, err := s.CreateDatabase(ctx, "testdb")
+	require.NoError(t, err)
+	_, err = db.PutDocument(ctx, &model.Document{
+		ID: "_design/app",
+		Data: map[string]interface{}{
+			"language": queryview.Language,
+			"views": map[string]interface{}{
+				"by_n": map[string]interface{}{"map": map[string]interface{}{"fields": []string{"n"}}},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	do := func(method, path, body string) (int, interface{}) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.SetBasicAuth("admin", "secret")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var result interface{}
+		_ = json.NewDecoder(w.Body).Decode(&result)
+		return w.Code, result
+	}
+	put := func(id string, n int) {
+		_, err := db.PutDocument(ctx, &model.Document{ID: id, Data: map[string]interface{}{"n": n}})
+		require.NoError(t, err)
+	}
+	staleRows := func() int {
+		code, res := do("GET", "/testdb/_design/app/_view/by_n?update=false", "")
+		require.Equal(t, http.StatusOK, code)
+		return len(res.(map[string]interface{})["rows"].([]interface{}))
+	}
+
+	code, res := do("GET", "/testdb/_index_mode", "")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, model.IndexModeSync, res)
+	code, _ = do("PUT", "/testdb/_index_mode", `"later"`)
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	put("doc1", 1)
+	assert.Equal(t, 1, staleRows())
+
+	// writes only record the change for async indices
+	code, _ = do("PUT", "/testdb/_index_mode", `"async"`)
+	require.Equal(t, http.StatusOK, code)
+	_, res = do("GET", "/testdb/_index_mode", "")
+	assert.Equal(t, model.IndexModeAsync, res)
+	put("doc2", 2)
+	assert.Equal(t, 1, staleRows())
+	_, res = do("GET", "/testdb/_design/app/_info", "")
+	assert.Equal(t, true, res.(map[string]interface{})["view_index"].(map[string]interface{})["updater_running"])
+
+	tc := controller.Task{Storage: s, Logger: logger.NewNoLog()}
+	require.NoError(t, tc.ProcessTasksForDatabase(ctx, db))
+	assert.Equal(t, 2, staleRows())
+	_, res = do("GET", "/testdb/_design/app/_info", "")
+	assert.Equal(t, false, res.(map[string]interface{})["view_index"].(map[string]interface{})["updater_running"])
+
+	// the design document option overrides the database mode
+	_, err = db.PutDocument(ctx, &model.Document{
+		ID: "_design/sync",
+		Data: map[string]interface{}{
+			"language": queryview.Language,
+			"options":  map[string]interface{}{"index_mode": model.IndexModeSync},
+			"views": map[string]interface{}{
+				"by_n": map[string]interface{}{"map": map[string]interface{}{"fields": []string{"n"}}},
+			},
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, tc.ProcessTasksForDatabase(ctx, db))
+	put("doc3", 3)
+	code, res = do("GET", "/testdb/_design/sync/_view/by_n?update=false", "")
+	require.Equal(t, http.StatusOK, code)
+	assert.Len(t, res.(map[string]interface{})["rows"], 3)
+	assert.Equal(t, 2, staleRows())
+
+	// back to sync the index catches up and is updated with every write
+	code, _ = do("PUT", "/testdb/_index_mode", `"sync"`)
+	require.Equal(t, http.StatusOK, code)
+	require.NoError(t, tc.ProcessTasksForDatabase(ctx, db))
+	put("doc4", 4)
+	assert.Equal(t, 4, staleRows())
+}
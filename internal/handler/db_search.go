@@ -6,7 +6,6 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/url"
-	"time"
 
 	"github.com/goydb/goydb/pkg/model"
 	"github.com/goydb/goydb/pkg/port"
@@ -49,20 +48,15 @@ func (s *DBSearch) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	sq := buildSearchQuery(opts)
 
-	// stale handling: if stale != "ok", wait for pending index tasks.
-	if sq.Stale != "ok" {
-		for {
-			n, err := db.TaskCount(r.Context())
-			if err != nil {
-				s.Logger.Errorf(r.Context(), "failed to get task count", "error", err)
-				WriteError(w, http.StatusInternalServerError, err.Error())
-				return
-			}
-			if n == 0 {
-				break
-			}
-			time.Sleep(time.Second)
-		}
+	// stale handling: if stale != "ok", wait for the index
+	update := updateMode(opts)
+	if sq.Stale == "ok" {
+		update = "false"
+	}
+	if err := waitForIndex(r.Context(), db, ddfn.String(), update); err != nil {
+		s.Logger.Errorf(r.Context(), "failed to wait for index", "error", err)
+		WriteError(w, http.StatusInternalServerError, err.Error())
+		return
 	}
 
 	sr, err := db.SearchDocuments(r.Context(), &ddfn, sq)
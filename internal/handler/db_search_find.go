@@ -0,0 +1,108 @@
+//go:build !nosearch
+
+package handler
+
+import (
+	"net/http"
+
+	"github.com/goydb/goydb/internal/controller"
+	"github.com/goydb/goydb/pkg/model"
+	"github.com/goydb/goydb/pkg/port"
+)
+
+// searchFindBatchSize is the number of search hits
+// fetched from the index at once
+const searchFindBatchSize = 200
+
+// DBSearchFind handles POST /{db}/_design/{ddoc}/_search/{index}/_find.
+// It applies a Mango selector, sort and fields projection to the hits
+// of a search query. This is a goydb extension.
+type DBSearchFind struct {
+	Base
+}
+
+func (s *DBSearchFind) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close() //nolint:errcheck
+
+	db := Database{Base: s.Base}.Do(w, r)
+	if db == nil {
+		return
+	}
+
+	if _, ok := (Authenticator{Base: s.Base}.DB(w, r, db)); !ok {
+		return
+	}
+
+	ddfn := model.DesignDocFn{
+		Type:        model.SearchFn,
+		DesignDocID: string(model.DesignDocPrefix) + pathVar(r, "docid"),
+		FnName:      pathVar(r, "index"),
+	}
+	if _, ok := db.Indices()[ddfn.String()]; !ok {
+		WriteError(w, http.StatusNotFound, "index not found")
+		return
+	}
+
+	find, ok := decodeIndexFindQuery(w, r)
+	if !ok {
+		return
+	}
+	if find.Query == "" {
+		find.Query = "*:*"
+	}
+
+	if err := waitForIndex(r.Context(), db, ddfn.String(), string(find.Update)); err != nil {
+		s.Logger.Errorf(r.Context(), "failed to wait for index", "error", err)
+		WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	ms := controller.NewMangoSearch(find.FindQuery, nil)
+	includeDocs := find.IncludeDocs || ms.NeedsDocs()
+
+	sq := &port.SearchQuery{
+		Query: find.Query,
+		Limit: searchFindBatchSize,
+	}
+	for {
+		sr, err := db.SearchDocuments(r.Context(), &ddfn, sq)
+		if err != nil {
+			WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		docs := make([]*model.Document, len(sr.Records))
+		for i, rec := range sr.Records {
+			docs[i] = &model.Document{ID: rec.ID}
+		}
+		if includeDocs && len(docs) > 0 {
+			if err := db.EnrichDocuments(r.Context(), docs); err != nil {
+				WriteError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+		}
+
+		var done bool
+		for i, rec := range sr.Records {
+			var doc *model.Document
+			if includeDocs && docs[i].Data != nil {
+				doc = docs[i]
+			}
+			done, err = ms.Add(controller.SearchRow(rec, doc))
+			if err != nil {
+				WriteError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			if done {
+				break
+			}
+		}
+
+		if done || sr.Bookmark == "" || len(sr.Records) == 0 {
+			break
+		}
+		sq.Bookmark = sr.Bookmark
+	}
+
+	writeIndexFindResponse(w, find, ms)
+}
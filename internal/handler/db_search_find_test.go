@@ -0,0 +1,38 @@
+//go:build !nosearch && !nogoja
+
+package handler
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchFind_FiltersHits(t *testing.T) {
+	s, router, cleanup := setupViewTest(t)
+	defer cleanup()
+
+	setupSearchDB(t, s, router, "testdb")
+
+	result, code := postIndexFind(t, router, "/testdb/_design/myidx/_search/search/_find", map[string]interface{}{
+		"query":    "type:person",
+		"selector": map[string]interface{}{"doc.age": map[string]interface{}{"$lt": 30}},
+		"sort":     []interface{}{"doc.age"},
+		"fields":   []string{"doc.name"},
+	})
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, result.Rows, 2)
+	assert.Equal(t, map[string]interface{}{"id": "doc2", "doc": map[string]interface{}{"name": "bob"}}, result.Rows[0])
+	assert.Equal(t, map[string]interface{}{"id": "doc5", "doc": map[string]interface{}{"name": "eve"}}, result.Rows[1])
+
+	// stored fields can be matched without loading the documents
+	result, code = postIndexFind(t, router, "/testdb/_design/myidx/_search/search/_find", map[string]interface{}{
+		"selector": map[string]interface{}{"fields.type": "admin"},
+	})
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, result.Rows, 1)
+	assert.Equal(t, "doc4", result.Rows[0]["id"])
+	assert.Nil(t, result.Rows[0]["doc"])
+}
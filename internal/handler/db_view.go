@@ -2,13 +2,14 @@ package handler
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math"
 	"net/http"
 	"net/url"
 	"sort"
-	"time"
 
 	"github.com/fxamacker/cbor/v2"
 	"github.com/goydb/goydb/internal/controller"
@@ -187,78 +188,170 @@ func (s *DBView) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// Merge URL query params with JSON body (URL params take precedence).
 	options := mergeBodyIntoOptions(r, r.URL.Query())
 
-	var update string
-	if len(options["update"]) > 0 {
-		update = options["update"][0]
+	var parseErr *queryParseError
+	result, err := runViewQuery(r.Context(), db, idx, ddfn, options)
+	if errors.As(err, &parseErr) {
+		WriteNamedError(w, http.StatusBadRequest, "query_parse_error", parseErr.Error())
+		return
+	}
+	var rows []Rows
+	if err == nil {
+		rows, err = result.all(r.Context())
+	}
+	if err != nil {
+		s.Logger.Errorf(r.Context(), "failed to query view", "error", err)
+		WriteError(w, http.StatusInternalServerError, err.Error())
+		return
 	}
 
-	// stale=ok or stale=update_after → skip the view update wait.
-	if stale := options.Get("stale"); stale == "ok" || stale == "update_after" {
-		update = "false"
+	response := buildViewResponse(rows, result.total, result.q, db, r)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response) // nolint: errcheck
+}
+
+// viewResult is the result of a view query. The index entries are
+// sorted by view collation, they are read from the index and the
+// documents of include_docs are loaded as the rows are read.
+type viewResult struct {
+	db    port.Database
+	q     port.AllDocsQuery
+	total int
+	rows  []Rows    // rows of a reduce query
+	docs  *viewRows // index entries of a map query
+}
+
+// next returns the next row, nil if all rows were read
+func (vr *viewResult) next(ctx context.Context) (*Rows, error) {
+	if len(vr.rows) > 0 {
+		row := vr.rows[0]
+		vr.rows = vr.rows[1:]
+		return &row, nil
+	}
+	if vr.docs == nil {
+		return nil, nil
 	}
+	doc, err := vr.docs.next(ctx)
+	if doc == nil || err != nil {
+		return nil, err
+	}
+	if vr.q.IncludeDocs {
+		if err := vr.db.EnrichDocuments(ctx, []*model.Document{doc}); err != nil {
+			return nil, err
+		}
+	}
+	row := vr.docRow(doc)
+	return &row, nil
+}
 
-	switch update {
-	case "", "true":
-		// wait for all view updates to take place
-		for {
-			n, err := db.TaskCount(r.Context())
-			if err != nil {
-				s.Logger.Errorf(r.Context(), "failed to get task count", "error", err)
-				WriteError(w, http.StatusInternalServerError, err.Error())
-				return
-			}
-			if n == 0 {
-				break
-			}
-			time.Sleep(time.Second)
+// all returns the remaining rows, the index entries are read in one
+// transaction and the documents are loaded at once
+func (vr *viewResult) all(ctx context.Context) ([]Rows, error) {
+	rows := vr.rows
+	vr.rows = nil
+	if vr.docs == nil {
+		return rows, nil
+	}
+
+	vr.docs.batch = math.MaxInt
+	var docs []*model.Document
+	for {
+		doc, err := vr.docs.next(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if doc == nil {
+			break
 		}
-	/*case "lazy":
-	err = db.AddTasks(r.Context(), []*model.Task{
-		&model.Task{
-			Action:    model.ActionUpdateView,
-			DBName:    db.Name(),
-			ViewDocID: docID,
-		},
-	})*/
-	case "false": // do nothing
+		docs = append(docs, doc)
+	}
+	vr.docs = nil
+	if len(docs) == 0 {
+		return rows, nil
+	}
+	if vr.q.IncludeDocs {
+		if err := vr.db.EnrichDocuments(ctx, docs); err != nil {
+			return nil, err
+		}
+	}
+	rows = make([]Rows, len(docs))
+	for i, doc := range docs {
+		rows[i] = vr.docRow(doc)
+	}
+	return rows, nil
+}
+
+func (vr *viewResult) docRow(doc *model.Document) Rows {
+	row := Rows{
+		ID:    doc.ID,
+		Key:   doc.Key,
+		Value: doc.Value,
+	}
+	if vr.q.IncludeDocs && doc.Data != nil {
+		row.Doc = doc.Data
+		row.Doc["_id"] = doc.ID
+		row.Doc["_rev"] = doc.Rev
+		if doc.Deleted {
+			row.Doc["_deleted"] = doc.Deleted
+		}
+	}
+	return row
+}
+
+// runViewQuery runs the query described by the options on the view index.
+// Invalid options are reported as queryParseError.
+func runViewQuery(ctx context.Context, db port.Database, idx port.DocumentIndex, ddfn model.DesignDocFn, options url.Values) (*viewResult, error) {
+	if err := validateViewQueryOptions(options); err != nil {
+		return nil, err
 	}
 
 	var q port.AllDocsQuery
-	parseViewQueryOptions(&q, options)
-	q.DDFN = &model.DesignDocFn{
-		Type:        model.ViewFn,
-		DesignDocID: docID,
-		FnName:      viewName,
+	if err := waitForIndex(ctx, db, ddfn.String(), updateMode(options)); err != nil {
+		return nil, err
 	}
 
+	parseViewQueryOptions(&q, options)
+	q.DDFN = &ddfn
+
+	var rows []Rows
+	var entries *viewRows
 	var total int
 	var err error
 
-	if boolOption("reduce", true, options) {
-		var docs map[interface{}]interface{}
-		err = db.Transaction(r.Context(), func(tx port.DatabaseTx) error {
-			designDoc, err := tx.GetDocument(r.Context(), docID)
-			if err != nil {
-				return err
-			}
-
-			view, ok := designDoc.View(ddfn.FnName)
-			if ok {
-				docs, total, err = controller.DesignDoc{
-					DB: db,
-				}.ReduceDocs(r.Context(), tx, idx, q, view)
-			} else {
-				err = fmt.Errorf("unknown view function name: %q", ddfn.FnName)
-			}
+	var designDoc *model.Document
+	var view *model.View
+	err = db.Transaction(ctx, func(tx port.DatabaseTx) error {
+		var err error
+		designDoc, err = tx.GetDocument(ctx, ddfn.DesignDocID)
+		if err != nil {
+			return err
+		}
+		var ok bool
+		view, ok = designDoc.View(ddfn.FnName)
+		if !ok {
+			return fmt.Errorf("unknown view function name: %q", ddfn.FnName)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
 
+	reduce := boolOption("reduce", true, options)
+	if reduce && view.ReduceFn != "" {
+		var docs map[interface{}]interface{}
+		err = db.Transaction(ctx, func(tx port.DatabaseTx) error {
+			var err error
+			docs, total, err = controller.DesignDoc{
+				DB: db,
+			}.ReduceDocs(ctx, tx, idx, q, designDoc, view)
 			return err
 		})
 		if err != nil {
-			WriteError(w, http.StatusInternalServerError, err.Error())
-			return
+			return nil, err
 		}
 
-		rows := make([]Rows, 0, len(docs))
+		rows = make([]Rows, 0, len(docs))
 		var noneReducerDocs []*model.Document // docs from None reducer for include_docs
 		for key, value := range docs {
 			var row Rows
@@ -278,9 +371,8 @@ func (s *DBView) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 		// Enrich and attach docs for include_docs=true (None reducer path).
 		if q.IncludeDocs && len(noneReducerDocs) > 0 {
-			if enrichErr := db.EnrichDocuments(r.Context(), noneReducerDocs); enrichErr != nil {
-				WriteError(w, http.StatusInternalServerError, enrichErr.Error())
-				return
+			if enrichErr := db.EnrichDocuments(ctx, noneReducerDocs); enrichErr != nil {
+				return nil, enrichErr
 			}
 			enriched := make(map[string]*model.Document, len(noneReducerDocs))
 			for _, d := range noneReducerDocs {
@@ -313,141 +405,25 @@ func (s *DBView) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 				return model.ViewKeyCmp(rows[i].Key, rows[j].Key) < 0
 			})
 		}
-
-		response := buildViewResponse(rows, total, q, db, r)
-
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(response) // nolint: errcheck
 	} else {
-		var docList []*model.Document
-
-		if len(q.ViewKeys) > 0 {
-			// Multi-key lookup: iterate for each key independently.
-			err = db.Transaction(r.Context(), func(tx port.DatabaseTx) error {
-				for _, k := range q.ViewKeys {
-					sk, ek := cborKeyRange(k)
-					if sk == nil {
-						continue
-					}
-					iter, iterErr := db.IndexIterator(r.Context(), tx, idx)
-					if iterErr != nil {
-						return iterErr
-					}
-					iter.SetStartKey(sk)
-					iter.SetEndKey(ek)
-					iter.SetExclusiveEnd(false)
-					for doc := iter.First(); iter.Continue(); doc = iter.Next() {
-						docList = append(docList, doc)
-					}
-				}
-				total = len(docList) // total = total results for key lookup
-				return nil
-			})
-		} else {
-			err = db.Transaction(r.Context(), func(tx port.DatabaseTx) error {
-				iter, iterErr := db.IndexIterator(r.Context(), tx, idx)
-				if iterErr != nil {
-					return iterErr
-				}
-
-				// Do NOT set iterator bounds (SetStartKey/SetEndKey/SetDescending/
-				// SetSkip/SetLimit) — CBOR byte ordering diverges from CouchDB
-				// collation for strings of different lengths.  Instead, iterate
-				// the full index and rely on the semantic post-filter below.
-				// Skip and limit are applied after the sort step.
-				total = iter.Total()
-				for doc := iter.First(); iter.Continue(); doc = iter.Next() {
-					// Semantic post-filter: use ViewKeyCmp for CouchDB-correct ordering.
-					if q.ViewDescending {
-						if q.ViewDecodedStartKey != nil && model.ViewKeyCmp(doc.Key, q.ViewDecodedStartKey) > 0 {
-							continue
-						}
-						if q.ViewDecodedEndKey != nil {
-							cmp := model.ViewKeyCmp(doc.Key, q.ViewDecodedEndKey)
-							if q.ViewExclusiveEnd && cmp <= 0 {
-								continue
-							} else if !q.ViewExclusiveEnd && cmp < 0 {
-								continue
-							}
-						}
-					} else {
-						if q.ViewDecodedStartKey != nil && model.ViewKeyCmp(doc.Key, q.ViewDecodedStartKey) < 0 {
-							continue
-						}
-						if q.ViewDecodedEndKey != nil {
-							cmp := model.ViewKeyCmp(doc.Key, q.ViewDecodedEndKey)
-							if q.ViewExclusiveEnd && cmp >= 0 {
-								continue
-							} else if !q.ViewExclusiveEnd && cmp > 0 {
-								continue
-							}
-						}
-					}
-					if q.StartKeyDocID != "" && doc.ID < q.StartKeyDocID {
-						continue
-					}
-					if q.EndKeyDocID != "" && doc.ID > q.EndKeyDocID {
-						continue
-					}
-					docList = append(docList, doc)
-				}
-
-				return iterErr
-			})
-		}
-
-		// Enrich documents with full data if include_docs=true
-		if err == nil && q.IncludeDocs && len(docList) > 0 {
-			err = db.EnrichDocuments(r.Context(), docList)
+		// the rows of map-only views aren't limited by default
+		if reduce && options.Get("limit") == "" {
+			q.Limit = 0
 		}
+		// the rows are streamed from the index
+		entries, total, err = newViewRows(ctx, db, idx, q)
 		if err != nil {
-			WriteError(w, http.StatusInternalServerError, err.Error())
-			return
-		}
-
-		// Sort result rows.
-		if q.ViewDescending && len(q.ViewKeys) == 0 {
-			sort.Slice(docList, func(i, j int) bool {
-				return model.ViewKeyCmp(docList[i].Key, docList[j].Key) > 0
-			})
-		} else {
-			sort.Slice(docList, func(i, j int) bool {
-				return model.ViewKeyCmp(docList[i].Key, docList[j].Key) < 0
-			})
-		}
-
-		// Apply skip and limit after sort so they operate on correctly ordered results.
-		if q.Skip > 0 {
-			if int(q.Skip) >= len(docList) {
-				docList = nil
-			} else {
-				docList = docList[q.Skip:]
-			}
-		}
-		if q.Limit > 0 && int(q.Limit) < len(docList) {
-			docList = docList[:q.Limit]
+			return nil, err
 		}
-
-		rows := make([]Rows, len(docList))
-		for i, doc := range docList {
-			rows[i].ID = doc.ID
-			rows[i].Key = doc.Key
-			rows[i].Value = doc.Value
-			if q.IncludeDocs && doc.Data != nil {
-				rows[i].Doc = doc.Data
-				rows[i].Doc["_id"] = doc.ID
-				rows[i].Doc["_rev"] = doc.Rev
-				if doc.Deleted {
-					rows[i].Doc["_deleted"] = doc.Deleted
-				}
-			}
-		}
-
-		response := buildViewResponse(rows, total, q, db, r)
-
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(response) // nolint: errcheck
 	}
+
+	return &viewResult{
+		db:    db,
+		q:     q,
+		total: total,
+		rows:  rows,
+		docs:  entries,
+	}, nil
 }
 
 // buildViewResponse constructs a ViewResponse honoring sorted and update_seq flags.
@@ -461,16 +437,10 @@ func buildViewResponse(rows []Rows, total int, q port.AllDocsQuery, db port.Data
 		response.Offset = &offset
 	}
 	if q.ViewUpdateSeq {
-		// The update_seq is the current sequence of the _changes bucket, which
-		// is incremented each time a document is modified (via PutWithSequence).
-		// db.Sequence() reads the docs bucket sequence (always 0); instead we
-		// read directly from the _changes bucket inside a read transaction.
-		var updateSeq int64
-		_ = db.Transaction(r.Context(), func(tx port.DatabaseTx) error {
-			updateSeq = int64(tx.Sequence([]byte("_changes")))
-			return nil
-		})
-		response.UpdateSeq = updateSeq
+		// The update_seq is the sequence of the database changes the
+		// index processed, a stale index reports its checkpoint.
+		seq, _, _ := db.IndexSeq(r.Context(), q.DDFN.String())
+		response.UpdateSeq = int64(seq)
 	}
 	return response
 }
@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/goydb/goydb/internal/adapter/logger"
+	"github.com/goydb/goydb/internal/adapter/storage"
+	"github.com/goydb/goydb/internal/adapter/view/queryview"
+	"github.com/goydb/goydb/internal/controller"
+	"github.com/goydb/goydb/pkg/model"
+	"github.com/goydb/goydb/pkg/port"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestViewCleanup(t *testing.T) {
+	ctx := context.Background()
+	s, router := setupQueryViewTest(t)
+	db, err := s.CreateDatabase(ctx, "testdb")
+	require.NoError(t, err)
+	_, err = db.PutDocument(ctx, &model.Document{ID: "doc1", Data: map[string]interface{}{"n": 1, "m": 2}})
+	require.NoError(t, err)
+
+	ddoc := func(view, field string) map[string]interface{} {
+		return map[string]interface{}{
+			"language": queryview.Language,
+			"views": map[string]interface{}{
+				view: map[string]interface{}{"map": map[string]interface{}{"fields": []string{field}}},
+			},
+		}
+	}
+	rev, err := db.PutDocument(ctx, &model.Document{ID: "_design/app", Data: ddoc("by_n", "n")})
+	require.NoError(t, err)
+	tc := controller.Task{Storage: s, Logger: logger.NewNoLog()}
+	require.NoError(t, tc.ProcessTasksForDatabase(ctx, db))
+
+	buckets := func() map[string]bool {
+		names := make(map[string]bool)
+		err := db.Transaction(ctx, func(tx port.DatabaseTx) error {
+			for _, name := range tx.Buckets() {
+				names[string(name)] = true
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		return names
+	}
+	cleanup := func() int64 {
+		req := httptest.NewRequest("POST", "/testdb/_view_cleanup", nil)
+		req.SetBasicAuth("admin", "secret")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusAccepted, w.Code)
+		var result map[string]interface{}
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&result))
+		assert.Equal(t, true, result["ok"])
+		return int64(result["bytes_freed"].(float64))
+	}
+	byN := model.DesignDocFn{Type: model.ViewFn, DesignDocID: "_design/app", FnName: "by_n"}.String()
+	byM := model.DesignDocFn{Type: model.ViewFn, DesignDocID: "_design/app", FnName: "by_m"}.String()
+	assert.True(t, buckets()[byN])
+	assert.Zero(t, cleanup())
+
+	// the renamed view leaves the old index behind
+	rev, err = db.PutDocument(ctx, &model.Document{ID: "_design/app", Rev: rev, Data: ddoc("by_m", "m")})
+	require.NoError(t, err)
+	require.NoError(t, tc.ProcessTasksForDatabase(ctx, db))
+	assert.True(t, buckets()[byN])
+	assert.True(t, buckets()[byM])
+
+	// orphaned search index directory
+	dir := filepath.Join(s.Path(), "testdb.d", storage.SearchDir, "indexes:gone:idx.bleve")
+	require.NoError(t, os.MkdirAll(dir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "store"), make([]byte, 100), 0644))
+
+	assert.GreaterOrEqual(t, cleanup(), int64(100))
+	assert.False(t, buckets()[byN])
+	assert.False(t, buckets()[byN+":invalidation"])
+	assert.True(t, buckets()[byM])
+	assert.NotContains(t, db.Indices(), byN)
+	assert.NoDirExists(t, dir)
+
+	// design document IDs and view names can contain colons
+	_, err = db.PutDocument(ctx, &model.Document{ID: "_design/a:b", Data: ddoc("v:1", "n")})
+	require.NoError(t, err)
+	require.NoError(t, tc.ProcessTasksForDatabase(ctx, db))
+	colon := model.DesignDocFn{Type: model.ViewFn, DesignDocID: "_design/a:b", FnName: "v:1"}.String()
+	require.True(t, buckets()[colon])
+	assert.Zero(t, cleanup())
+	assert.True(t, buckets()[colon])
+	assert.True(t, buckets()[colon+":invalidation"])
+
+	// deleting the design document removes its indices in the background
+	_, err = db.DeleteDocument(ctx, "_design/app", rev)
+	require.NoError(t, err)
+	assert.True(t, buckets()[byM])
+	require.NoError(t, tc.ProcessTasksForDatabase(ctx, db))
+	assert.False(t, buckets()[byM])
+	assert.NotContains(t, db.Indices(), byM)
+	assert.True(t, buckets()[colon], "indices of other design documents are kept")
+	assert.Contains(t, db.Indices(), colon)
+}
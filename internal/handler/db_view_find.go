@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/goydb/goydb/internal/controller"
+	"github.com/goydb/goydb/pkg/model"
+	"github.com/goydb/goydb/pkg/port"
+)
+
+// IndexFindQuery is the request body for running a Mango query
+// over the rows of a view or the hits of a search index.
+type IndexFindQuery struct {
+	model.FindQuery
+	// IncludeDocs loads the document of every row, this happens
+	// automatically if the query references the doc field.
+	IncludeDocs bool `json:"include_docs"`
+	// Query is the lucene query used to search the index (search only).
+	Query string `json:"query"`
+}
+
+// IndexFindResponse is the response to an IndexFindQuery.
+type IndexFindResponse struct {
+	Rows           []map[string]interface{} `json:"rows"`
+	ExecutionStats *model.ExecutionStats    `json:"execution_stats,omitempty"`
+}
+
+// DBViewFind handles POST /{db}/_design/{ddoc}/_view/{view}/_find.
+// It applies a Mango selector, sort and fields projection to the
+// rows of a view. This is a goydb extension.
+type DBViewFind struct {
+	Base
+}
+
+func (s *DBViewFind) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close() //nolint:errcheck
+
+	db := Database{Base: s.Base}.Do(w, r)
+	if db == nil {
+		return
+	}
+
+	if _, ok := (Authenticator{Base: s.Base}.DB(w, r, db)); !ok {
+		return
+	}
+
+	ddfn := model.DesignDocFn{
+		Type:        model.ViewFn,
+		DesignDocID: string(model.DesignDocPrefix) + pathVar(r, "docid"),
+		FnName:      pathVar(r, "view"),
+	}
+	idx, ok := db.Indices()[ddfn.String()]
+	if !ok {
+		WriteError(w, http.StatusNotFound, "index not found")
+		return
+	}
+
+	find, ok := decodeIndexFindQuery(w, r)
+	if !ok {
+		return
+	}
+
+	if err := waitForIndex(r.Context(), db, ddfn.String(), string(find.Update)); err != nil {
+		s.Logger.Errorf(r.Context(), "failed to wait for index", "error", err)
+		WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	var ms *controller.MangoSearch
+	err := db.Transaction(r.Context(), func(tx port.DatabaseTx) error {
+		var includeDocs bool
+		ms = controller.NewMangoSearch(find.FindQuery, func(rec *model.Document) (*model.Document, error) {
+			if !includeDocs {
+				return controller.ViewRow(rec, nil), nil
+			}
+			doc, err := tx.GetDocument(r.Context(), rec.ID)
+			if err != nil {
+				return nil, err
+			}
+			return controller.ViewRow(rec, doc), nil
+		})
+		includeDocs = find.IncludeDocs || ms.NeedsDocs()
+
+		iter, err := db.IndexIterator(r.Context(), tx, idx)
+		if err != nil {
+			return err
+		}
+		return ms.Iterate(iter)
+	})
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	writeIndexFindResponse(w, find, ms)
+}
+
+// decodeIndexFindQuery decodes the IndexFindQuery of the request body
+func decodeIndexFindQuery(w http.ResponseWriter, r *http.Request) (*IndexFindQuery, bool) {
+	var find IndexFindQuery
+	if err := json.NewDecoder(r.Body).Decode(&find); err != nil {
+		WriteError(w, http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+	if find.Limit == 0 {
+		find.Limit = 25
+	}
+	return &find, true
+}
+
+// writeIndexFindResponse writes the rows of the search, applying
+// the fields projection of the query.
+func writeIndexFindResponse(w http.ResponseWriter, find *IndexFindQuery, ms *controller.MangoSearch) {
+	docs, stats := ms.Result()
+
+	response := IndexFindResponse{
+		Rows: make([]map[string]interface{}, len(docs)),
+	}
+	if find.ExecutionStats {
+		response.ExecutionStats = stats
+	}
+	for i, doc := range docs {
+		row := doc.Data
+		if len(find.Fields) > 0 {
+			row = pickFields(row, find.Fields)
+			row["id"] = doc.ID
+		}
+		response.Rows[i] = row
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response) // nolint: errcheck
+}
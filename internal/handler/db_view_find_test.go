@@ -0,0 +1,122 @@
+//go:build !nogoja
+
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/goydb/goydb/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func postIndexFind(t *testing.T, router *mux.Router, path string, body interface{}) (IndexFindResponse, int) {
+	t.Helper()
+	b, err := json.Marshal(body)
+	require.NoError(t, err)
+	req := httptest.NewRequest("POST", path, bytes.NewReader(b))
+	req.SetBasicAuth("admin", "secret")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var result IndexFindResponse
+	if w.Code == http.StatusOK {
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&result))
+	}
+	return result, w.Code
+}
+
+func TestViewFind_SelectorOnKeyAndValue(t *testing.T) {
+	s, router, cleanup := setupViewTest(t)
+	defer cleanup()
+
+	ctx := t.Context()
+	db, err := s.CreateDatabase(ctx, "testdb")
+	require.NoError(t, err)
+
+	for _, d := range []struct {
+		id, name string
+		age      int
+	}{{"a", "alice", 30}, {"b", "bob", 25}, {"c", "carol", 35}, {"d", "dave", 40}} {
+		_, err = db.PutDocument(ctx, &model.Document{
+			ID:   d.id,
+			Data: map[string]interface{}{"name": d.name, "age": d.age, "city": "bern"},
+		})
+		require.NoError(t, err)
+	}
+
+	putDesignDoc(t, router, "testdb", "people", map[string]interface{}{
+		"views": map[string]interface{}{
+			"by_name": map[string]interface{}{
+				"map": `function(doc) { emit(doc.name, doc.age); }`,
+			},
+		},
+	})
+
+	result, code := postIndexFind(t, router, "/testdb/_design/people/_view/by_name/_find", map[string]interface{}{
+		"selector":        map[string]interface{}{"value": map[string]interface{}{"$gte": 30}},
+		"sort":            []interface{}{map[string]string{"value": "desc"}},
+		"execution_stats": true,
+	})
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, result.Rows, 3)
+	assert.Equal(t, "dave", result.Rows[0]["key"])
+	assert.Equal(t, "carol", result.Rows[1]["key"])
+	assert.Equal(t, "alice", result.Rows[2]["key"])
+	assert.Nil(t, result.Rows[0]["doc"])
+	require.NotNil(t, result.ExecutionStats)
+	assert.Equal(t, 4, result.ExecutionStats.TotalDocsExamined)
+	assert.Equal(t, 3, result.ExecutionStats.ResultsReturned)
+
+	// limit without sort stops early
+	result, code = postIndexFind(t, router, "/testdb/_design/people/_view/by_name/_find", map[string]interface{}{
+		"selector": map[string]interface{}{"key": map[string]interface{}{"$gt": "a"}},
+		"limit":    2,
+	})
+	require.Equal(t, http.StatusOK, code)
+	assert.Len(t, result.Rows, 2)
+}
+
+func TestViewFind_DocFieldsAndProjection(t *testing.T) {
+	s, router, cleanup := setupViewTest(t)
+	defer cleanup()
+
+	ctx := t.Context()
+	db, err := s.CreateDatabase(ctx, "testdb")
+	require.NoError(t, err)
+
+	_, err = db.PutDocument(ctx, &model.Document{ID: "a", Data: map[string]interface{}{"name": "alice", "city": "bern"}})
+	require.NoError(t, err)
+	_, err = db.PutDocument(ctx, &model.Document{ID: "b", Data: map[string]interface{}{"name": "bob", "city": "basel"}})
+	require.NoError(t, err)
+
+	putDesignDoc(t, router, "testdb", "people", map[string]interface{}{
+		"views": map[string]interface{}{
+			"by_name": map[string]interface{}{
+				"map": `function(doc) { emit(doc.name, null); }`,
+			},
+		},
+	})
+
+	// referencing doc.* loads the documents automatically
+	result, code := postIndexFind(t, router, "/testdb/_design/people/_view/by_name/_find", map[string]interface{}{
+		"selector": map[string]interface{}{"doc.city": "basel"},
+		"fields":   []string{"key", "doc.city"},
+	})
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, result.Rows, 1)
+	assert.Equal(t, map[string]interface{}{
+		"id":  "b",
+		"key": "bob",
+		"doc": map[string]interface{}{"city": "basel"},
+	}, result.Rows[0])
+
+	_, code = postIndexFind(t, router, "/testdb/_design/people/_view/missing/_find", map[string]interface{}{
+		"selector": map[string]interface{}{"key": "bob"},
+	})
+	assert.Equal(t, http.StatusNotFound, code)
+}
@@ -0,0 +1,293 @@
+package handler
+
+import (
+	"container/heap"
+	"context"
+
+	"github.com/goydb/goydb/pkg/model"
+	"github.com/goydb/goydb/pkg/port"
+)
+
+// viewRowBatch is the number of rows read from the index in one
+// transaction, no transaction is held while the rows are processed
+const viewRowBatch = 100
+
+// viewRun is a range of index entries that is sorted by view collation.
+// The entries of the index are sorted by their CBOR encoding, which
+// diverges from the collation, e.g. strings are sorted by length first
+// and negative numbers are reversed. The index consists of few runs that
+// are sorted in the order of the index or in the reverse order.
+type viewRun struct {
+	lo, hi   []byte // raw keys of the first and the last entry
+	reversed bool   // the collation is the reverse order of the index
+	pos      []byte // raw key of the next entry, nil if all were read
+	iter     port.Iterator
+	head     *model.Document
+}
+
+// viewRows streams the rows of a map query in collation order by merging
+// the runs of the index. The rows are read in batches, each batch in a
+// transaction that continues after the last row of the previous one.
+type viewRows struct {
+	db         port.Database
+	idx        port.DocumentIndex
+	q          port.AllDocsQuery
+	descending bool
+	runs       []*viewRun
+	batch      int
+	buf        []*model.Document
+	skip       int
+	limit      int // -1 is unlimited
+}
+
+// newViewRows finds the runs of the index that contain the rows of the
+// query and returns the stream of the rows with the total of the query
+func newViewRows(ctx context.Context, db port.Database, idx port.DocumentIndex, q port.AllDocsQuery) (*viewRows, int, error) {
+	vr := &viewRows{
+		db:    db,
+		idx:   idx,
+		q:     q,
+		batch: viewRowBatch,
+		skip:  int(q.Skip),
+		limit: -1,
+	}
+	if q.Limit > 0 {
+		vr.limit = int(q.Limit)
+	}
+
+	var total int
+	err := db.Transaction(ctx, func(tx port.DatabaseTx) error {
+		if len(q.ViewKeys) > 0 {
+			iter, err := db.IndexIterator(ctx, tx, idx)
+			if err != nil {
+				return err
+			}
+			total = iter.Total()
+
+			// every key is a run, the rows are sorted by their key
+			for _, k := range q.ViewKeys {
+				sk, ek := cborKeyRange(k)
+				if sk == nil {
+					continue
+				}
+				iter, err := db.IndexIterator(ctx, tx, idx)
+				if err != nil {
+					return err
+				}
+				iter.SetStartKey(sk)
+				iter.SetEndKey(ek)
+				iter.SetExclusiveEnd(false)
+				run := &viewRun{}
+				for doc := iter.First(); iter.Continue() && doc != nil; doc = iter.Next() {
+					if run.lo == nil {
+						run.lo = iter.Key()
+					}
+					run.hi = iter.Key()
+				}
+				if run.lo != nil {
+					vr.runs = append(vr.runs, run)
+				}
+			}
+			return nil
+		}
+
+		iter, err := db.IndexIterator(ctx, tx, idx)
+		if err != nil {
+			return err
+		}
+		vr.descending = q.ViewDescending
+		total = iter.Total()
+		var run *viewRun
+		var prev interface{}
+		order := 0
+		for doc := iter.First(); iter.Continue() && doc != nil; doc = iter.Next() {
+			if run != nil {
+				cmp := model.ViewKeyCmp(doc.Key, prev)
+				switch {
+				case order == 0:
+					order = cmp
+				case cmp != 0 && cmp != order:
+					run = nil
+				}
+			}
+			key := iter.Key()
+			if run == nil {
+				run = &viewRun{lo: key}
+				vr.runs = append(vr.runs, run)
+				order = 0
+			}
+			run.hi, run.reversed = key, order < 0
+			prev = doc.Key
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	for _, run := range vr.runs {
+		run.pos = run.lo
+		if run.reversed != vr.descending {
+			run.pos = run.hi
+		}
+	}
+	return vr, total, nil
+}
+
+// next returns the next row of the query, nil if all rows were read
+func (vr *viewRows) next(ctx context.Context) (*model.Document, error) {
+	for vr.limit != 0 {
+		if len(vr.buf) == 0 {
+			if err := vr.fill(ctx); err != nil {
+				return nil, err
+			}
+			if len(vr.buf) == 0 {
+				return nil, nil
+			}
+		}
+		doc := vr.buf[0]
+		vr.buf = vr.buf[1:]
+
+		ok, past := vr.inRange(doc)
+		if past {
+			vr.limit = 0
+			break
+		}
+		if !ok {
+			continue
+		}
+		if vr.skip > 0 {
+			vr.skip--
+			continue
+		}
+		if vr.limit > 0 {
+			vr.limit--
+		}
+		return doc, nil
+	}
+	return nil, nil
+}
+
+// inRange returns false if the row is outside of the requested range,
+// past is true if the following rows are outside of it as well. The
+// rows of a multi-key lookup are in range.
+func (vr *viewRows) inRange(doc *model.Document) (ok, past bool) {
+	q := vr.q
+	if len(q.ViewKeys) > 0 {
+		return true, false
+	}
+	if q.ViewDescending {
+		if q.ViewDecodedStartKey != nil && model.ViewKeyCmp(doc.Key, q.ViewDecodedStartKey) > 0 {
+			return false, false
+		}
+		if q.ViewDecodedEndKey != nil {
+			cmp := model.ViewKeyCmp(doc.Key, q.ViewDecodedEndKey)
+			if cmp < 0 || (q.ViewExclusiveEnd && cmp == 0) {
+				return false, true
+			}
+		}
+	} else {
+		if q.ViewDecodedStartKey != nil && model.ViewKeyCmp(doc.Key, q.ViewDecodedStartKey) < 0 {
+			return false, false
+		}
+		if q.ViewDecodedEndKey != nil {
+			cmp := model.ViewKeyCmp(doc.Key, q.ViewDecodedEndKey)
+			if cmp > 0 || (q.ViewExclusiveEnd && cmp == 0) {
+				return false, true
+			}
+		}
+	}
+	if q.StartKeyDocID != "" && doc.ID < q.StartKeyDocID {
+		return false, false
+	}
+	if q.EndKeyDocID != "" && doc.ID > q.EndKeyDocID {
+		return false, false
+	}
+	return true, false
+}
+
+// fill reads the next batch of rows, the runs are positioned at the
+// entries that weren't read yet and merged by collation
+func (vr *viewRows) fill(ctx context.Context) error {
+	return vr.db.Transaction(ctx, func(tx port.DatabaseTx) error {
+		h := &viewRunHeap{descending: vr.descending}
+		for _, run := range vr.runs {
+			if run.pos == nil {
+				continue
+			}
+			iter, err := vr.db.IndexIterator(ctx, tx, vr.idx)
+			if err != nil {
+				return err
+			}
+			iter.SetStartKey(run.pos)
+			if run.reversed != vr.descending {
+				iter.SetDescending(true)
+				iter.SetEndKey(run.lo)
+			} else {
+				iter.SetEndKey(run.hi)
+			}
+			run.iter, run.head = iter, iter.First()
+			if !iter.Continue() || run.head == nil {
+				run.pos = nil
+				continue
+			}
+			run.pos = iter.Key()
+			h.runs = append(h.runs, run)
+		}
+		heap.Init(h)
+
+		for len(vr.buf) < vr.batch && h.Len() > 0 {
+			run := h.runs[0]
+			vr.buf = append(vr.buf, run.head)
+			run.head = run.iter.Next()
+			if run.head == nil {
+				run.pos = nil
+				heap.Pop(h)
+				continue
+			}
+			run.pos = run.iter.Key()
+			heap.Fix(h, 0)
+		}
+		for _, run := range vr.runs {
+			run.iter, run.head = nil, nil
+		}
+		return nil
+	})
+}
+
+// viewRunHeap orders the runs by the collation of their next row,
+// rows with equal keys by document id
+type viewRunHeap struct {
+	runs       []*viewRun
+	descending bool
+}
+
+func (h *viewRunHeap) Len() int { return len(h.runs) }
+
+func (h *viewRunHeap) Less(i, j int) bool {
+	a, b := h.runs[i].head, h.runs[j].head
+	cmp := model.ViewKeyCmp(a.Key, b.Key)
+	if cmp == 0 {
+		if a.ID == b.ID {
+			return false
+		}
+		cmp = 1
+		if a.ID < b.ID {
+			cmp = -1
+		}
+	}
+	if h.descending {
+		return cmp > 0
+	}
+	return cmp < 0
+}
+
+func (h *viewRunHeap) Swap(i, j int) { h.runs[i], h.runs[j] = h.runs[j], h.runs[i] }
+
+func (h *viewRunHeap) Push(x interface{}) { h.runs = append(h.runs, x.(*viewRun)) }
+
+func (h *viewRunHeap) Pop() interface{} {
+	run := h.runs[len(h.runs)-1]
+	h.runs = h.runs[:len(h.runs)-1]
+	return run
+}
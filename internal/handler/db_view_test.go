@@ -33,10 +33,12 @@ func setupViewTest(t *testing.T) (*storage.Storage, *mux.Router, func()) {
 	log := logger.NewNoLog()
 	s, err := storage.Open(dir,
 		storage.WithLogger(log),
-		storage.WithViewEngine("", gojaview.NewViewServer),
-		storage.WithViewEngine("javascript", gojaview.NewViewServer),
-		storage.WithReducerEngine("", gojaview.NewReducerBuilder(log)),
-		storage.WithReducerEngine("javascript", gojaview.NewReducerBuilder(log)),
+		storage.WithViewDesignDocEngine("", gojaview.NewViewServer),
+		storage.WithViewDesignDocEngine("javascript", gojaview.NewViewServer),
+		storage.WithReducerDesignDocEngine("", gojaview.NewReducerBuilder(log)),
+		storage.WithReducerDesignDocEngine("javascript", gojaview.NewReducerBuilder(log)),
+		storage.WithListDesignDocEngine("", gojaview.NewListServer),
+		storage.WithListDesignDocEngine("javascript", gojaview.NewListServer),
 	)
 	require.NoError(t, err)
 
@@ -52,7 +54,7 @@ func setupViewTest(t *testing.T) (*storage.Storage, *mux.Router, func()) {
 	require.NoError(t, err)
 
 	taskCtx, cancelTasks := context.WithCancel(context.Background())
-	tc := controller.Task{Storage: s}
+	tc := controller.Task{Storage: s, Logger: logger.NewNoLog()}
 	go tc.Run(taskCtx)
 
 	return s, r, func() {
@@ -1881,3 +1883,86 @@ func TestReduce_Sum_ArrayValues(t *testing.T) {
 	assert.EqualValues(t, 9, arr[0])  // 1+3+5
 	assert.EqualValues(t, 12, arr[1]) // 2+4+6
 }
+
+func TestView_RequireViewsLib(t *testing.T) {
+	s, router, cleanup := setupViewTest(t)
+	defer cleanup()
+
+	ctx := t.Context()
+	db, err := s.CreateDatabase(ctx, "testdb")
+	require.NoError(t, err)
+
+	for _, e := range []struct {
+		id string
+		v  int
+	}{{"d1", 1}, {"d2", 2}} {
+		_, err = db.PutDocument(ctx, &model.Document{
+			ID:   e.id,
+			Data: map[string]interface{}{"v": e.v},
+		})
+		require.NoError(t, err)
+	}
+
+	ddoc := map[string]interface{}{
+		"views": map[string]interface{}{
+			"lib": map[string]interface{}{
+				"scale": `exports.factor = 10;`,
+			},
+			"scaled": map[string]interface{}{
+				"map":    `function(doc) { emit(doc._id, doc.v * require('views/lib/scale').factor); }`,
+				"reduce": `function(keys, values, rereduce) { return sum(values) + require('views/lib/scale').factor; }`,
+			},
+		},
+	}
+	putDesignDoc(t, router, "testdb", "lib", ddoc)
+
+	result, code := queryView(t, router, "testdb", "lib", "scaled", "reduce=false")
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, result.Rows, 2)
+	assert.InDelta(t, float64(10), result.Rows[0].Value, 0.001)
+	assert.InDelta(t, float64(20), result.Rows[1].Value, 0.001)
+
+	result, code = queryView(t, router, "testdb", "lib", "scaled", "")
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, result.Rows, 1)
+	assert.InDelta(t, float64(40), result.Rows[0].Value, 0.001)
+
+	// lib is not a view
+	_, code = queryView(t, router, "testdb", "lib", "lib", "")
+	assert.Equal(t, http.StatusNotFound, code)
+
+	// a change of the lib rebuilds the view
+	doc, err := db.GetDocument(ctx, "_design/lib")
+	require.NoError(t, err)
+	ddoc["_rev"] = doc.Rev
+	ddoc["views"].(map[string]interface{})["lib"] = map[string]interface{}{
+		"scale": `exports.factor = 100;`,
+	}
+	putDesignDoc(t, router, "testdb", "lib", ddoc)
+
+	result, code = queryView(t, router, "testdb", "lib", "scaled", "reduce=false")
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, result.Rows, 2)
+	assert.InDelta(t, float64(100), result.Rows[0].Value, 0.001)
+	assert.InDelta(t, float64(200), result.Rows[1].Value, 0.001)
+}
+
+func TestView_QueryParseError(t *testing.T) {
+	s, router, cleanup := setupViewTest(t)
+	defer cleanup()
+
+	setupSimpleViewDB(t, s, router, "testdb", []string{"alice"})
+
+	for _, query := range []string{
+		"limit=abc",
+		"skip=-1",
+		"descending=yes",
+		"startkey=alice",
+		"keys=\"alice\"",
+		"update=sometimes",
+		"reduce=false&group_level=1",
+	} {
+		_, code := queryView(t, router, "testdb", "users", "by_name", query)
+		assert.Equal(t, http.StatusBadRequest, code, query)
+	}
+}
@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/sessions"
+	"github.com/goydb/goydb/internal/adapter/logger"
+	"github.com/goydb/goydb/internal/adapter/storage"
+	"github.com/goydb/goydb/internal/adapter/view/queryview"
+	"github.com/goydb/goydb/internal/controller"
+	"github.com/goydb/goydb/internal/service"
+	"github.com/goydb/goydb/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupQueryViewTest returns a router without task processor
+// and query views enabled
+func setupQueryViewTest(t *testing.T) (*storage.Storage, *mux.Router) {
+	t.Helper()
+	s, err := storage.Open(t.TempDir(),
+		storage.WithLogger(logger.NewNoLog()),
+		storage.WithViewEngine(queryview.Language, queryview.NewViewServer),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.Close() })
+	router := mux.NewRouter()
+	err = Router{
+		Storage:      s,
+		SessionStore: sessions.NewCookieStore([]byte("test-secret-32-bytes-long-enough")),
+		Admins:       model.AdminUsers{model.AdminUser{Username: "admin", Password: "secret"}},
+		Replication:  &service.Replication{Storage: s, Logger: logger.NewNoLog()},
+		Logger:       logger.NewNoLog(),
+	}.Build(router)
+	require.NoError(t, err)
+	return s, router
+}
+
+func TestView_UpdateModes(t *testing.T) {
+	ctx := context.Background()
+	s, router := setupQueryViewTest(t)
+
+	db, err := s.CreateDatabase(ctx, "testdb")
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		_, err := db.PutDocument(ctx, &model.Document{
+			ID:   fmt.Sprintf("doc%d", i),
+			Data: map[string]interface{}{"n": i},
+		})
+		require.NoError(t, err)
+	}
+	_, err = db.PutDocument(ctx, &model.Document{
+		ID: "_design/app",
+		Data: map[string]interface{}{
+			"language": queryview.Language,
+			"views": map[string]interface{}{
+				"by_n": map[string]interface{}{"map": map[string]interface{}{"fields": []string{"n"}}},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	get := func(ctx context.Context, path string) (int, map[string]interface{}) {
+		req := httptest.NewRequest("GET", path, nil).WithContext(ctx)
+		req.SetBasicAuth("admin", "secret")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var result map[string]interface{}
+		_ = json.NewDecoder(w.Body).Decode(&result)
+		return w.Code, result
+	}
+
+	// the index isn't built yet, no task processor is running
+	for _, query := range []string{"update=false", "update=lazy", "stale=ok", "stale=update_after"} {
+		code, res := get(ctx, "/testdb/_design/app/_view/by_n?update_seq=true&"+query)
+		require.Equal(t, http.StatusOK, code, query)
+		assert.Empty(t, res["rows"], query)
+		assert.EqualValues(t, 0, res["update_seq"], query)
+	}
+	code, res := get(ctx, "/testdb/_design/app/_info")
+	require.Equal(t, http.StatusOK, code)
+	info := res["view_index"].(map[string]interface{})
+	assert.Equal(t, true, info["updater_running"])
+	assert.EqualValues(t, 0, info["update_seq"])
+	assert.EqualValues(t, 4, info["updates_pending"].(map[string]interface{})["total"])
+
+	// update=true waits for the index
+	waitCtx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer cancel()
+	code, _ = get(waitCtx, "/testdb/_design/app/_view/by_n")
+	assert.Equal(t, http.StatusInternalServerError, code)
+
+	tc := controller.Task{Storage: s, Logger: logger.NewNoLog()}
+	require.NoError(t, tc.ProcessTasksForDatabase(ctx, db))
+
+	code, res = get(ctx, "/testdb/_design/app/_view/by_n?update_seq=true")
+	require.Equal(t, http.StatusOK, code)
+	assert.Len(t, res["rows"], 3)
+	assert.EqualValues(t, 4, res["update_seq"])
+	code, res = get(ctx, "/testdb/_design/app/_info")
+	require.Equal(t, http.StatusOK, code)
+	info = res["view_index"].(map[string]interface{})
+	assert.Equal(t, false, info["updater_running"])
+	assert.EqualValues(t, 4, info["update_seq"])
+}
@@ -5,40 +5,19 @@ import (
 )
 
 // DDShowFunction handles GET/POST /{db}/_design/{ddoc}/_show/{func}[/{docid}].
-// Show functions are a CouchDB 1.x feature that transforms documents using
-// server-side JavaScript. Returns 501 Not Implemented.
+// Show functions transform a document into an arbitrary response (e.g. HTML
+// or CSV). The implementation is in ddoc_show.go.
 type DDShowFunction struct {
 	Base
 }
 
-func (s *DDShowFunction) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	defer r.Body.Close() //nolint:errcheck
-
-	db := Database{Base: s.Base}.Do(w, r)
-	if db == nil {
-		return
-	}
-
-	WriteError(w, http.StatusNotImplemented, "show functions are not supported")
-}
-
-// DDListFunction handles GET/POST /{db}/_design/{ddoc}/_list/{func}/{view}.
-// List functions are a CouchDB 1.x feature. Returns 501 Not Implemented.
+// DDListFunction handles GET/POST /{db}/_design/{ddoc}/_list/{func}[/{ddoc2}]/{view}.
+// List functions transform the rows of a view into an arbitrary response,
+// which is streamed to the client. The implementation is in ddoc_list.go.
 type DDListFunction struct {
 	Base
 }
 
-func (s *DDListFunction) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	defer r.Body.Close() //nolint:errcheck
-
-	db := Database{Base: s.Base}.Do(w, r)
-	if db == nil {
-		return
-	}
-
-	WriteError(w, http.StatusNotImplemented, "list functions are not supported")
-}
-
 // DDUpdateFunction handles POST/PUT /{db}/_design/{ddoc}/_update/{func}[/{docid}].
 // Update functions are server-side JavaScript handlers that can create or modify
 // documents. The implementation is in ddoc_update.go.
@@ -47,18 +26,10 @@ type DDUpdateFunction struct {
 }
 
 // DDRewrite handles ALL /{db}/_design/{ddoc}/_rewrite/{path:.*}.
-// Rewrite rules are a CouchDB 1.x feature. Returns 501 Not Implemented.
+// Requests are rewritten using the rewrites rules or function of the
+// design document and dispatched using Router. The implementation is
+// in ddoc_rewrite.go.
 type DDRewrite struct {
 	Base
-}
-
-func (s *DDRewrite) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	defer r.Body.Close() //nolint:errcheck
-
-	db := Database{Base: s.Base}.Do(w, r)
-	if db == nil {
-		return
-	}
-
-	WriteError(w, http.StatusNotImplemented, "rewrite rules are not supported")
+	Router http.Handler
 }
@@ -9,7 +9,7 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-func TestShowFunction_NotImplemented(t *testing.T) {
+func TestShowFunction_MissingDesignDoc(t *testing.T) {
 	s, router, cleanup := setupRevsDiffTest(t)
 	defer cleanup()
 
@@ -21,10 +21,10 @@ func TestShowFunction_NotImplemented(t *testing.T) {
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 
-	assert.Equal(t, http.StatusNotImplemented, w.Code)
+	assert.Equal(t, http.StatusNotFound, w.Code)
 }
 
-func TestListFunction_NotImplemented(t *testing.T) {
+func TestListFunction_MissingDesignDoc(t *testing.T) {
 	s, router, cleanup := setupRevsDiffTest(t)
 	defer cleanup()
 
@@ -36,7 +36,7 @@ func TestListFunction_NotImplemented(t *testing.T) {
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 
-	assert.Equal(t, http.StatusNotImplemented, w.Code)
+	assert.Equal(t, http.StatusNotFound, w.Code)
 }
 
 func TestUpdateFunction_MissingDesignDoc(t *testing.T) {
@@ -54,7 +54,7 @@ func TestUpdateFunction_MissingDesignDoc(t *testing.T) {
 	assert.Equal(t, http.StatusNotFound, w.Code)
 }
 
-func TestRewrite_NotImplemented(t *testing.T) {
+func TestRewrite_MissingDesignDoc(t *testing.T) {
 	s, router, cleanup := setupRevsDiffTest(t)
 	defer cleanup()
 
@@ -66,5 +66,5 @@ func TestRewrite_NotImplemented(t *testing.T) {
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 
-	assert.Equal(t, http.StatusNotImplemented, w.Code)
+	assert.Equal(t, http.StatusNotFound, w.Code)
 }
@@ -0,0 +1,189 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/goydb/goydb/pkg/model"
+	"github.com/goydb/goydb/pkg/port"
+)
+
+func (s *DDListFunction) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close() //nolint:errcheck
+
+	db := Database{Base: s.Base}.Do(w, r)
+	if db == nil {
+		return
+	}
+
+	session, ok := Authenticator{Base: s.Base}.DB(w, r, db)
+	if !ok {
+		return
+	}
+
+	ddocID := pathVar(r, "docid")
+	funcName := pathVar(r, "func")
+	viewName := pathVar(r, "view")
+	// the view may be defined in another design document
+	viewDDocID := pathVar(r, "viewdocid")
+	if viewDDocID == "" {
+		viewDDocID = ddocID
+	}
+
+	ctx := r.Context()
+
+	// Load design document
+	ddoc, err := db.GetDocument(ctx, "_design/"+ddocID)
+	if err != nil || ddoc == nil {
+		WriteError(w, http.StatusNotFound, "missing")
+		return
+	}
+
+	// Find the list function
+	fn, found := ddoc.List(funcName)
+	if !found {
+		WriteError(w, http.StatusNotFound, "missing function "+funcName+" on design doc _design/"+ddocID)
+		return
+	}
+
+	ddfn := model.DesignDocFn{
+		Type:        model.ViewFn,
+		DesignDocID: string(model.DesignDocPrefix) + viewDDocID,
+		FnName:      viewName,
+	}
+	idx, ok := db.Indices()[ddfn.String()]
+	if !ok {
+		WriteError(w, http.StatusNotFound, "index not found")
+		return
+	}
+
+	// Get the engine for this language
+	builder := db.ListDesignDocEngine(ddoc.Language())
+	if builder == nil {
+		WriteError(w, http.StatusInternalServerError, "no list engine for language "+ddoc.Language())
+		return
+	}
+
+	// Compile the function
+	listServer, err := builder(fn.ListFnCode, ddoc)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to compile list function: "+err.Error())
+		return
+	}
+
+	// the request object consumes the body, restore it
+	// to read the view query options of POST requests
+	reqObj := requestObject(r, session, db, "")
+	r.Body = io.NopCloser(strings.NewReader(reqObj["body"].(string)))
+	options := mergeBodyIntoOptions(r, r.URL.Query())
+
+	var parseErr *queryParseError
+	result, err := runViewQuery(ctx, db, idx, ddfn, options)
+	if errors.As(err, &parseErr) {
+		WriteNamedError(w, http.StatusBadRequest, "query_parse_error", parseErr.Error())
+		return
+	}
+	if err != nil {
+		s.Logger.Errorf(ctx, "failed to query view", "error", err)
+		WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	view := buildViewResponse(nil, result.total, result.q, db, r)
+
+	out := &listResponseWriter{w: w}
+	err = listServer.ExecuteList(ctx, listHead(view), reqObj, listRows(ctx, result), out)
+	if err == nil {
+		return
+	}
+
+	if out.started {
+		// the status line was sent already, the response ends early
+		s.Logger.Warnf(ctx, "list function failed", "error", err)
+		return
+	}
+	if errors.Is(err, port.ErrNotAcceptable) {
+		WriteError(w, http.StatusNotAcceptable, err.Error())
+		return
+	}
+	WriteError(w, http.StatusInternalServerError, err.Error())
+}
+
+// listHead returns the head passed to list functions
+func listHead(view ViewResponse) map[string]interface{} {
+	head := make(map[string]interface{})
+	if view.TotalRows != nil {
+		head["total_rows"] = *view.TotalRows
+	}
+	if view.Offset != nil {
+		head["offset"] = *view.Offset
+	}
+	if view.UpdateSeq != nil {
+		head["update_seq"] = view.UpdateSeq
+	}
+	return head
+}
+
+// listRows returns the rows one by one in the format of the view
+// response, the document of a row is loaded when the row is fetched
+func listRows(ctx context.Context, result *viewResult) port.ListRows {
+	return func() (map[string]interface{}, error) {
+		row, err := result.next(ctx)
+		if row == nil || err != nil {
+			return nil, err
+		}
+
+		m := map[string]interface{}{
+			"key":   row.Key,
+			"value": row.Value,
+		}
+		if row.ID != "" {
+			m["id"] = row.ID
+		}
+		if row.Doc != nil {
+			m["doc"] = row.Doc
+		}
+		return m, nil
+	}
+}
+
+// listResponseWriter writes the output of a list function as chunked
+// response, every chunk is flushed to the client when it is sent.
+type listResponseWriter struct {
+	w       http.ResponseWriter
+	started bool
+}
+
+func (lw *listResponseWriter) Start(code int, headers map[string]string) error {
+	lw.started = true
+
+	lw.w.Header().Set("Vary", "Accept")
+	for k, v := range headers {
+		lw.w.Header().Set(k, v)
+	}
+	if lw.w.Header().Get("Content-Type") == "" {
+		lw.w.Header().Set("Content-Type", "text/html;charset=utf-8")
+	}
+	if code == 0 {
+		code = http.StatusOK
+	}
+	lw.w.WriteHeader(code)
+	lw.flush()
+	return nil
+}
+
+func (lw *listResponseWriter) Send(chunk string) error {
+	if _, err := io.WriteString(lw.w, chunk); err != nil {
+		return err
+	}
+	lw.flush()
+	return nil
+}
+
+func (lw *listResponseWriter) flush() {
+	if flusher, ok := lw.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
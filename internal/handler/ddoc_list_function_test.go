@@ -0,0 +1,256 @@
+//go:build !nogoja
+
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/goydb/goydb/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testCSVList = `function(head, req) {
+	start({headers: {"Content-Type": "text/csv"}});
+	send("total," + head.total_rows + "\n");
+	var row;
+	while (row = getRow()) {
+		send(row.id + "," + row.key + "\n");
+	}
+	return "end\n";
+}`
+
+// listRequest sends a request to a list function endpoint.
+func listRequest(t *testing.T, router *mux.Router, method, path, body string, headers map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, bytes.NewReader([]byte(body)))
+	req.SetBasicAuth("admin", "secret")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestList_CSV(t *testing.T) {
+	s, router, cleanup := setupViewTest(t)
+	defer cleanup()
+
+	setupSimpleViewDB(t, s, router, "testdb", []string{"carol", "alice", "bob"})
+	putDesignDoc(t, router, "testdb", "render", map[string]interface{}{
+		"lists": map[string]interface{}{
+			"csv":  testCSVList,
+			"docs": `function(head, req) { var row; while (row = getRow()) { send(row.doc.name + ","); } }`,
+		},
+	})
+
+	// view of another design document
+	w := listRequest(t, router, "GET", "/testdb/_design/render/_list/csv/users/by_name", "", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "text/csv", w.Header().Get("Content-Type"))
+	assert.Equal(t, "total,3\ndoc2,alice\ndoc3,bob\ndoc1,carol\nend\n", w.Body.String())
+
+	// view query parameters
+	w = listRequest(t, router, "GET", "/testdb/_design/render/_list/csv/users/by_name?reduce=false&descending=true&limit=2", "", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "total,3\ndoc1,carol\ndoc3,bob\nend\n", w.Body.String())
+
+	// view query parameters in the body of a POST request
+	w = listRequest(t, router, "POST", "/testdb/_design/render/_list/csv/users/by_name", `{"keys":["bob"]}`, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "total,3\ndoc3,bob\nend\n", w.Body.String())
+
+	// documents are loaded as the rows are fetched
+	w = listRequest(t, router, "GET", "/testdb/_design/render/_list/docs/users/by_name?include_docs=true&reduce=false", "", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "alice,bob,carol,", w.Body.String())
+
+	// invalid view query parameters
+	w = listRequest(t, router, "GET", "/testdb/_design/render/_list/csv/users/by_name?limit=abc", "", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "query_parse_error")
+
+	// unknown view
+	w = listRequest(t, router, "GET", "/testdb/_design/render/_list/csv/users/nope", "", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestList_SameDesignDoc(t *testing.T) {
+	s, router, cleanup := setupViewTest(t)
+	defer cleanup()
+
+	setupSimpleViewDB(t, s, router, "testdb", []string{"alice"})
+	putDesignDoc(t, router, "testdb", "render", map[string]interface{}{
+		"views": map[string]interface{}{
+			"count": map[string]interface{}{
+				"map":    `function(doc) { emit(doc.type, 1); }`,
+				"reduce": "_count",
+			},
+		},
+		"lists": map[string]interface{}{
+			"values": `function(head, req) {
+				var row, out = [];
+				while (row = getRow()) {
+					out.push(row.key + "=" + row.value);
+				}
+				return out.join(";");
+			}`,
+		},
+	})
+
+	w := listRequest(t, router, "GET", "/testdb/_design/render/_list/values/count?group=true", "", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/html")
+	assert.Equal(t, "user=1", w.Body.String())
+}
+
+func TestList_Provides(t *testing.T) {
+	s, router, cleanup := setupViewTest(t)
+	defer cleanup()
+
+	setupSimpleViewDB(t, s, router, "testdb", []string{"alice", "bob"})
+	putDesignDoc(t, router, "testdb", "render", map[string]interface{}{
+		"lists": map[string]interface{}{
+			"names": `function(head, req) {
+				provides("html", function() {
+					send("<ul>");
+					var row;
+					while (row = getRow()) {
+						send("<li>" + row.key + "</li>");
+					}
+					return "</ul>";
+				});
+				provides("json", function() {
+					var row, names = [];
+					while (row = getRow()) {
+						names.push(row.key);
+					}
+					return toJSON(names);
+				});
+			}`,
+			"fails": `function(head, req) {
+				throw new Error("boom");
+			}`,
+		},
+	})
+
+	w := listRequest(t, router, "GET", "/testdb/_design/render/_list/names/users/by_name", "", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "<ul><li>alice</li><li>bob</li></ul>", w.Body.String())
+
+	w = listRequest(t, router, "GET", "/testdb/_design/render/_list/names/users/by_name", "", map[string]string{
+		"Accept": "application/json",
+	})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `["alice","bob"]`, w.Body.String())
+
+	w = listRequest(t, router, "GET", "/testdb/_design/render/_list/names/users/by_name?format=json", "", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.JSONEq(t, `["alice","bob"]`, w.Body.String())
+
+	w = listRequest(t, router, "GET", "/testdb/_design/render/_list/names/users/by_name", "", map[string]string{
+		"Accept": "image/png",
+	})
+	assert.Equal(t, http.StatusNotAcceptable, w.Code)
+
+	w = listRequest(t, router, "GET", "/testdb/_design/render/_list/fails/users/by_name", "", nil)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestList_StreamsRowsInCollationOrder(t *testing.T) {
+	s, router, cleanup := setupViewTest(t)
+	defer cleanup()
+
+	ctx := t.Context()
+	db, err := s.CreateDatabase(ctx, "testdb")
+	require.NoError(t, err)
+
+	// strings of different lengths and negative numbers are in a different
+	// order in the index, more rows than fit into one batch
+	const n = 250
+	for i := 0; i < n; i++ {
+		var key interface{}
+		switch i % 3 {
+		case 0:
+			key = fmt.Sprintf("%s%d", strings.Repeat("a", i%7), i)
+		case 1:
+			key = -float64(i) / 2
+		default:
+			key = float64(i) * 1.5
+		}
+		_, err = db.PutDocument(ctx, &model.Document{
+			ID:   fmt.Sprintf("doc%03d", i),
+			Data: map[string]interface{}{"k": key},
+		})
+		require.NoError(t, err)
+	}
+
+	putDesignDoc(t, router, "testdb", "render", map[string]interface{}{
+		"views": map[string]interface{}{
+			"by_k": map[string]interface{}{
+				"map": `function(doc) { emit(doc.k, null); }`,
+			},
+		},
+		"lists": map[string]interface{}{
+			"rows": `function(head, req) {
+				var row, out = [];
+				while (row = getRow()) {
+					out.push([row.key, row.id]);
+				}
+				return toJSON(out);
+			}`,
+		},
+	})
+
+	list := func(query string) [][2]interface{} {
+		t.Helper()
+		w := listRequest(t, router, "GET", "/testdb/_design/render/_list/rows/by_k?"+query, "", nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var rows [][2]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &rows))
+		return rows
+	}
+	cmp := func(a, b [2]interface{}) int {
+		if c := model.ViewKeyCmp(a[0], b[0]); c != 0 {
+			return c
+		}
+		return strings.Compare(a[1].(string), b[1].(string))
+	}
+
+	rows := list("")
+	require.Len(t, rows, n)
+	for i := 1; i < len(rows); i++ {
+		assert.Negative(t, cmp(rows[i-1], rows[i]), "rows %d and %d", i-1, i)
+	}
+
+	desc := list("descending=true")
+	require.Len(t, desc, n)
+	for i := range desc {
+		assert.Equal(t, rows[n-1-i], desc[i])
+	}
+
+	assert.Equal(t, rows[10:130], list("skip=10&limit=120"))
+	assert.Equal(t, desc[10:130], list("descending=true&skip=10&limit=120"))
+
+	// the range is in collation order across the runs of the index
+	var lo, hi int
+	for i, row := range rows {
+		if row[0] == -5.0 {
+			lo = i
+		}
+		if row[0] == "aa9" {
+			hi = i
+		}
+	}
+	require.Less(t, lo, hi)
+	assert.Equal(t, rows[lo:hi+1], list("startkey=-5&endkey=%22aa9%22"))
+}
@@ -0,0 +1,203 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/goydb/goydb/pkg/model"
+)
+
+// defaultRewriteLimit is the maximum number of nested rewrites of a
+// request if httpd/rewrite_limit is not configured.
+const defaultRewriteLimit = 100
+
+// rewriteDepthKey is the context key of the number of rewrites of a request
+type rewriteDepthKey struct{}
+
+func (s *DDRewrite) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close() //nolint:errcheck
+
+	db := Database{Base: s.Base}.Do(w, r)
+	if db == nil {
+		return
+	}
+
+	session, ok := Authenticator{Base: s.Base}.DB(w, r, db)
+	if !ok {
+		return
+	}
+
+	depth, _ := r.Context().Value(rewriteDepthKey{}).(int)
+	limit := configInt64(s.Config, "httpd", "rewrite_limit")
+	if limit <= 0 {
+		limit = defaultRewriteLimit
+	}
+	if int64(depth) >= limit {
+		WriteError(w, http.StatusBadRequest, "Exceeded rewrite recursion limit")
+		return
+	}
+
+	ddocID := pathVar(r, "docid")
+	ctx := r.Context()
+
+	// Load design document
+	ddoc, err := db.GetDocument(ctx, "_design/"+ddocID)
+	if err != nil || ddoc == nil {
+		WriteError(w, http.StatusNotFound, "missing")
+		return
+	}
+
+	// path below _rewrite
+	prefix := "/_design/" + ddocID + "/_rewrite"
+	var rest string
+	if i := strings.Index(r.URL.Path, prefix); i >= 0 {
+		rest = r.URL.Path[i+len(prefix):]
+	}
+
+	secure := configBool(s.Config, "httpd", "secure_rewrites", true)
+
+	if rules, ok := ddoc.RewriteRules(); ok {
+		path := model.SplitRewritePath(rest)
+		for _, rule := range rules {
+			to, query, ok := rule.Match(r.Method, path, r.URL.Query())
+			if !ok {
+				continue
+			}
+			// targets are relative to the design document
+			target, ok := rewriteTarget([]string{db.Name(), "_design", ddocID}, to, secure)
+			if !ok {
+				WriteError(w, http.StatusForbidden, "insecure_rewrite_rule")
+				return
+			}
+			s.dispatch(w, r, depth, target, query.Encode(), r.Method, nil, nil)
+			return
+		}
+		WriteError(w, http.StatusNotFound, "Invalid path.")
+		return
+	}
+
+	fn, ok := ddoc.RewriteFunction()
+	if !ok {
+		WriteError(w, http.StatusNotFound, "Invalid path.")
+		return
+	}
+
+	builder := db.RewriteDesignDocEngine(ddoc.Language())
+	if builder == nil {
+		WriteError(w, http.StatusInternalServerError, "no rewrite engine for language "+ddoc.Language())
+		return
+	}
+
+	rewriteServer, err := builder(fn.RewriteFnCode, ddoc)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to compile rewrite function: "+err.Error())
+		return
+	}
+
+	// the request object consumes the body, it is
+	// restored for the rewritten request
+	reqObj := requestObject(r, session, db, "")
+	body := reqObj["body"].(string)
+	r.Body = io.NopCloser(strings.NewReader(body))
+
+	result, err := rewriteServer.ExecuteRewrite(ctx, reqObj)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// respond without rewriting
+	if result.Path == "" {
+		w.Header().Set("Content-Type", "text/html;charset=utf-8")
+		for k, v := range result.Headers {
+			w.Header().Set(k, v)
+		}
+		w.WriteHeader(result.Code)
+		if result.Body != nil {
+			w.Write([]byte(*result.Body)) //nolint:errcheck
+		}
+		return
+	}
+
+	// paths are relative to the database
+	target, ok := rewriteTarget([]string{db.Name()}, result.Path, secure)
+	if !ok {
+		WriteError(w, http.StatusForbidden, "insecure_rewrite_rule")
+		return
+	}
+
+	// a path may contain a query, the query of the result replaces it
+	rawQuery := r.URL.RawQuery
+	if i := strings.Index(target, "?"); i >= 0 {
+		target, rawQuery = target[:i], target[i+1:]
+	}
+	if result.Query != nil {
+		rawQuery = rewriteQuery(result.Query).Encode()
+	}
+
+	method := r.Method
+	if result.Method != "" {
+		method = strings.ToUpper(result.Method)
+	}
+
+	s.dispatch(w, r, depth, target, rawQuery, method, result.Headers, result.Body)
+}
+
+// dispatch serves the rewritten request using the router, the request
+// keeps the headers (and therefore the session) of the original request.
+func (s *DDRewrite) dispatch(w http.ResponseWriter, r *http.Request, depth int, path, rawQuery, method string, headers map[string]string, body *string) {
+	nr := r.Clone(context.WithValue(r.Context(), rewriteDepthKey{}, depth+1))
+	nr.Method = method
+	nr.URL.Path = path
+	nr.URL.RawPath = ""
+	nr.URL.RawQuery = rawQuery
+	nr.RequestURI = nr.URL.RequestURI()
+	for k, v := range headers {
+		nr.Header.Set(k, v)
+	}
+	if body != nil {
+		nr.Body = io.NopCloser(strings.NewReader(*body))
+		nr.ContentLength = int64(len(*body))
+	}
+
+	s.Router.ServeHTTP(w, nr)
+}
+
+// rewriteTarget resolves the relative path against the base path segments.
+// If secure is set the path may not leave the database (the first segment
+// of base). The resolved path is absolute.
+func rewriteTarget(base []string, path string, secure bool) (string, bool) {
+	segments := append([]string{}, base...)
+	for _, part := range strings.Split(path, "/") {
+		switch part {
+		case "..":
+			if len(segments) == 0 || (secure && len(segments) == 1) {
+				return "", false
+			}
+			segments = segments[:len(segments)-1]
+		case ".", "":
+		default:
+			segments = append(segments, part)
+		}
+	}
+	return "/" + strings.Join(segments, "/"), true
+}
+
+// rewriteQuery converts the query of a rewrite function, strings are used
+// as is, other values are encoded as JSON.
+func rewriteQuery(query map[string]interface{}) url.Values {
+	q := make(url.Values, len(query))
+	for k, v := range query {
+		if s, ok := v.(string); ok {
+			q.Set(k, s)
+			continue
+		}
+		data, _ := json.Marshal(v)
+		q.Set(k, string(data))
+	}
+	return q
+}
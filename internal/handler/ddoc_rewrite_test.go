@@ -0,0 +1,110 @@
+//go:build !nogoja
+
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRewrite_Rules(t *testing.T) {
+	s, router, cleanup := setupUpdateTest(t)
+	defer cleanup()
+
+	_, err := s.CreateDatabase(t.Context(), "testdb")
+	require.NoError(t, err)
+
+	vduPutDoc(t, router, "/testdb/doc1", map[string]interface{}{"title": "Hello"})
+	code, res := vduPutDoc(t, router, "/testdb/_design/app", map[string]interface{}{
+		"shows": map[string]interface{}{
+			"title": `function(doc, req) { return doc.title + (req.query.suffix || ""); }`,
+		},
+		"rewrites": []interface{}{
+			map[string]interface{}{"from": "", "to": "_show/title/doc1"},
+			map[string]interface{}{"from": "/docs/:id", "to": "../../:id", "method": "GET"},
+			map[string]interface{}{"from": "/page/:id/*", "to": "_show/title/:id", "query": map[string]interface{}{"suffix": "*"}},
+			map[string]interface{}{"from": "/escape", "to": "../../../_all_dbs"},
+			map[string]interface{}{"from": "/loop", "to": "_rewrite/loop"},
+		},
+	})
+	require.Equal(t, http.StatusCreated, code, res)
+
+	w := showGet(t, router, "/testdb/_design/app/_rewrite", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "Hello", w.Body.String())
+
+	w = showGet(t, router, "/testdb/_design/app/_rewrite/docs/doc1", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var doc map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &doc))
+	assert.Equal(t, "Hello", doc["title"])
+
+	// method doesn't match
+	w = updatePost(t, router, "/testdb/_design/app/_rewrite/docs/doc1", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = showGet(t, router, "/testdb/_design/app/_rewrite/page/doc1/a/b", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "Helloa/b", w.Body.String())
+
+	w = showGet(t, router, "/testdb/_design/app/_rewrite/escape", nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = showGet(t, router, "/testdb/_design/app/_rewrite/loop", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "recursion limit")
+
+	w = showGet(t, router, "/testdb/_design/app/_rewrite/unknown", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestRewrite_Function(t *testing.T) {
+	s, router, cleanup := setupUpdateTest(t)
+	defer cleanup()
+
+	_, err := s.CreateDatabase(t.Context(), "testdb")
+	require.NoError(t, err)
+
+	vduPutDoc(t, router, "/testdb/doc1", map[string]interface{}{"title": "Hello"})
+	code, res := vduPutDoc(t, router, "/testdb/_design/app", map[string]interface{}{
+		"updates": map[string]interface{}{
+			"echo": `function(doc, req) { return [null, req.method + " " + req.body + " " + req.userCtx.name]; }`,
+		},
+		"rewrites": `function(req) {
+			var path = req.path.slice(4);
+			if (path[0] === "doc") {
+				return path[1];
+			}
+			if (path[0] === "up") {
+				return "../_all_dbs";
+			}
+			if (path[0] === "echo") {
+				return {path: "_design/app/_update/echo", method: "POST", body: "rewritten"};
+			}
+			return {code: 404, body: "not found: " + path.join("/")};
+		}`,
+	})
+	require.Equal(t, http.StatusCreated, code, res)
+
+	w := showGet(t, router, "/testdb/_design/app/_rewrite/doc/doc1", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var doc map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &doc))
+	assert.Equal(t, "Hello", doc["title"])
+
+	// the rewritten request keeps the session of the caller
+	w = showGet(t, router, "/testdb/_design/app/_rewrite/echo", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "POST rewritten admin", w.Body.String())
+
+	w = showGet(t, router, "/testdb/_design/app/_rewrite/up", nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = showGet(t, router, "/testdb/_design/app/_rewrite/x/y", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "not found: x/y", w.Body.String())
+}
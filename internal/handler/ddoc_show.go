@@ -0,0 +1,154 @@
+package handler
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/goydb/goydb/pkg/model"
+	"github.com/goydb/goydb/pkg/port"
+)
+
+func (s *DDShowFunction) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close() //nolint:errcheck
+
+	db := Database{Base: s.Base}.Do(w, r)
+	if db == nil {
+		return
+	}
+
+	session, ok := Authenticator{Base: s.Base}.DB(w, r, db)
+	if !ok {
+		return
+	}
+
+	ddocID := pathVar(r, "docid")
+	funcName := pathVar(r, "func")
+	showDocID := pathVar(r, "showdocid")
+
+	ctx := r.Context()
+
+	// Load design document
+	ddoc, err := db.GetDocument(ctx, "_design/"+ddocID)
+	if err != nil || ddoc == nil {
+		WriteError(w, http.StatusNotFound, "missing")
+		return
+	}
+
+	// Find the show function
+	fn, found := ddoc.Show(funcName)
+	if !found {
+		WriteError(w, http.StatusNotFound, "missing function "+funcName+" on design doc _design/"+ddocID)
+		return
+	}
+
+	// Load the document, if not found the function receives null
+	var doc *model.Document
+	if showDocID != "" {
+		doc, err = db.GetDocument(ctx, showDocID)
+		if err != nil || doc == nil || doc.Deleted {
+			doc = nil
+		}
+
+		// the response only depends on the document, the design
+		// document and the request, so it can be revalidated
+		etag := showETag(r, session, ddoc, doc)
+		w.Header().Set("ETag", etag)
+		if r.Method == http.MethodGet && etagMatches(r.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
+	// Get the engine for this language
+	builder := db.ShowDesignDocEngine(ddoc.Language())
+	if builder == nil {
+		WriteError(w, http.StatusInternalServerError, "no show engine for language "+ddoc.Language())
+		return
+	}
+
+	// Compile the function
+	showServer, err := builder(fn.ShowFnCode, ddoc)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to compile show function: "+err.Error())
+		return
+	}
+
+	reqObj := requestObject(r, session, db, showDocID)
+
+	// Execute the show function
+	result, err := showServer.ExecuteShow(ctx, docToJSObj(doc), reqObj)
+	if errors.Is(err, port.ErrNotAcceptable) {
+		WriteError(w, http.StatusNotAcceptable, err.Error())
+		return
+	} else if err != nil {
+		WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeShowResult(w, result)
+}
+
+// writeShowResult writes the response returned by a show function
+func writeShowResult(w http.ResponseWriter, result *port.ShowResult) {
+	w.Header().Set("Vary", "Accept")
+	for k, v := range result.Headers {
+		w.Header().Set(k, v)
+	}
+
+	statusCode := http.StatusOK
+	if result.Code != 0 {
+		statusCode = result.Code
+	}
+
+	if result.JSON != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(statusCode)
+		json.NewEncoder(w).Encode(result.JSON) //nolint:errcheck
+		return
+	}
+
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "text/html;charset=utf-8")
+	}
+	w.WriteHeader(statusCode)
+	w.Write([]byte(result.Body)) //nolint:errcheck
+}
+
+// showETag returns the ETag of a show response based on the revisions of
+// the design document and the document, the query, the negotiated content
+// and the user.
+func showETag(r *http.Request, session *model.Session, ddoc, doc *model.Document) string {
+	h := md5.New()
+	docRev := "null"
+	if doc != nil {
+		docRev = doc.Rev
+	}
+	parts := []string{
+		ddoc.Rev,
+		docRev,
+		r.URL.RawQuery,
+		r.Header.Get("Accept"),
+		session.Name,
+		strings.Join(session.Roles, ","),
+	}
+	for _, p := range parts {
+		h.Write([]byte(p)) //nolint:errcheck
+		h.Write([]byte{0}) //nolint:errcheck
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil)) + `"`
+}
+
+// etagMatches returns true if the If-None-Match header contains the etag.
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,161 @@
+//go:build !nogoja
+
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// createDesignDocWithShow creates a design doc with show functions.
+func createDesignDocWithShow(t *testing.T, router http.Handler, dbName, ddocName string, shows map[string]string) string {
+	t.Helper()
+	doc := map[string]interface{}{
+		"language": "javascript",
+		"shows":    shows,
+	}
+	code, result := vduPutDoc(t, router, "/"+dbName+"/_design/"+ddocName, doc)
+	require.Equal(t, http.StatusCreated, code, "failed to create design doc: %v", result)
+	return result["rev"].(string)
+}
+
+// showGet sends a GET to the show function endpoint with optional headers.
+func showGet(t *testing.T, router http.Handler, path string, headers map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest("GET", path, nil)
+	req.SetBasicAuth("admin", "secret")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestShow_Doc(t *testing.T) {
+	s, router, cleanup := setupUpdateTest(t)
+	defer cleanup()
+
+	_, err := s.CreateDatabase(t.Context(), "testdb")
+	require.NoError(t, err)
+
+	vduPutDoc(t, router, "/testdb/doc1", map[string]interface{}{"title": "Hello"})
+	createDesignDocWithShow(t, router, "testdb", "myddoc", map[string]string{
+		"title": `function(doc, req) {
+			if (!doc) { return {code: 404, body: "no doc " + req.id}; }
+			return {body: "<h1>" + doc.title + "</h1>", headers: {"X-Test": "yes"}};
+		}`,
+	})
+
+	w := showGet(t, router, "/testdb/_design/myddoc/_show/title/doc1", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "<h1>Hello</h1>", w.Body.String())
+	assert.Equal(t, "yes", w.Header().Get("X-Test"))
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/html")
+
+	w = showGet(t, router, "/testdb/_design/myddoc/_show/title/missing", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "no doc missing", w.Body.String())
+
+	w = showGet(t, router, "/testdb/_design/myddoc/_show/nope/doc1", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestShow_JSON(t *testing.T) {
+	s, router, cleanup := setupUpdateTest(t)
+	defer cleanup()
+
+	_, err := s.CreateDatabase(t.Context(), "testdb")
+	require.NoError(t, err)
+
+	createDesignDocWithShow(t, router, "testdb", "myddoc", map[string]string{
+		"info": `function(doc, req) { return {json: {query: req.query.q}}; }`,
+	})
+
+	w := showGet(t, router, "/testdb/_design/myddoc/_show/info?q=abc", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"query":"abc"}`, w.Body.String())
+}
+
+func TestShow_Provides(t *testing.T) {
+	s, router, cleanup := setupUpdateTest(t)
+	defer cleanup()
+
+	_, err := s.CreateDatabase(t.Context(), "testdb")
+	require.NoError(t, err)
+
+	vduPutDoc(t, router, "/testdb/doc1", map[string]interface{}{"title": "Hello"})
+	createDesignDocWithShow(t, router, "testdb", "myddoc", map[string]string{
+		"doc": `function(doc, req) {
+			registerType("text-csv", "text/csv");
+			provides("html", function() { return "<p>" + doc.title + "</p>"; });
+			provides("json", function() { return toJSON({title: doc.title}); });
+			provides("text-csv", function() { return "title\n" + doc.title; });
+		}`,
+	})
+
+	// first provided type without Accept header
+	w := showGet(t, router, "/testdb/_design/myddoc/_show/doc/doc1", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "<p>Hello</p>", w.Body.String())
+
+	// Accept header
+	w = showGet(t, router, "/testdb/_design/myddoc/_show/doc/doc1", map[string]string{
+		"Accept": "text/plain;q=0.5, application/json",
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "application/json")
+	assert.JSONEq(t, `{"title":"Hello"}`, w.Body.String())
+
+	// explicit format overrides the Accept header
+	w = showGet(t, router, "/testdb/_design/myddoc/_show/doc/doc1?format=text-csv", map[string]string{
+		"Accept": "application/json",
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/csv", w.Header().Get("Content-Type"))
+	assert.Equal(t, "title\nHello", w.Body.String())
+
+	// nothing acceptable
+	w = showGet(t, router, "/testdb/_design/myddoc/_show/doc/doc1", map[string]string{
+		"Accept": "image/png",
+	})
+	assert.Equal(t, http.StatusNotAcceptable, w.Code)
+}
+
+func TestShow_ETag(t *testing.T) {
+	s, router, cleanup := setupUpdateTest(t)
+	defer cleanup()
+
+	_, err := s.CreateDatabase(t.Context(), "testdb")
+	require.NoError(t, err)
+
+	_, res := vduPutDoc(t, router, "/testdb/doc1", map[string]interface{}{"title": "Hello"})
+	createDesignDocWithShow(t, router, "testdb", "myddoc", map[string]string{
+		"title": `function(doc, req) { return doc.title; }`,
+	})
+
+	w := showGet(t, router, "/testdb/_design/myddoc/_show/title/doc1", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	etag := w.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+
+	w = showGet(t, router, "/testdb/_design/myddoc/_show/title/doc1", map[string]string{
+		"If-None-Match": etag,
+	})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.String())
+
+	// a new revision of the document changes the etag
+	vduPutDoc(t, router, "/testdb/doc1", map[string]interface{}{"_rev": res["rev"], "title": "World"})
+	w = showGet(t, router, "/testdb/_design/myddoc/_show/title/doc1", map[string]string{
+		"If-None-Match": etag,
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "World", w.Body.String())
+	assert.NotEqual(t, etag, w.Header().Get("ETag"))
+}
@@ -3,7 +3,6 @@ package handler
 import (
 	"encoding/json"
 	"errors"
-	"io"
 	"net/http"
 	"strings"
 
@@ -46,14 +45,14 @@ func (s *DDUpdateFunction) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get the engine for this language
-	builder := db.UpdateEngine(ddoc.Language())
+	builder := db.UpdateDesignDocEngine(ddoc.Language())
 	if builder == nil {
 		WriteError(w, http.StatusInternalServerError, "no update engine for language "+ddoc.Language())
 		return
 	}
 
 	// Compile the function
-	updateServer, err := builder(fn.UpdateFnCode)
+	updateServer, err := builder(fn.UpdateFnCode, ddoc)
 	if err != nil {
 		WriteError(w, http.StatusInternalServerError, "failed to compile update function: "+err.Error())
 		return
@@ -70,33 +69,7 @@ func (s *DDUpdateFunction) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		// If not found, jsDoc stays nil — the function receives null
 	}
 
-	// Read request body
-	bodyBytes, _ := io.ReadAll(r.Body)
-	body := string(bodyBytes)
-
-	// Build the req object
-	queryParams := map[string]interface{}{}
-	for k, v := range r.URL.Query() {
-		if len(v) == 1 {
-			queryParams[k] = v[0]
-		} else {
-			queryParams[k] = v
-		}
-	}
-
-	reqObj := map[string]interface{}{
-		"body":     body,
-		"method":   r.Method,
-		"query":    queryParams,
-		"headers":  headerToMap(r.Header),
-		"userCtx":  userCtxToJS(session, db.Name()),
-		"secObj":   secObjToJS(ctx, db),
-		"id":       updateDocID,
-		"uuid":     uuid.NewV4().String(),
-		"path":     splitPath(r.URL.Path),
-		"peer":     r.RemoteAddr,
-		"raw_path": r.URL.RawPath,
-	}
+	reqObj := requestObject(r, session, db, updateDocID)
 
 	// Execute the update function
 	result, err := updateServer.ExecuteUpdate(ctx, jsDoc, reqObj)
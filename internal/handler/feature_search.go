@@ -17,6 +17,7 @@ func init() {
 
 		// Design document search/nouveau endpoints.
 		r.Methods("GET", "POST").Path("/{db}/_design/{docid}/_search/{index}").Handler(&DBSearch{Base: b})
+		r.Methods("POST").Path("/{db}/_design/{docid}/_search/{index}/_find").Handler(&DBSearchFind{Base: b})
 		r.Methods("GET").Path("/{db}/_design/{docid}/_search_info/{index}").Handler(&SearchInfo{Base: b})
 		r.Methods("POST").Path("/{db}/_design/{docid}/_nouveau/{index}").Handler(&NouveauSearch{Base: b})
 		r.Methods("GET").Path("/{db}/_design/{docid}/_nouveau_info/{index}").Handler(&NouveauInfo{Base: b})
@@ -0,0 +1,298 @@
+package handler
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goydb/goydb/internal/adapter/storage"
+	"github.com/goydb/goydb/pkg/model"
+)
+
+// findAllConcurrency is the maximum number of databases queried in parallel
+const findAllConcurrency = 8
+
+// FindAllQuery is a FindQuery executed on multiple databases.
+type FindAllQuery struct {
+	model.FindQuery
+	// Databases is a glob pattern (e.g. "userdb-*") or a list of
+	// database names and patterns.
+	Databases DatabaseList `json:"databases"`
+}
+
+// DatabaseList is a list of database names or glob patterns,
+// a single string is decoded as a list with one element.
+type DatabaseList []string
+
+func (dl *DatabaseList) UnmarshalJSON(data []byte) error {
+	var pattern string
+	if err := json.Unmarshal(data, &pattern); err == nil {
+		*dl = DatabaseList{pattern}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("databases must be a string or an array of strings")
+	}
+	*dl = list
+	return nil
+}
+
+// FindAllFailure reports a database the query failed on.
+type FindAllFailure struct {
+	DB     string `json:"db"`
+	Error  string `json:"error"`
+	Reason string `json:"reason"`
+}
+
+// FindAllResponse is the response of the cross database find.
+type FindAllResponse struct {
+	Docs           []map[string]interface{} `json:"docs"`
+	Bookmark       string                   `json:"bookmark,omitempty"`
+	Failures       []FindAllFailure         `json:"failures,omitempty"`
+	ExecutionStats *model.ExecutionStats    `json:"execution_stats,omitempty"`
+}
+
+// FindAll handles POST /_find.
+// It runs a Mango query on all databases matching the databases
+// of the request. Every document is tagged with its database in
+// the _db field. This is a goydb extension, server admins only.
+type FindAll struct {
+	Base
+}
+
+// findAllResult is the result of the query on a single database
+type findAllResult struct {
+	db    string
+	docs  []*model.Document
+	stats *model.ExecutionStats
+	err   error
+	// failure is the error reported for the database,
+	// unexpected errors fail the whole request
+	failure string
+}
+
+func (s *FindAll) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close() //nolint:errcheck
+
+	if _, ok := (Authenticator{Base: s.Base, RequiresAdmin: true}.Do(w, r)); !ok {
+		return
+	}
+
+	var find FindAllQuery
+	if err := json.NewDecoder(r.Body).Decode(&find); err != nil {
+		WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(find.Databases) == 0 {
+		WriteError(w, http.StatusBadRequest, "databases is required")
+		return
+	}
+	if find.Limit == 0 {
+		find.Limit = 25
+	}
+
+	cursors, err := decodeFindAllBookmark(find.Bookmark)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	names, err := s.matchDatabases(r, find.Databases)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	start := time.Now()
+	results := s.findAll(r, find, names, cursors)
+
+	// databases are merged in name order, a stable sort keeps
+	// that order for documents that compare equal
+	var response FindAllResponse
+	var stats model.ExecutionStats
+	var docs []*model.Document
+	origin := make(map[*model.Document]string)
+	for _, res := range results {
+		if res.err != nil && res.failure == "" {
+			s.Logger.Errorf(r.Context(), "find failed", "db", res.db, "error", res.err)
+			WriteError(w, http.StatusInternalServerError, res.err.Error())
+			return
+		}
+	}
+	for _, res := range results {
+		if res.err != nil {
+			response.Failures = append(response.Failures, FindAllFailure{
+				DB:     res.db,
+				Error:  res.failure,
+				Reason: res.err.Error(),
+			})
+			continue
+		}
+		stats.TotalKeysExamined += res.stats.TotalKeysExamined
+		stats.TotalDocsExamined += res.stats.TotalDocsExamined
+		for _, doc := range res.docs {
+			origin[doc] = res.db
+			docs = append(docs, doc)
+		}
+	}
+	find.SortDocuments(docs)
+
+	// skip and limit are applied to the merged documents, the cursor
+	// of a database advances to the last of its documents consumed
+	skip := find.Skip
+	if skip > len(docs) {
+		skip = len(docs)
+	}
+	end := skip + find.Limit
+	if end > len(docs) {
+		end = len(docs)
+	}
+	for _, doc := range docs[:end] {
+		cursors[origin[doc]] = find.Cursor(doc)
+	}
+	docs = docs[skip:end]
+
+	response.Docs = make([]map[string]interface{}, len(docs))
+	for i, doc := range docs {
+		data := doc.Data
+		if data == nil {
+			data = make(map[string]interface{})
+		}
+		data["_id"] = doc.ID
+		data["_rev"] = doc.Rev
+		if len(find.Fields) > 0 {
+			data = projectFields(data, find.Fields)
+		}
+		data["_db"] = origin[doc]
+		response.Docs[i] = data
+	}
+	if len(docs) > 0 {
+		response.Bookmark = encodeFindAllBookmark(cursors)
+	}
+	if find.ExecutionStats {
+		stats.ResultsReturned = len(docs)
+		stats.ExecutionTime = float64(time.Since(start)) / float64(time.Millisecond)
+		response.ExecutionStats = &stats
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response) // nolint: errcheck
+}
+
+// matchDatabases returns the sorted names of the databases matching the
+// list. Patterns only match system databases (starting with an underscore)
+// if the pattern starts with an underscore as well.
+func (s *FindAll) matchDatabases(r *http.Request, list DatabaseList) ([]string, error) {
+	all, err := s.Storage.Databases(r.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	selected := make(map[string]struct{})
+	for _, pattern := range list {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid database pattern %q: %w", pattern, err)
+		}
+		if !strings.ContainsAny(pattern, "*?[") {
+			// plain names are queried even if they don't exist,
+			// so that they are reported as failure
+			selected[pattern] = struct{}{}
+			continue
+		}
+		for _, name := range all {
+			if strings.HasPrefix(name, "_") && !strings.HasPrefix(pattern, "_") {
+				continue
+			}
+			if ok, _ := path.Match(pattern, name); ok {
+				selected[name] = struct{}{}
+			}
+		}
+	}
+
+	names := make([]string, 0, len(selected))
+	for name := range selected {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// findAll executes the query on all databases concurrently. Every database
+// returns the documents after its cursor, enough to fill the page after
+// the global skip. The results are in the order of names.
+func (s *FindAll) findAll(r *http.Request, find FindAllQuery, names []string, cursors map[string]*model.FindCursor) []findAllResult {
+	results := make([]findAllResult, len(names))
+	sem := make(chan struct{}, findAllConcurrency)
+	var wg sync.WaitGroup
+
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			res := &results[i]
+			res.db = name
+
+			db, err := s.Storage.Database(r.Context(), name)
+			switch {
+			case errors.Is(err, storage.ErrUnknownDatabase):
+				res.err, res.failure = err, "not_found"
+				return
+			case errors.Is(err, storage.ErrQuarantinedDatabase):
+				res.err, res.failure = err, "quarantined"
+				return
+			case err != nil:
+				res.err = err
+				return
+			}
+
+			// the skip is applied to the merged documents, as the
+			// skip of an unsorted FindDocs counts unmatched documents too
+			q := find.FindQuery
+			q.Bookmark = ""
+			q.After = cursors[name]
+			q.Skip = 0
+			q.Limit = find.Skip + find.Limit
+			res.docs, res.stats, res.err = db.FindDocs(r.Context(), q)
+			if res.err != nil {
+				res.failure = "find_failed"
+			}
+		}(i, name)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// decodeFindAllBookmark decodes the per database cursors of the bookmark,
+// the cursor is the position of the last document returned of a database
+func decodeFindAllBookmark(bookmark string) (map[string]*model.FindCursor, error) {
+	cursors := make(map[string]*model.FindCursor)
+	if bookmark == "" {
+		return cursors, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(bookmark)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bookmark: %w", err)
+	}
+	if err := json.Unmarshal(data, &cursors); err != nil {
+		return nil, fmt.Errorf("invalid bookmark: %w", err)
+	}
+	return cursors, nil
+}
+
+// encodeFindAllBookmark encodes the per database cursors as bookmark
+func encodeFindAllBookmark(cursors map[string]*model.FindCursor) string {
+	data, _ := json.Marshal(cursors) // map keys are sorted
+	return base64.RawURLEncoding.EncodeToString(data)
+}
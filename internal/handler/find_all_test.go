@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/goydb/goydb/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func postFindAll(t *testing.T, router http.Handler, body string) (FindAllResponse, int) {
+	t.Helper()
+	req := httptest.NewRequest("POST", "/_find", strings.NewReader(body))
+	req.SetBasicAuth("admin", "secret")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var result FindAllResponse
+	if w.Code == http.StatusOK {
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&result))
+	}
+	return result, w.Code
+}
+
+func TestFindAll_SortLimitBookmark(t *testing.T) {
+	s, router, cleanup := setupRevsDiffTest(t)
+	defer cleanup()
+
+	ctx := t.Context()
+	ages := map[string][]float64{
+		"userdb-a": {30, 10, 50},
+		"userdb-b": {20, 40},
+		"other":    {5},
+	}
+	for name, values := range ages {
+		db, err := s.CreateDatabase(ctx, name)
+		require.NoError(t, err)
+		for i, age := range values {
+			_, err = db.PutDocument(ctx, &model.Document{
+				ID:   fmt.Sprintf("doc%d", i),
+				Data: map[string]interface{}{"type": "user", "age": age},
+			})
+			require.NoError(t, err)
+		}
+	}
+
+	query := `{"databases": "userdb-*", "selector": {"type": "user"}, "sort": ["age"], "limit": 2, "bookmark": %q}`
+
+	var ages2 []float64
+	var dbs []string
+	bookmark := ""
+	for page := 0; page < 5; page++ {
+		result, code := postFindAll(t, router, fmt.Sprintf(query, bookmark))
+		require.Equal(t, http.StatusOK, code)
+		if len(result.Docs) == 0 {
+			break
+		}
+		assert.LessOrEqual(t, len(result.Docs), 2)
+		for _, doc := range result.Docs {
+			ages2 = append(ages2, doc["age"].(float64))
+			dbs = append(dbs, doc["_db"].(string))
+		}
+		bookmark = result.Bookmark
+	}
+
+	assert.Equal(t, []float64{10, 20, 30, 40, 50}, ages2)
+	assert.Equal(t, []string{"userdb-a", "userdb-b", "userdb-a", "userdb-b", "userdb-a"}, dbs)
+}
+
+func TestFindAll_PartialFailure(t *testing.T) {
+	s, router, cleanup := setupRevsDiffTest(t)
+	defer cleanup()
+
+	ctx := t.Context()
+	db, err := s.CreateDatabase(ctx, "userdb-a")
+	require.NoError(t, err)
+	_, err = db.PutDocument(ctx, &model.Document{ID: "x", Data: map[string]interface{}{"type": "user"}})
+	require.NoError(t, err)
+
+	result, code := postFindAll(t, router, `{"databases": ["userdb-a", "missing"], "selector": {"type": "user"}}`)
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, result.Docs, 1)
+	assert.Equal(t, "userdb-a", result.Docs[0]["_db"])
+	require.Len(t, result.Failures, 1)
+	assert.Equal(t, "missing", result.Failures[0].DB)
+	assert.Equal(t, "not_found", result.Failures[0].Error)
+}
+
+func TestFindAll_UnsortedBookmark(t *testing.T) {
+	s, router, cleanup := setupRevsDiffTest(t)
+	defer cleanup()
+
+	ctx := t.Context()
+	for _, name := range []string{"userdb-a", "userdb-b"} {
+		db, err := s.CreateDatabase(ctx, name)
+		require.NoError(t, err)
+		for i := 0; i < 5; i++ {
+			_, err = db.PutDocument(ctx, &model.Document{
+				ID:   fmt.Sprintf("doc%d", i),
+				Data: map[string]interface{}{"type": "user"},
+			})
+			require.NoError(t, err)
+		}
+		// documents that don't match don't move the cursor
+		_, err = db.PutDocument(ctx, &model.Document{ID: "doc2a", Data: map[string]interface{}{"type": "group"}})
+		require.NoError(t, err)
+	}
+
+	query := `{"databases": "userdb-*", "selector": {"type": "user"}, "limit": 3, "bookmark": %q}`
+
+	var ids []string
+	bookmark := ""
+	for page := 0; page < 10; page++ {
+		result, code := postFindAll(t, router, fmt.Sprintf(query, bookmark))
+		require.Equal(t, http.StatusOK, code)
+		if len(result.Docs) == 0 {
+			break
+		}
+		for _, doc := range result.Docs {
+			ids = append(ids, doc["_db"].(string)+"/"+doc["_id"].(string))
+		}
+		bookmark = result.Bookmark
+	}
+
+	assert.Equal(t, []string{
+		"userdb-a/doc0", "userdb-a/doc1", "userdb-a/doc2", "userdb-a/doc3", "userdb-a/doc4",
+		"userdb-b/doc0", "userdb-b/doc1", "userdb-b/doc2", "userdb-b/doc3", "userdb-b/doc4",
+	}, ids)
+}
+
+func TestFindAll_RequiresAdmin(t *testing.T) {
+	_, router, cleanup := setupRevsDiffTest(t)
+	defer cleanup()
+
+	req := httptest.NewRequest("POST", "/_find", strings.NewReader(`{"databases": "*", "selector": {}}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
@@ -1,8 +1,12 @@
 package handler
 
 import (
+	"context"
+	"errors"
 	"net/http"
+	"net/url"
 
+	"github.com/goydb/goydb/internal/adapter/storage"
 	"github.com/goydb/goydb/pkg/port"
 )
 
@@ -13,6 +17,10 @@ type Database struct {
 func (c Database) Do(w http.ResponseWriter, r *http.Request) port.Database {
 	dbName := pathVar(r, "db")
 	db, err := c.Storage.Database(r.Context(), dbName)
+	if errors.Is(err, storage.ErrQuarantinedDatabase) {
+		WriteError(w, http.StatusInternalServerError, err.Error())
+		return nil
+	}
 	if err != nil {
 		c.Logger.Warnf(r.Context(), "database not found", "dbName", dbName, "error", err)
 		WriteError(w, http.StatusNotFound, "Database does not exist.")
@@ -20,3 +28,27 @@ func (c Database) Do(w http.ResponseWriter, r *http.Request) port.Database {
 	}
 	return db
 }
+
+// updateMode returns the update parameter of an index query,
+// the legacy stale=ok and stale=update_after are mapped to
+// update=false and update=lazy.
+func updateMode(options url.Values) string {
+	switch options.Get("stale") {
+	case "ok":
+		return "false"
+	case "update_after":
+		return "lazy"
+	}
+	return options.Get("update")
+}
+
+// waitForIndex blocks until the index processed all changes of
+// the database. With update=false or update=lazy the index is
+// queried as is, a building index is completed in the background.
+func waitForIndex(ctx context.Context, db port.Database, name, update string) error {
+	switch update {
+	case "false", "lazy":
+		return nil
+	}
+	return db.WaitIndex(ctx, name)
+}
@@ -0,0 +1,197 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/goydb/goydb/internal/adapter/reducer"
+	"github.com/goydb/goydb/internal/controller"
+	"github.com/goydb/goydb/pkg/model"
+	"github.com/goydb/goydb/pkg/port"
+)
+
+// builtinReducers are the reduce functions implemented by goydb
+// itself, the native reducers with parameters are checked separately
+var builtinReducers = map[string]bool{
+	"_sum":                   true,
+	"_count":                 true,
+	"_stats":                 true,
+	"_approx_count_distinct": true,
+}
+
+// DesignDocError is a function of a design document that can't be compiled
+type DesignDocError struct {
+	// Function is the path of the function in the design
+	// document, e.g. "views.by_name.map" or "validate_doc_update"
+	Function string `json:"function"`
+	Reason   string `json:"reason"`
+}
+
+func (e DesignDocError) Error() string {
+	return e.Function + ": " + e.Reason
+}
+
+// CompileDesignDoc compiles every function of the design document with
+// the engine of its language and returns the functions that failed,
+// sorted by path. Functions of languages without a registered engine
+// are not checked, they fail when they are used.
+func CompileDesignDoc(db port.Database, ddoc *model.Document) []DesignDocError {
+	lang := ddoc.Language()
+	var errs []DesignDocError
+	check := func(function string, err error) {
+		if err != nil {
+			errs = append(errs, DesignDocError{Function: function, Reason: err.Error()})
+		}
+	}
+
+	for _, f := range ddoc.Functions() {
+		switch f.Type {
+		case model.ViewFn:
+			if builder := db.ViewDesignDocEngine(lang); builder != nil && f.MapFn != "" {
+				_, err := builder(f.MapFn, ddoc)
+				check("views."+f.Name+".map", err)
+			}
+			check("views."+f.Name+".reduce", compileReduce(db, lang, f.ReduceFn, ddoc))
+		case model.SearchFn:
+			if builder := db.ViewDesignDocEngine(lang); builder != nil && f.SearchFn != "" {
+				_, err := builder(f.SearchFn, ddoc)
+				check("indexes."+f.Name+".index", err)
+			}
+		}
+	}
+
+	if builder := db.FilterDesignDocEngine(lang); builder != nil {
+		for _, f := range ddoc.Filters() {
+			_, err := builder(f.FilterFn, ddoc)
+			check("filters."+f.Name, err)
+		}
+	}
+	if builder := db.UpdateDesignDocEngine(lang); builder != nil {
+		for _, f := range ddoc.Updates() {
+			_, err := builder(f.UpdateFnCode, ddoc)
+			check("updates."+f.Name, err)
+		}
+	}
+	if builder := db.ShowDesignDocEngine(lang); builder != nil {
+		for _, name := range functionNames(ddoc, "shows") {
+			if f, ok := ddoc.Show(name); ok {
+				_, err := builder(f.ShowFnCode, ddoc)
+				check("shows."+name, err)
+			}
+		}
+	}
+	if builder := db.ListDesignDocEngine(lang); builder != nil {
+		for _, name := range functionNames(ddoc, "lists") {
+			if f, ok := ddoc.List(name); ok {
+				_, err := builder(f.ListFnCode, ddoc)
+				check("lists."+name, err)
+			}
+		}
+	}
+	if builder := db.RewriteDesignDocEngine(lang); builder != nil {
+		if f, ok := ddoc.RewriteFunction(); ok {
+			_, err := builder(f.RewriteFnCode, ddoc)
+			check("rewrites", err)
+		}
+	}
+	if builder := db.ValidateDesignDocEngine(lang); builder != nil {
+		if fn, ok := ddoc.Data["validate_doc_update"].(string); ok && fn != "" {
+			_, err := builder(fn, ddoc)
+			check("validate_doc_update", err)
+		}
+	}
+
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Function < errs[j].Function })
+	return errs
+}
+
+// compileReduce checks the built-in reduce functions and compiles
+// custom reduce functions with the reducer engine of the language
+func compileReduce(db port.Database, lang, fn string, ddoc *model.Document) error {
+	if fn == "" || builtinReducers[fn] {
+		return nil
+	}
+	_, ok, err := reducer.NewNative(fn)
+	if ok || err != nil {
+		return err
+	}
+	if strings.HasPrefix(fn, "_") {
+		return fmt.Errorf("unknown built-in reduce function %q", fn)
+	}
+	builder := db.ReducerDesignDocEngine(lang)
+	if builder == nil {
+		return nil
+	}
+	_, err = builder(fn, ddoc)
+	return err
+}
+
+// dbCopyTargets returns the dbcopy target databases of the views
+// of the design document
+func dbCopyTargets(ddoc *model.Document) []string {
+	var targets []string
+	for _, f := range ddoc.Functions() {
+		if f.Type != model.ViewFn {
+			continue
+		}
+		if view, ok := ddoc.View(f.Name); ok && view.DBCopy != "" && view.ReduceFn != "" {
+			targets = append(targets, view.DBCopy)
+		}
+	}
+	return targets
+}
+
+// authorizeDBCopies checks that the session is allowed to write
+// the dbcopy target databases of the design document
+func authorizeDBCopies(ctx context.Context, s port.Storage, ddoc *model.Document, session *model.Session) error {
+	for _, target := range dbCopyTargets(ddoc) {
+		err := controller.AuthorizeDBCopy(ctx, s, target, session)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setDBCopyAuthor records the session as the author of the revision
+// of the design document if the design document has dbcopy views
+func setDBCopyAuthor(ctx context.Context, db port.Database, ddoc *model.Document, rev string, session *model.Session) error {
+	if len(dbCopyTargets(ddoc)) == 0 {
+		return nil
+	}
+	return controller.SetDBCopyAuthor(ctx, db, ddoc.ID, rev, session)
+}
+
+// functionNames returns the names of the functions in the section
+// of the design document, e.g. "shows"
+func functionNames(ddoc *model.Document, section string) []string {
+	fns, _ := ddoc.Data[section].(map[string]interface{})
+	names := make([]string, 0, len(fns))
+	for name := range fns {
+		names = append(names, name)
+	}
+	return names
+}
+
+// writeDesignDocErrors writes the bad request response for the
+// design document functions that failed to compile
+func writeDesignDocErrors(w http.ResponseWriter, errs []DesignDocError) bool {
+	if len(errs) == 0 {
+		return false
+	}
+	WriteError(w, http.StatusBadRequest, designDocErrorsReason(errs))
+	return true
+}
+
+// designDocErrorsReason returns the reason of the error for the
+// design document functions that failed to compile
+func designDocErrorsReason(errs []DesignDocError) string {
+	reasons := make([]string, len(errs))
+	for i, err := range errs {
+		reasons[i] = err.Error()
+	}
+	return "invalid design document: " + strings.Join(reasons, "; ")
+}
@@ -7,14 +7,19 @@ import (
 )
 
 func WriteError(w http.ResponseWriter, status int, reason string) {
-	w.WriteHeader(status)
 	statusText := strings.ToLower(http.StatusText(status))
 	statusText = strings.ReplaceAll(statusText, " ", "_")
 	statusText = strings.ReplaceAll(statusText, "'", "")
+	WriteNamedError(w, status, statusText, reason)
+}
 
+// WriteNamedError writes an error with a name that isn't
+// derived from the status, e.g. query_parse_error
+func WriteNamedError(w http.ResponseWriter, status int, name, reason string) {
+	w.WriteHeader(status)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(ErrorResponse{ // nolint: errcheck
-		Error:  statusText,
+		Error:  name,
 		Reason: reason,
 	})
 }
@@ -0,0 +1,20 @@
+package handler
+
+import (
+	"time"
+
+	"github.com/goydb/goydb/internal/service"
+)
+
+// findCache returns the Mango result cache configured with the limits of
+// the find_cache config section, or nil if the cache is disabled.
+func findCache(config *ConfigStore, cache *service.FindCache) *service.FindCache {
+	if cache == nil || !configBool(config, "find_cache", "enable", false) {
+		return nil
+	}
+	cache.Configure(
+		configInt64(config, "find_cache", "max_memory"),
+		time.Duration(configInt64(config, "find_cache", "ttl"))*time.Second,
+	)
+	return cache
+}
@@ -2,6 +2,7 @@ package handler
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/url"
 	"strconv"
 	"strings"
@@ -107,3 +108,52 @@ func parseViewQueryOptions(q *port.AllDocsQuery, opts url.Values) {
 		}
 	}
 }
+
+// queryParseError is an invalid query parameter, it is
+// reported as 400 query_parse_error
+type queryParseError struct {
+	reason string
+}
+
+func (e *queryParseError) Error() string {
+	return e.reason
+}
+
+// validateViewQueryOptions checks the types of the view query parameters
+func validateViewQueryOptions(opts url.Values) error {
+	for _, name := range []string{"skip", "limit", "group_level"} {
+		if v := opts.Get(name); v != "" {
+			if i, err := strconv.ParseInt(v, 10, 64); err != nil || i < 0 {
+				return &queryParseError{fmt.Sprintf("Invalid value for positive integer: %q", v)}
+			}
+		}
+	}
+	for _, name := range []string{"reduce", "include_docs", "descending", "inclusive_end", "update_seq", "sorted"} {
+		if v := opts.Get(name); v != "" && v != "true" && v != "false" {
+			return &queryParseError{fmt.Sprintf("Invalid boolean parameter: %q", v)}
+		}
+	}
+	for _, name := range []string{"key", "keys", "startkey", "start_key", "endkey", "end_key"} {
+		if v := opts.Get(name); v != "" && !json.Valid([]byte(v)) {
+			return &queryParseError{fmt.Sprintf("Invalid JSON value for %s: %q", name, v)}
+		}
+	}
+	if v := opts.Get("keys"); v != "" {
+		var keys []interface{}
+		if err := json.Unmarshal([]byte(v), &keys); err != nil {
+			return &queryParseError{"`keys` member must be an array."}
+		}
+		if opts.Get("key") != "" {
+			return &queryParseError{"`keys` is incompatible with `key`"}
+		}
+	}
+	switch unquoteJSON(opts.Get("update")) {
+	case "", "true", "false", "lazy":
+	default:
+		return &queryParseError{fmt.Sprintf("Invalid value for `update`: %q", opts.Get("update"))}
+	}
+	if opts.Get("reduce") == "false" && (opts.Get("group") == "true" || opts.Get("group_level") != "") {
+		return &queryParseError{"Invalid use of grouping on a map view."}
+	}
+	return nil
+}
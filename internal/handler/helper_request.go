@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/goydb/goydb/pkg/model"
+	"github.com/goydb/goydb/pkg/port"
+	uuid "github.com/satori/go.uuid"
+)
+
+// requestObject builds the CouchDB request object passed to design
+// document functions (update, show, list). id is the document id
+// of the request, if any.
+func requestObject(r *http.Request, session *model.Session, db port.Database, id string) map[string]interface{} {
+	bodyBytes, _ := io.ReadAll(r.Body)
+
+	queryParams := map[string]interface{}{}
+	for k, v := range r.URL.Query() {
+		if len(v) == 1 {
+			queryParams[k] = v[0]
+		} else {
+			queryParams[k] = v
+		}
+	}
+
+	return map[string]interface{}{
+		"body":     string(bodyBytes),
+		"method":   r.Method,
+		"query":    queryParams,
+		"headers":  headerToMap(r.Header),
+		"userCtx":  userCtxToJS(session, db.Name()),
+		"secObj":   secObjToJS(r.Context(), db),
+		"id":       id,
+		"uuid":     uuid.NewV4().String(),
+		"path":     splitPath(r.URL.Path),
+		"peer":     r.RemoteAddr,
+		"raw_path": r.URL.RawPath,
+	}
+}
@@ -51,7 +51,8 @@ func validateDocUpdate(ctx context.Context, db port.Database, logger port.Logger
 
 	for _, ddoc := range docs {
 		fnStr, ok := ddoc.Data["validate_doc_update"].(string)
-		if !ok || fnStr == "" {
+		// staged design documents are only active after the promotion
+		if !ok || fnStr == "" || ddoc.IsStagedDesignDoc() {
 			continue
 		}
 
@@ -62,14 +63,14 @@ func validateDocUpdate(ctx context.Context, db port.Database, logger port.Logger
 		}
 
 		lang := ddoc.Language()
-		builder := db.ValidateEngine(lang)
+		builder := db.ValidateDesignDocEngine(lang)
 		if builder == nil {
 			// No engine for this language — skip.
 			logger.Warnf(ctx, "no validate engine for language", "language", lang, "ddoc", ddoc.ID)
 			continue
 		}
 
-		vs, err := builder(fnStr)
+		vs, err := builder(fnStr, ddoc)
 		if err != nil {
 			// Compilation error — log and skip to avoid blocking all writes.
 			logger.Warnf(ctx, "failed to compile validate_doc_update", "ddoc", ddoc.ID, "error", err)
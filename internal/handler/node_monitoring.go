@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"net/http"
 	"runtime"
+
+	"github.com/goydb/goydb/internal/service"
 )
 
 // NodeStats handles GET /_node/{node}/_stats.
@@ -14,9 +16,22 @@ type NodeStats struct {
 func (s *NodeStats) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close() //nolint:errcheck
 
+	var cache service.FindCacheStats
+	if s.FindCache != nil {
+		cache = s.FindCache.Stats()
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{ // nolint: errcheck
+		"mango": map[string]interface{}{
+			"result_cache_hits":    map[string]interface{}{"value": cache.Hits, "type": "counter", "desc": "number of _find requests served from the result cache"},
+			"result_cache_misses":  map[string]interface{}{"value": cache.Misses, "type": "counter", "desc": "number of _find requests not found in the result cache"},
+			"result_cache_entries": map[string]interface{}{"value": cache.Entries, "type": "gauge", "desc": "number of cached _find results"},
+			"result_cache_memory":  map[string]interface{}{"value": cache.Memory, "type": "gauge", "desc": "estimated size of the cached _find results in bytes"},
+		},
 		"couchdb": map[string]interface{}{
+			"open_databases":        map[string]interface{}{"value": s.Storage.OpenDatabases(), "type": "gauge", "desc": "number of open databases"},
+			"quarantined_databases": map[string]interface{}{"value": len(s.Storage.QuarantinedDatabases()), "type": "gauge", "desc": "number of databases that failed to open"},
 			"httpd_request_methods": map[string]interface{}{
 				"GET":    map[string]interface{}{"value": 0, "type": "counter", "desc": "number of HTTP GET requests"},
 				"POST":   map[string]interface{}{"value": 0, "type": "counter", "desc": "number of HTTP POST requests"},
@@ -54,6 +69,7 @@ func (s *NodeSystem) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		"run_queue":              runtime.NumGoroutine(),
 		"message_queues":         0,
 		"internal_replication_jobs": 0,
+		"quarantined_databases":     s.Storage.QuarantinedDatabases(),
 	})
 }
 
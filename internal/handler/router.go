@@ -18,6 +18,7 @@ type Router struct {
 	Admins       model.AdminUsers
 	Config       *ConfigStore
 	Replication  *service.Replication
+	FindCache    *service.FindCache
 	Logger       port.Logger
 }
 
@@ -30,6 +31,9 @@ func (router Router) Build(r *mux.Router) error {
 		}
 		b.Config = NewConfigStore(configPath, b.Logger)
 	}
+	if b.FindCache == nil {
+		b.FindCache = service.NewFindCache()
+	}
 
 	r.Methods("GET").Path("/_up").Handler(&Up{Base: b})
 	r.Methods("GET").Path("/_config").Handler(&ConfigAll{Base: b})
@@ -57,8 +61,13 @@ func (router Router) Build(r *mux.Router) error {
 	r.Methods("GET").Path("/_db_updates").Handler(&DBUpdates{Base: b})
 	r.Methods("GET").Path("/_all_dbs").Handler(&DBAll{Base: b})
 	r.Methods("POST").Path("/_dbs_info").Handler(&DBsInfo{Base: b})
+	r.Methods("POST").Path("/_find").Handler(&FindAll{Base: b})
 	r.Methods("GET").Path("/_uuids").Handler(&UUIDs{})
 	r.Methods("GET").Path("/_active_tasks").Handler(&ActiveTasks{Base: b})
+	r.Methods("PUT").Path("/_active_tasks/{db}").Handler(&ActiveTasksPut{Base: b})
+	r.Methods("PUT").Path("/_active_tasks/{db}/{task_id}").Handler(&ActiveTasksPut{Base: b})
+	r.Methods("DELETE").Path("/_active_tasks/{db}").Handler(&ActiveTasksDelete{Base: b})
+	r.Methods("DELETE").Path("/_active_tasks/{db}/{task_id}").Handler(&ActiveTasksDelete{Base: b})
 	r.Methods("POST").Path("/_replicate").Handler(&Replicate{Base: b})
 	r.Methods("GET").Path("/_scheduler/jobs").Handler(&SchedulerJobs{Base: b})
 	r.Methods("GET").Path("/_scheduler/docs").Handler(&SchedulerDocs{Base: b})
@@ -89,11 +98,13 @@ func (router Router) Build(r *mux.Router) error {
 	r.Methods("PUT").Path("/{db}/_purged_infos_limit").Handler(&DBPurgedInfosLimitPut{Base: b})
 	r.Methods("POST").Path("/{db}/_find").Handler(&DBDocsFind{Base: b})
 	r.Methods("POST").Path("/{db}/_explain").Handler(&DBDocsExplain{Base: b})
+	r.Methods("POST").Path("/{db}/_aggregate").Handler(&DBAggregate{Base: b})
 	r.Methods("POST").Path("/{db}/_index").Handler(&DBIndexPost{Base: b})
 	r.Methods("GET").Path("/{db}/_index").Handler(&DBIndexGet{Base: b})
 	r.Methods("DELETE").Path("/{db}/_index/{ddoc}/json/{name}").Handler(&DBIndexDelete{Base: b})
 	r.Methods("POST").Path("/{db}/_design_docs/queries").Handler(&DBDesignDocsQueries{Base: b})
 	r.Methods("GET", "POST").Path("/{db}/_design_docs").Handler(&DBDesignDocs{Base: b})
+	r.Methods("POST").Path("/{db}/_design_validate").Handler(&DBDesignValidate{Base: b})
 	r.Methods("POST").Path("/{db}/_bulk_get").Handler(&DBDocsBulkGet{Base: b})
 	r.Methods("POST").Path("/{db}/_missing_revs").Handler(&DBMissingRevs{Base: b})
 
@@ -102,8 +113,11 @@ func (router Router) Build(r *mux.Router) error {
 
 	r.Methods("GET").Path("/{db}/_revs_limit").Handler(&DBRevsLimitGet{Base: b})
 	r.Methods("PUT").Path("/{db}/_revs_limit").Handler(&DBRevsLimitPut{Base: b})
+	r.Methods("GET").Path("/{db}/_index_mode").Handler(&DBIndexModeGet{Base: b})
+	r.Methods("PUT").Path("/{db}/_index_mode").Handler(&DBIndexModePut{Base: b})
 
 	r.Methods("POST").Path("/{db}/_design/{docid}/_view/{view}/queries").Handler(&DBViewQueries{Base: b})
+	r.Methods("POST").Path("/{db}/_design/{docid}/_view/{view}/_find").Handler(&DBViewFind{Base: b})
 	r.Methods("GET", "POST").Path("/{db}/_design/{docid}/_view/{view}").Handler(&DBView{Base: b})
 	for _, hook := range routerHooks {
 		hook(r, b)
@@ -112,10 +126,13 @@ func (router Router) Build(r *mux.Router) error {
 	r.Methods("GET", "POST").Path("/{db}/_design/{docid}/_show/{func}").Handler(&DDShowFunction{Base: b})
 	r.Methods("GET", "POST").Path("/{db}/_design/{docid}/_show/{func}/{showdocid}").Handler(&DDShowFunction{Base: b})
 	r.Methods("GET", "POST").Path("/{db}/_design/{docid}/_list/{func}/{view}").Handler(&DDListFunction{Base: b})
+	r.Methods("GET", "POST").Path("/{db}/_design/{docid}/_list/{func}/{viewdocid}/{view}").Handler(&DDListFunction{Base: b})
 	r.Methods("POST", "PUT").Path("/{db}/_design/{docid}/_update/{func}").Handler(&DDUpdateFunction{Base: b})
 	r.Methods("POST", "PUT").Path("/{db}/_design/{docid}/_update/{func}/{updatedocid}").Handler(&DDUpdateFunction{Base: b})
-	r.PathPrefix("/{db}/_design/{docid}/_rewrite/").Handler(&DDRewrite{Base: b})
+	r.Path("/{db}/_design/{docid}/_rewrite").Handler(&DDRewrite{Base: b, Router: r})
+	r.PathPrefix("/{db}/_design/{docid}/_rewrite/").Handler(&DDRewrite{Base: b, Router: r})
 	r.Methods("GET").Path("/{db}/_design/{docid}/_info").Handler(&DBIndexInfo{Base: b})
+	r.Methods("POST").Path("/{db}/_design/{docid}/_promote").Handler(&DBDesignDocPromote{Base: b})
 	r.Methods("HEAD").Path("/{db}/_design/{docid}").Handler(&DBDocHead{Base: b, Design: true})
 	r.Methods("GET").Path("/{db}/_design/{docid}").Handler(&DBDocGet{Base: b, Design: true})
 	r.Methods("PUT").Path("/{db}/_design/{docid}").Handler(&DBDocPut{Base: b})
@@ -9,7 +9,8 @@ import (
 )
 
 // SearchCleanup handles POST /{db}/_search_cleanup and POST /{db}/_nouveau_cleanup.
-// Returns ok; no-op for embedded server without full-text search indices.
+// Removes the search index directories (and other indices) that don't belong
+// to a design document anymore.
 type SearchCleanup struct {
 	Base
 }
@@ -21,6 +22,15 @@ func (s *SearchCleanup) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if db == nil {
 		return
 	}
+	if _, ok := (Authenticator{Base: s.Base, RequiresAdmin: true}.DB(w, r, db)); !ok {
+		return
+	}
+
+	_, err := db.ViewCleanup(r.Context())
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]bool{"ok": true}) // nolint: errcheck
@@ -27,9 +27,9 @@ func TestSearchCleanup(t *testing.T) {
 
 	require.Equal(t, http.StatusOK, w.Code)
 
-	var result map[string]bool
+	var result map[string]interface{}
 	require.NoError(t, json.NewDecoder(w.Body).Decode(&result))
-	assert.True(t, result["ok"])
+	assert.Equal(t, true, result["ok"])
 }
 
 func TestNouveauCleanup(t *testing.T) {
@@ -46,9 +46,9 @@ func TestNouveauCleanup(t *testing.T) {
 
 	require.Equal(t, http.StatusOK, w.Code)
 
-	var result map[string]bool
+	var result map[string]interface{}
 	require.NoError(t, json.NewDecoder(w.Body).Decode(&result))
-	assert.True(t, result["ok"])
+	assert.Equal(t, true, result["ok"])
 }
 
 func TestSearchAnalyze(t *testing.T) {
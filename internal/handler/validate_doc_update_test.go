@@ -30,14 +30,14 @@ func setupVDUTest(t *testing.T) (*storage.Storage, *mux.Router, func()) {
 	log := logger.NewNoLog()
 	s, err := storage.Open(dir,
 		storage.WithLogger(log),
-		storage.WithViewEngine("", gojaview.NewViewServer),
-		storage.WithViewEngine("javascript", gojaview.NewViewServer),
-		storage.WithFilterEngine("", gojaview.NewFilterServer),
-		storage.WithFilterEngine("javascript", gojaview.NewFilterServer),
-		storage.WithReducerEngine("", gojaview.NewReducerBuilder(log)),
-		storage.WithReducerEngine("javascript", gojaview.NewReducerBuilder(log)),
-		storage.WithValidateEngine("", gojaview.NewValidateServerBuilder(log)),
-		storage.WithValidateEngine("javascript", gojaview.NewValidateServerBuilder(log)),
+		storage.WithViewDesignDocEngine("", gojaview.NewViewServer),
+		storage.WithViewDesignDocEngine("javascript", gojaview.NewViewServer),
+		storage.WithFilterDesignDocEngine("", gojaview.NewFilterServer),
+		storage.WithFilterDesignDocEngine("javascript", gojaview.NewFilterServer),
+		storage.WithReducerDesignDocEngine("", gojaview.NewReducerBuilder(log)),
+		storage.WithReducerDesignDocEngine("javascript", gojaview.NewReducerBuilder(log)),
+		storage.WithValidateDesignDocEngine("", gojaview.NewValidateServerBuilder(log)),
+		storage.WithValidateDesignDocEngine("javascript", gojaview.NewValidateServerBuilder(log)),
 	)
 	require.NoError(t, err)
 
@@ -0,0 +1,430 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/goydb/goydb/pkg/model"
+	"github.com/goydb/goydb/pkg/port"
+	"github.com/goydb/goydb/pkg/zipvfs"
+	"github.com/mitchellh/mapstructure"
+)
+
+// vhostsDB is the database containing the virtual host documents
+const vhostsDB = "_vhosts"
+
+// VirtualHosts routes requests by their Host header. Virtual hosts are
+// configured in the vhosts config section (CouchDB style, the host is
+// mapped to a path or an URL) and as documents of the _vhosts database.
+// The table of virtual hosts is cached, it is reloaded if the configuration
+// or a document changed.
+// Requests for other hosts are passed to Handler unchanged.
+type VirtualHosts struct {
+	Base
+	Handler http.Handler
+
+	mu      sync.Mutex
+	loaded  bool
+	version uint64 // of the configuration the routes were loaded from
+	db      port.Database
+	cancel  context.CancelFunc
+	changed atomic.Bool
+	routes  []vhostRoute
+}
+
+// virtualHost is a loaded virtual host configuration
+type virtualHost struct {
+	config   model.VirtualHostConfiguration
+	prefixes []string // proxy prefixes, longest first
+	reverse  map[string]*httputil.ReverseProxy
+	static   http.Handler
+}
+
+// vhostRoute is a domain pattern of a virtual host. Labels of the
+// pattern are literals, variables (:name) or a leading wildcard (*).
+type vhostRoute struct {
+	labels []string
+	port   string
+	host   *virtualHost
+}
+
+func (s *VirtualHosts) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	routes := s.load(r.Context())
+	if len(routes) == 0 {
+		s.Handler.ServeHTTP(w, r)
+		return
+	}
+
+	host, port, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		host = r.Host
+	}
+	labels := strings.Split(strings.TrimSuffix(strings.ToLower(host), "."), ".")
+
+	for _, route := range routes {
+		bindings, ok := route.match(labels, port)
+		if !ok {
+			continue
+		}
+		if s.globalHandler(r.URL.EscapedPath()) {
+			break
+		}
+		s.serve(w, r, route.host, bindings)
+		return
+	}
+
+	s.Handler.ServeHTTP(w, r)
+}
+
+// serve serves the request using the proxy with the longest matching
+// prefix, the static files are served if no prefix matches.
+func (s *VirtualHosts) serve(w http.ResponseWriter, r *http.Request, vh *virtualHost, bindings map[string]string) {
+	path := r.URL.EscapedPath()
+
+	var prefix string
+	var proxy model.Proxy
+	found := false
+	for _, p := range vh.prefixes {
+		if vhostPrefixMatch(path, p) {
+			prefix, proxy, found = p, vh.config.Proxy[p], true
+			break
+		}
+	}
+	if !found {
+		if vh.static != nil {
+			vh.static.ServeHTTP(w, r)
+			return
+		}
+		WriteError(w, http.StatusNotFound, "missing")
+		return
+	}
+
+	rest := path
+	if proxy.StripPrefix {
+		rest = "/" + strings.TrimPrefix(strings.TrimPrefix(path, prefix), "/")
+	}
+
+	nr := r.Clone(r.Context())
+	switch proxy.Type {
+	case model.ProxyReverse:
+		if err := setEscapedPath(nr.URL, rest); err != nil {
+			WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		vh.reverse[prefix].ServeHTTP(w, nr)
+	default:
+		target := strings.Split(proxy.Target, "/")
+		for i, part := range target {
+			if strings.HasPrefix(part, ":") {
+				if v, ok := bindings[part[1:]]; ok {
+					target[i] = url.PathEscape(v)
+				}
+			}
+		}
+		if err := setEscapedPath(nr.URL, joinVHostPath(strings.Join(target, "/"), rest)); err != nil {
+			WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		nr.RequestURI = nr.URL.RequestURI()
+		nr.Header.Set("X-Couchdb-Vhost-Path", r.URL.Path)
+		s.Handler.ServeHTTP(w, nr)
+	}
+}
+
+// globalHandler returns true if the first segment of the path is one of
+// httpd/vhost_global_handlers, these paths are not rewritten.
+func (s *VirtualHosts) globalHandler(path string) bool {
+	handlers, _ := s.Config.Get("httpd", "vhost_global_handlers")
+	segment := strings.SplitN(strings.TrimPrefix(path, "/"), "/", 2)[0]
+	for _, h := range strings.Split(handlers, ",") {
+		if h = strings.TrimSpace(h); h != "" && h == segment {
+			return true
+		}
+	}
+	return false
+}
+
+// load returns the cached routes of all virtual hosts, they are loaded
+// again if the configuration or the _vhosts database changed.
+func (s *VirtualHosts) load(ctx context.Context) []vhostRoute {
+	version := s.Config.Version()
+	db, err := s.Storage.Database(ctx, vhostsDB)
+	if err != nil {
+		db = nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if db != s.db {
+		s.watch(db)
+		s.changed.Store(true)
+	}
+	if !s.changed.Swap(false) && s.loaded && version == s.version {
+		return s.routes
+	}
+	s.loaded, s.version = true, version
+
+	section, _ := s.Config.Section("vhosts")
+
+	var hosts []model.VirtualHostConfiguration
+	for domain, target := range section {
+		proxy := model.Proxy{Type: model.ProxyDB, Target: target}
+		if strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://") {
+			proxy.Type = model.ProxyReverse
+		}
+		hosts = append(hosts, model.VirtualHostConfiguration{
+			Name:    domain,
+			Domains: []string{domain},
+			Proxy:   map[string]model.Proxy{"/": proxy},
+		})
+	}
+	if db != nil {
+		docs, _, err := db.AllDocs(ctx, port.AllDocsQuery{SkipLocal: true, IncludeDocs: true})
+		if err != nil {
+			s.Logger.Warnf(ctx, "failed to load virtual hosts", "error", err)
+		}
+		for _, doc := range docs {
+			if doc.Deleted || doc.IsDesignDoc() {
+				continue
+			}
+			config := model.VirtualHostConfiguration{Name: doc.ID, Rev: doc.Rev}
+			if err := mapstructure.Decode(doc.Data, &config); err != nil {
+				s.Logger.Warnf(ctx, "invalid virtual host", "vhost", doc.ID, "error", err)
+				continue
+			}
+			hosts = append(hosts, config)
+		}
+	}
+
+	var routes []vhostRoute
+	for _, config := range hosts {
+		vh, err := s.virtualHost(ctx, config)
+		if err != nil {
+			s.Logger.Warnf(ctx, "invalid virtual host", "vhost", config.Name, "error", err)
+			continue
+		}
+		for _, domain := range config.Domains {
+			routes = append(routes, newVHostRoute(domain, vh))
+		}
+	}
+	sortVHostRoutes(routes)
+	s.routes = routes
+
+	return routes
+}
+
+// watch registers a change listener on the _vhosts database, the
+// listener of the previous database is removed.
+func (s *VirtualHosts) watch(db port.Database) {
+	if s.cancel != nil {
+		s.cancel()
+		s.cancel = nil
+	}
+	s.db = db
+	if db == nil {
+		return
+	}
+
+	lctx, cancel := context.WithCancel(context.Background())
+	err := db.AddListener(lctx, port.ChangeListenerFunc(func(ctx context.Context, doc *model.Document) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		s.changed.Store(true)
+		return nil
+	}))
+	if err != nil {
+		cancel()
+		s.Logger.Warnf(context.Background(), "failed to watch virtual hosts", "error", err)
+		return
+	}
+	s.cancel = cancel
+}
+
+// virtualHost prepares the proxies and the static file system of the configuration
+func (s *VirtualHosts) virtualHost(ctx context.Context, config model.VirtualHostConfiguration) (*virtualHost, error) {
+	vh := &virtualHost{
+		config:  config,
+		reverse: make(map[string]*httputil.ReverseProxy),
+	}
+
+	for prefix, proxy := range config.Proxy {
+		switch proxy.Type {
+		case model.ProxyDB, "":
+			if !strings.HasPrefix(proxy.Target, "/") {
+				return nil, fmt.Errorf("db target %q must be an absolute path", proxy.Target)
+			}
+		case model.ProxyReverse:
+			u, err := url.Parse(proxy.Target)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return nil, fmt.Errorf("reverse target %q must be a http(s) URL", proxy.Target)
+			}
+			vh.reverse[prefix] = &httputil.ReverseProxy{
+				Rewrite: func(pr *httputil.ProxyRequest) {
+					pr.SetURL(u)
+					pr.SetXForwarded()
+				},
+				ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+					s.Logger.Warnf(r.Context(), "virtual host proxy failed", "vhost", config.Name, "target", proxy.Target, "error", err)
+					WriteError(w, http.StatusBadGateway, "proxy_error")
+				},
+			}
+		default:
+			return nil, fmt.Errorf("unknown proxy type %q", proxy.Type)
+		}
+		vh.prefixes = append(vh.prefixes, prefix)
+	}
+	sort.Slice(vh.prefixes, func(i, j int) bool {
+		return len(vh.prefixes[i]) > len(vh.prefixes[j])
+	})
+
+	if config.Static != "" {
+		if strings.HasSuffix(strings.ToLower(config.Static), ".zip") {
+			f, err := os.Open(config.Static)
+			if err != nil {
+				return nil, err
+			}
+			defer f.Close() //nolint:errcheck
+			vh.config.FS, err = zipvfs.BuildFileSystem(ctx, f)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %q: %w", config.Static, err)
+			}
+		} else {
+			fi, err := os.Stat(config.Static)
+			if err != nil {
+				return nil, err
+			}
+			if !fi.IsDir() {
+				return nil, fmt.Errorf("static %q is not a directory or zip file", config.Static)
+			}
+			vh.config.FS = http.Dir(config.Static)
+		}
+		vh.static = http.FileServer(vh.config)
+	}
+
+	return vh, nil
+}
+
+// newVHostRoute parses the domain pattern, an optional port
+// is separated by a colon e.g. example.com:5984.
+func newVHostRoute(domain string, vh *virtualHost) vhostRoute {
+	domain = strings.ToLower(strings.TrimSpace(domain))
+	route := vhostRoute{host: vh}
+	if i := strings.LastIndex(domain, ":"); i > 0 && isDigits(domain[i+1:]) {
+		domain, route.port = domain[:i], domain[i+1:]
+	}
+	route.labels = strings.Split(strings.TrimSuffix(domain, "."), ".")
+	return route
+}
+
+// match matches the host labels and port of a request, variables
+// of the pattern are bound to the labels.
+func (route vhostRoute) match(labels []string, port string) (map[string]string, bool) {
+	if route.port != "" && route.port != port {
+		return nil, false
+	}
+
+	pattern := route.labels
+	if pattern[0] == "*" {
+		// the wildcard matches one or more labels
+		pattern = pattern[1:]
+		if len(labels) <= len(pattern) {
+			return nil, false
+		}
+		labels = labels[len(labels)-len(pattern):]
+	} else if len(labels) != len(pattern) {
+		return nil, false
+	}
+
+	var bindings map[string]string
+	for i, p := range pattern {
+		if strings.HasPrefix(p, ":") {
+			if bindings == nil {
+				bindings = make(map[string]string)
+			}
+			bindings[p[1:]] = labels[i]
+			continue
+		}
+		if p != labels[i] {
+			return nil, false
+		}
+	}
+	return bindings, true
+}
+
+// sortVHostRoutes sorts the routes by specificity, routes with more
+// literal labels first, then variables before wildcards and routes
+// with a port before routes without.
+func sortVHostRoutes(routes []vhostRoute) {
+	literals := func(r vhostRoute) int {
+		n := 0
+		for _, l := range r.labels {
+			if l != "*" && !strings.HasPrefix(l, ":") {
+				n++
+			}
+		}
+		return n
+	}
+	sort.SliceStable(routes, func(i, j int) bool {
+		a, b := routes[i], routes[j]
+		if la, lb := literals(a), literals(b); la != lb {
+			return la > lb
+		}
+		if wa, wb := a.labels[0] == "*", b.labels[0] == "*"; wa != wb {
+			return wb
+		}
+		if (a.port != "") != (b.port != "") {
+			return a.port != ""
+		}
+		return strings.Join(a.labels, ".") < strings.Join(b.labels, ".")
+	})
+}
+
+// vhostPrefixMatch returns true if the path is the prefix or below it
+func vhostPrefixMatch(path, prefix string) bool {
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+	return len(path) == len(prefix) || strings.HasSuffix(prefix, "/") || path[len(prefix)] == '/'
+}
+
+// joinVHostPath appends the request path to the target path
+func joinVHostPath(target, path string) string {
+	path = strings.TrimPrefix(path, "/")
+	if path == "" {
+		return target
+	}
+	return strings.TrimSuffix(target, "/") + "/" + path
+}
+
+// setEscapedPath sets the path of the URL from its escaped form
+func setEscapedPath(u *url.URL, escaped string) error {
+	path, err := url.PathUnescape(escaped)
+	if err != nil {
+		return err
+	}
+	u.Path, u.RawPath = path, escaped
+	return nil
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,274 @@
+package handler
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/sessions"
+	"github.com/goydb/goydb/internal/adapter/logger"
+	"github.com/goydb/goydb/internal/adapter/storage"
+	"github.com/goydb/goydb/internal/service"
+	"github.com/goydb/goydb/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupVHostsTest(t *testing.T) (*storage.Storage, *ConfigStore, http.Handler) {
+	t.Helper()
+	dir := t.TempDir()
+
+	log := logger.NewNoLog()
+	s, err := storage.Open(dir, storage.WithLogger(log))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.Close() })
+	require.NoError(t, s.EnsureSystemDatabases(t.Context()))
+
+	cs := NewConfigStore(filepath.Join(dir, "_config.json"), log)
+	r := mux.NewRouter()
+	r.UseEncodedPath()
+	err = Router{
+		Storage:      s,
+		SessionStore: sessions.NewCookieStore([]byte("test-secret-32-bytes-long-enough")),
+		Admins:       model.AdminUsers{model.AdminUser{Username: "admin", Password: "secret"}},
+		Config:       cs,
+		Replication:  &service.Replication{Storage: s, Logger: log},
+		Logger:       log,
+	}.Build(r)
+	require.NoError(t, err)
+
+	return s, cs, &VirtualHosts{
+		Base:    Base{Storage: s, Config: cs, Logger: log},
+		Handler: r,
+	}
+}
+
+func vhostGet(t *testing.T, h http.Handler, host, path string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest("GET", path, nil)
+	req.Host = host
+	req.SetBasicAuth("admin", "secret")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func vhostPut(t *testing.T, h http.Handler, path string, body interface{}) (int, map[string]interface{}) {
+	t.Helper()
+	b, err := json.Marshal(body)
+	require.NoError(t, err)
+	req := httptest.NewRequest("PUT", path, bytes.NewReader(b))
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBasicAuth("admin", "secret")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	result := map[string]interface{}{}
+	_ = json.NewDecoder(w.Body).Decode(&result)
+	return w.Code, result
+}
+
+func TestVirtualHosts_Config(t *testing.T) {
+	s, cs, h := setupVHostsTest(t)
+
+	for _, name := range []string{"blog", "shop"} {
+		_, err := s.CreateDatabase(t.Context(), name)
+		require.NoError(t, err)
+		code, res := vhostPut(t, h, "/"+name+"/doc1", map[string]interface{}{"db": name})
+		require.Equal(t, http.StatusCreated, code, res)
+	}
+
+	cs.Set("vhosts", "blog.test", "/blog")
+	cs.Set("vhosts", ":db.shops.test", "/:db")
+	cs.Set("vhosts", "*.wild.test:8080", "/shop")
+
+	w := vhostGet(t, h, "blog.test", "/doc1")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var doc map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &doc))
+	assert.Equal(t, "blog", doc["db"])
+
+	w = vhostGet(t, h, "Blog.Test:7070", "/")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"db_name":"blog"`)
+
+	// variables of the host are substituted
+	w = vhostGet(t, h, "shop.shops.test", "/doc1")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"db":"shop"`)
+
+	// wildcard with port
+	w = vhostGet(t, h, "a.b.wild.test:8080", "/doc1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = vhostGet(t, h, "a.wild.test", "/doc1")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// global handlers are not rewritten
+	w = vhostGet(t, h, "blog.test", "/_uuids")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "uuids")
+
+	// other hosts are not rewritten
+	w = vhostGet(t, h, "other.test", "/blog/doc1")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// the table is cached until the config changes
+	vh := h.(*VirtualHosts)
+	routes := vh.load(t.Context())
+	require.Len(t, routes, 3)
+	assert.Same(t, &routes[0], &vh.load(t.Context())[0])
+
+	// changes of the config are applied
+	cs.Delete("vhosts", "blog.test")
+	assert.Len(t, vh.load(t.Context()), 2)
+	w = vhostGet(t, h, "blog.test", "/doc1")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = vhostGet(t, h, "blog.test", "/blog/doc1")
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestVirtualHosts_Database(t *testing.T) {
+	s, _, h := setupVHostsTest(t)
+
+	_, err := s.CreateDatabase(t.Context(), "app")
+	require.NoError(t, err)
+	code, res := vhostPut(t, h, "/app/doc1", map[string]interface{}{"title": "Hello"})
+	require.Equal(t, http.StatusCreated, code, res)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "upstream "+r.URL.Path)
+	}))
+	defer upstream.Close()
+
+	static := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(static, "index.html"), []byte("static index"), 0o600))
+
+	zipPath := filepath.Join(t.TempDir(), "site.zip")
+	f, err := os.Create(zipPath)
+	require.NoError(t, err)
+	zw := zip.NewWriter(f)
+	fw, err := zw.Create("app.js")
+	require.NoError(t, err)
+	_, _ = fw.Write([]byte("zipped"))
+	require.NoError(t, zw.Close())
+	require.NoError(t, f.Close())
+
+	code, res = vhostPut(t, h, "/_vhosts/app", map[string]interface{}{
+		"domains": []interface{}{"app.test", "www.app.test"},
+		"proxy": map[string]interface{}{
+			"/api": map[string]interface{}{"type": "reverse", "target": upstream.URL + "/v1", "stripPrefix": true},
+			"/db":  map[string]interface{}{"type": "db", "target": "/app", "stripPrefix": true},
+		},
+		"static": static,
+	})
+	require.Equal(t, http.StatusCreated, code, res)
+	rev := res["rev"].(string)
+
+	// change notifications are delivered asynchronously
+	var w *httptest.ResponseRecorder
+	require.Eventually(t, func() bool {
+		w = vhostGet(t, h, "www.app.test", "/db/doc1")
+		return w.Code == http.StatusOK
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Contains(t, w.Body.String(), `"title":"Hello"`)
+
+	w = vhostGet(t, h, "app.test", "/api/users")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "upstream /v1/users", w.Body.String())
+
+	w = vhostGet(t, h, "app.test", "/")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "static index", w.Body.String())
+
+	// documents are reloaded on change
+	code, res = vhostPut(t, h, "/_vhosts/app", map[string]interface{}{
+		"_rev":    rev,
+		"domains": []interface{}{"app.test"},
+		"static":  zipPath,
+	})
+	require.Equal(t, http.StatusCreated, code, res)
+
+	assert.Eventually(t, func() bool {
+		w := vhostGet(t, h, "app.test", "/app.js")
+		return w.Code == http.StatusOK && w.Body.String() == "zipped"
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// the proxy of the removed domain is gone
+	w = vhostGet(t, h, "www.app.test", "/db/doc1")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// paths without proxy and static file are missing
+	w = vhostGet(t, h, "app.test", "/db/doc1")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestVirtualHosts_Invalid(t *testing.T) {
+	_, cs, h := setupVHostsTest(t)
+
+	code, res := vhostPut(t, h, "/_vhosts/broken", map[string]interface{}{
+		"domains": []interface{}{"broken.test"},
+		"proxy": map[string]interface{}{
+			"/": map[string]interface{}{"type": "reverse", "target": "ftp://example"},
+		},
+	})
+	require.Equal(t, http.StatusCreated, code, res)
+	cs.Set("vhosts", "relative.test", "db")
+
+	// invalid hosts are ignored
+	w := vhostGet(t, h, "broken.test", "/_all_dbs")
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = vhostGet(t, h, "relative.test", "/_all_dbs")
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestVHostRoute_Match(t *testing.T) {
+	tests := []struct {
+		domain   string
+		host     string
+		port     string
+		ok       bool
+		bindings map[string]string
+	}{
+		{domain: "example.com", host: "example.com", ok: true},
+		{domain: "example.com", host: "www.example.com"},
+		{domain: "example.com:5984", host: "example.com", port: "5984", ok: true},
+		{domain: "example.com:5984", host: "example.com", port: "80"},
+		{domain: "*.example.com", host: "a.b.example.com", ok: true},
+		{domain: "*.example.com", host: "example.com"},
+		{domain: ":db.example.com", host: "blog.example.com", ok: true, bindings: map[string]string{"db": "blog"}},
+		{domain: ":db.example.com", host: "a.blog.example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.domain+"/"+tt.host, func(t *testing.T) {
+			route := newVHostRoute(tt.domain, nil)
+			bindings, ok := route.match(splitLabels(tt.host), tt.port)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.bindings, bindings)
+		})
+	}
+}
+
+func splitLabels(host string) []string {
+	return newVHostRoute(host, nil).labels
+}
+
+func TestSortVHostRoutes(t *testing.T) {
+	var routes []vhostRoute
+	for _, d := range []string{"*.example.com", ":db.example.com", "www.example.com", "www.example.com:80"} {
+		routes = append(routes, newVHostRoute(d, nil))
+	}
+	sortVHostRoutes(routes)
+
+	var order []string
+	for _, r := range routes {
+		order = append(order, r.labels[0]+":"+r.port)
+	}
+	assert.Equal(t, []string{"www:80", "www:", ":db:", "*:"}, order)
+}
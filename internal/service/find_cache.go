@@ -0,0 +1,284 @@
+package service
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/goydb/goydb/pkg/model"
+	"github.com/goydb/goydb/pkg/port"
+)
+
+// findCacheEntryOverhead is the estimated size in bytes of an entry
+// without its documents
+const findCacheEntryOverhead = 256
+
+// FindCache caches the results of Mango queries per database.
+//
+// Entries are invalidated by a change listener on the database whenever
+// a changed document matches the selector of a query or was part of its
+// result. A page of a result (skip, bookmark or a full limit) is
+// invalidated by any change, as the documents before and after the page
+// aren't known. Notifications are delivered asynchronously, a result may
+// therefore be served shortly after a write that invalidates it. The
+// cache is bounded by memory (least recently used entries are evicted
+// first) and entries expire after the TTL.
+type FindCache struct {
+	mu        sync.Mutex
+	maxMemory int64
+	ttl       time.Duration
+	memory    int64
+	lru       *list.List // of *findCacheEntry, most recently used first
+	dbs       map[string]*findCacheDB
+
+	hits, misses atomic.Uint64
+}
+
+// findCacheDB holds the entries of a database
+type findCacheDB struct {
+	db      port.Database
+	cancel  context.CancelFunc
+	gen     uint64 // incremented on every change notification
+	entries map[string]*findCacheEntry
+}
+
+type findCacheEntry struct {
+	cdb     *findCacheDB
+	key     string
+	query   model.FindQuery
+	docs    []*model.Document
+	ids     map[string]struct{} // of all matched documents, unless paged
+	paged   bool
+	size    int64
+	expires time.Time
+	elem    *list.Element
+}
+
+// FindCacheToken is returned by a cache miss and is used to store the result.
+type FindCacheToken struct {
+	cdb   *findCacheDB
+	gen   uint64
+	key   string
+	query model.FindQuery
+}
+
+// FindCacheStats are the counters of the cache.
+type FindCacheStats struct {
+	Hits    uint64
+	Misses  uint64
+	Entries int
+	Memory  int64
+}
+
+// NewFindCache creates an empty cache.
+func NewFindCache() *FindCache {
+	return &FindCache{
+		lru: list.New(),
+		dbs: make(map[string]*findCacheDB),
+	}
+}
+
+// Configure sets the memory limit in bytes and the TTL of new entries,
+// evicting entries if the cache exceeds the new limit. Zero means
+// unlimited.
+func (c *FindCache) Configure(maxMemory int64, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.maxMemory = maxMemory
+	c.ttl = ttl
+	c.evict()
+}
+
+// Get returns the cached result of the query on db. On a miss the
+// returned token has to be passed to Put together with the result.
+func (c *FindCache) Get(_ context.Context, db port.Database, query model.FindQuery) ([]*model.Document, *FindCacheToken, bool) {
+	key := query.Normalized()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cdb := c.database(db)
+	if e, ok := cdb.entries[key]; ok {
+		if e.expires.IsZero() || time.Now().Before(e.expires) {
+			c.lru.MoveToFront(e.elem)
+			c.hits.Add(1)
+			return cloneDocs(e.docs), nil, true
+		}
+		c.remove(e)
+	}
+
+	c.misses.Add(1)
+	return nil, &FindCacheToken{cdb: cdb, gen: cdb.gen, key: key, query: query}, false
+}
+
+// Put stores the result of a query. Results are dropped if the database
+// changed since the token was issued, as the change notification may
+// already have been processed.
+func (c *FindCache) Put(token *FindCacheToken, docs []*model.Document) {
+	size := int64(findCacheEntryOverhead)
+	ids := make(map[string]struct{}, len(docs))
+	for _, doc := range docs {
+		data, _ := json.Marshal(doc.Data)
+		size += int64(len(data) + len(doc.ID) + len(doc.Rev))
+		ids[doc.ID] = struct{}{}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cdb := token.cdb
+	if c.dbs[cdb.db.Name()] != cdb || cdb.gen != token.gen {
+		return
+	}
+	if c.maxMemory > 0 && size > c.maxMemory {
+		return
+	}
+	if old, ok := cdb.entries[token.key]; ok {
+		c.remove(old)
+	}
+
+	e := &findCacheEntry{
+		cdb:   cdb,
+		key:   token.key,
+		query: token.query,
+		docs:  cloneDocs(docs),
+		ids:   ids,
+		paged: paged(token.query, len(docs)),
+		size:  size,
+	}
+	if c.ttl > 0 {
+		e.expires = time.Now().Add(c.ttl)
+	}
+	e.elem = c.lru.PushFront(e)
+	cdb.entries[e.key] = e
+	c.memory += size
+	c.evict()
+}
+
+// Stats returns the counters of the cache.
+func (c *FindCache) Stats() FindCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return FindCacheStats{
+		Hits:    c.hits.Load(),
+		Misses:  c.misses.Load(),
+		Entries: c.lru.Len(),
+		Memory:  c.memory,
+	}
+}
+
+// database returns the entries of db, registering a change listener
+// when the database is seen first. If the database was recreated the
+// entries of the previous instance are dropped, as are the entries
+// of an unloaded or deleted database.
+func (c *FindCache) database(db port.Database) *findCacheDB {
+	cdb, ok := c.dbs[db.Name()]
+	if ok && cdb.db == db {
+		return cdb
+	}
+	if ok {
+		c.dropDatabase(cdb)
+	}
+
+	lctx, cancel := context.WithCancel(context.Background())
+	cdb = &findCacheDB{
+		db:      db,
+		cancel:  cancel,
+		entries: make(map[string]*findCacheEntry),
+	}
+	err := db.AddListener(lctx, port.ChangeListenerFunc(func(ctx context.Context, doc *model.Document) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		c.documentChanged(cdb, doc)
+		return nil
+	}))
+	if err != nil {
+		cancel()
+		// without a listener nothing can be cached, the
+		// database is not registered so Put drops all results
+		return cdb
+	}
+	c.dbs[db.Name()] = cdb
+
+	go func() {
+		select {
+		case <-db.Done():
+			c.mu.Lock()
+			if c.dbs[db.Name()] == cdb {
+				c.dropDatabase(cdb)
+			}
+			c.mu.Unlock()
+		case <-lctx.Done():
+		}
+	}()
+	return cdb
+}
+
+// documentChanged removes all entries of the database whose result
+// could be affected by the changed document.
+func (c *FindCache) documentChanged(cdb *findCacheDB, doc *model.Document) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cdb.gen++
+	for _, e := range cdb.entries {
+		if _, ok := e.ids[doc.ID]; ok || e.paged {
+			c.remove(e)
+			continue
+		}
+		if doc.Deleted {
+			continue
+		}
+		if ok, err := e.query.Match(doc); ok || err != nil {
+			c.remove(e)
+		}
+	}
+}
+
+// paged returns true if the result with n documents is a page of the
+// matched documents, otherwise the result contains all matched documents
+func paged(query model.FindQuery, n int) bool {
+	return query.Skip > 0 || query.Bookmark != "" || (query.Limit > 0 && n >= query.Limit)
+}
+
+func (c *FindCache) dropDatabase(cdb *findCacheDB) {
+	cdb.cancel()
+	for _, e := range cdb.entries {
+		c.remove(e)
+	}
+	delete(c.dbs, cdb.db.Name())
+}
+
+func (c *FindCache) remove(e *findCacheEntry) {
+	c.lru.Remove(e.elem)
+	delete(e.cdb.entries, e.key)
+	c.memory -= e.size
+}
+
+// evict removes least recently used entries until the memory limit is met
+func (c *FindCache) evict() {
+	for c.maxMemory > 0 && c.memory > c.maxMemory && c.lru.Len() > 0 {
+		c.remove(c.lru.Back().Value.(*findCacheEntry))
+	}
+}
+
+// cloneDocs copies the documents and their top-level fields, so that
+// callers can add fields (e.g. _id and _rev) without modifying the cache.
+func cloneDocs(docs []*model.Document) []*model.Document {
+	out := make([]*model.Document, len(docs))
+	for i, doc := range docs {
+		d := *doc
+		if doc.Data != nil {
+			d.Data = make(map[string]interface{}, len(doc.Data))
+			for k, v := range doc.Data {
+				d.Data[k] = v
+			}
+		}
+		out[i] = &d
+	}
+	return out
+}
@@ -0,0 +1,180 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/goydb/goydb/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func findCacheQuery(t *testing.T, q string) model.FindQuery {
+	var query model.FindQuery
+	require.NoError(t, json.Unmarshal([]byte(q), &query))
+	return query
+}
+
+func TestFindCache_Invalidation(t *testing.T) {
+	s, cleanup := setupReplicationTest(t)
+	defer cleanup()
+
+	ctx := t.Context()
+	db, err := s.CreateDatabase(ctx, "testdb")
+	require.NoError(t, err)
+	_, err = db.PutDocument(ctx, &model.Document{ID: "a", Data: map[string]interface{}{"type": "user", "age": 30}})
+	require.NoError(t, err)
+	time.Sleep(50 * time.Millisecond) // let the change notification pass
+
+	cache := NewFindCache()
+	query := findCacheQuery(t, `{"selector": {"type": "user", "age": 30}}`)
+
+	_, token, ok := cache.Get(ctx, db, query)
+	require.False(t, ok)
+	docs, _, err := db.FindDocs(ctx, query)
+	require.NoError(t, err)
+	cache.Put(token, docs)
+
+	// the member order of the selector doesn't matter
+	docs, _, ok = cache.Get(ctx, db, findCacheQuery(t, `{"selector": {"age": 30, "type": "user"}}`))
+	require.True(t, ok)
+	assert.Len(t, docs, 1)
+
+	// a document that can't match keeps the entry
+	_, err = db.PutDocument(ctx, &model.Document{ID: "b", Data: map[string]interface{}{"type": "order"}})
+	require.NoError(t, err)
+	time.Sleep(50 * time.Millisecond)
+	_, _, ok = cache.Get(ctx, db, query)
+	assert.True(t, ok)
+
+	// a matching document invalidates the entry
+	_, err = db.PutDocument(ctx, &model.Document{ID: "c", Data: map[string]interface{}{"type": "user", "age": 30}})
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		return cache.Stats().Entries == 0
+	}, time.Second, 10*time.Millisecond)
+
+	stats := cache.Stats()
+	assert.Equal(t, uint64(2), stats.Hits)
+	assert.Equal(t, uint64(1), stats.Misses)
+}
+
+func TestFindCache_MemoryLimit(t *testing.T) {
+	s, cleanup := setupReplicationTest(t)
+	defer cleanup()
+
+	ctx := t.Context()
+	db, err := s.CreateDatabase(ctx, "testdb")
+	require.NoError(t, err)
+
+	cache := NewFindCache()
+	cache.Configure(2*findCacheEntryOverhead+100, 0)
+
+	for _, q := range []string{
+		`{"selector": {"a": 1}}`,
+		`{"selector": {"a": 2}}`,
+		`{"selector": {"a": 3}}`,
+	} {
+		_, token, ok := cache.Get(ctx, db, findCacheQuery(t, q))
+		require.False(t, ok)
+		cache.Put(token, nil)
+	}
+
+	assert.Equal(t, 2, cache.Stats().Entries)
+	_, _, ok := cache.Get(ctx, db, findCacheQuery(t, `{"selector": {"a": 1}}`))
+	assert.False(t, ok, "least recently used entry is evicted")
+	_, _, ok = cache.Get(ctx, db, findCacheQuery(t, `{"selector": {"a": 3}}`))
+	assert.True(t, ok)
+}
+
+func TestFindCache_DeletedDocument(t *testing.T) {
+	s, cleanup := setupReplicationTest(t)
+	defer cleanup()
+
+	ctx := t.Context()
+	db, err := s.CreateDatabase(ctx, "testdb")
+	require.NoError(t, err)
+	_, err = db.PutDocument(ctx, &model.Document{ID: "a", Data: map[string]interface{}{"type": "user"}})
+	require.NoError(t, err)
+	rev, err := db.PutDocument(ctx, &model.Document{ID: "b", Data: map[string]interface{}{"type": "user"}})
+	require.NoError(t, err)
+	time.Sleep(50 * time.Millisecond)
+
+	cache := NewFindCache()
+	query := findCacheQuery(t, `{"selector": {"type": "user"}, "limit": 1}`)
+	_, token, ok := cache.Get(ctx, db, query)
+	require.False(t, ok)
+	docs, _, err := db.FindDocs(ctx, query)
+	require.NoError(t, err)
+	require.Len(t, docs, 1)
+	cache.Put(token, docs)
+
+	// deleting a matched document after the page invalidates the entry
+	_, err = db.DeleteDocument(ctx, "b", rev)
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		return cache.Stats().Entries == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestFindCache_DeletedDatabase(t *testing.T) {
+	s, cleanup := setupReplicationTest(t)
+	defer cleanup()
+
+	ctx := t.Context()
+	db, err := s.CreateDatabase(ctx, "testdb")
+	require.NoError(t, err)
+
+	cache := NewFindCache()
+	query := findCacheQuery(t, `{"selector": {"type": "user"}}`)
+	_, token, ok := cache.Get(ctx, db, query)
+	require.False(t, ok)
+	cache.Put(token, nil)
+	_, _, ok = cache.Get(ctx, db, query)
+	require.True(t, ok)
+
+	// queries that use a building index as is are cached separately
+	_, _, ok = cache.Get(ctx, db, findCacheQuery(t, `{"selector": {"type": "user"}, "update": false}`))
+	assert.False(t, ok)
+
+	// the entries and the listener of a deleted database are dropped
+	require.NoError(t, s.DeleteDatabase(ctx, "testdb"))
+	assert.Eventually(t, func() bool {
+		cache.mu.Lock()
+		defer cache.mu.Unlock()
+		return cache.lru.Len() == 0 && len(cache.dbs) == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestFindCache_SkipWindow(t *testing.T) {
+	s, cleanup := setupReplicationTest(t)
+	defer cleanup()
+
+	ctx := t.Context()
+	db, err := s.CreateDatabase(ctx, "testdb")
+	require.NoError(t, err)
+	for _, id := range []string{"a", "b", "c"} {
+		_, err = db.PutDocument(ctx, &model.Document{ID: id, Data: map[string]interface{}{"type": "user"}})
+		require.NoError(t, err)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	cache := NewFindCache()
+	query := findCacheQuery(t, `{"selector": {"type": "user"}, "skip": 1, "limit": 25}`)
+	_, token, ok := cache.Get(ctx, db, query)
+	require.False(t, ok)
+	docs, _, err := db.FindDocs(ctx, query)
+	require.NoError(t, err)
+	require.Len(t, docs, 2)
+	cache.Put(token, docs)
+
+	// a skipped document that doesn't match anymore shifts the page
+	doc, err := db.GetDocument(ctx, "a")
+	require.NoError(t, err)
+	_, err = db.PutDocument(ctx, &model.Document{ID: "a", Rev: doc.Rev, Data: map[string]interface{}{"type": "order"}})
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		return cache.Stats().Entries == 0
+	}, time.Second, 10*time.Millisecond)
+}
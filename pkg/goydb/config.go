@@ -6,7 +6,9 @@ import (
 	"flag"
 	"fmt"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/caarlos0/env/v6"
 	gorilla_handlers "github.com/gorilla/handlers"
@@ -14,10 +16,12 @@ import (
 	"github.com/gorilla/sessions"
 	adapterlogger "github.com/goydb/goydb/internal/adapter/logger"
 	"github.com/goydb/goydb/internal/adapter/storage"
+	"github.com/goydb/goydb/internal/adapter/view/nativeview"
 	"github.com/goydb/goydb/internal/controller"
 	"github.com/goydb/goydb/internal/handler"
 	"github.com/goydb/goydb/internal/service"
 	"github.com/goydb/goydb/pkg/model"
+	"github.com/goydb/goydb/pkg/port"
 	"github.com/goydb/goydb/pkg/public"
 )
 
@@ -42,6 +46,9 @@ type Config struct {
 	// Containers are zip file based containers that should be mounted before the
 	// database application
 	Containers []public.Container
+
+	// native are the Go functions registered by the embedding application
+	native *nativeview.Registry
 }
 
 // NewConfig will create a new configuration
@@ -98,8 +105,17 @@ func (c *Config) BuildDatabase() (*Goydb, error) {
 		storage.WithLogger(logger.With("component", "storage")),
 	}
 	for _, hook := range storageOptionHooks {
-		storageOpts = append(storageOpts, hook(logger)...)
-	}
+		storageOpts = append(storageOpts, hook(logger, cs)...)
+	}
+	storageOpts = append(storageOpts, c.nativeStorageOptions()...)
+	maxDBsOpen, _ := cs.Get("couchdb", "max_dbs_open")
+	idleTimeout, _ := cs.Get("couchdb", "db_idle_timeout")
+	maxOpen, _ := strconv.Atoi(maxDBsOpen)
+	idleSeconds, _ := strconv.Atoi(idleTimeout)
+	storageOpts = append(storageOpts,
+		storage.WithMaxOpenDatabases(maxOpen),
+		storage.WithIdleTimeout(time.Duration(idleSeconds)*time.Second),
+	)
 	s, err := storage.Open(c.DatabaseDir, storageOpts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database dir: %w", err)
@@ -109,10 +125,15 @@ func (c *Config) BuildDatabase() (*Goydb, error) {
 		return nil, fmt.Errorf("failed to ensure system databases: %w", err)
 	}
 
+	workers, _ := cs.Get("indexer", "workers")
 	tc := controller.Task{
 		Storage: s,
 		Logger:  logger.With("component", "task"),
 	}
+	tc.ValidateDocUpdate = func(ctx context.Context, db port.Database, newDoc, oldDoc *model.Document, session *model.Session) error {
+		return handler.ValidateDocUpdate(ctx, db, tc.Logger, newDoc, oldDoc, session)
+	}
+	tc.Workers, _ = strconv.Atoi(workers)
 	go tc.Run(context.Background())
 	replication := &service.Replication{
 		Storage: s,
@@ -151,6 +172,12 @@ func (c *Config) BuildDatabase() (*Goydb, error) {
 		return nil, fmt.Errorf("failed to build router: %w", err)
 	}
 
+	// Route virtual hosts before the requests reach the router.
+	gdb.Handler = &handler.VirtualHosts{
+		Base:    handler.Base{Storage: s, Config: cs, Logger: logger},
+		Handler: gdb.Handler,
+	}
+
 	// Apply CORS middleware if configured.
 	if enable, _ := cs.Get("httpd", "enable_cors"); enable == "true" {
 		opts := buildCORSOpts(cs)
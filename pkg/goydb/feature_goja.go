@@ -11,18 +11,26 @@ import (
 
 func init() {
 	handler.RegisterFeature("goja")
-	RegisterStorageOptionHook(func(logger port.Logger) []storage.StorageOption {
+	handler.RegisterConfigDefaults(gojaview.ConfigDefaults)
+	RegisterStorageOptionHook(func(logger port.Logger, config *handler.ConfigStore) []storage.StorageOption {
+		gojaview.Configure(config.Get)
 		return []storage.StorageOption{
-			storage.WithViewEngine("", gojaview.NewViewServer),
-			storage.WithViewEngine("javascript", gojaview.NewViewServer),
-			storage.WithFilterEngine("", gojaview.NewFilterServer),
-			storage.WithFilterEngine("javascript", gojaview.NewFilterServer),
-			storage.WithReducerEngine("", gojaview.NewReducerBuilder(logger.With("component", "reducer"))),
-			storage.WithReducerEngine("javascript", gojaview.NewReducerBuilder(logger.With("component", "reducer"))),
-			storage.WithValidateEngine("", gojaview.NewValidateServerBuilder(logger.With("component", "validate"))),
-			storage.WithValidateEngine("javascript", gojaview.NewValidateServerBuilder(logger.With("component", "validate"))),
-			storage.WithUpdateEngine("", gojaview.NewUpdateServer),
-			storage.WithUpdateEngine("javascript", gojaview.NewUpdateServer),
+			storage.WithViewDesignDocEngine("", gojaview.NewViewServer),
+			storage.WithViewDesignDocEngine("javascript", gojaview.NewViewServer),
+			storage.WithFilterDesignDocEngine("", gojaview.NewFilterServer),
+			storage.WithFilterDesignDocEngine("javascript", gojaview.NewFilterServer),
+			storage.WithReducerDesignDocEngine("", gojaview.NewReducerBuilder(logger.With("component", "reducer"))),
+			storage.WithReducerDesignDocEngine("javascript", gojaview.NewReducerBuilder(logger.With("component", "reducer"))),
+			storage.WithValidateDesignDocEngine("", gojaview.NewValidateServerBuilder(logger.With("component", "validate"))),
+			storage.WithValidateDesignDocEngine("javascript", gojaview.NewValidateServerBuilder(logger.With("component", "validate"))),
+			storage.WithUpdateDesignDocEngine("", gojaview.NewUpdateServer),
+			storage.WithUpdateDesignDocEngine("javascript", gojaview.NewUpdateServer),
+			storage.WithShowDesignDocEngine("", gojaview.NewShowServer),
+			storage.WithShowDesignDocEngine("javascript", gojaview.NewShowServer),
+			storage.WithListDesignDocEngine("", gojaview.NewListServer),
+			storage.WithListDesignDocEngine("javascript", gojaview.NewListServer),
+			storage.WithRewriteDesignDocEngine("", gojaview.NewRewriteServer),
+			storage.WithRewriteDesignDocEngine("javascript", gojaview.NewRewriteServer),
 		}
 	})
 }
@@ -0,0 +1,64 @@
+//go:build !noqueryserver
+
+package goydb
+
+import (
+	"context"
+	"os"
+	"slices"
+	"sort"
+
+	"github.com/goydb/goydb/internal/adapter/storage"
+	"github.com/goydb/goydb/internal/adapter/view/queryserver"
+	"github.com/goydb/goydb/internal/adapter/view/queryview"
+	"github.com/goydb/goydb/internal/handler"
+	"github.com/goydb/goydb/pkg/port"
+)
+
+func init() {
+	handler.RegisterFeature("query_servers")
+	handler.RegisterConfigDefaults(queryserver.ConfigDefaults)
+	RegisterStorageOptionHook(func(logger port.Logger, config *handler.ConfigStore) []storage.StorageOption {
+		// the commands are read once, query_servers can't be
+		// changed over HTTP as it would run arbitrary commands
+		section, _ := config.Section("query_servers")
+		commands := queryserver.Commands(section, os.Environ())
+		languages := make([]string, 0, len(commands))
+		for language := range commands {
+			languages = append(languages, language)
+		}
+		sort.Strings(languages)
+
+		var opts []storage.StorageOption
+		for _, language := range languages {
+			if builtinLanguage(language) {
+				logger.Warnf(context.Background(), "query server ignored, the language is built in", "language", language)
+				continue
+			}
+			qs := queryserver.New(language, commands[language], config.Get, logger.With("component", "query_server", "language", language))
+			opts = append(opts,
+				storage.WithViewDesignDocEngine(language, qs.NewViewServer),
+				storage.WithReducerEngine(language, qs.NewReducer),
+				storage.WithFilterDesignDocEngine(language, qs.NewFilterServer),
+				storage.WithValidateDesignDocEngine(language, qs.NewValidateServer),
+				storage.WithUpdateDesignDocEngine(language, qs.NewUpdateServer),
+			)
+		}
+		return opts
+	})
+}
+
+// builtinLanguage reports if the language is executed by a compiled-in
+// engine or by native functions
+func builtinLanguage(language string) bool {
+	features := handler.Features()
+	switch language {
+	case "", NativeLanguage, queryview.Language:
+		return true
+	case "javascript":
+		return slices.Contains(features, "goja")
+	case "tengo":
+		return slices.Contains(features, "tengo")
+	}
+	return false
+}
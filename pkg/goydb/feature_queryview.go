@@ -0,0 +1,17 @@
+package goydb
+
+import (
+	"github.com/goydb/goydb/internal/adapter/storage"
+	"github.com/goydb/goydb/internal/adapter/view/queryview"
+	"github.com/goydb/goydb/internal/handler"
+	"github.com/goydb/goydb/pkg/port"
+)
+
+func init() {
+	RegisterStorageOptionHook(func(logger port.Logger, _ *handler.ConfigStore) []storage.StorageOption {
+		return []storage.StorageOption{
+			storage.WithViewEngine(queryview.Language, queryview.NewViewServer),
+			storage.WithReducerEngine(queryview.Language, queryview.NewReducer),
+		}
+	})
+}
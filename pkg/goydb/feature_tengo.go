@@ -11,12 +11,17 @@ import (
 
 func init() {
 	handler.RegisterFeature("tengo")
-	RegisterStorageOptionHook(func(logger port.Logger) []storage.StorageOption {
+	RegisterStorageOptionHook(func(logger port.Logger, config *handler.ConfigStore) []storage.StorageOption {
+		tengoview.Configure(config.Get)
 		return []storage.StorageOption{
 			storage.WithViewEngine("tengo", tengoview.NewViewServer),
 			storage.WithFilterEngine("tengo", tengoview.NewFilterServer),
+			storage.WithReducerEngine("tengo", tengoview.NewReducerBuilder(logger.With("component", "reducer"))),
 			storage.WithValidateEngine("tengo", tengoview.NewValidateServerBuilder(logger.With("component", "validate"))),
 			storage.WithUpdateEngine("tengo", tengoview.NewUpdateServer),
+			storage.WithShowEngine("tengo", tengoview.NewShowServer),
+			storage.WithListEngine("tengo", tengoview.NewListServer),
+			storage.WithRewriteEngine("tengo", tengoview.NewRewriteServer),
 		}
 	})
 }
@@ -5,16 +5,21 @@ package goydb
 import (
 	"github.com/goydb/goydb/internal/adapter/storage"
 	"github.com/goydb/goydb/internal/adapter/view/tengoview"
+	"github.com/goydb/goydb/internal/handler"
 	"github.com/goydb/goydb/pkg/port"
 )
 
 func init() {
-	RegisterStorageOptionHook(func(logger port.Logger) []storage.StorageOption {
+	RegisterStorageOptionHook(func(logger port.Logger, _ *handler.ConfigStore) []storage.StorageOption {
 		return []storage.StorageOption{
 			storage.WithViewEngine("", tengoview.NewViewServer),
 			storage.WithFilterEngine("", tengoview.NewFilterServer),
+			storage.WithReducerEngine("", tengoview.NewReducerBuilder(logger.With("component", "reducer"))),
 			storage.WithValidateEngine("", tengoview.NewValidateServerBuilder(logger.With("component", "validate"))),
 			storage.WithUpdateEngine("", tengoview.NewUpdateServer),
+			storage.WithShowEngine("", tengoview.NewShowServer),
+			storage.WithListEngine("", tengoview.NewListServer),
+			storage.WithRewriteEngine("", tengoview.NewRewriteServer),
 		}
 	})
 }
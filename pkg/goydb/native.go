@@ -0,0 +1,73 @@
+package goydb
+
+import (
+	"github.com/goydb/goydb/internal/adapter/storage"
+	"github.com/goydb/goydb/internal/adapter/view/nativeview"
+)
+
+// NativeLanguage is the design document language of native Go functions
+const NativeLanguage = "go"
+
+// MapFunc emits the view rows of a document
+type MapFunc = nativeview.MapFunc
+
+// ReduceKey is a key of the first reduce pass with the id of the
+// document that emitted it
+type ReduceKey = nativeview.ReduceKey
+
+// ReduceFunc reduces the values, keys are nil if rereduce is set
+type ReduceFunc = nativeview.ReduceFunc
+
+// FilterFunc tests if a document passes the filter
+type FilterFunc = nativeview.FilterFunc
+
+// ValidateFunc validates a document update, updates are rejected by
+// returning *model.ErrForbidden or *model.ErrUnauthorized
+type ValidateFunc = nativeview.ValidateFunc
+
+// RegisterMap registers a native map function. Design documents with
+// "language": "go" reference it by "name@version" e.g.
+// {"views": {"by_customer": {"map": "myapp.byCustomer@2"}}}. The referenced
+// version must match the registered version, views are only rebuilt if the
+// design document changes, bump the version in both after changing a function.
+func (c *Config) RegisterMap(name, version string, fn MapFunc) {
+	c.nativeRegistry().RegisterMap(name, version, fn)
+}
+
+// RegisterReduce registers a native reduce function, design
+// documents reference it by "name@version" like map functions.
+func (c *Config) RegisterReduce(name, version string, fn ReduceFunc) {
+	c.nativeRegistry().RegisterReduce(name, version, fn)
+}
+
+// RegisterFilter registers a native filter function, design
+// documents reference it by "name" or "name@version".
+func (c *Config) RegisterFilter(name, version string, fn FilterFunc) {
+	c.nativeRegistry().RegisterFilter(name, version, fn)
+}
+
+// RegisterValidate registers a native validate_doc_update function,
+// design documents reference it by "name" or "name@version".
+func (c *Config) RegisterValidate(name, version string, fn ValidateFunc) {
+	c.nativeRegistry().RegisterValidate(name, version, fn)
+}
+
+func (c *Config) nativeRegistry() *nativeview.Registry {
+	if c.native == nil {
+		c.native = nativeview.NewRegistry()
+	}
+	return c.native
+}
+
+// nativeStorageOptions registers the native engines if functions were registered
+func (c *Config) nativeStorageOptions() []storage.StorageOption {
+	if c.native == nil {
+		return nil
+	}
+	return []storage.StorageOption{
+		storage.WithViewEngine(NativeLanguage, c.native.NewViewServer),
+		storage.WithReducerEngine(NativeLanguage, c.native.NewReducer),
+		storage.WithFilterEngine(NativeLanguage, c.native.NewFilterServer),
+		storage.WithValidateEngine(NativeLanguage, c.native.NewValidateServer),
+	}
+}
@@ -0,0 +1,110 @@
+package goydb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/goydb/goydb/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func nativeRequest(t *testing.T, h http.Handler, method, path string, body interface{}) (int, map[string]interface{}) {
+	t.Helper()
+	var r *bytes.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		require.NoError(t, err)
+		r = bytes.NewReader(b)
+	} else {
+		r = bytes.NewReader(nil)
+	}
+	req := httptest.NewRequest(method, path, r)
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBasicAuth("admin", "secret")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	result := map[string]interface{}{}
+	_ = json.NewDecoder(w.Body).Decode(&result)
+	return w.Code, result
+}
+
+func TestConfig_NativeFunctions(t *testing.T) {
+	cfg, err := NewConfig()
+	require.NoError(t, err)
+	cfg.DatabaseDir = t.TempDir()
+	cfg.EnablePublicDir = false
+
+	cfg.RegisterMap("test.byCustomer", "2", func(ctx context.Context, doc *model.Document, emit func(key, value interface{})) error {
+		if customer, ok := doc.Data["customer"]; ok {
+			emit(customer, doc.Data["total"])
+		}
+		return nil
+	})
+	cfg.RegisterReduce("test.sum", "1", func(keys []ReduceKey, values []interface{}, rereduce bool) (interface{}, error) {
+		var sum float64
+		for _, v := range values {
+			sum += v.(float64)
+		}
+		return sum, nil
+	})
+	cfg.RegisterValidate("test.requireTotal", "1", func(ctx context.Context, newDoc, oldDoc, userCtx, secObj map[string]interface{}) error {
+		if _, ok := newDoc["total"]; !ok && newDoc["_deleted"] != true {
+			return &model.ErrForbidden{Msg: "total is required"}
+		}
+		return nil
+	})
+
+	gdb, err := cfg.BuildDatabase()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = gdb.Close() })
+	h := gdb.Handler
+
+	code, res := nativeRequest(t, h, "PUT", "/orders", nil)
+	require.Equal(t, http.StatusCreated, code, res)
+
+	// the version referenced by the design document isn't registered
+	code, res = nativeRequest(t, h, "PUT", "/orders/_design/outdated", map[string]interface{}{
+		"language": NativeLanguage,
+		"views": map[string]interface{}{
+			"totals": map[string]interface{}{"map": "test.byCustomer@1"},
+		},
+	})
+	assert.NotEqual(t, http.StatusCreated, code)
+	assert.Contains(t, res["reason"], `requires "1"`)
+	code, res = nativeRequest(t, h, "PUT", "/orders/_design/app", map[string]interface{}{
+		"language": NativeLanguage,
+		"views": map[string]interface{}{
+			"totals": map[string]interface{}{"map": "test.byCustomer@2", "reduce": "test.sum@1"},
+		},
+		"validate_doc_update": "test.requireTotal",
+	})
+	require.Equal(t, http.StatusCreated, code, res)
+
+	for i, customer := range []string{"a", "b", "a"} {
+		code, res = nativeRequest(t, h, "PUT", fmt.Sprintf("/orders/o%d", i), map[string]interface{}{
+			"customer": customer,
+			"total":    i + 1,
+		})
+		require.Equal(t, http.StatusCreated, code, res)
+	}
+	code, res = nativeRequest(t, h, "PUT", "/orders/invalid", map[string]interface{}{"customer": "c"})
+	assert.Equal(t, http.StatusForbidden, code, res)
+	assert.Equal(t, "total is required", res["reason"])
+
+	code, res = nativeRequest(t, h, "GET", "/orders/_design/app/_view/totals?reduce=false", nil)
+	require.Equal(t, http.StatusOK, code, res)
+	assert.Len(t, res["rows"], 3)
+
+	code, res = nativeRequest(t, h, "GET", "/orders/_design/app/_view/totals?group=true", nil)
+	require.Equal(t, http.StatusOK, code, res)
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"key": "a", "value": float64(4)},
+		map[string]interface{}{"key": "b", "value": float64(2)},
+	}, res["rows"])
+}
@@ -0,0 +1,75 @@
+package goydb
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_QueryViews(t *testing.T) {
+	cfg, err := NewConfig()
+	require.NoError(t, err)
+	cfg.DatabaseDir = t.TempDir()
+	cfg.EnablePublicDir = false
+
+	gdb, err := cfg.BuildDatabase()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = gdb.Close() })
+	h := gdb.Handler
+
+	code, res := nativeRequest(t, h, "PUT", "/orders", nil)
+	require.Equal(t, http.StatusCreated, code, res)
+
+	code, res = nativeRequest(t, h, "PUT", "/orders/_design/app", map[string]interface{}{
+		"language": "query",
+		"views": map[string]interface{}{
+			"totals": map[string]interface{}{
+				"map": map[string]interface{}{
+					"fields": []string{"customer"},
+					"value":  "$doc.total",
+					"filter": map[string]interface{}{"type": "order"},
+				},
+				"reduce": "_sum",
+			},
+		},
+	})
+	require.Equal(t, http.StatusCreated, code, res)
+
+	for i, customer := range []string{"a", "b", "a"} {
+		code, res = nativeRequest(t, h, "PUT", fmt.Sprintf("/orders/o%d", i), map[string]interface{}{
+			"type":     "order",
+			"customer": customer,
+			"total":    i + 1,
+		})
+		require.Equal(t, http.StatusCreated, code, res)
+	}
+	code, res = nativeRequest(t, h, "PUT", "/orders/note", map[string]interface{}{"type": "note", "customer": "a", "total": 100})
+	require.Equal(t, http.StatusCreated, code, res)
+
+	code, res = nativeRequest(t, h, "GET", "/orders/_design/app/_view/totals?reduce=false", nil)
+	require.Equal(t, http.StatusOK, code, res)
+	assert.Len(t, res["rows"], 3)
+
+	code, res = nativeRequest(t, h, "GET", "/orders/_design/app/_view/totals?group=true", nil)
+	require.Equal(t, http.StatusOK, code, res)
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"key": "a", "value": float64(4)},
+		map[string]interface{}{"key": "b", "value": float64(2)},
+	}, res["rows"])
+
+	// custom reduce functions are rejected
+	code, res = nativeRequest(t, h, "PUT", "/orders/_design/custom", map[string]interface{}{
+		"language": "query",
+		"views": map[string]interface{}{
+			"totals": map[string]interface{}{
+				"map":    map[string]interface{}{"fields": []string{"customer"}},
+				"reduce": "function(keys, values) { return 1; }",
+			},
+		},
+	})
+	assert.Equal(t, http.StatusBadRequest, code, res)
+	assert.Contains(t, res["reason"], "views.totals.reduce: query views only support built-in reducers")
+}
@@ -2,15 +2,17 @@ package goydb
 
 import (
 	"github.com/goydb/goydb/internal/adapter/storage"
+	"github.com/goydb/goydb/internal/handler"
 	"github.com/goydb/goydb/pkg/port"
 )
 
-type storageOptionHook func(logger port.Logger) []storage.StorageOption
+type storageOptionHook func(logger port.Logger, config *handler.ConfigStore) []storage.StorageOption
 
 var storageOptionHooks []storageOptionHook
 
 // RegisterStorageOptionHook adds a hook that provides additional storage
-// options at database open time. Used by build-tagged feature files.
+// options at database open time. Used by build-tagged feature files,
+// the config allows engines to read their limits.
 func RegisterStorageOptionHook(hook storageOptionHook) {
 	storageOptionHooks = append(storageOptionHooks, hook)
 }
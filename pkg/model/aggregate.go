@@ -0,0 +1,363 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Accumulator operations supported by the _aggregate endpoint.
+const (
+	AccumulatorCount       = "$count"
+	AccumulatorSum         = "$sum"
+	AccumulatorMin         = "$min"
+	AccumulatorMax         = "$max"
+	AccumulatorAvg         = "$avg"
+	AccumulatorDistinct    = "$distinct"
+	AccumulatorPercentiles = "$percentiles"
+)
+
+// ErrAggregateLimitExceeded is returned if an aggregation exceeds
+// the configured number of groups or memory.
+var ErrAggregateLimitExceeded = errors.New("aggregation exceeds the configured limits")
+
+// AggregateQuery selects documents using a Mango selector, groups
+// them by the values of the GroupBy fields and computes the
+// accumulators for every group.
+type AggregateQuery struct {
+	// Selector JSON object describing criteria used to select documents.
+	// Optional, all documents are aggregated if empty.
+	Selector SelectorGroup `json:"selector"`
+	// GroupBy fields whose values form the key of a group. Documents
+	// lacking a field are grouped under null. Optional, without any
+	// field all matching documents form a single group.
+	GroupBy []string `json:"group_by"`
+	// Accumulators maps the name of an output value to its accumulator.
+	Accumulators map[string]Accumulator `json:"accumulators"`
+	// UseIndex instructs the query planner to use a specific index.
+	UseIndex interface{} `json:"use_index,omitempty"`
+	// Include execution statistics in the query response.
+	ExecutionStats bool `json:"execution_stats"`
+}
+
+// FindQuery returns the query used for the match stage.
+func (q AggregateQuery) FindQuery() FindQuery {
+	return FindQuery{
+		Selector: q.Selector,
+		UseIndex: q.UseIndex,
+	}
+}
+
+// Accumulator computes a value over the documents of a group, e.g.
+// {"$sum": "amount"} or {"$percentiles": {"field": "amount", "percentiles": [50, 99]}}.
+type Accumulator struct {
+	Op    string
+	Field string
+	// Percentiles to compute (0-100), $percentiles only.
+	Percentiles []float64
+}
+
+func (a *Accumulator) UnmarshalJSON(data []byte) error {
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return err
+	}
+	if len(obj) != 1 {
+		return fmt.Errorf("accumulator needs exactly one operator, got %d", len(obj))
+	}
+
+	for op, arg := range obj {
+		a.Op = op
+		switch op {
+		case AccumulatorCount:
+			// {"$count": true} counts all documents, {"$count": "field"}
+			// only the documents having the field
+			var field string
+			if err := json.Unmarshal(arg, &field); err == nil {
+				a.Field = field
+			}
+		case AccumulatorSum, AccumulatorMin, AccumulatorMax, AccumulatorAvg, AccumulatorDistinct:
+			if err := json.Unmarshal(arg, &a.Field); err != nil || a.Field == "" {
+				return fmt.Errorf("accumulator %s expects a field name", op)
+			}
+		case AccumulatorPercentiles:
+			var p struct {
+				Field       string    `json:"field"`
+				Percentiles []float64 `json:"percentiles"`
+			}
+			if err := json.Unmarshal(arg, &p); err != nil || p.Field == "" {
+				return fmt.Errorf("accumulator %s expects an object with field and percentiles", op)
+			}
+			if len(p.Percentiles) == 0 {
+				p.Percentiles = []float64{50}
+			}
+			for _, v := range p.Percentiles {
+				if v < 0 || v > 100 {
+					return fmt.Errorf("accumulator %s: percentile %v out of range 0-100", op, v)
+				}
+			}
+			a.Field, a.Percentiles = p.Field, p.Percentiles
+		default:
+			return fmt.Errorf("unknown accumulator %q", op)
+		}
+	}
+	return nil
+}
+
+// AggregateLimits bound the resources used by an Aggregation,
+// zero means unlimited.
+type AggregateLimits struct {
+	// MaxGroups is the maximum number of distinct groups.
+	MaxGroups int
+	// MaxMemory is the approximate maximum number of bytes used
+	// for group keys and the values kept by $distinct and $percentiles.
+	MaxMemory int64
+}
+
+// AggregateRow is a group of the aggregation result.
+type AggregateRow struct {
+	Key   []interface{}          `json:"key"`
+	Value map[string]interface{} `json:"value"`
+}
+
+// Aggregation computes the result of an AggregateQuery over the
+// documents passed to Add. The documents have to match the selector
+// already.
+type Aggregation struct {
+	query  AggregateQuery
+	limits AggregateLimits
+	names  []string
+	groups map[string]*aggregateGroup
+	memory int64
+}
+
+// aggregateGroup holds the state of the accumulators for a group
+type aggregateGroup struct {
+	key  []interface{}
+	accs []accumulatorState
+}
+
+type accumulatorState struct {
+	count    int
+	sum      float64
+	min, max interface{}
+	distinct map[string]interface{}
+	values   []float64
+}
+
+// memory estimations in bytes
+const (
+	aggregateGroupOverhead = 64
+	aggregateValueOverhead = 16
+)
+
+// NewAggregation creates the aggregation for the query
+func NewAggregation(query AggregateQuery, limits AggregateLimits) *Aggregation {
+	names := make([]string, 0, len(query.Accumulators))
+	for name := range query.Accumulators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return &Aggregation{
+		query:  query,
+		limits: limits,
+		names:  names,
+		groups: make(map[string]*aggregateGroup),
+	}
+}
+
+// Add accumulates the document into its group
+func (a *Aggregation) Add(doc *Document) error {
+	key := make([]interface{}, len(a.query.GroupBy))
+	for i, f := range a.query.GroupBy {
+		key[i], _ = aggregateField(doc, f)
+	}
+	ks := ViewKeyString(key)
+
+	g, ok := a.groups[ks]
+	if !ok {
+		if a.limits.MaxGroups > 0 && len(a.groups) >= a.limits.MaxGroups {
+			return fmt.Errorf("%w: more than %d groups", ErrAggregateLimitExceeded, a.limits.MaxGroups)
+		}
+		if err := a.grow(int64(len(ks)) + aggregateGroupOverhead*int64(len(a.names)+1)); err != nil {
+			return err
+		}
+		g = &aggregateGroup{key: key, accs: make([]accumulatorState, len(a.names))}
+		a.groups[ks] = g
+	}
+
+	for i, name := range a.names {
+		if err := a.accumulate(&g.accs[i], a.query.Accumulators[name], doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *Aggregation) accumulate(s *accumulatorState, acc Accumulator, doc *Document) error {
+	if acc.Op == AccumulatorCount {
+		if _, ok := aggregateField(doc, acc.Field); acc.Field == "" || ok {
+			s.count++
+		}
+		return nil
+	}
+	v, ok := aggregateField(doc, acc.Field)
+	if !ok {
+		return nil
+	}
+
+	switch acc.Op {
+	case AccumulatorSum, AccumulatorAvg:
+		if n, ok := aggregateNumber(v); ok {
+			s.count++
+			s.sum += n
+		}
+	case AccumulatorMin:
+		if s.count == 0 || ViewKeyCmp(v, s.min) < 0 {
+			s.min = v
+		}
+		s.count++
+	case AccumulatorMax:
+		if s.count == 0 || ViewKeyCmp(v, s.max) > 0 {
+			s.max = v
+		}
+		s.count++
+	case AccumulatorDistinct:
+		vs := ViewKeyString(v)
+		if s.distinct == nil {
+			s.distinct = make(map[string]interface{})
+		}
+		if _, ok := s.distinct[vs]; !ok {
+			if err := a.grow(int64(len(vs)) + aggregateValueOverhead); err != nil {
+				return err
+			}
+			s.distinct[vs] = v
+		}
+	case AccumulatorPercentiles:
+		if n, ok := aggregateNumber(v); ok {
+			if err := a.grow(8); err != nil {
+				return err
+			}
+			s.values = append(s.values, n)
+		}
+	}
+	return nil
+}
+
+// grow accounts n bytes against the memory limit
+func (a *Aggregation) grow(n int64) error {
+	a.memory += n
+	if a.limits.MaxMemory > 0 && a.memory > a.limits.MaxMemory {
+		return fmt.Errorf("%w: more than %d bytes", ErrAggregateLimitExceeded, a.limits.MaxMemory)
+	}
+	return nil
+}
+
+// Rows returns the groups ordered by key using view collation
+func (a *Aggregation) Rows() []*AggregateRow {
+	rows := make([]*AggregateRow, 0, len(a.groups))
+	for _, g := range a.groups {
+		row := &AggregateRow{
+			Key:   g.key,
+			Value: make(map[string]interface{}, len(a.names)),
+		}
+		for i, name := range a.names {
+			row.Value[name] = g.accs[i].result(a.query.Accumulators[name])
+		}
+		rows = append(rows, row)
+	}
+
+	sort.Slice(rows, func(i, j int) bool {
+		return aggregateLess(rows[i].Key, rows[j].Key)
+	})
+	return rows
+}
+
+func (s *accumulatorState) result(acc Accumulator) interface{} {
+	switch acc.Op {
+	case AccumulatorCount:
+		return s.count
+	case AccumulatorSum:
+		return s.sum
+	case AccumulatorMin:
+		return s.min
+	case AccumulatorMax:
+		return s.max
+	case AccumulatorAvg:
+		if s.count == 0 {
+			return nil
+		}
+		return s.sum / float64(s.count)
+	case AccumulatorDistinct:
+		values := make([]interface{}, 0, len(s.distinct))
+		for _, v := range s.distinct {
+			values = append(values, v)
+		}
+		sort.Slice(values, func(i, j int) bool {
+			return aggregateLess(values[i], values[j])
+		})
+		return values
+	case AccumulatorPercentiles:
+		sort.Float64s(s.values)
+		result := make(map[string]interface{}, len(acc.Percentiles))
+		for _, p := range acc.Percentiles {
+			result[strconv.FormatFloat(p, 'f', -1, 64)] = percentile(s.values, p)
+		}
+		return result
+	}
+	return nil
+}
+
+// percentile returns the p-th percentile of the sorted values using
+// linear interpolation between the closest ranks, nil if there are
+// no values.
+func percentile(sorted []float64, p float64) interface{} {
+	if len(sorted) == 0 {
+		return nil
+	}
+	rank := p / 100 * float64(len(sorted)-1)
+	lo := int(math.Floor(rank))
+	hi := int(math.Ceil(rank))
+	return sorted[lo] + (sorted[hi]-sorted[lo])*(rank-float64(lo))
+}
+
+// aggregateLess orders values by view collation, values that collate
+// equal (e.g. objects) are ordered by their JSON representation so
+// that the order is stable.
+func aggregateLess(a, b interface{}) bool {
+	if c := ViewKeyCmp(a, b); c != 0 {
+		return c < 0
+	}
+	return strings.Compare(ViewKeyString(a), ViewKeyString(b)) < 0
+}
+
+func aggregateNumber(v interface{}) (float64, bool) {
+	if viewKeyTypePriority(v) != 2 {
+		return 0, false
+	}
+	return viewToFloat64(v), true
+}
+
+// aggregateField returns the value of the dotted path in the document
+// and whether the path exists. Unlike Document.Exists a field that is
+// explicitly null is reported as present, it is grouped under a null
+// key and counted by $count.
+func aggregateField(doc *Document, path string) (interface{}, bool) {
+	var v interface{} = doc.Data
+	for _, part := range strings.Split(path, ".") {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		v, ok = m[part]
+		if !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
@@ -0,0 +1,91 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func aggregateTestQuery(t *testing.T, q string) AggregateQuery {
+	var query AggregateQuery
+	require.NoError(t, json.Unmarshal([]byte(q), &query))
+	return query
+}
+
+func TestAggregation_KeyOrder(t *testing.T) {
+	agg := NewAggregation(aggregateTestQuery(t, `{
+		"group_by": ["k"],
+		"accumulators": {"n": {"$count": true}}
+	}`), AggregateLimits{})
+
+	for _, v := range []interface{}{"b", 2.0, nil, "a", true, 1.0, "b"} {
+		data := map[string]interface{}{}
+		if v != nil {
+			data["k"] = v
+		}
+		require.NoError(t, agg.Add(&Document{Data: data}))
+	}
+
+	var keys []interface{}
+	for _, row := range agg.Rows() {
+		keys = append(keys, row.Key[0])
+	}
+	assert.Equal(t, []interface{}{nil, true, 1.0, 2.0, "a", "b"}, keys)
+	assert.Equal(t, 2, agg.Rows()[5].Value["n"])
+}
+
+func TestAggregation_ZeroValues(t *testing.T) {
+	agg := NewAggregation(aggregateTestQuery(t, `{
+		"group_by": ["k"],
+		"accumulators": {
+			"n": {"$count": "v"},
+			"min": {"$min": "v"}
+		}
+	}`), AggregateLimits{})
+
+	for _, data := range []map[string]interface{}{
+		{"k": 0.0, "v": 0.0},
+		{"k": 0.0, "v": 5.0},
+		{"k": false},
+	} {
+		require.NoError(t, agg.Add(&Document{Data: data}))
+	}
+
+	rows := agg.Rows()
+	require.Len(t, rows, 2)
+	assert.Equal(t, []interface{}{false}, rows[0].Key)
+	assert.Equal(t, 0, rows[0].Value["n"])
+	assert.Equal(t, []interface{}{0.0}, rows[1].Key)
+	assert.Equal(t, 2, rows[1].Value["n"])
+	assert.Equal(t, 0.0, rows[1].Value["min"])
+}
+
+func TestAggregation_Percentiles(t *testing.T) {
+	agg := NewAggregation(aggregateTestQuery(t, `{
+		"accumulators": {"p": {"$percentiles": {"field": "v", "percentiles": [0, 25, 50, 100]}}}
+	}`), AggregateLimits{})
+
+	for _, v := range []float64{40, 10, 30, 20} {
+		require.NoError(t, agg.Add(&Document{Data: map[string]interface{}{"v": v}}))
+	}
+	rows := agg.Rows()
+	require.Len(t, rows, 1)
+	assert.Equal(t, map[string]interface{}{
+		"0": 10.0, "25": 17.5, "50": 25.0, "100": 40.0,
+	}, rows[0].Value["p"])
+}
+
+func TestAggregation_MemoryLimit(t *testing.T) {
+	agg := NewAggregation(aggregateTestQuery(t, `{
+		"accumulators": {"d": {"$distinct": "v"}}
+	}`), AggregateLimits{MaxMemory: 1024})
+
+	var err error
+	for i := 0; i < 1000 && err == nil; i++ {
+		err = agg.Add(&Document{Data: map[string]interface{}{"v": float64(i)}})
+	}
+	assert.True(t, errors.Is(err, ErrAggregateLimitExceeded))
+}
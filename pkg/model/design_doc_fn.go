@@ -8,11 +8,14 @@ import (
 type FnType string
 
 const (
-	ViewFn   FnType = "views"
-	SearchFn FnType = "indexes"
-	FilterFn FnType = "filters"
-	MangoFn  FnType = "mango_indexes"
-	UpdateFn FnType = "updates"
+	ViewFn    FnType = "views"
+	SearchFn  FnType = "indexes"
+	FilterFn  FnType = "filters"
+	MangoFn   FnType = "mango_indexes"
+	UpdateFn  FnType = "updates"
+	ShowFn    FnType = "shows"
+	ListFn    FnType = "lists"
+	RewriteFn FnType = "rewrites"
 )
 
 type DesignDocFn struct {
@@ -222,10 +222,33 @@ func (doc Document) Language() string {
 	return "" // default
 }
 
+// IndexMode returns the options.index_mode of a design
+// document or "" if the database default is used
+func (doc Document) IndexMode() string {
+	options, _ := doc.Data["options"].(map[string]interface{})
+	v, _ := options["index_mode"].(string)
+	return v
+}
+
 func (doc Document) IsDesignDoc() bool {
 	return strings.HasPrefix(doc.ID, string(DesignDocPrefix))
 }
 
+// StagedDesignDocSuffix marks the staged version of a design document,
+// "_design/app.staged" builds its indices in the background until it
+// is promoted to "_design/app".
+const StagedDesignDocSuffix = ".staged"
+
+// StagedDesignDocID returns the ID of the staged version of the design document
+func StagedDesignDocID(id string) string {
+	return id + StagedDesignDocSuffix
+}
+
+// IsStagedDesignDoc returns true for the staged version of a design document
+func (doc Document) IsStagedDesignDoc() bool {
+	return doc.IsDesignDoc() && strings.HasSuffix(doc.ID, StagedDesignDocSuffix)
+}
+
 func (doc Document) IsLocalDoc() bool {
 	return strings.HasPrefix(doc.ID, string(LocalDocPrefix))
 }
@@ -236,13 +259,16 @@ type Function struct {
 	Name string
 	Type FnType
 
-	MapFn        string
-	ReduceFn     string
-	SearchFn     string
-	Analyzer     string
-	FilterFn     string
-	UpdateFnCode string
-	MangoFields  []string
+	MapFn         string
+	ReduceFn      string
+	SearchFn      string
+	Analyzer      string
+	FilterFn      string
+	UpdateFnCode  string
+	ShowFnCode    string
+	ListFnCode    string
+	RewriteFnCode string
+	MangoFields   []string
 }
 
 func (f *Function) DesignDocFn() *DesignDocFn {
@@ -253,6 +279,10 @@ func (f *Function) DesignDocFn() *DesignDocFn {
 	}
 }
 
+// ViewLib is the name of the views property that contains the modules
+// that can be required by view functions, it is not a view.
+const ViewLib = "lib"
+
 func (doc *Document) Functions() []*Function {
 	var functions []*Function
 
@@ -260,12 +290,16 @@ func (doc *Document) Functions() []*Function {
 	views, ok := doc.Data["views"].(map[string]interface{})
 	if ok {
 		for name, viewInterface := range views {
+			// lib contains the modules of the views
+			if name == ViewLib {
+				continue
+			}
 			view, ok := viewInterface.(map[string]interface{})
 			if !ok {
 				continue
 			}
 
-			mapFn, _ := view["map"].(string)
+			mapFn := mapFunction(view["map"])
 			reduceFn, _ := view["reduce"].(string)
 
 			functions = append(functions, &Function{
@@ -327,6 +361,22 @@ func (doc *Document) Functions() []*Function {
 	return functions
 }
 
+// mapFunction returns the source of a map function, declarative
+// map functions (objects) are passed to the engine as JSON.
+func mapFunction(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case map[string]interface{}:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return ""
+		}
+		return string(data)
+	}
+	return ""
+}
+
 func (doc *Document) View(name string) (view *View, ok bool) {
 	views, ok := doc.Data["views"].(map[string]interface{})
 	if !ok {
@@ -334,7 +384,7 @@ func (doc *Document) View(name string) (view *View, ok bool) {
 	}
 
 	viewInterface, ok := views[name]
-	if !ok {
+	if !ok || name == ViewLib {
 		return nil, false
 	}
 
@@ -343,13 +393,15 @@ func (doc *Document) View(name string) (view *View, ok bool) {
 		return nil, false
 	}
 
-	mapFn, _ := viewData["map"].(string)
+	mapFn := mapFunction(viewData["map"])
 	reduceFn, _ := viewData["reduce"].(string)
+	dbCopy, _ := viewData["dbcopy"].(string)
 
 	return &View{
 		Language: doc.Language(),
 		MapFn:    mapFn,
 		ReduceFn: reduceFn,
+		DBCopy:   dbCopy,
 	}, true
 }
 
@@ -517,6 +569,54 @@ func (doc *Document) Update(name string) (*Function, bool) {
 	}, true
 }
 
+// Show returns a specific show function by name
+func (doc *Document) Show(name string) (*Function, bool) {
+	if !doc.IsDesignDoc() {
+		return nil, false
+	}
+
+	shows, ok := doc.Data["shows"].(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+
+	code, ok := shows[name].(string)
+	if !ok {
+		return nil, false
+	}
+
+	return &Function{
+		doc:        doc,
+		Name:       name,
+		Type:       ShowFn,
+		ShowFnCode: code,
+	}, true
+}
+
+// List returns a specific list function by name
+func (doc *Document) List(name string) (*Function, bool) {
+	if !doc.IsDesignDoc() {
+		return nil, false
+	}
+
+	lists, ok := doc.Data["lists"].(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+
+	code, ok := lists[name].(string)
+	if !ok {
+		return nil, false
+	}
+
+	return &Function{
+		doc:        doc,
+		Name:       name,
+		Type:       ListFn,
+		ListFnCode: code,
+	}, true
+}
+
 func (doc *Document) Field(path string) interface{} {
 	parts := strings.Split(path, ".")
 	v := reflect.ValueOf(doc.Data)
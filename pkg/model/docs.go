@@ -18,3 +18,20 @@ var MetaBucket = []byte("meta")
 // RevsLimitKey is the key for the revs_limit value in MetaBucket.
 // Value is a big-endian uint64. Default is 1000 when absent.
 var RevsLimitKey = []byte("revs_limit")
+
+// IndexModeKey is the key for the index_mode value in MetaBucket.
+// Value is IndexModeSync or IndexModeAsync, sync when absent.
+var IndexModeKey = []byte("index_mode")
+
+// DBCopyAuthorKey returns the key of the DBCopyAuthor of the design
+// document in MetaBucket. Value is the JSON encoded DBCopyAuthor.
+func DBCopyAuthorKey(ddocID string) []byte {
+	return []byte("dbcopy_author:" + ddocID)
+}
+
+const (
+	// IndexModeSync updates the index in the transaction of the write
+	IndexModeSync = "sync"
+	// IndexModeAsync updates the index in the background from the changes
+	IndexModeAsync = "async"
+)
@@ -62,12 +62,23 @@ type FindQuery struct {
 	Conflicts bool `json:"conflicts"`
 	// Stable — single-node no-op; accepted for CouchDB compatibility.
 	Stable bool `json:"stable"`
-	// Update — single-node no-op; accepted for CouchDB compatibility.
-	Update string `json:"update"`
+	// Update — "false" or "lazy" use a building index as is, otherwise
+	// the documents are scanned until the index is built.
+	Update FindUpdate `json:"update"`
 	// R — quorum parameter; single-node no-op.
 	R int `json:"r"`
 	// Q — shard parameter; single-node no-op.
 	Q int `json:"q"`
+	// After only returns the documents positioned after the
+	// cursor, used to page through results without skip
+	After *FindCursor `json:"-"`
+}
+
+// FindCursor is the position of a document in the results of a
+// query, the values of the sort fields followed by the document id
+type FindCursor struct {
+	Key []interface{} `json:"key,omitempty"`
+	ID  string        `json:"id"`
 }
 
 // EqConditions returns a map of fieldName → value for all top-level $eq
@@ -87,10 +98,61 @@ func (fq FindQuery) EqConditions() map[string]interface{} {
 	return result
 }
 
+// ReferencedFields returns the fields referenced by the selector,
+// the sort and the fields projection of the query.
+func (fq FindQuery) ReferencedFields() []string {
+	fields := fq.Selector.Fields()
+	for _, s := range fq.Sort {
+		fields = append(fields, s.Field)
+	}
+	return append(fields, fq.Fields...)
+}
+
+// Normalized returns a canonical representation of the parts of the
+// query that determine its result. Queries that only differ in the
+// order of their selector members have the same representation.
+func (fq FindQuery) Normalized() string {
+	var b strings.Builder
+	b.WriteString(fq.Selector.normalized())
+	for _, s := range fq.Sort {
+		fmt.Fprintf(&b, "|sort(%s, %t)", s.Field, s.Order)
+	}
+	fmt.Fprintf(&b, "|fields%q|limit(%d)|skip(%d)|bookmark(%q)|use_index(%v)|update(%q)",
+		fq.Fields, fq.Limit, fq.Skip, fq.Bookmark, fq.UseIndex, fq.Update)
+	return b.String()
+}
+
 func (fq FindQuery) Match(doc *Document) (bool, error) {
 	return fq.Selector.Match(doc)
 }
 
+// Cursor returns the position of the document in the results
+func (fq FindQuery) Cursor(doc *Document) *FindCursor {
+	c := &FindCursor{ID: doc.ID}
+	for _, sq := range fq.Sort {
+		c.Key = append(c.Key, doc.Field(sq.Field))
+	}
+	return c
+}
+
+// AfterCursor returns true if the document is positioned after
+// the After cursor of the query or if the query has no cursor
+func (fq FindQuery) AfterCursor(doc *Document) bool {
+	if fq.After == nil {
+		return true
+	}
+	for i, sq := range fq.Sort {
+		var key interface{}
+		if i < len(fq.After.Key) {
+			key = fq.After.Key[i]
+		}
+		if c := sq.compare(doc.Field(sq.Field), key); c != 0 {
+			return c > 0
+		}
+	}
+	return doc.ID > fq.After.ID
+}
+
 func (fq FindQuery) SortDocuments(docs []*Document) {
 	if len(fq.Sort) == 0 {
 		return // no sort parameters given, leave orginal order
@@ -128,6 +190,28 @@ type ExecutionStats struct {
 	// Total execution time in milliseconds as measured by the
 	// database.
 	ExecutionTime float64 `json:"execution_time_ms"`
+
+	// Plan is the query plan that was executed, it
+	// carries the warning of an unusable use_index
+	Plan *QueryPlan `json:"-"`
+}
+
+// FindUpdate is the update parameter of a query, CouchDB
+// uses a boolean, the view modes are accepted as strings.
+type FindUpdate string
+
+func (fu *FindUpdate) UnmarshalJSON(data []byte) error {
+	var b bool
+	if err := json.Unmarshal(data, &b); err == nil {
+		*fu = FindUpdate(fmt.Sprint(b))
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	*fu = FindUpdate(s)
+	return nil
 }
 
 type SortList []Sort
@@ -157,12 +241,15 @@ func (sl *SortList) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// Less compares the documents by the first sort field,
+// the following fields are compared if the values are equal
 func (sl SortList) Less(l, r *Document) bool {
-	var ltr = true
 	for _, sq := range sl {
-		ltr = ltr && sq.Less(l, r)
+		if c := sq.compare(l.Field(sq.Field), r.Field(sq.Field)); c != 0 {
+			return c < 0
+		}
 	}
-	return ltr
+	return false
 }
 
 const (
@@ -176,13 +263,25 @@ type Sort struct {
 }
 
 func (s Sort) Less(l, r *Document) bool {
+	return s.compare(l.Field(s.Field), r.Field(s.Field)) < 0
+}
+
+// compare returns -1 if l is sorted before r, 1 if l is
+// sorted after r and 0 if the values are equal
+func (s Sort) compare(l, r interface{}) int {
 	var lv, rv SelectorValue
-	lv.Set(l.Field(s.Field))
-	rv.Set(r.Field(s.Field))
-	if s.Order {
-		return rv.LessThen(&lv)
+	lv.Set(l)
+	rv.Set(r)
+	c := 0
+	if lv.LessThen(&rv) {
+		c = -1
+	} else if rv.LessThen(&lv) {
+		c = 1
+	}
+	if s.Order == SortOrderDesc {
+		return -c
 	}
-	return lv.LessThen(&rv)
+	return c
 }
 
 type SelectorGroupOp string
@@ -235,6 +334,37 @@ func (sg SelectorGroup) String() string {
 	return fmt.Sprintf("%s(%v)", string(sg.Operation), strings.Join(members, ", "))
 }
 
+// normalized returns the String representation with
+// the members of all groups in sorted order
+func (sg SelectorGroup) normalized() string {
+	members := make([]string, len(sg.Members))
+	for i, m := range sg.Members {
+		if g, ok := m.(*SelectorGroup); ok {
+			members[i] = g.normalized()
+		} else {
+			members[i] = m.String()
+		}
+	}
+	sort.Strings(members)
+
+	return fmt.Sprintf("%s(%v)", string(sg.Operation), strings.Join(members, ", "))
+}
+
+// Fields returns the names of all fields used in the selector group
+// and its nested groups.
+func (sg SelectorGroup) Fields() []string {
+	var fields []string
+	for _, m := range sg.Members {
+		switch m := m.(type) {
+		case *FieldSelector:
+			fields = append(fields, m.Field)
+		case *SelectorGroup:
+			fields = append(fields, m.Fields()...)
+		}
+	}
+	return fields
+}
+
 func (sg *SelectorGroup) UnmarshalJSON(blob []byte) error {
 	if sg.Operation == "" { // set default
 		sg.Operation = SelectorAnd
@@ -317,3 +317,31 @@ func NewTestCase(
 		want:      want,
 	}
 }
+
+func TestFindQuery_SortAndCursor(t *testing.T) {
+	var fq FindQuery
+	require.NoError(t, json.Unmarshal([]byte(`{"sort": ["type", {"age": "desc"}]}`), &fq))
+
+	doc := func(id, typ string, age float64) *Document {
+		return &Document{ID: id, Data: map[string]interface{}{"type": typ, "age": age}}
+	}
+	docs := []*Document{
+		doc("a", "user", 20),
+		doc("b", "group", 10),
+		doc("c", "user", 30),
+		doc("d", "user", 30),
+	}
+	fq.SortDocuments(docs)
+	var ids []string
+	for _, d := range docs {
+		ids = append(ids, d.ID)
+	}
+	assert.Equal(t, []string{"b", "c", "d", "a"}, ids)
+
+	// the cursor is after c, equal sort values are ordered by id
+	fq.After = fq.Cursor(docs[1])
+	assert.False(t, fq.AfterCursor(docs[0]))
+	assert.False(t, fq.AfterCursor(docs[1]))
+	assert.True(t, fq.AfterCursor(docs[2]))
+	assert.True(t, fq.AfterCursor(docs[3]))
+}
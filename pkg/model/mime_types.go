@@ -0,0 +1,149 @@
+package model
+
+import (
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// defaultMimeTypes are the types known to provides() without
+// calling registerType(), as defined by CouchDB.
+var defaultMimeTypes = map[string][]string{
+	"all":              {"*/*"},
+	"text":             {"text/plain; charset=utf-8", "txt"},
+	"html":             {"text/html; charset=utf-8"},
+	"xhtml":            {"application/xhtml+xml", "xhtml"},
+	"xml":              {"application/xml", "text/xml", "application/x-xml"},
+	"js":               {"text/javascript", "application/javascript", "application/x-javascript"},
+	"css":              {"text/css"},
+	"ics":              {"text/calendar"},
+	"csv":              {"text/csv"},
+	"rss":              {"application/rss+xml"},
+	"atom":             {"application/atom+xml"},
+	"yaml":             {"application/x-yaml", "text/yaml"},
+	"multipart_form":   {"multipart/form-data"},
+	"url_encoded_form": {"application/x-www-form-urlencoded"},
+	"json":             {"application/json", "text/x-json"},
+}
+
+// MimeTypes maps the keys used by provides() to their mime types.
+// The first mime type of a key is used as content type of the response.
+type MimeTypes map[string][]string
+
+// NewMimeTypes returns the default mime types.
+func NewMimeTypes() MimeTypes {
+	mt := make(MimeTypes, len(defaultMimeTypes))
+	for k, v := range defaultMimeTypes {
+		mt[k] = v
+	}
+	return mt
+}
+
+// Register registers the mime types for key, replacing existing ones.
+func (mt MimeTypes) Register(key string, types ...string) {
+	mt[key] = types
+}
+
+// ContentType returns the content type used for the key.
+func (mt MimeTypes) ContentType(key string) string {
+	types := mt[key]
+	if len(types) == 0 {
+		return ""
+	}
+	return types[0]
+}
+
+// Negotiate selects the key of the provided keys used to render the
+// response. An explicit format (?format=) has to be provided, otherwise
+// the accepted types of the Accept header are checked in order of their
+// quality. The first provided key is used if accept is empty.
+func (mt MimeTypes) Negotiate(provided []string, format, accept string) (string, bool) {
+	if len(provided) == 0 {
+		return "", false
+	}
+	if format != "" {
+		for _, key := range provided {
+			if key == format {
+				return key, true
+			}
+		}
+		return "", false
+	}
+	if strings.TrimSpace(accept) == "" {
+		return provided[0], true
+	}
+
+	for _, accepted := range parseAccept(accept) {
+		for _, key := range provided {
+			for _, t := range mt[key] {
+				if mimeMatch(accepted, t) {
+					return key, true
+				}
+			}
+		}
+	}
+	return "", false
+}
+
+// parseAccept returns the media types of an Accept header ordered by quality,
+// types with a quality of zero are not acceptable and omitted.
+func parseAccept(accept string) []string {
+	type entry struct {
+		mediaType string
+		q         float64
+	}
+	var entries []entry
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		if q > 0 {
+			entries = append(entries, entry{mediaType, q})
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].q > entries[j].q
+	})
+
+	types := make([]string, len(entries))
+	for i, e := range entries {
+		types[i] = e.mediaType
+	}
+	return types
+}
+
+// mimeMatch returns true if the accepted media type (which may
+// contain wildcards) matches the mime type t.
+func mimeMatch(accepted, t string) bool {
+	mediaType, _, err := mime.ParseMediaType(t)
+	if err != nil {
+		return false
+	}
+	if accepted == "*/*" || mediaType == "*/*" || accepted == mediaType {
+		return true
+	}
+	if strings.HasSuffix(accepted, "/*") {
+		return strings.HasPrefix(mediaType, strings.TrimSuffix(accepted, "*"))
+	}
+	return false
+}
+
+// NegotiationParams returns the format query parameter and the Accept
+// header of a CouchDB request object.
+func NegotiationParams(req map[string]interface{}) (format, accept string) {
+	if query, ok := req["query"].(map[string]interface{}); ok {
+		format, _ = query["format"].(string)
+	}
+	if headers, ok := req["headers"].(map[string]interface{}); ok {
+		accept, _ = headers["Accept"].(string)
+	}
+	return format, accept
+}
@@ -0,0 +1,105 @@
+package model
+
+import "strings"
+
+// Reasons reported in the explain output for index candidates that were
+// not selected. The names follow CouchDB 3.3 where an equivalent exists.
+const (
+	ReasonFieldMismatch       = "field_mismatch"
+	ReasonSortOrderMismatch   = "sort_order_mismatch"
+	ReasonExcludedByUser      = "excluded_by_user"
+	ReasonUnfavoredType       = "unfavored_type"
+	ReasonHigherCost          = "higher_cost"
+	ReasonLessOverlap         = "less_overlap"
+	ReasonAlphabeticallyAfter = "alphabetically_comes_after"
+)
+
+// QueryIndex describes an index in the format used by _explain.
+type QueryIndex struct {
+	Ddoc        *string                `json:"ddoc"`
+	Name        string                 `json:"name"`
+	Type        string                 `json:"type"`
+	Partitioned bool                   `json:"partitioned"`
+	Def         map[string]interface{} `json:"def"`
+}
+
+// NewAllDocsQueryIndex returns the description of the special _all_docs index.
+func NewAllDocsQueryIndex() QueryIndex {
+	return QueryIndex{
+		Name: "_all_docs",
+		Type: "special",
+		Def: map[string]interface{}{
+			"fields": []interface{}{map[string]string{"_id": "asc"}},
+		},
+	}
+}
+
+// NewJSONQueryIndex returns the description of a Mango json index.
+func NewJSONQueryIndex(mi *MangoIndex) QueryIndex {
+	ddoc := mi.Ddoc
+	fields := make([]interface{}, len(mi.Fields))
+	for i, f := range mi.Fields {
+		fields[i] = map[string]string{f: "asc"}
+	}
+	return QueryIndex{
+		Ddoc: &ddoc,
+		Name: mi.Name,
+		Type: "json",
+		Def:  map[string]interface{}{"fields": fields},
+	}
+}
+
+// CandidateReason is a single reason why an index was not used.
+type CandidateReason struct {
+	Name string `json:"name"`
+}
+
+// CandidateAnalysis is the planner's verdict on a single index.
+type CandidateAnalysis struct {
+	Usable   bool              `json:"usable"`
+	Reasons  []CandidateReason `json:"reasons"`
+	Ranking  int               `json:"ranking"`
+	Covering *bool             `json:"covering"`
+}
+
+// IndexCandidate is an index that was considered by the planner.
+type IndexCandidate struct {
+	Index         QueryIndex        `json:"index"`
+	Analysis      CandidateAnalysis `json:"analysis"`
+	EstimatedRows uint64            `json:"estimated_rows"`
+	EstimatedCost float64           `json:"estimated_cost"`
+}
+
+// QueryPlan is the result of planning a FindQuery.
+//
+// Selected is nil if the query is executed as a full scan over
+// _all_docs. Values holds the equality values (in index field order)
+// used to look up the selected index.
+type QueryPlan struct {
+	Selected   *MangoIndex
+	Values     []interface{}
+	Index      IndexCandidate
+	Candidates []IndexCandidate
+	Warning    string
+}
+
+// UseIndexHint returns the design document and index name requested by
+// the use_index parameter. name is empty if only a design document was
+// given, ok is false if no hint was supplied.
+func (fq FindQuery) UseIndexHint() (ddoc, name string, ok bool) {
+	switch ui := fq.UseIndex.(type) {
+	case string:
+		ddoc = ui
+	case []interface{}:
+		if len(ui) >= 1 {
+			ddoc, _ = ui[0].(string)
+		}
+		if len(ui) >= 2 {
+			name, _ = ui[1].(string)
+		}
+	}
+	if ddoc == "" {
+		return "", "", false
+	}
+	return strings.TrimPrefix(ddoc, string(DesignDocPrefix)), name, true
+}
@@ -0,0 +1,179 @@
+package model
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+)
+
+// RewriteRule is a rule of the declarative rewrites array of a design document.
+//
+// From is matched against the path below _rewrite, segments starting with
+// a colon bind the segment to a variable and * binds the remaining path.
+// The variables (and the query parameters of the request) are substituted
+// in To and the values of Query.
+type RewriteRule struct {
+	From   string                 `json:"from"`
+	To     string                 `json:"to"`
+	Method string                 `json:"method,omitempty"`
+	Query  map[string]interface{} `json:"query,omitempty"`
+}
+
+// RewriteRules returns the declarative rewrite rules of the design document,
+// false if the design document has no rewrites array.
+func (doc *Document) RewriteRules() ([]RewriteRule, bool) {
+	if !doc.IsDesignDoc() {
+		return nil, false
+	}
+
+	raw, ok := doc.Data["rewrites"].([]interface{})
+	if !ok {
+		return nil, false
+	}
+
+	rules := make([]RewriteRule, 0, len(raw))
+	for _, r := range raw {
+		m, ok := r.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		var rule RewriteRule
+		rule.From, _ = m["from"].(string)
+		rule.To, _ = m["to"].(string)
+		rule.Method, _ = m["method"].(string)
+		rule.Query, _ = m["query"].(map[string]interface{})
+		rules = append(rules, rule)
+	}
+	return rules, true
+}
+
+// RewriteFunction returns the rewrite function of the design document,
+// false if rewrites is not a function.
+func (doc *Document) RewriteFunction() (*Function, bool) {
+	if !doc.IsDesignDoc() {
+		return nil, false
+	}
+
+	code, ok := doc.Data["rewrites"].(string)
+	if !ok {
+		return nil, false
+	}
+
+	return &Function{
+		doc:           doc,
+		Name:          "rewrites",
+		Type:          RewriteFn,
+		RewriteFnCode: code,
+	}, true
+}
+
+// Match matches the rule against the method and the path segments of the
+// request. It returns the target of the rule (relative to the design
+// document) and the query, which contains the query parameters of the
+// request and the query of the rule.
+func (rule RewriteRule) Match(method string, path []string, query url.Values) (string, url.Values, bool) {
+	if rule.Method != "" && rule.Method != "*" && !strings.EqualFold(rule.Method, method) {
+		return "", nil, false
+	}
+
+	bindings := make(map[string]string)
+	for k, v := range query {
+		if len(v) > 0 {
+			bindings[k] = v[0]
+		}
+	}
+
+	from := SplitRewritePath(rule.From)
+	var wildcard string
+	matched := false
+	for i, part := range from {
+		if part == "*" {
+			if i > len(path) {
+				return "", nil, false
+			}
+			wildcard = strings.Join(path[i:], "/")
+			matched = true
+			break
+		}
+		if i >= len(path) {
+			return "", nil, false
+		}
+		if strings.HasPrefix(part, ":") {
+			bindings[part[1:]] = path[i]
+		} else if part != path[i] {
+			return "", nil, false
+		}
+	}
+	if !matched && len(from) != len(path) {
+		return "", nil, false
+	}
+
+	to := strings.Split(rule.To, "/")
+	for i, part := range to {
+		to[i] = substituteRewriteVar(part, bindings, wildcard)
+	}
+
+	q := make(url.Values, len(query)+len(rule.Query))
+	for k, v := range query {
+		q[k] = v
+	}
+	for k, v := range rule.Query {
+		q.Set(k, rewriteQueryValue(v, bindings, wildcard))
+	}
+
+	return strings.Join(to, "/"), q, true
+}
+
+// SplitRewritePath splits a path into its segments, ignoring leading and
+// trailing slashes.
+func SplitRewritePath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return []string{}
+	}
+	return strings.Split(path, "/")
+}
+
+// substituteRewriteVar replaces a :var or * with its binding
+func substituteRewriteVar(s string, bindings map[string]string, wildcard string) string {
+	if s == "*" {
+		return wildcard
+	}
+	if strings.HasPrefix(s, ":") {
+		if v, ok := bindings[s[1:]]; ok {
+			return v
+		}
+	}
+	return s
+}
+
+// rewriteQueryValue substitutes the bindings in the query value of a rule,
+// strings are used as is, other values are encoded as JSON.
+func rewriteQueryValue(v interface{}, bindings map[string]string, wildcard string) string {
+	if s, ok := v.(string); ok {
+		return substituteRewriteVar(s, bindings, wildcard)
+	}
+	data, _ := json.Marshal(substituteRewriteValue(v, bindings, wildcard))
+	return string(data)
+}
+
+func substituteRewriteValue(v interface{}, bindings map[string]string, wildcard string) interface{} {
+	switch v := v.(type) {
+	case string:
+		return substituteRewriteVar(v, bindings, wildcard)
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, e := range v {
+			out[i] = substituteRewriteValue(e, bindings, wildcard)
+		}
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			out[k] = substituteRewriteValue(e, bindings, wildcard)
+		}
+		return out
+	default:
+		return v
+	}
+}
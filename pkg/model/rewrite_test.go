@@ -0,0 +1,105 @@
+package model
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRewriteRule_Match(t *testing.T) {
+	tests := []struct {
+		name   string
+		rule   RewriteRule
+		method string
+		path   string
+		query  url.Values
+		to     string
+		q      url.Values
+		ok     bool
+	}{
+		{
+			name: "root",
+			rule: RewriteRule{From: "", To: "index.html"},
+			path: "", to: "index.html", q: url.Values{}, ok: true,
+		},
+		{
+			name: "literal mismatch",
+			rule: RewriteRule{From: "/a", To: "b"},
+			path: "c",
+		},
+		{
+			name: "variable",
+			rule: RewriteRule{From: "/doc/:id", To: "../../:id"},
+			path: "doc/abc", to: "../../abc", q: url.Values{}, ok: true,
+		},
+		{
+			name: "variable needs segment",
+			rule: RewriteRule{From: "/doc/:id", To: "../../:id"},
+			path: "doc",
+		},
+		{
+			name: "wildcard",
+			rule: RewriteRule{From: "/static/*", To: "files/*"},
+			path: "static/css/app.css", to: "files/css/app.css", q: url.Values{}, ok: true,
+		},
+		{
+			name: "wildcard empty",
+			rule: RewriteRule{From: "*", To: "*"},
+			path: "", to: "", q: url.Values{}, ok: true,
+		},
+		{
+			name:   "method",
+			rule:   RewriteRule{From: "/a", To: "b", Method: "POST"},
+			method: "GET", path: "a",
+		},
+		{
+			name: "query",
+			rule: RewriteRule{From: "/by/:name", To: "_view/by_name", Query: map[string]interface{}{
+				"key":    []interface{}{":name", ":type"},
+				"limit":  ":limit",
+				"reduce": false,
+			}},
+			path:  "by/alice",
+			query: url.Values{"type": {"user"}, "limit": {"5"}},
+			to:    "_view/by_name",
+			q: url.Values{
+				"type":   {"user"},
+				"limit":  {"5"},
+				"key":    {`["alice","user"]`},
+				"reduce": {"false"},
+			},
+			ok: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := tt.method
+			if method == "" {
+				method = "GET"
+			}
+			to, q, ok := tt.rule.Match(method, SplitRewritePath(tt.path), tt.query)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.to, to)
+			assert.Equal(t, tt.q, q)
+		})
+	}
+}
+
+func TestDocument_RewriteRules(t *testing.T) {
+	doc := &Document{ID: "_design/app", Data: map[string]interface{}{
+		"rewrites": []interface{}{
+			map[string]interface{}{"from": "/", "to": "index.html", "method": "GET"},
+		},
+	}}
+	rules, ok := doc.RewriteRules()
+	assert.True(t, ok)
+	assert.Equal(t, []RewriteRule{{From: "/", To: "index.html", Method: "GET"}}, rules)
+	_, ok = doc.RewriteFunction()
+	assert.False(t, ok)
+
+	doc.Data["rewrites"] = "function(req) { return 'x'; }"
+	fn, ok := doc.RewriteFunction()
+	assert.True(t, ok)
+	assert.Equal(t, "function(req) { return 'x'; }", fn.RewriteFnCode)
+}
@@ -12,6 +12,7 @@ const (
 	ActionUpdateView   TaskAction = iota
 	ActionUpdateSearch
 	ActionUpdateMango
+	ActionViewCleanup
 )
 
 type Task struct {
@@ -22,9 +23,70 @@ type Task struct {
 	DesignDocFn string
 	DBName      string
 
+	CreatedAt       time.Time // time the task was queued
+	StartedAt       time.Time // time the first attempt started
 	UpdatedAt       time.Time
 	ProcessingTotal int // total number of things to process
 	Processed       int // number of things processed
+
+	// controls set by admins, paused tasks are not processed and
+	// tasks with a higher priority are processed first
+	Paused   bool
+	Priority int
+
+	// failed tasks are retried with a backoff
+	Attempts  int // number of failed attempts
+	RetryAt   time.Time
+	LastError string
+}
+
+// TaskControls are the controls of a task that are changed,
+// nil fields are unchanged
+type TaskControls struct {
+	Paused   *bool `json:"paused"`
+	Priority *int  `json:"priority"`
+}
+
+// TaskLeaseTimeout is the time a task is leased to the worker that took
+// it (ActiveSince), the lease is renewed with every progress update. If
+// the worker crashed the task is processed again after the timeout.
+const TaskLeaseTimeout = time.Minute
+
+// Runnable returns true if the task can be processed at the passed time
+func (t Task) Runnable(now time.Time) bool {
+	return !t.Paused && !t.RunnableAt().After(now)
+}
+
+// RunnableAt returns the time the task can be processed, after the
+// lease expired and the retry is due
+func (t Task) RunnableAt() time.Time {
+	at := t.RetryAt
+	if !t.ActiveSince.IsZero() {
+		if leaseEnd := t.ActiveSince.Add(TaskLeaseTimeout); leaseEnd.After(at) {
+			at = leaseEnd
+		}
+	}
+	return at
+}
+
+// Throughput returns the number of things processed per second since
+// the task started
+func (t Task) Throughput() float64 {
+	if t.StartedAt.IsZero() || !t.UpdatedAt.After(t.StartedAt) {
+		return 0
+	}
+	return float64(t.Processed) / t.UpdatedAt.Sub(t.StartedAt).Seconds()
+}
+
+// ETA returns the estimated time the task completes, based on the
+// throughput, or the zero time if there is no estimate
+func (t Task) ETA() time.Time {
+	throughput := t.Throughput()
+	if throughput == 0 || t.ProcessingTotal <= t.Processed {
+		return time.Time{}
+	}
+	remaining := float64(t.ProcessingTotal-t.Processed) / throughput
+	return t.UpdatedAt.Add(time.Duration(remaining * float64(time.Second)))
 }
 
 func (t Task) String() string {
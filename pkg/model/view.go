@@ -4,4 +4,21 @@ type View struct {
 	Language string
 	MapFn    string
 	ReduceFn string
+	// DBCopy is the name of the database the grouped
+	// reduce results of the view are copied to
+	DBCopy string
+}
+
+// DBCopyAuthor is the user that wrote the revision of a design document
+// with materialized views (dbcopy), the views are copied with the
+// permissions of the user
+type DBCopyAuthor struct {
+	Rev   string   `json:"rev"`
+	Name  string   `json:"name"`
+	Roles []string `json:"roles"`
+}
+
+// Session returns the session of the author
+func (a DBCopyAuthor) Session() *Session {
+	return &Session{Name: a.Name, Roles: a.Roles}
 }
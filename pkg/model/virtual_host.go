@@ -3,6 +3,7 @@ package model
 import (
 	"fmt"
 	"net/http"
+	"os"
 )
 
 type ProxyType string
@@ -25,8 +26,13 @@ func (c VirtualHostConfiguration) String() string {
 	return fmt.Sprintf("<Proxy type=%v proxy=%v static=%v>", c.Domains, c.Proxy, c.Static)
 }
 
+// Open opens the file of the static file system of the virtual host,
+// hosts without static files don't contain any files.
 func (c VirtualHostConfiguration) Open(name string) (http.File, error) {
-	return nil, nil
+	if c.FS == nil {
+		return nil, os.ErrNotExist
+	}
+	return c.FS.Open(name)
 }
 
 type Proxy struct {
@@ -2,11 +2,19 @@ package port
 
 import (
 	"context"
+	"errors"
 	"io"
+	"time"
 
 	"github.com/goydb/goydb/pkg/model"
 )
 
+// ErrTaskCanceled is returned if a running task was canceled by an admin
+var ErrTaskCanceled = errors.New("task canceled")
+
+// ErrTaskPaused is returned if a running task was paused by an admin
+var ErrTaskPaused = errors.New("task paused")
+
 // DatabaseTx is a handle to an open write transaction on a Database.
 // It embeds EngineWriteTransaction so callers can pass it wherever
 // EngineWriteTransaction or EngineReadTransaction is expected.
@@ -18,6 +26,14 @@ type DatabaseTx interface {
 	DeleteDocument(ctx context.Context, docID, rev string) (*model.Document, error)
 	GetLeaves(ctx context.Context, docID string) ([]*model.Document, error)
 	GetLeaf(ctx context.Context, docID, rev string) (*model.Document, error)
+
+	// IndexSeq returns the checkpoint of a building or async
+	// index, false if the index is updated with every write.
+	IndexSeq(name string) (uint64, bool)
+	SetIndexSeq(name string, seq uint64)
+	IndexBuilt(name string)
+	ChangesSince(ctx context.Context, since uint64, limit int) ([]*model.Document, uint64, error)
+	CountChangesSince(since uint64) int
 }
 
 // Database is the high-level interface for a single CouchDB-style database.
@@ -43,6 +59,8 @@ type Database interface {
 	AllDocs(ctx context.Context, q AllDocsQuery) ([]*model.Document, int, error)
 	AllDesignDocs(ctx context.Context) ([]*model.Document, int, error)
 	FindDocs(ctx context.Context, query model.FindQuery) ([]*model.Document, *model.ExecutionStats, error)
+	ExplainFind(ctx context.Context, query model.FindQuery) (*model.QueryPlan, error)
+	AggregateDocs(ctx context.Context, query model.AggregateQuery, limits model.AggregateLimits) ([]*model.AggregateRow, *model.ExecutionStats, error)
 	Changes(ctx context.Context, options *model.ChangesOptions) ([]*model.Document, int, error)
 
 	GetSecurity(ctx context.Context) (*model.Security, error)
@@ -52,16 +70,36 @@ type Database interface {
 	SetRevsLimit(ctx context.Context, limit int) error
 	AddListener(ctx context.Context, l ChangeListener) error
 	NotifyDocumentUpdate(doc *model.Document)
+	// Done is closed when the database is unloaded or deleted, the
+	// listeners aren't notified by the next loaded instance
+	Done() <-chan struct{}
 
 	GetTasks(ctx context.Context, count int) ([]*model.Task, error)
 	PeekTasks(ctx context.Context, count int) ([]*model.Task, error)
 	CompleteTasks(ctx context.Context, tasks []*model.Task) error
+	// UpdateTask stores the progress of the task, the controls set by
+	// admins are kept. It returns ErrTaskCanceled if the task was
+	// canceled and ErrTaskPaused if the task was paused.
 	UpdateTask(ctx context.Context, task *model.Task) error
+	// CancelTask removes the task, a running task stops after its
+	// current batch
+	CancelTask(ctx context.Context, id uint64) error
+	ControlTask(ctx context.Context, id uint64, controls model.TaskControls) (*model.Task, error)
+	// ScheduleTasks queues the database in the task scheduler
+	// at the time its next task can be processed
+	ScheduleTasks(ctx context.Context) error
 	TaskCount(ctx context.Context) (int, error)
 
 	EnrichDocuments(ctx context.Context, docs []*model.Document) error
 
 	Indices() map[string]DocumentIndex
+	IndexSeq(ctx context.Context, name string) (seq uint64, building bool, err error)
+	WaitIndex(ctx context.Context, name string) error
+	AsyncIndices() []string
+	GetIndexMode(ctx context.Context) (string, error)
+	SetIndexMode(ctx context.Context, mode string) error
+	PromoteDesignDoc(ctx context.Context, docID string) (rev string, err error)
+	ViewCleanup(ctx context.Context) (freed int64, err error)
 	Iterator(ctx context.Context, ddfn *model.DesignDocFn, fn func(i Iterator) error) error
 	IndexIterator(ctx context.Context, tx EngineReadTransaction, idx DocumentIndex) (Iterator, error)
 	Transaction(ctx context.Context, fn func(tx DatabaseTx) error) error
@@ -71,12 +109,49 @@ type Database interface {
 	ReducerEngine(name string) ReducerServerBuilder
 	ValidateEngine(name string) ValidateServerBuilder
 	UpdateEngine(name string) UpdateServerBuilder
+	ShowEngine(name string) ShowServerBuilder
+	ListEngine(name string) ListServerBuilder
+	RewriteEngine(name string) RewriteServerBuilder
+	// the design document engines compile the functions of a design
+	// document, modules of the design document can be required
+	ViewDesignDocEngine(name string) DesignDocBuilder[ViewServer]
+	FilterDesignDocEngine(name string) DesignDocBuilder[FilterServer]
+	ReducerDesignDocEngine(name string) DesignDocBuilder[Reducer]
+	ValidateDesignDocEngine(name string) DesignDocBuilder[ValidateServer]
+	UpdateDesignDocEngine(name string) DesignDocBuilder[UpdateServer]
+	ShowDesignDocEngine(name string) DesignDocBuilder[ShowServer]
+	ListDesignDocEngine(name string) DesignDocBuilder[ListServer]
+	RewriteDesignDocEngine(name string) DesignDocBuilder[RewriteServer]
 }
 
 // Storage is the high-level interface for the storage layer that manages
 // multiple databases. *storage.Storage satisfies this interface.
+// TaskScheduler is the ready-queue of the databases that have
+// background work for the task controller
+type TaskScheduler interface {
+	// Schedule queues the database to be processed at the time
+	Schedule(name string, at time.Time, priority int)
+	// ScheduleChanges queues the database to update its async
+	// indices and materialized views at the time
+	ScheduleChanges(name string, at time.Time)
+	// Next blocks until a queued database is due, changed is true if
+	// it was queued by ScheduleChanges. The database isn't returned
+	// again until Done is called.
+	Next(ctx context.Context) (name string, changed bool, err error)
+	Done(name string)
+}
+
 type Storage interface {
+	// Databases returns all databases, they are loaded on first access
 	Databases(ctx context.Context) ([]string, error)
+	// LoadedDatabases returns the databases that were accessed
+	LoadedDatabases(ctx context.Context) ([]string, error)
+	// QuarantinedDatabases returns the databases that failed
+	// to load with the reason
+	QuarantinedDatabases() map[string]string
+	// OpenDatabases returns the number of open database files
+	OpenDatabases() int
+	TaskScheduler() TaskScheduler
 	Database(ctx context.Context, name string) (Database, error)
 	CreateDatabase(ctx context.Context, name string) (Database, error)
 	DeleteDatabase(ctx context.Context, name string) error
@@ -58,3 +58,17 @@ type DocumentIndexSourceUpdate interface {
 	// returns the source type of the index
 	SourceType() model.FnType
 }
+
+// KeyChangeTracker is implemented by indices that can record the keys
+// of the rows that changed, e.g. to update materialized views
+// incrementally
+type KeyChangeTracker interface {
+	// TrackKeyChanges enables or disables the recording
+	TrackKeyChanges(enabled bool)
+	// ChangedKeys returns the distinct keys that changed and the records
+	// of the changes, passed to ClearChangedKeys once they are processed
+	ChangedKeys(tx EngineReadTransaction) (keys, records [][]byte)
+	// ClearChangedKeys removes the processed records, keys that changed
+	// again meanwhile have a new record
+	ClearChangedKeys(tx EngineWriteTransaction, records [][]byte)
+}
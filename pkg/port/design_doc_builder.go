@@ -0,0 +1,32 @@
+package port
+
+import "github.com/goydb/goydb/pkg/model"
+
+// DesignDocBuilder compiles a function of a design document. Engines of
+// languages with CommonJS modules are registered with a design document
+// builder, modules required by the function are resolved against the
+// design document. The design document is nil if the function isn't
+// part of one.
+type DesignDocBuilder[T any] func(fn string, ddoc *model.Document) (T, error)
+
+// IgnoreDesignDoc returns a design document builder of an engine without
+// modules, the design document isn't passed to the builder.
+func IgnoreDesignDoc[T any](builder func(fn string) (T, error)) DesignDocBuilder[T] {
+	if builder == nil {
+		return nil
+	}
+	return func(fn string, _ *model.Document) (T, error) {
+		return builder(fn)
+	}
+}
+
+// WithoutDesignDoc returns a builder of functions that aren't part
+// of a design document, modules can't be required.
+func (b DesignDocBuilder[T]) WithoutDesignDoc() func(fn string) (T, error) {
+	if b == nil {
+		return nil
+	}
+	return func(fn string) (T, error) {
+		return b(fn, nil)
+	}
+}
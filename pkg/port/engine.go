@@ -39,6 +39,8 @@ type EngineWriteTransaction interface {
 }
 
 type EngineReadTransaction interface {
+	// Buckets returns the names of all buckets
+	Buckets() [][]byte
 	BucketStats(bucket []byte) *model.IndexStats
 	Cursor(bucket []byte) EngineCursor
 	Get(bucket, key []byte) ([]byte, error)
@@ -9,6 +9,9 @@ type Iterator interface {
 	IncLimit()
 	Continue() bool
 	Remaining() int
+	// Key returns the raw key of the current entry, e.g. to
+	// continue the iteration in another transaction
+	Key() []byte
 
 	SetLimit(int)
 	SetStartKey([]byte)
@@ -0,0 +1,32 @@
+package port
+
+import "context"
+
+// ListEngines maps language names to list builders
+type ListEngines map[string]ListServerBuilder
+
+// ListServerBuilder compiles a list function into a ListServer
+type ListServerBuilder func(fn string) (ListServer, error)
+
+// ListServer executes a compiled list function
+type ListServer interface {
+	// ExecuteList runs the list function with the head of the view result
+	// and the request. Rows are fetched one by one using getRow() and every
+	// chunk passed to send() is written to out as it is produced, followed
+	// by the return value of the function.
+	// Formats registered with provides() are negotiated like for shows.
+	ExecuteList(ctx context.Context, head, req map[string]interface{}, rows ListRows, out ListWriter) error
+}
+
+// ListRows returns the next row of the view, nil if all rows were consumed.
+type ListRows func() (map[string]interface{}, error)
+
+// ListWriter receives the response of a list function.
+type ListWriter interface {
+	// Start is called exactly once with the status code (0 if not set)
+	// and headers passed to start(), before the first row is fetched or
+	// the first chunk is sent.
+	Start(code int, headers map[string]string) error
+	// Send writes a chunk of the response body.
+	Send(chunk string) error
+}
@@ -10,3 +10,9 @@ type Reducer interface {
 	Reduce(doc *model.Document)
 	Result() map[interface{}]interface{}
 }
+
+// ReducerError is implemented by reducers that can fail, Err returns
+// the first error of the reduce function.
+type ReducerError interface {
+	Err() error
+}
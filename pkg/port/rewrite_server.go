@@ -0,0 +1,33 @@
+package port
+
+import "context"
+
+// RewriteEngines maps language names to rewrite builders
+type RewriteEngines map[string]RewriteServerBuilder
+
+// RewriteServerBuilder compiles a rewrite function into a RewriteServer
+type RewriteServerBuilder func(fn string) (RewriteServer, error)
+
+// RewriteServer executes a compiled rewrite function
+type RewriteServer interface {
+	// ExecuteRewrite runs the rewrite function with the request object
+	// and returns how the request is rewritten.
+	ExecuteRewrite(ctx context.Context, req map[string]interface{}) (*RewriteResult, error)
+}
+
+// RewriteResult holds the result of a rewrite function.
+type RewriteResult struct {
+	// Path is the rewritten path relative to the database. If it is
+	// empty the request isn't rewritten but answered with Code and Body.
+	Path string
+	// Query replaces the query of the request if not nil.
+	Query map[string]interface{}
+	// Method replaces the method of the request if not empty.
+	Method string
+	// Headers are set on the rewritten request.
+	Headers map[string]string
+	// Body replaces the body of the request if not nil.
+	Body *string
+	// Code is the status code of a response without Path.
+	Code int
+}
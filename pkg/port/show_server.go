@@ -0,0 +1,38 @@
+package port
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNotAcceptable is returned if none of the formats registered with
+// provides() is acceptable for the request.
+var ErrNotAcceptable = errors.New("no acceptable format provided")
+
+// ShowEngines maps language names to show builders
+type ShowEngines map[string]ShowServerBuilder
+
+// ShowServerBuilder compiles a show function into a ShowServer
+type ShowServerBuilder func(fn string) (ShowServer, error)
+
+// ShowServer executes a compiled show function
+type ShowServer interface {
+	// ExecuteShow runs the show function with the given document and request.
+	// doc may be nil if no docid was provided or the document doesn't exist.
+	// Formats registered with provides() are negotiated using the format
+	// query parameter and the Accept header of the request.
+	ExecuteShow(ctx context.Context, doc map[string]interface{}, req map[string]interface{}) (*ShowResult, error)
+}
+
+// ShowResult holds the response of a show function execution.
+type ShowResult struct {
+	// Code is the HTTP status code (from response object), 0 if not set.
+	Code int
+	// Headers are custom HTTP headers from the response object, including
+	// the Content-Type of the format selected by provides().
+	Headers map[string]string
+	// Body is the response body as a string (mutually exclusive with JSON).
+	Body string
+	// JSON is the response body as a JSON-serializable value.
+	JSON interface{}
+}
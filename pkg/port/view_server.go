@@ -2,10 +2,15 @@ package port
 
 import (
 	"context"
+	"errors"
 
 	"github.com/goydb/goydb/pkg/model"
 )
 
+// ErrTimeout is returned if the execution of a function
+// exceeds the configured timeout.
+var ErrTimeout = errors.New("os_process_timeout exceeded")
+
 type ViewEngines map[string]ViewServerBuilder
 
 // ViewServerBuilder build a new ViewServer using the passed map function